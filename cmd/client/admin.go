@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// admin - управление учётными записями; нужна роль администратора на сервере
+func (a *app) admin(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("admin subcommand is required: users, user, disable, enable, logout, delete, quota or audit")
+	}
+	sub, args := args[0], args[1:]
+	if sub == "quota" {
+		return a.adminQuota(ctx, args)
+	}
+	if sub == "audit" {
+		return a.adminAudit(ctx, args)
+	}
+
+	if sub == "users" {
+		fs := flag.NewFlagSet("admin users", flag.ExitOnError)
+		query := fs.String("q", "", "part of the mail")
+		fs.Parse(args)
+
+		var after int64
+		for {
+			users, next, err := a.api.AdminUsers(ctx, *query, after, 0)
+			if err != nil {
+				return err
+			}
+			for _, u := range users {
+				printUser(u)
+			}
+			if next == 0 {
+				return nil
+			}
+			after = next
+		}
+	}
+
+	fs := flag.NewFlagSet("admin "+sub, flag.ExitOnError)
+	mail := fs.String("mail", "", "user mail")
+	fs.Parse(args)
+	if *mail == "" {
+		return fmt.Errorf("-mail is required")
+	}
+
+	switch sub {
+	case "user":
+		u, err := a.api.AdminUser(ctx, *mail)
+		if err != nil {
+			return err
+		}
+		printUser(u)
+	case "disable", "enable":
+		if err := a.api.AdminSetDisabled(ctx, *mail, sub == "disable"); err != nil {
+			return err
+		}
+		fmt.Printf("user %sd\n", sub)
+	case "logout":
+		revoked, err := a.api.AdminLogout(ctx, *mail)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d sessions revoked\n", revoked)
+	case "delete":
+		if err := a.api.AdminDeleteUser(ctx, *mail); err != nil {
+			return err
+		}
+		fmt.Println("user deleted")
+	default:
+		return fmt.Errorf("unknown admin subcommand %q", sub)
+	}
+	return nil
+}
+
+// printUser - адрес, ID, состояние, число записей и сессий, последнее обращение
+func printUser(u structs.UserInfo) {
+	state := "active"
+	switch {
+	case u.DisabledAt != nil:
+		state = "disabled " + u.DisabledAt.Local().Format(time.DateTime)
+	case u.Pending:
+		state = "pending"
+	}
+	lastActivity := "never"
+	if u.LastActivity != nil {
+		lastActivity = u.LastActivity.Local().Format(time.DateTime)
+	}
+	fmt.Printf("%d\t%s\t%s\trecords=%d\tsessions=%d\tlast=%s\n", u.ID, u.Username, state, u.Records, u.Sessions, lastActivity)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// audit - журнал аудита пользователя от новых событий к старым
+func (a *app) audit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	limit := fs.Int("limit", 50, "number of events to show (0 - all)")
+	fs.Parse(args)
+
+	var before int64
+	shown := 0
+	for {
+		pageSize := 0
+		if *limit != 0 {
+			pageSize = *limit - shown
+		}
+		events, next, err := a.api.AuditEvents(ctx, before, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			printAuditEvent(e)
+		}
+		shown += len(events)
+		if next == 0 || *limit != 0 && shown >= *limit {
+			return nil
+		}
+		before = next
+	}
+}
+
+// adminAudit - выгрузка журнала аудита в файл или стандартный вывод
+func (a *app) adminAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("admin audit", flag.ExitOnError)
+	format := fs.String("format", "jsonl", "export format: jsonl or csv")
+	mail := fs.String("mail", "", "export events of one user only")
+	since := fs.String("since", "", "export events since the time (RFC 3339)")
+	until := fs.String("until", "", "export events before the time (RFC 3339)")
+	out := fs.String("out", "", "output file (standard output if empty)")
+	fs.Parse(args)
+
+	var sinceTime, untilTime time.Time
+	var err error
+	if *since != "" {
+		if sinceTime, err = time.Parse(time.RFC3339, *since); err != nil {
+			return fmt.Errorf("-since: %w", err)
+		}
+	}
+	if *until != "" {
+		if untilTime, err = time.Parse(time.RFC3339, *until); err != nil {
+			return fmt.Errorf("-until: %w", err)
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	return a.api.AdminExportAudit(ctx, w, *format, *mail, sinceTime, untilTime)
+}
+
+// printAuditEvent - время, событие, результат, действие, адрес клиента и подробности
+func printAuditEvent(e structs.AuditEvent) {
+	result := "ok"
+	if !e.Success {
+		result = "failed"
+	}
+	fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", e.CreatedAt.Local().Format(time.DateTime), e.Event, result, e.Action, e.IP, e.Details)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/stepanov-ds/GophKeeper/internal/client"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// upload - шифрование файла и загрузка его частями; прерванная загрузка продолжается повторным запуском
+func (a *app) upload(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	file := fs.String("file", "", "file to upload")
+	chunk := fs.Int64("chunk", 8<<20, "bytes per request")
+	fs.Parse(args)
+	if *id == 0 || *file == "" {
+		return fmt.Errorf("-id and -file are required")
+	}
+	if *chunk <= 0 {
+		return fmt.Errorf("-chunk must be positive")
+	}
+	source, err := filepath.Abs(*file)
+	if err != nil {
+		return err
+	}
+
+	upload, found, err := client.LoadUpload(a.dir, *id)
+	if err != nil {
+		return err
+	}
+	if found && upload.Source != source {
+		fmt.Fprintf(os.Stderr, "dropping unfinished upload of %s\n", upload.Source)
+		if err := client.RemoveUpload(a.dir, *id, upload); err != nil {
+			return err
+		}
+		found = false
+	}
+	if !found {
+		upload, err = a.prepareUpload(ctx, *id, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	blob, err := a.api.Blob(ctx, upload.BlobID)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := os.Open(upload.Encrypted)
+	if err != nil {
+		return fmt.Errorf("error while opening encrypted copy: %w", err)
+	}
+	defer encrypted.Close()
+
+	for blob.Received < blob.Size {
+		size := min(*chunk, blob.Size-blob.Received)
+		section := io.NewSectionReader(encrypted, blob.Received, size)
+		blob, err = a.api.UploadBlob(ctx, blob.ID, blob.Received, section, size)
+		if err != nil {
+			return fmt.Errorf("%w (run upload again to resume)", err)
+		}
+		fmt.Fprintf(os.Stderr, "\ruploaded %d of %d bytes", blob.Received, blob.Size)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	blob, err = a.api.CompleteBlob(ctx, blob.ID)
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveUpload(a.dir, *id, upload); err != nil {
+		return err
+	}
+	fmt.Printf("upload complete: blobID=%d historyID=%d\n", blob.ID, blob.HistoryID)
+	return nil
+}
+
+// prepareUpload - зашифрованная копия файла, её хэш и новая загрузка на сервере
+func (a *app) prepareUpload(ctx context.Context, id int64, source string) (client.Upload, error) {
+	keys, err := a.blobKeys(ctx, id)
+	if err != nil {
+		return client.Upload{}, err
+	}
+	key := keys[0]
+
+	in, err := os.Open(source)
+	if err != nil {
+		return client.Upload{}, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(client.UploadsDir(a.dir), 0o700); err != nil {
+		return client.Upload{}, err
+	}
+	out, err := os.CreateTemp(client.UploadsDir(a.dir), fmt.Sprintf("%d.*.enc", id))
+	if err != nil {
+		return client.Upload{}, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := vault.EncryptStream(key, io.MultiWriter(out, hash, counter), in); err != nil {
+		os.Remove(out.Name())
+		return client.Upload{}, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return client.Upload{}, err
+	}
+
+	upload := client.Upload{
+		Source:    source,
+		Encrypted: out.Name(),
+		Size:      counter.n,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+	}
+	blob, err := a.api.CreateBlob(ctx, id, upload.Size, upload.SHA256)
+	if err != nil {
+		os.Remove(out.Name())
+		return client.Upload{}, err
+	}
+	upload.BlobID = blob.ID
+
+	return upload, client.SaveUpload(a.dir, id, upload)
+}
+
+// download - докачка зашифрованного содержимого во временный файл, проверка хэша и расшифровка
+func (a *app) download(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID (blob is taken from synced state)")
+	blobID := fs.Int64("blob", 0, "blob ID (overrides -id)")
+	output := fs.String("out", "", "output file")
+	fs.Parse(args)
+	if *output == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	if *blobID == 0 {
+		state, err := client.LoadState(a.dir)
+		if err != nil {
+			return err
+		}
+		record, found := state.Records[*id]
+		if !found || record.BlobID == 0 {
+			return fmt.Errorf("record %d has no binary content, run sync first", *id)
+		}
+		*blobID = record.BlobID
+	}
+
+	blob, err := a.api.Blob(ctx, *blobID)
+	if err != nil {
+		return err
+	}
+
+	partial := *output + ".part"
+	part, err := os.OpenFile(partial, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	offset, err := part.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > blob.Size {
+		if err := part.Truncate(0); err != nil {
+			return err
+		}
+		offset, _ = part.Seek(0, io.SeekStart)
+	}
+
+	if offset < blob.Size {
+		body, err := a.api.DownloadBlob(ctx, blob.ID, offset)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(part, body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("download interrupted at %d of %d bytes (run download again to resume): %w", offset+n, blob.Size, err)
+		}
+	}
+
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, part); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != blob.SHA256 {
+		part.Close()
+		os.Remove(partial)
+		return fmt.Errorf("sha256 mismatch, partial download removed")
+	}
+
+	keys, err := a.blobKeys(ctx, blob.SecureDataID)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err = a.decryptBlob(key, part, *output); err == nil || i == len(keys)-1 {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	part.Close()
+	os.Remove(partial)
+
+	fmt.Printf("saved %s\n", *output)
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// decryptBlob - расшифровка скачанного содержимого part ключом key в файл output
+func (a *app) decryptBlob(key []byte, part *os.File, output string) error {
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := vault.DecryptStream(key, out, part); err != nil {
+		out.Close()
+		os.Remove(output)
+		return err
+	}
+	return out.Close()
+}
+
+// blobKeys - ключи файлов записи id по локальной копии, первый - для новых загрузок. Файлы общей
+// записи шифруются ключом записи, чтобы их могли расшифровать получатели; файлы, загруженные
+// до открытия доступа, владелец расшифровывает ключом хранилища
+func (a *app) blobKeys(ctx context.Context, id int64) ([][]byte, error) {
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	d := state.Records[id]
+	if _, err := a.vaultKey(ctx); err != nil {
+		return nil, err
+	}
+	recordKey, _, found, err := a.recordKey(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	if found {
+		keys = append(keys, recordKey)
+	}
+	if d.Share == nil {
+		key, err := a.dataKey(ctx, d.VaultID)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("shared record is not encrypted with a record key")
+	}
+	return keys, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// history - список ревизий записи или расшифрованное содержимое одной ревизии
+func (a *app) history(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	revision := fs.Int64("revision", 0, "show the content of this revision")
+	fs.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	if *revision == 0 {
+		revisions, err := a.api.Revisions(ctx, *id)
+		if err != nil {
+			return err
+		}
+		for _, r := range revisions {
+			fmt.Printf("%d\t%s\t%s\t%s\tactive=%t\n", r.HistoryID, r.CreatedAt.Local().Format(time.DateTime), r.Method, r.Kind, r.IsActive)
+		}
+		return nil
+	}
+
+	r, err := a.api.Revision(ctx, *id, *revision)
+	if err != nil {
+		return err
+	}
+	key, err := a.recordDataKey(ctx, *id)
+	if err != nil {
+		return err
+	}
+	plaintext, err := vault.Decrypt(key, *id, r.Kind, r.Data)
+	if err != nil {
+		return fmt.Errorf("error while decrypting revision: %w", err)
+	}
+	fmt.Printf("%d\t%s\t%s\t%s\n", r.HistoryID, r.Kind, r.Metadata, plaintext)
+	return nil
+}
+
+// restore - восстановление содержимого записи из ревизии
+func (a *app) restore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	revision := fs.Int64("revision", 0, "revision to restore")
+	force := fs.Bool("force", false, "restore even if the record was changed on another device")
+	fs.Parse(args)
+	if *id == 0 || *revision == 0 {
+		return fmt.Errorf("-id and -revision are required")
+	}
+
+	historyID, err := a.knownHistoryID(*id, *force)
+	if err != nil {
+		return err
+	}
+	resp, err := a.api.Restore(ctx, *id, historyID, *revision)
+	if err != nil {
+		return a.conflict(err)
+	}
+	fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+	return nil
+}
+
+// retention - просмотр и изменение числа хранимых ревизий
+func (a *app) retention(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	set := fs.Int("set", -1, "revisions to keep per record (0 - server default)")
+	fs.Parse(args)
+
+	if *set >= 0 {
+		if err := a.api.SetHistoryRetention(ctx, *set); err != nil {
+			return err
+		}
+	}
+	r, err := a.api.HistoryRetention(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.Limit > 0:
+		fmt.Printf("keeping %d revisions per record\n", r.Limit)
+	case r.Default > 0:
+		fmt.Printf("keeping %d revisions per record (server default)\n", r.Default)
+	default:
+		fmt.Println("keeping all revisions (server default)")
+	}
+	return nil
+}
@@ -0,0 +1,640 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/stepanov-ds/GophKeeper/internal/client"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const usage = `Usage: client [-profile name] [-server address] [TLS flags] <command> [flags]
+
+TLS flags (saved in profile):
+  -ca <file>          CA certificate of the server
+  -cert <file> -key <file>
+                      client certificate for mutual TLS
+  -insecure           skip server certificate verification (development only)
+
+Commands:
+  register -mail <mail>                        register a new user
+  verify   [-mail <mail>] -code <code>         confirm the registration with the code from the mail
+  login    -mail <mail> [-totp <code>]         request a code by mail and log in
+  logout                                       end the session on the server and forget tokens
+  sessions [-revoke <id>]                      list active sessions or revoke one
+  totp     [enroll | confirm -code <code> | disable -code <code> | backup-codes -code <code>]
+                                               show or manage two-factor authentication
+  add      -data <data> [-kind <kind>] [-metadata <json>] [-validate <json>] [-vault <id>]
+                                               add a record (to the default personal vault unless -vault)
+  update   -id <id> -data <data> [-kind <kind>] [-metadata <json>] [-validate <json>] [-force]
+                                               update a record
+  delete   -id <id> [-force]                   delete a record
+  sync     [-limit <n>] [-full]                pull changes from the server
+  watch                                        sync whenever records change on the server (until Ctrl+C)
+  list                                         list synced records
+  search   [-metadata <json>] [-tags <a,b>] [-kind <kind>] [-state <state>] [-sort <field>] [-desc]
+                                               find records on the server by metadata
+  history  -id <id> [-revision <id>]           list revisions of a record or show one
+  restore  -id <id> -revision <id> [-force]    restore a record from a revision
+  retention [-set <n>]                         show or change revisions kept per record
+  usage                                        show record count and size against your quotas
+  audit    [-limit <n>]                        show recent logins and changes of your account (0 - all)
+  upload   -id <id> -file <path> [-chunk <n>]  encrypt and upload binary content of a record
+  download -id <id> -out <path> [-blob <id>]   download and decrypt binary content
+  share    -id <id> -to <mail> [-rw]           share a record with another user (read-only unless -rw)
+  shares   -id <id>                            list users a record is shared with
+  unshare  -id <id> -user <mail>               revoke access to a shared record
+  orgs     [-create <name>]                    list your organizations or create one
+  members  -org <id> [-add <mail> [-role <role>] | -remove <mail>]
+                                               list, add or remove members of an organization
+  vaults   [-create <name> [-org <id>]]        list vaults or create a personal or team vault
+  passwd                                       change the master password
+  admin    users [-q <text>] | user -mail <mail> | disable -mail <mail> | enable -mail <mail> |
+           logout -mail <mail> | delete -mail <mail> |
+           quota -mail <mail> [-records <n>] [-bytes <n>] [-record-size <n>] [-revisions <n>] [-reset] |
+           audit [-format jsonl|csv] [-mail <mail>] [-since <time>] [-until <time>] [-out <path>]
+                                               manage accounts (server -admins only)
+
+Record data is encrypted with a key derived from the master password, which is
+read from GOPHKEEPER_MASTER_PASSWORD or asked interactively.
+
+Kinds: credentials, text (default), binary, card, otp. -validate passes data
+that the server checks without storing it, e.g. '{"number":"4111 1111 1111 1111",
+"expiry":"12/30"}' for a card or '{"secret":"JBSWY3DPEHPK3PXP"}' for an OTP seed.
+
+With two-factor authentication enabled, login asks for a code from the
+authenticator app after the mail code. Each of the backup codes shown on
+confirm works once in place of an authenticator code.
+
+Records shared with you are synced together with your own and show the owner
+in list. With -rw you can update them; only the owner can delete a record.
+
+Records of team vaults are encrypted with the organization key, which every
+member receives encrypted with their public key. Roles: owner, admin, member
+(read and write) and read-only. Owners manage everyone, admins everyone but
+owners; -remove with your own mail leaves the organization.
+
+update and delete send the version of the record from the last sync. If the
+record was changed on another device since then, the server refuses the change
+and the local copy is replaced with the current one; -force skips the check.
+`
+
+// app - состояние запуска: выбранный профиль, клиент API и ключ разблокированного хранилища
+type app struct {
+	dir     string
+	profile client.Profile
+	api     *client.Client
+	key     []byte
+	// keys - ключи пользователя, полученные при разблокировке хранилища
+	keys structs.UserKeys
+	// vaultsByID - именованные хранилища с ключами организаций, загружаются по необходимости
+	vaultsByID map[int64]vaultInfo
+}
+
+func main() {
+	global := flag.NewFlagSet("client", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	profileName := global.String("profile", envOr("GOPHKEEPER_PROFILE", "default"), "profile name")
+	server := global.String("server", os.Getenv("GOPHKEEPER_SERVER"), "server address (saved in profile)")
+	caFile := global.String("ca", "", "server CA certificate")
+	certFile := global.String("cert", "", "client certificate")
+	keyFile := global.String("key", "", "client key")
+	insecure := global.Bool("insecure", false, "skip server certificate verification")
+	global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	dir, err := client.ProfileDir(*profileName)
+	if err != nil {
+		fail(err)
+	}
+	profile, err := client.LoadProfile(dir)
+	if err != nil {
+		fail(err)
+	}
+	if *server != "" {
+		profile.Server = *server
+	}
+	if profile.Server == "" {
+		profile.Server = "localhost:8085"
+	}
+	if *caFile != "" {
+		profile.CAFile = *caFile
+	}
+	if *certFile != "" {
+		profile.CertFile, profile.KeyFile = *certFile, *keyFile
+	}
+	if *insecure {
+		profile.Insecure = true
+	}
+
+	tlsConfig, err := client.TLSConfig(profile.CAFile, profile.CertFile, profile.KeyFile, profile.Insecure)
+	if err != nil {
+		fail(err)
+	}
+
+	a := &app{
+		dir:     dir,
+		profile: profile,
+		api:     client.New(profile.Server, profile.Token, tlsConfig),
+	}
+	a.api.UseRefreshToken(profile.RefreshToken, a.saveTokens)
+
+	cmd, args := global.Arg(0), global.Args()[1:]
+	ctx := context.Background()
+
+	switch cmd {
+	case "register":
+		err = a.register(ctx, args)
+	case "verify":
+		err = a.verify(ctx, args)
+	case "login":
+		err = a.login(ctx, args)
+	case "logout":
+		err = a.logout(ctx)
+	case "sessions":
+		err = a.sessions(ctx, args)
+	case "totp":
+		err = a.totp(ctx, args)
+	case "add":
+		err = a.add(ctx, args)
+	case "update":
+		err = a.update(ctx, args)
+	case "delete":
+		err = a.delete(ctx, args)
+	case "sync":
+		err = a.sync(ctx, args)
+	case "watch":
+		err = a.watch(ctx)
+	case "list":
+		err = a.list(ctx)
+	case "search":
+		err = a.search(ctx, args)
+	case "history":
+		err = a.history(ctx, args)
+	case "restore":
+		err = a.restore(ctx, args)
+	case "retention":
+		err = a.retention(ctx, args)
+	case "usage":
+		err = a.usage(ctx)
+	case "audit":
+		err = a.audit(ctx, args)
+	case "upload":
+		err = a.upload(ctx, args)
+	case "download":
+		err = a.download(ctx, args)
+	case "share":
+		err = a.share(ctx, args)
+	case "shares":
+		err = a.shares(ctx, args)
+	case "unshare":
+		err = a.unshare(ctx, args)
+	case "orgs":
+		err = a.orgs(ctx, args)
+	case "members":
+		err = a.members(ctx, args)
+	case "vaults":
+		err = a.vaults(ctx, args)
+	case "admin":
+		err = a.admin(ctx, args)
+	case "passwd":
+		err = a.passwd(ctx)
+	default:
+		global.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func (a *app) register(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("register", flag.ExitOnError)
+	mail := fs.String("mail", a.profile.Login, "mail")
+	fs.Parse(args)
+	if *mail == "" {
+		return fmt.Errorf("-mail is required")
+	}
+
+	pending, err := a.api.Register(ctx, *mail)
+	if err != nil {
+		return err
+	}
+	a.profile.Login = *mail
+	if err := client.SaveProfile(a.dir, a.profile); err != nil {
+		return err
+	}
+	if !pending {
+		fmt.Println("registration success")
+		return nil
+	}
+
+	fmt.Printf("verification code sent to %s\n", *mail)
+	code, err := readLine(bufio.NewReader(os.Stdin), "enter code (or run verify -code <code> later): ")
+	if err != nil || code == "" {
+		return err
+	}
+	return a.confirm(ctx, *mail, code)
+}
+
+// verify - подтверждение регистрации кодом из письма
+func (a *app) verify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	mail := fs.String("mail", a.profile.Login, "mail")
+	code := fs.String("code", "", "code from the mail")
+	fs.Parse(args)
+	if *mail == "" || *code == "" {
+		return fmt.Errorf("-mail and -code are required")
+	}
+	return a.confirm(ctx, *mail, *code)
+}
+
+func (a *app) confirm(ctx context.Context, mail string, code string) error {
+	if err := a.api.Verify(ctx, mail, code); err != nil {
+		return err
+	}
+	fmt.Println("registration confirmed, now run login")
+	return nil
+}
+
+func (a *app) login(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	mail := fs.String("mail", a.profile.Login, "mail")
+	code := fs.String("code", "", "code from the mail (asked interactively if empty)")
+	totp := fs.String("totp", "", "authenticator or backup code (asked interactively if required)")
+	fs.Parse(args)
+	if *mail == "" {
+		return fmt.Errorf("-mail is required")
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *code == "" {
+		if err := a.api.RequestChallenge(ctx, *mail); err != nil {
+			return err
+		}
+		fmt.Printf("code sent to %s\n", *mail)
+		line, err := readLine(stdin, "enter code: ")
+		if err != nil {
+			return err
+		}
+		*code = line
+	}
+
+	err := a.api.Login(ctx, *mail, *code, *totp)
+	if errors.Is(err, client.ErrTOTPRequired) {
+		line, readErr := readLine(stdin, "enter authenticator or backup code: ")
+		if readErr != nil {
+			return readErr
+		}
+		err = a.api.Login(ctx, *mail, *code, line)
+	}
+	if err != nil {
+		return err
+	}
+	a.profile.Login = *mail
+	if err := a.saveTokens(a.api.Token(), a.api.RefreshToken()); err != nil {
+		return err
+	}
+	fmt.Println("authorized")
+	return nil
+}
+
+// readLine - строка, введённая пользователем после приглашения prompt
+func readLine(r *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error while reading code: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// logout - отзыв сессии на сервере; локальные токены удаляются, даже если сервер недоступен
+func (a *app) logout(ctx context.Context) error {
+	var err error
+	if a.profile.Token != "" || a.profile.RefreshToken != "" {
+		err = a.api.Logout(ctx)
+	}
+	if saveErr := a.saveTokens("", ""); saveErr != nil {
+		return saveErr
+	}
+	if err != nil {
+		return fmt.Errorf("logged out locally, server session may still be active: %w", err)
+	}
+	fmt.Println("logged out")
+	return nil
+}
+
+// saveTokens - сохранение токенов сессии в профиле
+func (a *app) saveTokens(token string, refreshToken string) error {
+	a.profile.Token = token
+	a.profile.RefreshToken = refreshToken
+	return client.SaveProfile(a.dir, a.profile)
+}
+
+func (a *app) add(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	data := fs.String("data", "", "secret data")
+	kind := fs.String("kind", "", "record kind")
+	metadata := fs.String("metadata", "{}", "metadata JSON")
+	validate := fs.String("validate", "", "JSON for server-side validation (not stored)")
+	vaultID := fs.Int64("vault", 0, "vault ID (0 - default personal vault)")
+	fs.Parse(args)
+
+	// данные привязываются к ID записи, который назначает сервер: запись добавляется с пустой
+	// заготовкой и сразу заполняется
+	record, err := a.record(ctx, 0, *vaultID, *kind, "", *metadata, *validate)
+	if err != nil {
+		return err
+	}
+	resp, err := a.api.Add(ctx, record)
+	if err != nil {
+		return err
+	}
+	record.Data, err = a.encrypt(ctx, resp.SecureDataID, *vaultID, *kind, []byte(*data))
+	if err != nil {
+		return err
+	}
+	updated, err := a.api.Update(ctx, resp.SecureDataID, resp.HistoryID, record)
+	if err != nil {
+		if _, deleteErr := a.api.Delete(ctx, resp.SecureDataID, resp.HistoryID); deleteErr != nil {
+			return fmt.Errorf("%w (empty record %d is left: %v)", err, resp.SecureDataID, deleteErr)
+		}
+		return err
+	}
+	fmt.Printf("%s: ID=%d historyID=%d\n", resp.Message, resp.SecureDataID, updated.HistoryID)
+	return nil
+}
+
+func (a *app) update(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	data := fs.String("data", "", "secret data")
+	kind := fs.String("kind", "", "record kind (unchanged if empty)")
+	metadata := fs.String("metadata", "{}", "metadata JSON")
+	validate := fs.String("validate", "", "JSON for server-side validation (not stored)")
+	force := fs.Bool("force", false, "overwrite even if the record was changed on another device")
+	fs.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	record, err := a.record(ctx, *id, 0, *kind, *data, *metadata, *validate)
+	if err != nil {
+		return err
+	}
+	historyID, err := a.knownHistoryID(*id, *force)
+	if err != nil {
+		return err
+	}
+	resp, err := a.api.Update(ctx, *id, historyID, record)
+	if err != nil {
+		return a.conflict(err)
+	}
+	fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+	return nil
+}
+
+func (a *app) delete(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	force := fs.Bool("force", false, "delete even if the record was changed on another device")
+	fs.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	historyID, err := a.knownHistoryID(*id, *force)
+	if err != nil {
+		return err
+	}
+	resp, err := a.api.Delete(ctx, *id, historyID)
+	if err != nil {
+		return a.conflict(err)
+	}
+	fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+	return nil
+}
+
+// knownHistoryID - версия записи из локального состояния; 0 (без проверки), если запись не синхронизирована или force
+func (a *app) knownHistoryID(id int64, force bool) (int64, error) {
+	if force {
+		return 0, nil
+	}
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return 0, err
+	}
+	return state.Records[id].HistoryID, nil
+}
+
+// conflict - при конфликте версий текущая запись с сервера сохраняется в локальное состояние
+func (a *app) conflict(err error) error {
+	var conflict *client.ConflictError
+	if !errors.As(err, &conflict) {
+		return err
+	}
+	state, stateErr := client.LoadState(a.dir)
+	if stateErr != nil {
+		return stateErr
+	}
+	state.Records[conflict.Current.ID] = conflict.Current
+	if stateErr := client.SaveState(a.dir, state); stateErr != nil {
+		return stateErr
+	}
+	return fmt.Errorf("%w; local copy updated, review it and retry (or use -force)", err)
+}
+
+func (a *app) sync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	limit := fs.Int("limit", 100, "page size")
+	full := fs.Bool("full", false, "drop local state and sync from scratch")
+	fs.Parse(args)
+	if *limit <= 0 {
+		return fmt.Errorf("-limit must be positive")
+	}
+
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return err
+	}
+	// без курсора (новое устройство, -full или состояние старой версии) локальная копия
+	// заменяется снимком, после которого синхронизация продолжается изменениями
+	if *full || state.Cursor == "" {
+		state.Cursor = ""
+		state.LastHistoryID = 0
+		clear(state.Records)
+	}
+
+	received := 0
+	for {
+		page, err := a.api.SyncV2(ctx, structs.SyncRequest{
+			Cursor:   state.Cursor,
+			Snapshot: state.Cursor == "",
+			Limit:    *limit,
+		})
+		if err != nil {
+			return err
+		}
+		state.ApplyPage(page)
+		received += len(page.Records) + len(page.Tombstones)
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if err := client.SaveState(a.dir, state); err != nil {
+		return err
+	}
+	fmt.Printf("synced %d changes, lastHistoryID=%d\n", received, state.LastHistoryID)
+	return nil
+}
+
+// watch - синхронизация после каждого изменения на сервере до прерывания
+func (a *app) watch(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return err
+	}
+	err = a.api.Watch(ctx, state.LastHistoryID, func(historyID int64) error {
+		return a.sync(ctx, nil)
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// list - вывод записей; локальная копия хранит шифротекст, расшифровка только при выводе
+func (a *app) list(ctx context.Context) error {
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return err
+	}
+	records := state.Active()
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := a.unlockRecords(ctx, records); err != nil {
+		return err
+	}
+	a.printRecords(records)
+	return nil
+}
+
+// search - поиск на сервере по метаданным без загрузки всего хранилища
+func (a *app) search(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	metadata := fs.String("metadata", "", "JSON object the metadata must contain")
+	tags := fs.String("tags", "", "comma-separated tags that all must be in metadata \"tags\"")
+	kind := fs.String("kind", "", "record kind")
+	state := fs.String("state", "", "active (default), deleted or all")
+	sort := fs.String("sort", "", "id (default), historyID or metadata.<key>")
+	desc := fs.Bool("desc", false, "descending order")
+	limit := fs.Int("limit", 0, "page size")
+	fs.Parse(args)
+
+	query := structs.SearchQuery{
+		Kind:  *kind,
+		State: *state,
+		Sort:  *sort,
+		Limit: *limit,
+	}
+	if *metadata != "" {
+		if !json.Valid([]byte(*metadata)) {
+			return fmt.Errorf("-metadata must be valid JSON")
+		}
+		query.Metadata = json.RawMessage(*metadata)
+	}
+	if *tags != "" {
+		query.Tags = strings.Split(*tags, ",")
+	}
+	if *desc {
+		query.Order = "desc"
+	}
+
+	for {
+		resp, err := a.api.Search(ctx, query)
+		if err != nil {
+			return err
+		}
+		if len(resp.SecureData) != 0 {
+			if err := a.unlockRecords(ctx, resp.SecureData); err != nil {
+				return err
+			}
+		}
+		a.printRecords(resp.SecureData)
+		if resp.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = resp.NextCursor
+	}
+}
+
+// printRecords - вывод записей с расшифрованными данными; у записей именованных хранилищ - хранилище,
+// у чужих записей - владелец и право доступа. Хранилище должно быть разблокировано (unlockRecords)
+func (a *app) printRecords(records []structs.SecureData) {
+	for _, d := range records {
+		kind := d.Kind
+		if v, found := a.vaultsByID[d.VaultID]; found {
+			kind += " [" + v.name + "]"
+		}
+		if d.Share != nil {
+			kind += " (" + d.Share.Permission + " from " + d.Share.Owner + ")"
+		}
+		plaintext, err := a.decrypt(d)
+		if err != nil {
+			fmt.Printf("%d\t%s\t%s\t<decryption error: %v>\n", d.ID, kind, d.Metadata, err)
+			continue
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\n", d.ID, kind, d.Metadata, plaintext)
+	}
+}
+
+// record - запись для отправки на сервер с зашифрованными данными; id - изменяемая запись (0 для новой),
+// vaultID - хранилище новой записи
+func (a *app) record(ctx context.Context, id int64, vaultID int64, kind string, data string, metadata string, validate string) (client.Record, error) {
+	record := client.Record{Kind: kind, VaultID: vaultID}
+
+	if !json.Valid([]byte(metadata)) {
+		return record, fmt.Errorf("-metadata must be valid JSON")
+	}
+	record.Metadata = json.RawMessage(metadata)
+	if validate != "" {
+		if !json.Valid([]byte(validate)) {
+			return record, fmt.Errorf("-validate must be valid JSON")
+		}
+		record.Validate = json.RawMessage(validate)
+	}
+
+	var err error
+	record.Data, err = a.encrypt(ctx, id, vaultID, kind, []byte(data))
+	return record, err
+}
+
+func envOr(key string, def string) string {
+	if v, found := os.LookupEnv(key); found {
+		return v
+	}
+	return def
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// usage - объём записей пользователя и его квоты
+func (a *app) usage(ctx context.Context) error {
+	usage, err := a.api.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	printUsage(usage)
+	return nil
+}
+
+// adminQuota - квоты пользователя: изменение или возврат к значениям по умолчанию
+func (a *app) adminQuota(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("admin quota", flag.ExitOnError)
+	mail := fs.String("mail", "", "user mail")
+	records := fs.Int64("records", 0, "max active records (0 - unlimited)")
+	bytes := fs.Int64("bytes", 0, "max total size of active records in bytes (0 - unlimited)")
+	recordSize := fs.Int64("record-size", 0, "max size of one record in bytes (0 - unlimited)")
+	revisions := fs.Int("revisions", 0, "max revisions kept per record (0 - unlimited)")
+	reset := fs.Bool("reset", false, "return to the server defaults")
+	fs.Parse(args)
+	if *mail == "" {
+		return fmt.Errorf("-mail is required")
+	}
+
+	var quota *structs.Quota
+	if !*reset {
+		quota = &structs.Quota{Records: *records, Bytes: *bytes, RecordSize: *recordSize, Revisions: *revisions}
+	}
+	usage, err := a.api.AdminSetQuota(ctx, *mail, quota)
+	if err != nil {
+		return err
+	}
+	printUsage(usage)
+	return nil
+}
+
+func printUsage(u structs.Usage) {
+	limit := func(v int64) string {
+		if v == 0 {
+			return "unlimited"
+		}
+		return fmt.Sprint(v)
+	}
+	source := "server defaults"
+	if u.Custom {
+		source = "set by administrator"
+	}
+	fmt.Printf("records:     %d of %s\n", u.Records, limit(u.Quota.Records))
+	fmt.Printf("bytes:       %d of %s\n", u.Bytes, limit(u.Quota.Bytes))
+	fmt.Printf("record size: up to %s bytes\n", limit(u.Quota.RecordSize))
+	fmt.Printf("revisions:   up to %s per record\n", limit(int64(u.Quota.Revisions)))
+	fmt.Printf("quotas:      %s\n", source)
+}
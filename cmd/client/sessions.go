@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// sessions - список активных сессий или отзыв одной из них
+func (a *app) sessions(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	revoke := fs.String("revoke", "", "session ID to revoke")
+	fs.Parse(args)
+
+	if *revoke != "" {
+		if err := a.api.RevokeSession(ctx, *revoke); err != nil {
+			return err
+		}
+		fmt.Println("session revoked")
+		return nil
+	}
+
+	sessions, err := a.api.Sessions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		current := ""
+		if s.Current {
+			current = "\t(current)"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s%s\n", s.ID, s.LastSeen.Local().Format(time.DateTime), s.IP, s.UserAgent, current)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/client"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// share - открытие доступа к записи. Запись, зашифрованная ключом хранилища, сначала перешифровывается
+// собственным ключом записи, который затем шифруется открытым ключом получателя
+func (a *app) share(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("share", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	to := fs.String("to", "", "recipient mail")
+	rw := fs.Bool("rw", false, "allow the recipient to update the record")
+	fs.Parse(args)
+	if *id == 0 || *to == "" {
+		return fmt.Errorf("-id and -to are required")
+	}
+
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return err
+	}
+	d, found := state.Records[*id]
+	switch {
+	case !found || !d.IsActive:
+		return fmt.Errorf("record %d is not synced, run sync first", *id)
+	case d.Share != nil:
+		return fmt.Errorf("record %d is shared with you by %s, only the owner can share it", *id, d.Share.Owner)
+	case d.BlobID != 0:
+		return fmt.Errorf("records with binary content cannot be shared")
+	case d.VaultID != 0:
+		if err := a.loadVaults(ctx); err != nil {
+			return err
+		}
+		if a.vaultsByID[d.VaultID].key != nil {
+			return fmt.Errorf("records of team vaults are shared through organization membership")
+		}
+	}
+
+	if _, err := a.vaultKey(ctx); err != nil {
+		return err
+	}
+	publicKey, err := a.api.PublicKey(ctx, *to)
+	if err != nil {
+		return err
+	}
+
+	recordKey, _, found, err := a.recordKey(d)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if recordKey, err = a.useRecordKey(ctx, &d); err != nil {
+			return err
+		}
+		state.Records[d.ID] = d
+		if err := client.SaveState(a.dir, state); err != nil {
+			return err
+		}
+	}
+
+	sealed, err := vault.SealKey(publicKey, recordKey)
+	if err != nil {
+		return err
+	}
+	permission := structs.PermissionRead
+	if *rw {
+		permission = structs.PermissionWrite
+	}
+	resp, err := a.api.Share(ctx, *id, structs.ShareRequest{
+		Recipient:  *to,
+		Permission: permission,
+		WrappedKey: sealed,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+	return nil
+}
+
+// useRecordKey - перешифровка записи новым ключом записи; d обновляется до новой версии
+func (a *app) useRecordKey(ctx context.Context, d *structs.SecureData) ([]byte, error) {
+	plaintext, err := vault.Decrypt(a.key, d.ID, d.Kind, d.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error while decrypting record: %w", err)
+	}
+	recordKey, err := vault.NewVaultKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := vault.WrapRecordKey(a.key, recordKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := vault.EncryptRecord(recordKey, wrapped, d.ID, d.Kind, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.api.Update(ctx, d.ID, d.HistoryID, client.Record{
+		Kind:     d.Kind,
+		Data:     data,
+		Metadata: json.RawMessage(d.Metadata),
+	})
+	if err != nil {
+		return nil, a.conflict(err)
+	}
+	d.Data, d.HistoryID = data, resp.HistoryID
+	return recordKey, nil
+}
+
+func (a *app) shares(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("shares", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	fs.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	shares, err := a.api.Shares(ctx, *id)
+	if err != nil {
+		return err
+	}
+	for _, s := range shares {
+		revoked := ""
+		if s.RevokedAt != nil {
+			revoked = "revoked " + s.RevokedAt.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", s.Recipient, s.Permission, s.CreatedAt.Local().Format(time.DateTime), revoked)
+	}
+	return nil
+}
+
+func (a *app) unshare(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("unshare", flag.ExitOnError)
+	id := fs.Int64("id", 0, "record ID")
+	user := fs.String("user", "", "recipient mail")
+	fs.Parse(args)
+	if *id == 0 || *user == "" {
+		return fmt.Errorf("-id and -user are required")
+	}
+
+	resp, err := a.api.RevokeShare(ctx, *id, *user)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+	return nil
+}
+
+// recordKey - ключ записи d и его копия, обёрнутая ключом хранилища владельца; found=false, если запись
+// зашифрована ключом хранилища. Хранилище должно быть разблокировано
+func (a *app) recordKey(d structs.SecureData) (key []byte, wrapped string, found bool, err error) {
+	wrapped, found = vault.RecordKey(d.Data)
+	if !found {
+		return nil, "", false, nil
+	}
+	if d.Share == nil {
+		key, err = vault.UnwrapRecordKey(a.key, wrapped)
+		return key, wrapped, true, err
+	}
+
+	privateKey, err := vault.UnwrapPrivateKey(a.key, a.keys.WrappedPrivateKey)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("error while unwrapping private key: %w", err)
+	}
+	key, err = vault.OpenSealedKey(privateKey, d.Share.WrappedKey)
+	return key, wrapped, true, err
+}
+
+// decrypt - расшифровка данных собственной, доступной пользователю записи или записи хранилища организации
+func (a *app) decrypt(d structs.SecureData) ([]byte, error) {
+	if d.Share == nil {
+		key := a.key
+		if v := a.vaultsByID[d.VaultID]; v.key != nil {
+			key = v.key
+		}
+		return vault.Decrypt(key, d.ID, d.Kind, d.Data)
+	}
+	key, _, found, err := a.recordKey(d)
+	if err == nil && !found {
+		err = fmt.Errorf("shared record is not encrypted with a record key")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vault.DecryptRecord(key, d.ID, d.Kind, d.Data)
+}
+
+// encrypt - шифрование данных записи id типа kind (или заготовки новой записи хранилища vaultID):
+// общая запись (по локальной копии) шифруется своим ключом записи, чтобы её могли расшифровать
+// владелец и получатели, запись хранилища организации - ключом организации, остальные - ключом
+// хранилища. Пустой kind - тип записи не меняется
+func (a *app) encrypt(ctx context.Context, id int64, vaultID int64, kind string, plaintext []byte) (string, error) {
+	if _, err := a.vaultKey(ctx); err != nil {
+		return "", err
+	}
+	if id != 0 {
+		state, err := client.LoadState(a.dir)
+		if err != nil {
+			return "", err
+		}
+		d, found := state.Records[id]
+		if kind == "" {
+			if !found {
+				return "", fmt.Errorf("-kind is required: record %d is not synchronized", id)
+			}
+			kind = d.Kind
+		}
+		if found {
+			key, wrapped, found, err := a.recordKey(d)
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return vault.EncryptRecord(key, wrapped, id, kind, plaintext)
+			}
+			vaultID = d.VaultID
+		}
+	}
+	key, err := a.dataKey(ctx, vaultID)
+	if err != nil {
+		return "", err
+	}
+	return vault.Encrypt(key, id, kind, plaintext)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+// totp - состояние второго фактора и управление им: enroll, confirm, disable, backup-codes
+func (a *app) totp(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		status, err := a.api.TOTP(ctx)
+		if err != nil {
+			return err
+		}
+		if !status.Enabled {
+			fmt.Println("two-factor authentication is disabled")
+			return nil
+		}
+		fmt.Printf("two-factor authentication is enabled, %d backup codes left\n", status.BackupCodesLeft)
+		return nil
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("totp "+action, flag.ExitOnError)
+	code := fs.String("code", "", "authenticator or backup code")
+	fs.Parse(args[1:])
+	if action != "enroll" && *code == "" {
+		return fmt.Errorf("-code is required")
+	}
+
+	switch action {
+	case "enroll":
+		status, err := a.api.EnrollTOTP(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("add this key to your authenticator app:\n%s\nsecret: %s\n", status.URI, status.Secret)
+		fmt.Println("then run: totp confirm -code <code from the app>")
+	case "confirm":
+		status, err := a.api.ConfirmTOTP(ctx, *code)
+		if err != nil {
+			return err
+		}
+		fmt.Println("two-factor authentication enabled")
+		printBackupCodes(status.BackupCodes)
+	case "disable":
+		if err := a.api.DisableTOTP(ctx, *code); err != nil {
+			return err
+		}
+		fmt.Println("two-factor authentication disabled")
+	case "backup-codes":
+		status, err := a.api.RegenerateBackupCodes(ctx, *code)
+		if err != nil {
+			return err
+		}
+		printBackupCodes(status.BackupCodes)
+	default:
+		return fmt.Errorf("unknown totp action %q", action)
+	}
+	return nil
+}
+
+func printBackupCodes(codes []string) {
+	fmt.Println("backup codes (each works once, store them safely):")
+	for _, code := range codes {
+		fmt.Println("  " + code)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+	"golang.org/x/term"
+)
+
+// vaultKey - разблокировка хранилища мастер-паролем; при первом запуске хранилище создаётся.
+// Если у пользователя ещё нет пары ключей для общих записей, она создаётся вместе с хранилищем
+// или при разблокировке
+func (a *app) vaultKey(ctx context.Context) ([]byte, error) {
+	if a.key != nil {
+		return a.key, nil
+	}
+
+	keys, found, err := a.api.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		password, err := newPassword()
+		if err != nil {
+			return nil, err
+		}
+		keys, key, err := newKeys(password)
+		if err != nil {
+			return nil, err
+		}
+		if err := addKeyPair(&keys, key); err != nil {
+			return nil, err
+		}
+		if keys.Version, err = a.api.SetKeys(ctx, keys); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(os.Stderr, "vault created")
+		a.key, a.keys = key, keys
+		return key, nil
+	}
+
+	password, err := readPassword("master password: ")
+	if err != nil {
+		return nil, err
+	}
+	master, err := vault.DeriveKey(password, keys.KDF)
+	if err != nil {
+		return nil, err
+	}
+	key, err := vault.UnwrapKey(master, keys.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if keys.PublicKey == "" {
+		if err := addKeyPair(&keys, key); err != nil {
+			return nil, err
+		}
+		if keys.Version, err = a.api.SetKeys(ctx, keys); err != nil {
+			return nil, err
+		}
+	}
+	a.key, a.keys = key, keys
+	return key, nil
+}
+
+// addKeyPair - новая пара ключей X25519 с закрытым ключом, обёрнутым ключом хранилища
+func addKeyPair(keys *structs.UserKeys, vaultKey []byte) error {
+	publicKey, privateKey, err := vault.NewKeyPair()
+	if err != nil {
+		return err
+	}
+	keys.WrappedPrivateKey, err = vault.WrapPrivateKey(vaultKey, privateKey)
+	if err != nil {
+		return err
+	}
+	keys.PublicKey = publicKey
+	return nil
+}
+
+func (a *app) passwd(ctx context.Context) error {
+	key, err := a.vaultKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	password, err := newPassword()
+	if err != nil {
+		return err
+	}
+	params, err := vault.NewKDFParams()
+	if err != nil {
+		return err
+	}
+	master, err := vault.DeriveKey(password, params)
+	if err != nil {
+		return err
+	}
+	wrapped, err := vault.WrapKey(master, key)
+	if err != nil {
+		return err
+	}
+
+	a.keys.Version, err = a.api.SetKeys(ctx, structs.UserKeys{KDF: params, WrappedKey: wrapped, Version: a.keys.Version})
+	if err != nil {
+		return err
+	}
+	a.keys.KDF, a.keys.WrappedKey = params, wrapped
+	fmt.Println("master password changed")
+	return nil
+}
+
+// newKeys - новый ключ хранилища, обёрнутый мастер-ключом из password
+func newKeys(password string) (structs.UserKeys, []byte, error) {
+	params, err := vault.NewKDFParams()
+	if err != nil {
+		return structs.UserKeys{}, nil, err
+	}
+	master, err := vault.DeriveKey(password, params)
+	if err != nil {
+		return structs.UserKeys{}, nil, err
+	}
+	key, err := vault.NewVaultKey()
+	if err != nil {
+		return structs.UserKeys{}, nil, err
+	}
+	wrapped, err := vault.WrapKey(master, key)
+	if err != nil {
+		return structs.UserKeys{}, nil, err
+	}
+	return structs.UserKeys{KDF: params, WrappedKey: wrapped}, key, nil
+}
+
+func newPassword() (string, error) {
+	password, err := readPassword("new master password: ")
+	if err != nil {
+		return "", err
+	}
+	if _, found := os.LookupEnv("GOPHKEEPER_MASTER_PASSWORD"); found {
+		return password, nil
+	}
+	repeat, err := readPassword("repeat master password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != repeat {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return password, nil
+}
+
+// readPassword - чтение пароля из GOPHKEEPER_MASTER_PASSWORD или с терминала без эха
+func readPassword(prompt string) (string, error) {
+	if password, found := os.LookupEnv("GOPHKEEPER_MASTER_PASSWORD"); found {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error while reading password: %w", err)
+	}
+	if len(raw) == 0 {
+		return "", fmt.Errorf("empty master password")
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/client"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// Записи хранилищ организации шифруются ключом организации. Каждому участнику ключ передаётся
+// зашифрованным его открытым ключом X25519, поэтому сервер не может расшифровать записи.
+
+// vaultInfo - хранилище, доступное пользователю; key - ключ организации (nil для личных хранилищ)
+type vaultInfo struct {
+	name string
+	key  []byte
+}
+
+// orgs - список организаций пользователя или создание новой
+func (a *app) orgs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("orgs", flag.ExitOnError)
+	create := fs.String("create", "", "name of a new organization")
+	fs.Parse(args)
+
+	if *create != "" {
+		if _, err := a.vaultKey(ctx); err != nil {
+			return err
+		}
+		orgKey, err := vault.NewVaultKey()
+		if err != nil {
+			return err
+		}
+		sealed, err := vault.SealKey(a.keys.PublicKey, orgKey)
+		if err != nil {
+			return err
+		}
+		org, err := a.api.CreateOrganization(ctx, structs.OrganizationRequest{Name: *create, WrappedKey: sealed})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("organization created: ID=%d\n", org.ID)
+		return nil
+	}
+
+	orgs, err := a.api.Organizations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, o := range orgs {
+		fmt.Printf("%d\t%s\t%s\t%s\n", o.ID, o.Name, o.Role, o.CreatedAt.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+// members - участники организации, добавление участника или изменение его роли, исключение участника.
+// Новому участнику ключ организации передаётся зашифрованным его открытым ключом
+func (a *app) members(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("members", flag.ExitOnError)
+	orgID := fs.Int64("org", 0, "organization ID")
+	add := fs.String("add", "", "mail of a user to add or whose role to change")
+	role := fs.String("role", structs.RoleMember, "role of the added user: owner, admin, member or read-only")
+	remove := fs.String("remove", "", "mail of a member to remove (your own to leave)")
+	fs.Parse(args)
+	if *orgID == 0 {
+		return fmt.Errorf("-org is required")
+	}
+
+	switch {
+	case *add != "":
+		orgKey, err := a.orgKey(ctx, *orgID)
+		if err != nil {
+			return err
+		}
+		publicKey, err := a.api.PublicKey(ctx, *add)
+		if err != nil {
+			return err
+		}
+		sealed, err := vault.SealKey(publicKey, orgKey)
+		if err != nil {
+			return err
+		}
+		resp, err := a.api.SetMember(ctx, *orgID, *add, structs.MemberRequest{Role: *role, WrappedKey: sealed})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+		return nil
+	case *remove != "":
+		resp, err := a.api.RemoveMember(ctx, *orgID, *remove)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: historyID=%d\n", resp.Message, resp.HistoryID)
+		return nil
+	}
+
+	members, err := a.api.Members(ctx, *orgID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		fmt.Printf("%s\t%s\t%s\n", m.Username, m.Role, m.CreatedAt.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+// vaults - список хранилищ пользователя или создание нового
+func (a *app) vaults(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("vaults", flag.ExitOnError)
+	create := fs.String("create", "", "name of a new vault")
+	orgID := fs.Int64("org", 0, "organization of the new vault (personal if 0)")
+	fs.Parse(args)
+
+	if *create != "" {
+		v, err := a.api.CreateVault(ctx, structs.VaultRequest{Name: *create, OrgID: *orgID})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vault created: ID=%d\n", v.ID)
+		return nil
+	}
+
+	vaults, err := a.api.Vaults(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range vaults {
+		owner := "personal"
+		if v.OrgID != 0 {
+			owner = fmt.Sprintf("organization %d", v.OrgID)
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\n", v.ID, v.Name, owner, v.CreatedAt.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+// orgKey - ключ организации orgID, расшифрованный закрытым ключом пользователя
+func (a *app) orgKey(ctx context.Context, orgID int64) ([]byte, error) {
+	if _, err := a.vaultKey(ctx); err != nil {
+		return nil, err
+	}
+	orgs, err := a.api.Organizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		if o.ID == orgID {
+			return a.openOrgKey(o)
+		}
+	}
+	return nil, fmt.Errorf("organization %d not found", orgID)
+}
+
+func (a *app) openOrgKey(o structs.Organization) ([]byte, error) {
+	privateKey, err := vault.UnwrapPrivateKey(a.key, a.keys.WrappedPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error while unwrapping private key: %w", err)
+	}
+	key, err := vault.OpenSealedKey(privateKey, o.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening key of organization %d: %w", o.ID, err)
+	}
+	return key, nil
+}
+
+// loadVaults - хранилища пользователя с ключами организаций. Хранилище должно быть разблокировано
+func (a *app) loadVaults(ctx context.Context) error {
+	if a.vaultsByID != nil {
+		return nil
+	}
+	vaults, err := a.api.Vaults(ctx)
+	if err != nil {
+		return err
+	}
+	orgs, err := a.api.Organizations(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make(map[int64][]byte, len(orgs))
+	for _, o := range orgs {
+		if keys[o.ID], err = a.openOrgKey(o); err != nil {
+			return err
+		}
+	}
+
+	a.vaultsByID = make(map[int64]vaultInfo, len(vaults))
+	for _, v := range vaults {
+		a.vaultsByID[v.ID] = vaultInfo{name: v.Name, key: keys[v.OrgID]}
+	}
+	return nil
+}
+
+// unlockRecords - разблокировка хранилища и, если среди записей есть записи именованных хранилищ,
+// загрузка ключей организаций
+func (a *app) unlockRecords(ctx context.Context, records []structs.SecureData) error {
+	if _, err := a.vaultKey(ctx); err != nil {
+		return err
+	}
+	for _, d := range records {
+		if d.VaultID != 0 {
+			return a.loadVaults(ctx)
+		}
+	}
+	return nil
+}
+
+// dataKey - ключ шифрования записей хранилища vaultID: ключ организации или ключ хранилища пользователя
+func (a *app) dataKey(ctx context.Context, vaultID int64) ([]byte, error) {
+	key, err := a.vaultKey(ctx)
+	if err != nil || vaultID == 0 {
+		return key, err
+	}
+	if err := a.loadVaults(ctx); err != nil {
+		return nil, err
+	}
+	v, found := a.vaultsByID[vaultID]
+	if !found {
+		return nil, fmt.Errorf("vault %d not found", vaultID)
+	}
+	if v.key != nil {
+		return v.key, nil
+	}
+	return key, nil
+}
+
+// recordDataKey - ключ шифрования записи id по её хранилищу в локальной копии
+func (a *app) recordDataKey(ctx context.Context, id int64) ([]byte, error) {
+	state, err := client.LoadState(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	return a.dataKey(ctx, state.Records[id].VaultID)
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
 	"github.com/stepanov-ds/GophKeeper/internal/config"
 	"github.com/stepanov-ds/GophKeeper/internal/database"
+	"github.com/stepanov-ds/GophKeeper/internal/grpcserver"
+	"github.com/stepanov-ds/GophKeeper/internal/handlers/middlewares"
 	"github.com/stepanov-ds/GophKeeper/internal/handlers/router"
+	"github.com/stepanov-ds/GophKeeper/internal/mail"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/storage/memory"
+	"github.com/stepanov-ds/GophKeeper/internal/tlsconfig"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
@@ -16,16 +31,126 @@ func main() {
 	//конфигурация сервиса
 	config.ConfigServer()
 
-	//инициализация БД
-	database.InitConnection()
-	database.RunMigrations()
+	//транспорт почты
+	if err := mail.Configure(); err != nil {
+		log.Panicln(err)
+	}
+
+	//инициализация хранилища
+	var store storage.Storage
+	storageOptions := storage.Options{
+		HistoryRetention: *config.HistoryRetention,
+		UploadTTL:        *config.BlobUploadTTL,
+		Quota: structs.Quota{
+			Records:    *config.QuotaRecords,
+			Bytes:      *config.QuotaBytes,
+			RecordSize: *config.QuotaRecordSize,
+		},
+	}
+	switch *config.StorageType {
+	case "postgres":
+		db, err := database.New(*config.DatabaseDSN, storageOptions)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer db.Close()
+		database.RunMigrations(*config.DatabaseDSN)
+		metrics.RegisterPool(db.PoolStat)
+		store = db
+	case "memory":
+		log.Println("using in-memory storage, data will be lost on restart")
+		store = memory.New(storageOptions)
+	default:
+		log.Fatalf("unknown storage %q\n", *config.StorageType)
+	}
+
+	//кэш кодов авторизации, общий для REST и gRPC
+	cache := utils.NewMemoryCache(*config.CleanupTime)
+	metrics.RegisterCacheSize("challenges", cache.Len)
+	//защита входа и регистрации от перебора, общая для REST и gRPC
+	guard := auth.NewGuard()
+
+	//удаление неподтверждённых регистраций
+	go auth.ExpireRegistrations(context.Background(), store, *config.CleanupTime)
+	//удаление устаревших ключей Idempotency-Key
+	go middlewares.ExpireIdempotencyKeys(context.Background(), store, *config.CleanupTime)
+
+	//сертификаты TLS
+	var certs *tlsconfig.Reloader
+	if config.TLSEnabled() {
+		var err error
+		certs, err = tlsconfig.New(tlsconfig.Options{
+			CertFile:       *config.TLSCertFile,
+			KeyFile:        *config.TLSKeyFile,
+			ClientCAFile:   *config.TLSClientCAFile,
+			SelfSigned:     *config.TLSSelfSigned,
+			Hosts:          hosts(*config.EndpointServer, *config.EndpointGRPC),
+			ReloadInterval: *config.TLSReloadInterval,
+		})
+		if err != nil {
+			log.Panicln(err)
+		}
+	}
+
+	//запуск сервера gRPC
+	if *config.EndpointGRPC != "" {
+		lis, err := net.Listen("tcp", *config.EndpointGRPC)
+		if err != nil {
+			log.Panicln(err)
+		}
+		var opts []grpc.ServerOption
+		if certs != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(certs.Config())))
+		}
+		s := grpcserver.New(store, cache, guard, opts...)
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				log.Panicln(err)
+			}
+		}()
+	}
+
+	//метрики Prometheus на отдельном адресе
+	if *config.MetricsEnabled && *config.EndpointMetrics != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(*config.EndpointMetrics, mux); err != nil {
+				log.Panicln(err)
+			}
+		}()
+	}
 
 	//запуск сервера gin
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
-	router.Route(r)
-	if err := r.Run(*config.EndpointServer); err != nil {
+	router.Route(r, store, cache, guard)
+	if certs == nil {
+		if err := r.Run(*config.EndpointServer); err != nil {
+			log.Panicln(err)
+		}
+		return
+	}
+
+	server := &http.Server{
+		Addr:      *config.EndpointServer,
+		Handler:   r,
+		TLSConfig: certs.Config(),
+	}
+	if err := server.ListenAndServeTLS("", ""); err != nil {
 		log.Panicln(err)
 	}
 
 }
+
+// hosts - хосты из адресов сервера для самоподписанного сертификата
+func hosts(endpoints ...string) []string {
+	var result []string
+	for _, endpoint := range endpoints {
+		host, _, err := net.SplitHostPort(endpoint)
+		if err == nil && host != "" && host != "0.0.0.0" && host != "::" {
+			result = append(result, host)
+		}
+	}
+	return result
+}
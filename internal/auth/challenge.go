@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/mail"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+)
+
+var (
+	// ErrMailDelivery - код создан, но письмо не доставлено
+	ErrMailDelivery = errors.New("error while sending mail")
+	// ErrInvalidChallenge - неверный код; после config.ChallengeAttempts таких попыток код сгорает
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	ErrChallengeBurned  = errors.New("too many invalid codes, request a new one")
+)
+
+// challenge - выданный код и число неудачных попыток его ввода
+type challenge struct {
+	code     string
+	attempts atomic.Int32
+	// used - код уже принят; защищает от одновременного входа с одним кодом
+	used atomic.Bool
+}
+
+// IssueChallenge - новый код авторизации для пользователя login, отправленный ему на почту.
+// Код известен только получателю письма и в ответе не возвращается
+func IssueChallenge(ctx context.Context, store storage.Users, cache *utils.MemoryCache, login string) error {
+	if err := store.CheckUser(ctx, login); err != nil {
+		return fmt.Errorf("error while checking user: %w", err)
+	}
+
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Errorf("error while generating challenge string: %w", err)
+	}
+	code := hex.EncodeToString(bytes)
+
+	if err := mail.SendChallenge(ctx, login, code); err != nil {
+		return fmt.Errorf("%w: %w", ErrMailDelivery, err)
+	}
+
+	cache.Set(login, &challenge{code: code}, *config.ChallengeTTL)
+	metrics.ChallengeIssued()
+	return nil
+}
+
+// CheckChallenge - проверка кода авторизации пользователя login; код остаётся действительным
+// до ConsumeChallenge, чтобы с ним можно было повторить вход с кодом второго фактора
+func CheckChallenge(cache *utils.MemoryCache, login string, code string) error {
+	err := checkChallenge(cache, login, code)
+	result := metrics.ChallengeSucceeded
+	if err != nil {
+		result = metrics.ChallengeFailed
+	}
+	metrics.ChallengeChecked(result)
+	return err
+}
+
+func checkChallenge(cache *utils.MemoryCache, login string, code string) error {
+	value, success := cache.Get(login)
+	if !success {
+		return fmt.Errorf("no challenge in cache")
+	}
+	c, ok := value.(*challenge)
+	if !ok {
+		return ErrInvalidChallenge
+	}
+	if subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) != 1 {
+		if limit := *config.ChallengeAttempts; limit > 0 && int(c.attempts.Add(1)) >= limit {
+			cache.Delete(login)
+			return ErrChallengeBurned
+		}
+		return ErrInvalidChallenge
+	}
+	if c.used.Load() {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// ConsumeChallenge - вход по коду пользователя login завершён (второй фактор проверен): код одноразовый,
+// повторный вход с ним невозможен. ErrInvalidChallenge, если код уже использован другим запросом
+func ConsumeChallenge(cache *utils.MemoryCache, login string, code string) error {
+	value, success := cache.Get(login)
+	if !success {
+		return fmt.Errorf("no challenge in cache")
+	}
+	c, ok := value.(*challenge)
+	if !ok || subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) != 1 || c.used.Swap(true) {
+		return ErrInvalidChallenge
+	}
+	cache.Delete(login)
+	return nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+)
+
+// RateLimitError - запрос отклонён ограничением частоты или блокировкой аккаунта
+type RateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+// Guard - защита входа и регистрации от перебора: частота запросов по IP и по аккаунту
+// и временная блокировка аккаунта с экспоненциально растущим сроком после неудачных попыток
+type Guard struct {
+	ip      *utils.RateLimiter
+	account *utils.RateLimiter
+
+	mu       sync.Mutex
+	lockouts map[string]*lockout
+}
+
+type lockout struct {
+	failures    int
+	lastFailure time.Time
+	until       time.Time
+}
+
+// NewGuard - ограничения из конфигурации; общий для REST и gRPC
+func NewGuard() *Guard {
+	g := &Guard{
+		ip:       utils.NewRateLimiter(*config.RateLimitIP, *config.RateLimitPeriod, *config.CleanupTime),
+		account:  utils.NewRateLimiter(*config.RateLimitAccount, *config.RateLimitPeriod, *config.CleanupTime),
+		lockouts: make(map[string]*lockout),
+	}
+	go g.cleanup(*config.CleanupTime)
+	return g
+}
+
+// Allow - проверка запроса с адреса ip к аккаунту login (пустой login - только по адресу);
+// *RateLimitError, если запрос нужно отклонить
+func (g *Guard) Allow(ip string, login string) error {
+	login = accountKey(login)
+	if login != "" {
+		if wait := g.lockedFor(login); wait > 0 {
+			return &RateLimitError{Reason: "account is temporarily locked", RetryAfter: wait}
+		}
+	}
+	if ok, wait := g.ip.Allow(ip); !ok {
+		return &RateLimitError{Reason: "too many requests", RetryAfter: wait}
+	}
+	if login != "" {
+		if ok, wait := g.account.Allow(login); !ok {
+			return &RateLimitError{Reason: "too many requests for this account", RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// Failure - неудачная попытка входа; начиная с config.LockoutThreshold попыток аккаунт блокируется,
+// и каждая следующая неудача удваивает срок блокировки (не больше config.LockoutMax)
+func (g *Guard) Failure(login string) {
+	threshold := *config.LockoutThreshold
+	if threshold <= 0 {
+		return
+	}
+	login = accountKey(login)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	l, found := g.lockouts[login]
+	if !found || now.Sub(l.lastFailure) > *config.LockoutMax {
+		l = &lockout{}
+		g.lockouts[login] = l
+	}
+	l.failures++
+	l.lastFailure = now
+	if l.failures >= threshold {
+		l.until = now.Add(lockoutDuration(l.failures - threshold))
+	}
+}
+
+// Success - успешный вход сбрасывает счётчик неудач
+func (g *Guard) Success(login string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.lockouts, accountKey(login))
+}
+
+func (g *Guard) lockedFor(login string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if l, found := g.lockouts[login]; found {
+		return max(time.Until(l.until), 0)
+	}
+	return 0
+}
+
+// lockoutDuration - config.LockoutBase * 2^n, но не больше config.LockoutMax
+func lockoutDuration(n int) time.Duration {
+	d := *config.LockoutBase
+	for range n {
+		if d >= *config.LockoutMax {
+			break
+		}
+		d *= 2
+	}
+	return min(d, *config.LockoutMax)
+}
+
+// cleanup - удаление счётчиков, по которым давно не было неудач
+func (g *Guard) cleanup(n time.Duration) {
+	ticker := time.NewTicker(n)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		g.mu.Lock()
+		for login, l := range g.lockouts {
+			if time.Since(l.lastFailure) > *config.LockoutMax && time.Now().After(l.until) {
+				delete(g.lockouts, login)
+			}
+		}
+		g.mu.Unlock()
+	}
+}
+
+// accountKey - один аккаунт независимо от регистра и пробелов в адресе
+func accountKey(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
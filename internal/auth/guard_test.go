@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	setConfig(t, config.LockoutBase, 30*time.Second)
+	setConfig(t, config.LockoutMax, 10*time.Minute)
+
+	tests := []struct {
+		n        int
+		expected time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		// удвоение ограничено LockoutMax
+		{5, 10 * time.Minute},
+		{6, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lockoutDuration(tt.n); got != tt.expected {
+			t.Errorf("lockoutDuration(%d) = %v, expected %v", tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestGuardLockout(t *testing.T) {
+	setConfig(t, config.LockoutThreshold, 2)
+	setConfig(t, config.LockoutBase, time.Minute)
+	setConfig(t, config.LockoutMax, time.Hour)
+	g := &Guard{
+		ip:       utils.NewRateLimiter(0, time.Minute, time.Hour),
+		account:  utils.NewRateLimiter(0, time.Minute, time.Hour),
+		lockouts: make(map[string]*lockout),
+	}
+
+	g.Failure("alice@example.com")
+	if err := g.Allow("10.0.0.1", "alice@example.com"); err != nil {
+		t.Fatalf("Allow below threshold: %v", err)
+	}
+
+	// блокировка аккаунта независимо от регистра адреса, срок растёт с каждой неудачей
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute} {
+		g.Failure("Alice@Example.com ")
+		var limitErr *RateLimitError
+		err := g.Allow("10.0.0.2", "alice@example.com")
+		if !errors.As(err, &limitErr) || limitErr.RetryAfter > expected || limitErr.RetryAfter < expected-time.Second {
+			t.Fatalf("Allow after failure = %v, expected retry after %v", err, expected)
+		}
+	}
+
+	g.Success("alice@example.com")
+	if err := g.Allow("10.0.0.1", "alice@example.com"); err != nil {
+		t.Fatalf("Allow after success: %v", err)
+	}
+}
+
+// setConfig - значение параметра конфигурации до конца теста
+func setConfig[T any](t *testing.T, p *T, value T) {
+	prev := *p
+	*p = value
+	t.Cleanup(func() { *p = prev })
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+)
+
+// RoleAdmin - роль пользователя с доступом к административному API
+const RoleAdmin = "admin"
+
+// Claims - содержимое токена авторизации; ID (jti) - идентификатор сессии
+type Claims struct {
+	Login string `json:"login"`
+	// Role - RoleAdmin для пользователей из config.Admins
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateJWT - токен доступа пользователя login в сессии sessionID
+func GenerateJWT(login string, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(*config.AccessTokenTTL)
+	claims := &Claims{
+		Login: login,
+		Role:  role(login),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(config.JWTKey)
+}
+
+// role - роль пользователя login; администраторы перечислены в config.Admins, поэтому изменение
+// списка вступает в силу с выпуском нового токена доступа
+func role(login string) string {
+	for _, admin := range strings.Split(*config.Admins, ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && strings.EqualFold(admin, login) {
+			return RoleAdmin
+		}
+	}
+	return ""
+}
+
+// ParseJWT - проверка подписи и срока действия токена; отзыв сессии проверяет Authorize
+func ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return config.JWTKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.ID == "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+// ErrUnauthorized - токен недействителен или его сессия отозвана
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authorize - проверка токена доступа и активности его сессии (общая для REST и gRPC).
+// Ошибки, не связанные с самим токеном (например, недоступность БД), не оборачивают ErrUnauthorized.
+func Authorize(ctx context.Context, store storage.Sessions, tokenString string, ip string) (*Claims, error) {
+	claims, err := ParseJWT(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid token", ErrUnauthorized)
+	}
+	err = store.CheckSession(ctx, claims.ID, claims.Login, ip)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: session revoked or expired", ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while checking session: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// ErrInvalidRefreshToken - refresh токен неизвестен, истёк, отозван или уже использован
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// Tokens - пара токенов сессии
+type Tokens struct {
+	SessionID string
+	// Access - короткоживущий JWT для запросов к API
+	Access string
+	// Refresh - одноразовый токен для получения новой пары; имеет вид "<SessionID>.<секрет>"
+	Refresh          string
+	RefreshExpiresAt time.Time
+}
+
+// Client - сведения об устройстве, сохраняемые в сессии
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// StartSession - новая сессия пользователя login после успешного входа
+func StartSession(ctx context.Context, store storage.Sessions, login string, client Client) (Tokens, error) {
+	id, err := randomString(16, hex.EncodeToString)
+	if err != nil {
+		return Tokens{}, err
+	}
+	tokens, err := newTokens(id, login)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	session := structs.Session{
+		ID:        id,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: tokens.RefreshExpiresAt,
+	}
+	if err := store.CreateSession(ctx, login, session, hashToken(tokens.Refresh)); err != nil {
+		return Tokens{}, fmt.Errorf("error while creating session: %w", err)
+	}
+	return tokens, nil
+}
+
+// RefreshSession - новая пара токенов взамен refresh токена; старый токен становится недействительным
+func RefreshSession(ctx context.Context, store storage.Sessions, refreshToken string, client Client) (Tokens, error) {
+	id, _, found := strings.Cut(refreshToken, ".")
+	if !found || id == "" {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	tokens, err := newTokens(id, "")
+	if err != nil {
+		return Tokens{}, err
+	}
+	login, err := store.RotateSession(ctx, id, hashToken(refreshToken), hashToken(tokens.Refresh), tokens.RefreshExpiresAt, client.IP, client.UserAgent)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("error while rotating session: %w", err)
+	}
+
+	tokens.Access, err = GenerateJWT(login, id)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("error while generating token: %w", err)
+	}
+	return tokens, nil
+}
+
+// newTokens - токены сессии id; access токен выпускается, только если известен login
+func newTokens(id string, login string) (Tokens, error) {
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return Tokens{}, err
+	}
+	tokens := Tokens{
+		SessionID:        id,
+		Refresh:          id + "." + secret,
+		RefreshExpiresAt: time.Now().Add(*config.RefreshTokenTTL),
+	}
+	if login != "" {
+		tokens.Access, err = GenerateJWT(login, id)
+		if err != nil {
+			return Tokens{}, fmt.Errorf("error while generating token: %w", err)
+		}
+	}
+	return tokens, nil
+}
+
+// hashToken - в хранилище попадает только SHA-256 refresh токена
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error while generating random token: %w", err)
+	}
+	return encode(buf), nil
+}
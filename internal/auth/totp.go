@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Параметры TOTP (RFC 6238) - значения по умолчанию, которые понимают все приложения-аутентификаторы
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew - допустимое расхождение часов клиента и сервера в шагах
+	totpSkew        = 1
+	backupCodeCount = 10
+)
+
+var (
+	// ErrSecondFactorRequired - у пользователя подключён TOTP, а код не передан
+	ErrSecondFactorRequired = errors.New("totp code required")
+	// ErrInvalidSecondFactor - неверный, уже использованный или устаревший код
+	ErrInvalidSecondFactor = errors.New("invalid totp or backup code")
+	ErrTOTPNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrTOTPAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP - новый секрет, ожидающий подтверждения кодом (ConfirmTOTP)
+func EnrollTOTP(ctx context.Context, store storage.TOTP, login string) (structs.TOTPStatus, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return structs.TOTPStatus{}, fmt.Errorf("error while generating totp secret: %w", err)
+	}
+	secret := secretEncoding.EncodeToString(raw)
+
+	err := store.SetTOTPSecret(ctx, login, secret)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		return structs.TOTPStatus{}, ErrTOTPAlreadyEnabled
+	}
+	if err != nil {
+		return structs.TOTPStatus{}, fmt.Errorf("error while saving totp secret: %w", err)
+	}
+
+	return structs.TOTPStatus{
+		URI:    totpURI(login, secret),
+		Secret: secret,
+	}, nil
+}
+
+// ConfirmTOTP - включение второго фактора после проверки первого кода; возвращает резервные коды (показываются один раз)
+func ConfirmTOTP(ctx context.Context, store storage.TOTP, login string, code string) (structs.TOTPStatus, error) {
+	totp, err := selectTOTP(ctx, store, login)
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	if totp.Enabled {
+		return structs.TOTPStatus{}, ErrTOTPAlreadyEnabled
+	}
+	if err := verifyTOTP(ctx, store, login, totp.Secret, code); err != nil {
+		return structs.TOTPStatus{}, err
+	}
+
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	if err := store.EnableTOTP(ctx, login, hashes); err != nil {
+		return structs.TOTPStatus{}, fmt.Errorf("error while enabling totp: %w", err)
+	}
+	return structs.TOTPStatus{
+		Enabled:         true,
+		BackupCodes:     codes,
+		BackupCodesLeft: len(codes),
+	}, nil
+}
+
+// TOTPStatus - подключён ли второй фактор и сколько осталось резервных кодов
+func TOTPStatus(ctx context.Context, store storage.TOTP, login string) (structs.TOTPStatus, error) {
+	totp, err := store.SelectTOTP(ctx, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		return structs.TOTPStatus{}, nil
+	}
+	if err != nil {
+		return structs.TOTPStatus{}, fmt.Errorf("error while selecting totp: %w", err)
+	}
+	return structs.TOTPStatus{
+		Enabled:         totp.Enabled,
+		BackupCodesLeft: totp.BackupCodesLeft,
+	}, nil
+}
+
+// RegenerateBackupCodes - новый набор резервных кодов взамен старого; требуется свежий код
+func RegenerateBackupCodes(ctx context.Context, store storage.TOTP, login string, code string) (structs.TOTPStatus, error) {
+	if err := verifyEnabled(ctx, store, login, code); err != nil {
+		return structs.TOTPStatus{}, err
+	}
+
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	if err := store.SetBackupCodes(ctx, login, hashes); err != nil {
+		return structs.TOTPStatus{}, fmt.Errorf("error while saving backup codes: %w", err)
+	}
+	return structs.TOTPStatus{
+		Enabled:         true,
+		BackupCodes:     codes,
+		BackupCodesLeft: len(codes),
+	}, nil
+}
+
+// DisableTOTP - отключение второго фактора; требуется свежий код TOTP или неиспользованный резервный код
+func DisableTOTP(ctx context.Context, store storage.TOTP, login string, code string) error {
+	if err := verifyEnabled(ctx, store, login, code); err != nil {
+		return err
+	}
+	if err := store.DisableTOTP(ctx, login); err != nil {
+		return fmt.Errorf("error while disabling totp: %w", err)
+	}
+	return nil
+}
+
+// CheckSecondFactor - проверка второго фактора при входе; пользователям без TOTP код не нужен
+func CheckSecondFactor(ctx context.Context, store storage.TOTP, login string, code string) error {
+	totp, err := store.SelectTOTP(ctx, login)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !totp.Enabled) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error while selecting totp: %w", err)
+	}
+	if strings.TrimSpace(code) == "" {
+		return ErrSecondFactorRequired
+	}
+	return verifyCode(ctx, store, login, totp.Secret, code)
+}
+
+func selectTOTP(ctx context.Context, store storage.TOTP, login string) (structs.TOTP, error) {
+	totp, err := store.SelectTOTP(ctx, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		return totp, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return totp, fmt.Errorf("error while selecting totp: %w", err)
+	}
+	return totp, nil
+}
+
+func verifyEnabled(ctx context.Context, store storage.TOTP, login string, code string) error {
+	totp, err := selectTOTP(ctx, store, login)
+	if err != nil {
+		return err
+	}
+	if !totp.Enabled {
+		return ErrTOTPNotEnabled
+	}
+	return verifyCode(ctx, store, login, totp.Secret, code)
+}
+
+// verifyCode - шестизначный код проверяется как TOTP, остальные - как резервные коды
+func verifyCode(ctx context.Context, store storage.TOTP, login string, secret string, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits && strings.Trim(code, "0123456789") == "" {
+		return verifyTOTP(ctx, store, login, secret, code)
+	}
+
+	ok, err := store.UseBackupCode(ctx, login, hashBackupCode(code))
+	if err != nil {
+		return fmt.Errorf("error while checking backup code: %w", err)
+	}
+	if !ok {
+		return ErrInvalidSecondFactor
+	}
+	return nil
+}
+
+// verifyTOTP - код должен соответствовать шагу, который ещё не использовался (защита от повтора)
+func verifyTOTP(ctx context.Context, store storage.TOTP, login string, secret string, code string) error {
+	counter, ok := matchTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidSecondFactor
+	}
+	fresh, err := store.UseTOTPCounter(ctx, login, counter)
+	if err != nil {
+		return fmt.Errorf("error while saving totp counter: %w", err)
+	}
+	if !fresh {
+		return ErrInvalidSecondFactor
+	}
+	return nil
+}
+
+// matchTOTP - шаг, которому соответствует code, в пределах totpSkew от текущего
+func matchTOTP(secret string, code string, now time.Time) (int64, bool) {
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for counter := current + totpSkew; counter >= current-totpSkew; counter-- {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode - HOTP (RFC 4226) для шага counter
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// totpURI - otpauth URI для QR-кода приложения-аутентификатора
+func totpURI(login string, secret string) string {
+	issuer := *config.TOTPIssuer
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + login,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// generateBackupCodes - коды вида xxxx-xxxx и их хэши для хранилища
+func generateBackupCodes() (codes []string, hashes []string, err error) {
+	for range backupCodeCount {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("error while generating backup code: %w", err)
+		}
+		code := strings.ToLower(secretEncoding.EncodeToString(raw))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashBackupCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashBackupCode - хэш кода без учёта регистра, дефисов и пробелов
+func hashBackupCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
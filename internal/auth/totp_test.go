@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/storage/memory"
+)
+
+// rfc6238Key - ключ тестовых векторов SHA1 из приложения B RFC 6238
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// в RFC коды восьмизначные, шестизначный код - их последние шесть цифр
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, expected %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := secretEncoding.EncodeToString(rfc6238Key)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name    string
+		counter int64
+		match   bool
+	}{
+		{"current step", current, true},
+		{"previous step", current - 1, true},
+		{"next step", current + 1, true},
+		{"two steps behind", current - 2, false},
+		{"two steps ahead", current + 2, false},
+	}
+	for _, tt := range tests {
+		counter, ok := matchTOTP(secret, totpCode(rfc6238Key, tt.counter), now)
+		if ok != tt.match || (ok && counter != tt.counter) {
+			t.Errorf("%s: matchTOTP = %d, %v", tt.name, counter, ok)
+		}
+	}
+
+	if _, ok := matchTOTP(secret, "000000", time.Unix(59, 0)); ok {
+		t.Error("matchTOTP accepted a wrong code")
+	}
+	if _, ok := matchTOTP("not base32!", totpCode(rfc6238Key, current), now); ok {
+		t.Error("matchTOTP accepted an invalid secret")
+	}
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(storage.Options{})
+	if err := store.RegisterUser(ctx, "alice@example.com", nil); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	secret := secretEncoding.EncodeToString(rfc6238Key)
+	if err := store.SetTOTPSecret(ctx, "alice@example.com", secret); err != nil {
+		t.Fatalf("SetTOTPSecret: %v", err)
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	code := totpCode(rfc6238Key, current)
+	if err := verifyTOTP(ctx, store, "alice@example.com", secret, code); err != nil {
+		t.Fatalf("verifyTOTP: %v", err)
+	}
+
+	// повтор кода и код более раннего шага после использованного не принимаются
+	for _, replay := range []string{code, totpCode(rfc6238Key, current-1)} {
+		if err := verifyTOTP(ctx, store, "alice@example.com", secret, replay); !errors.Is(err, ErrInvalidSecondFactor) {
+			t.Fatalf("verifyTOTP replay = %v, expected %v", err, ErrInvalidSecondFactor)
+		}
+	}
+
+	// код следующего шага остаётся действительным
+	if err := verifyTOTP(ctx, store, "alice@example.com", secret, totpCode(rfc6238Key, current+1)); err != nil {
+		t.Fatalf("verifyTOTP next step: %v", err)
+	}
+}
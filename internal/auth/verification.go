@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/mail"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+var (
+	ErrInvalidMail = errors.New("invalid mail address")
+	// ErrInvalidVerification - неверный или истёкший код подтверждения
+	ErrInvalidVerification = errors.New("invalid or expired verification code")
+)
+
+// Register - регистрация пользователя mail. Если подтверждение адреса включено, на почту уходит код,
+// и аккаунт остаётся неподтверждённым (pending) до ConfirmRegistration
+func Register(ctx context.Context, store storage.Users, address string) (pending bool, err error) {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil || parsed.Address != address {
+		return false, ErrInvalidMail
+	}
+
+	if !*config.VerifyEmail {
+		if err := store.RegisterUser(ctx, address, nil); err != nil {
+			return false, fmt.Errorf("error while inserting user in DB: %w", err)
+		}
+		return false, nil
+	}
+
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return false, fmt.Errorf("error while generating verification code: %w", err)
+	}
+	code := hex.EncodeToString(bytes)
+
+	verification := &structs.Verification{
+		CodeHash:  hashVerificationCode(code),
+		ExpiresAt: time.Now().Add(*config.VerificationTTL),
+	}
+	if err := store.RegisterUser(ctx, address, verification); err != nil {
+		return false, fmt.Errorf("error while inserting user in DB: %w", err)
+	}
+
+	// при ошибке доставки регистрацию можно повторить - код будет заменён
+	if err := mail.SendVerification(ctx, address, code, verificationLink(address, code)); err != nil {
+		return true, fmt.Errorf("%w: %w", ErrMailDelivery, err)
+	}
+	return true, nil
+}
+
+// ConfirmRegistration - подтверждение адреса кодом из письма
+func ConfirmRegistration(ctx context.Context, store storage.Users, address string, code string) error {
+	err := store.VerifyUser(ctx, address, hashVerificationCode(strings.TrimSpace(code)))
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrInvalidVerification
+	}
+	if err != nil {
+		return fmt.Errorf("error while verifying user in DB: %w", err)
+	}
+	return nil
+}
+
+// ExpireRegistrations - периодическое удаление регистраций, не подтверждённых за config.VerificationTTL
+func ExpireRegistrations(ctx context.Context, store storage.Users, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := store.DeleteExpiredUsers(ctx)
+			if err != nil {
+				log.Println("error while deleting expired registrations:", err)
+			} else if deleted != 0 {
+				log.Println("expired registrations deleted:", deleted)
+			}
+		}
+	}
+}
+
+// verificationLink - ссылка на GET /verify; пустая, если публичный адрес сервера не задан
+func verificationLink(address string, code string) string {
+	if *config.PublicURL == "" {
+		return ""
+	}
+	query := url.Values{}
+	query.Set("mail", address)
+	query.Set("code", code)
+	return strings.TrimRight(*config.PublicURL, "/") + "/verify?" + query.Encode()
+}
+
+func hashVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// AdminUsers - страница пользователей, адрес которых содержит query, после пользователя с ID afterID;
+// next - afterID следующей страницы (0 на последней). Нужна роль администратора
+func (c *Client) AdminUsers(ctx context.Context, query string, afterID int64, limit int) (users []structs.UserInfo, next int64, err error) {
+	params := url.Values{}
+	if query != "" {
+		params.Set("q", query)
+	}
+	if afterID != 0 {
+		params.Set("after", strconv.FormatInt(afterID, 10))
+	}
+	if limit != 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/admin/users"
+	if len(params) != 0 {
+		path += "?" + params.Encode()
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.NextCursor != "" {
+		if next, err = strconv.ParseInt(resp.NextCursor, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("invalid next cursor: %w", err)
+		}
+	}
+	return resp.Users, next, nil
+}
+
+// AdminUser - сведения о пользователе username
+func (c *Client) AdminUser(ctx context.Context, username string) (structs.UserInfo, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/admin/users/"+url.PathEscape(username), nil)
+	if err != nil {
+		return structs.UserInfo{}, err
+	}
+	if resp.User == nil {
+		return structs.UserInfo{}, fmt.Errorf("server response has no user")
+	}
+	return *resp.User, nil
+}
+
+// AdminSetDisabled - блокировка (с отзывом всех сессий) или разблокировка пользователя username
+func (c *Client) AdminSetDisabled(ctx context.Context, username string, disabled bool) error {
+	action := "/enable"
+	if disabled {
+		action = "/disable"
+	}
+	_, err := c.do(ctx, http.MethodPost, "/admin/users/"+url.PathEscape(username)+action, nil)
+	return err
+}
+
+// AdminLogout - отзыв всех сессий пользователя username; число отозванных сессий
+func (c *Client) AdminLogout(ctx context.Context, username string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/admin/users/"+url.PathEscape(username)+"/logout", nil)
+	return resp.Revoked, err
+}
+
+// AdminDeleteUser - удаление пользователя username со всеми его данными
+func (c *Client) AdminDeleteUser(ctx context.Context, username string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/admin/users/"+url.PathEscape(username), nil)
+	return err
+}
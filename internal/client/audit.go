@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// AuditEvents - страница журнала аудита пользователя от новых событий к старым с ID меньше beforeID
+// (0 - с последнего); next - beforeID следующей страницы (0 на последней)
+func (c *Client) AuditEvents(ctx context.Context, beforeID int64, limit int) (events []structs.AuditEvent, next int64, err error) {
+	params := url.Values{}
+	if beforeID != 0 {
+		params.Set("before", strconv.FormatInt(beforeID, 10))
+	}
+	if limit != 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/audit"
+	if len(params) != 0 {
+		path += "?" + params.Encode()
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.NextCursor != "" {
+		if next, err = strconv.ParseInt(resp.NextCursor, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("invalid next cursor: %w", err)
+		}
+	}
+	return resp.AuditEvents, next, nil
+}
+
+// AdminExportAudit - выгрузка журнала аудита в w в формате format (jsonl или csv); пустой username
+// и нулевые since и until не ограничивают выгрузку. Нужна роль администратора
+func (c *Client) AdminExportAudit(ctx context.Context, w io.Writer, format string, username string, since time.Time, until time.Time) error {
+	params := url.Values{"format": {format}}
+	if username != "" {
+		params.Set("user", username)
+	}
+	if !since.IsZero() {
+		params.Set("since", since.Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		params.Set("until", until.Format(time.RFC3339))
+	}
+
+	if err := c.ensureToken(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/admin/audit?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error while creating request: %w", err)
+	}
+	c.authorize(req)
+
+	// выгрузка может быть долгой, поэтому без общего таймаута
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, err := decode(resp)
+		return err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error while reading audit export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// CreateBlob - начало загрузки бинарного содержимого записи secureDataID
+func (c *Client) CreateBlob(ctx context.Context, secureDataID int64, size int64, sha256 string) (structs.Blob, error) {
+	body := structs.Blob{
+		SecureDataID: secureDataID,
+		Size:         size,
+		SHA256:       sha256,
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/blobs", body)
+	if err != nil {
+		return structs.Blob{}, err
+	}
+	return blobFrom(resp)
+}
+
+// Blob - состояние загрузки
+func (c *Client) Blob(ctx context.Context, id int64) (structs.Blob, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/blobs/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return structs.Blob{}, err
+	}
+	return blobFrom(resp)
+}
+
+// UploadBlob - отправка size байт из body начиная со смещения offset
+func (c *Client) UploadBlob(ctx context.Context, id int64, offset int64, body io.Reader, size int64) (structs.Blob, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return structs.Blob{}, err
+	}
+	url := c.baseURL + "/blobs/" + strconv.FormatInt(id, 10) + "?offset=" + strconv.FormatInt(offset, 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, body)
+	if err != nil {
+		return structs.Blob{}, fmt.Errorf("error while creating request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.authorize(req)
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return structs.Blob{}, fmt.Errorf("error while sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	response, err := decode(resp)
+	if err != nil {
+		return structs.Blob{}, err
+	}
+	return blobFrom(response)
+}
+
+// CompleteBlob - завершение загрузки; сервер проверяет размер и хэш
+func (c *Client) CompleteBlob(ctx context.Context, id int64) (structs.Blob, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/blobs/"+strconv.FormatInt(id, 10)+"/complete", nil)
+	if err != nil {
+		return structs.Blob{}, err
+	}
+	return blobFrom(resp)
+}
+
+// DownloadBlob - поток содержимого начиная со смещения offset; вызывающий закрывает поток
+func (c *Client) DownloadBlob(ctx context.Context, id int64, offset int64) (io.ReadCloser, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return nil, err
+	}
+	url := c.baseURL + "/blobs/" + strconv.FormatInt(id, 10) + "/content?offset=" + strconv.FormatInt(offset, 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating request: %w", err)
+	}
+	c.authorize(req)
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while sending request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		_, err := decode(resp)
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func blobFrom(resp structs.Response) (structs.Blob, error) {
+	if resp.Blob == nil {
+		return structs.Blob{}, fmt.Errorf("blob not found in response")
+	}
+	return *resp.Blob, nil
+}
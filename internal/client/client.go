@@ -0,0 +1,409 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// userAgent - устройство в списке сессий пользователя
+var userAgent = "gophkeeper-client (" + runtime.GOOS + "/" + runtime.GOARCH + ")"
+
+// Client - HTTP клиент REST API сервера GophKeeper
+type Client struct {
+	baseURL string
+	token   string
+	// refreshToken - токен для продления сессии; saveTokens сохраняет новую пару после продления
+	refreshToken string
+	saveTokens   func(token string, refreshToken string) error
+	http         *http.Client
+	// stream - клиент без общего таймаута для передачи бинарного содержимого
+	stream *http.Client
+}
+
+// New - новый клиент для сервера baseURL с токеном авторизации token (может быть пустым).
+// Если задан tlsConfig, по умолчанию используется https.
+func New(baseURL string, token string, tlsConfig *tls.Config) *Client {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		if tlsConfig != nil {
+			baseURL = "https://" + baseURL
+		} else {
+			baseURL = "http://" + baseURL
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		stream:  &http.Client{Transport: transport},
+	}
+}
+
+// TLSConfig - настройки TLS клиента: caFile - CA сервера (по умолчанию системные),
+// certFile/keyFile - клиентский сертификат для mTLS, insecure - без проверки сертификата сервера
+func TLSConfig(caFile string, certFile string, keyFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && !insecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error while loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Token - текущий токен авторизации
+func (c *Client) Token() string {
+	return c.token
+}
+
+// RefreshToken - текущий refresh токен
+func (c *Client) RefreshToken() string {
+	return c.refreshToken
+}
+
+// UseRefreshToken - продление истекающего токена доступа перед запросами; save вызывается с новой парой токенов
+func (c *Client) UseRefreshToken(refreshToken string, save func(token string, refreshToken string) error) {
+	c.refreshToken = refreshToken
+	c.saveTokens = save
+}
+
+// Register - регистрация пользователя с почтой mail; pending - аккаунт нужно подтвердить кодом из письма (Verify)
+func (c *Client) Register(ctx context.Context, mail string) (pending bool, err error) {
+	resp, err := c.do(ctx, http.MethodPost, "/register", map[string]string{"mail": mail})
+	return resp.Pending, err
+}
+
+// Verify - подтверждение регистрации кодом из письма
+func (c *Client) Verify(ctx context.Context, mail string, code string) error {
+	_, err := c.do(ctx, http.MethodPost, "/verify", map[string]string{"mail": mail, "code": code})
+	return err
+}
+
+// RequestChallenge - запрос кода авторизации, сервер отправляет его на почту
+func (c *Client) RequestChallenge(ctx context.Context, mail string) error {
+	_, err := c.do(ctx, http.MethodGet, "/login", map[string]string{"mail": mail})
+	return err
+}
+
+// Login - подтверждение кода из письма, сохраняет полученный токен в клиенте.
+// totp - код второго фактора; если он нужен, но не передан, возвращается ErrTOTPRequired
+func (c *Client) Login(ctx context.Context, mail string, code string, totp string) error {
+	body := map[string]string{
+		"login":    mail,
+		"password": code,
+		"totp":     totp,
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/login", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if response, err := decode(resp); err != nil {
+		if response.TOTPRequired && totp == "" {
+			return ErrTOTPRequired
+		}
+		return err
+	}
+	return c.readTokens(resp)
+}
+
+// Record - изменяемая запись; Validate - необязательные данные для серверной проверки типа записи
+type Record struct {
+	Kind     string
+	Data     string
+	Metadata json.RawMessage
+	Validate json.RawMessage
+	// VaultID - хранилище новой записи (только для Add); 0 - основное личное хранилище
+	VaultID int64
+}
+
+// ConflictError - запись на сервере изменена после известной клиенту версии; Current - её текущее состояние
+type ConflictError struct {
+	Current structs.SecureData
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("record %d was changed on the server (current historyID %d)", e.Current.ID, e.Current.HistoryID)
+}
+
+// RateLimitError - сервер отклонил запрос из-за ограничения частоты или блокировки аккаунта
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("server error (status 429): %s", e.Message)
+}
+
+// QuotaError - изменение отклонено, так как превысило бы квоту Quota (structs.QuotaRecords и др.)
+type QuotaError struct {
+	Quota   string
+	Message string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("server error (status 413): %s", e.Message)
+}
+
+// Add - добавление новой записи
+func (c *Client) Add(ctx context.Context, record Record) (structs.Response, error) {
+	return c.update(ctx, "ADD", 0, 0, record)
+}
+
+// Update - изменение записи id версии historyID (0 - без проверки версии).
+// Если запись уже изменена, возвращается *ConflictError.
+func (c *Client) Update(ctx context.Context, id int64, historyID int64, record Record) (structs.Response, error) {
+	return c.update(ctx, "UPDATE", id, historyID, record)
+}
+
+// Delete - удаление записи id версии historyID (0 - без проверки версии)
+func (c *Client) Delete(ctx context.Context, id int64, historyID int64) (structs.Response, error) {
+	return c.update(ctx, "DELETE", id, historyID, Record{})
+}
+
+// Sync - получение страницы записей, изменённых после lastHistoryID
+func (c *Client) Sync(ctx context.Context, lastHistoryID int64, limit int) (structs.Response, error) {
+	body := struct {
+		Last  int64 `json:"lastHistoryID"`
+		Limit int   `json:"limit"`
+	}{
+		Last:  lastHistoryID,
+		Limit: limit,
+	}
+	return c.do(ctx, http.MethodPost, "/sync", body)
+}
+
+// SyncV2 - страница синхронизации по курсору (см. structs.SyncRequest)
+func (c *Client) SyncV2(ctx context.Context, request structs.SyncRequest) (structs.SyncPage, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/v2/sync", request)
+	if err != nil {
+		return structs.SyncPage{}, err
+	}
+	if resp.Sync == nil {
+		return structs.SyncPage{}, fmt.Errorf("sync page not found in response")
+	}
+	return *resp.Sync, nil
+}
+
+// Search - страница записей по запросу; следующая страница запрашивается с query.Cursor = NextCursor
+func (c *Client) Search(ctx context.Context, query structs.SearchQuery) (structs.Response, error) {
+	return c.do(ctx, http.MethodPost, "/search", query)
+}
+
+// Keys - параметры KDF и обёрнутый ключ хранилища; found=false, если хранилище ещё не создано
+func (c *Client) Keys(ctx context.Context) (keys structs.UserKeys, found bool, err error) {
+	resp, err := c.send(ctx, http.MethodGet, "/keys", nil)
+	if err != nil {
+		return keys, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return keys, false, nil
+	}
+
+	response, err := decode(resp)
+	if err != nil {
+		return keys, false, err
+	}
+	if response.Keys == nil {
+		return keys, false, fmt.Errorf("keys not found in response")
+	}
+	return *response.Keys, true, nil
+}
+
+// SetKeys - сохранение параметров KDF и обёрнутого ключа хранилища вместо версии keys.Version;
+// новая версия ключей
+func (c *Client) SetKeys(ctx context.Context, keys structs.UserKeys) (int64, error) {
+	resp, err := c.do(ctx, http.MethodPut, "/keys", keys)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Keys == nil {
+		return 0, fmt.Errorf("keys not found in response")
+	}
+	return resp.Keys.Version, nil
+}
+
+// Operation - одна операция пакетного изменения (Batch)
+type Operation struct {
+	// Type - ADD, UPDATE, DELETE или RESTORE
+	Type       string
+	ID         int64
+	HistoryID  int64
+	RevisionID int64
+	Record     Record
+}
+
+// Batch - применение операций в одной транзакции: либо все, либо ни одной.
+// Если одна из записей уже изменена, возвращается *ConflictError
+func (c *Client) Batch(ctx context.Context, ops []Operation) ([]structs.OperationResult, error) {
+	body := make([]operationBody, len(ops))
+	for i, op := range ops {
+		body[i] = newOperationBody(op)
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/update", body)
+	if err != nil && resp.Current != nil {
+		return nil, &ConflictError{Current: *resp.Current}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+func (c *Client) update(ctx context.Context, method string, id int64, historyID int64, record Record) (structs.Response, error) {
+	body := newOperationBody(Operation{
+		Type:      method,
+		ID:        id,
+		HistoryID: historyID,
+		Record:    record,
+	})
+	resp, err := c.do(ctx, http.MethodPost, "/update", body)
+	if err != nil && resp.Current != nil {
+		return resp, &ConflictError{Current: *resp.Current}
+	}
+	return resp, err
+}
+
+type operationBody struct {
+	ID         int64           `json:"ID,omitempty"`
+	Type       string          `json:"type"`
+	HistoryID  int64           `json:"historyID,omitempty"`
+	RevisionID int64           `json:"revisionID,omitempty"`
+	VaultID    int64           `json:"vaultID,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Data       string          `json:"data,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	Validate   json.RawMessage `json:"validate,omitempty"`
+}
+
+func newOperationBody(op Operation) operationBody {
+	return operationBody{
+		ID:         op.ID,
+		Type:       op.Type,
+		HistoryID:  op.HistoryID,
+		RevisionID: op.RevisionID,
+		VaultID:    op.Record.VaultID,
+		Kind:       op.Record.Kind,
+		Data:       op.Record.Data,
+		Metadata:   op.Record.Metadata,
+		Validate:   op.Record.Validate,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body any) (structs.Response, error) {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return structs.Response{}, err
+	}
+	defer resp.Body.Close()
+	return decode(resp)
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, body any) (*http.Response, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error while encoding JSON: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("error while creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	c.authorize(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while sending request: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	if c.token != "" {
+		req.AddCookie(&http.Cookie{Name: "Authorization", Value: c.token})
+	}
+}
+
+// decode - разбор ответа сервера; пустое тело допустимо (например, /sync без изменений)
+func decode(resp *http.Response) (structs.Response, error) {
+	var response structs.Response
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response, fmt.Errorf("error while reading response: %w", err)
+	}
+	if len(bytes.TrimSpace(raw)) != 0 {
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return response, fmt.Errorf("error while parsing response (status %d): %w", resp.StatusCode, err)
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return response, &RateLimitError{RetryAfter: time.Duration(seconds) * time.Second, Message: response.Error}
+	}
+	if resp.StatusCode == http.StatusRequestEntityTooLarge && response.QuotaExceeded != "" {
+		return response, &QuotaError{Quota: response.QuotaExceeded, Message: response.Error}
+	}
+	if resp.StatusCode != http.StatusOK {
+		if response.Error != "" {
+			return response, fmt.Errorf("server error (status %d): %s", resp.StatusCode, response.Error)
+		}
+		return response, fmt.Errorf("server error (status %d)", resp.StatusCode)
+	}
+	return response, nil
+}
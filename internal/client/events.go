@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watch - вызов fn с historyID каждого изменения записей после last до отмены ctx или ошибки fn;
+// если изменения после last уже были, fn сразу вызывается с последним из них. После обрыва потока
+// (в том числе по истечении токена) клиент подключается заново и продолжает с последнего события
+func (c *Client) Watch(ctx context.Context, last int64, fn func(historyID int64) error) error {
+	retry := time.Second
+	for {
+		connected, err := c.watch(ctx, &last, &retry, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// ошибки fn и отказ сервера (например, отозванная сессия) повтором не исправить
+		if err != nil && connected {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// watch - чтение одного потока /events; connected=false, если поток не удалось открыть из-за сети
+func (c *Client) watch(ctx context.Context, last *int64, retry *time.Duration, fn func(historyID int64) error) (connected bool, err error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/events", nil)
+	if err != nil {
+		return true, fmt.Errorf("error while creating request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(*last, 10))
+	c.authorize(req)
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error while sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, err := decode(resp)
+		return true, err
+	}
+
+	// поля события до пустой строки; комментарии (пульс) пропускаются
+	var id, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if id != "" && data != "" {
+				historyID, err := strconv.ParseInt(id, 10, 64)
+				if err != nil {
+					return true, fmt.Errorf("error while parsing event id: %w", err)
+				}
+				*last = historyID
+				if err := fn(historyID); err != nil {
+					return true, err
+				}
+			}
+			id, data = "", ""
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			data = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Revisions - ревизии записи id от новых к старым
+func (c *Client) Revisions(ctx context.Context, id int64) ([]structs.Revision, error) {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/records/%d/revisions", id), nil)
+	return resp.Revisions, err
+}
+
+// Revision - ревизия historyID записи id
+func (c *Client) Revision(ctx context.Context, id int64, historyID int64) (structs.Revision, error) {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/records/%d/revisions/%d", id, historyID), nil)
+	if err != nil {
+		return structs.Revision{}, err
+	}
+	if resp.Revision == nil {
+		return structs.Revision{}, fmt.Errorf("revision not found in response")
+	}
+	return *resp.Revision, nil
+}
+
+// Restore - новая ревизия записи id с содержимым ревизии revisionID; historyID - как в Update
+func (c *Client) Restore(ctx context.Context, id int64, historyID int64, revisionID int64) (structs.Response, error) {
+	body := struct {
+		ID         int64  `json:"ID"`
+		Type       string `json:"type"`
+		HistoryID  int64  `json:"historyID,omitempty"`
+		RevisionID int64  `json:"revisionID"`
+	}{
+		ID:         id,
+		Type:       "RESTORE",
+		HistoryID:  historyID,
+		RevisionID: revisionID,
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/update", body)
+	if err != nil && resp.Current != nil {
+		return resp, &ConflictError{Current: *resp.Current}
+	}
+	return resp, err
+}
+
+// HistoryRetention - число хранимых ревизий каждой записи
+func (c *Client) HistoryRetention(ctx context.Context) (structs.HistoryRetention, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/history/retention", nil)
+	if err != nil {
+		return structs.HistoryRetention{}, err
+	}
+	if resp.HistoryRetention == nil {
+		return structs.HistoryRetention{}, fmt.Errorf("history retention not found in response")
+	}
+	return *resp.HistoryRetention, nil
+}
+
+// SetHistoryRetention - изменение числа хранимых ревизий; 0 - значение сервера
+func (c *Client) SetHistoryRetention(ctx context.Context, limit int) error {
+	body := struct {
+		Limit int `json:"limit"`
+	}{
+		Limit: limit,
+	}
+	_, err := c.do(ctx, http.MethodPut, "/history/retention", body)
+	return err
+}
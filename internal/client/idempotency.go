@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey - запросы с этим контекстом отправляются с заголовком Idempotency-Key.
+// Повтор изменения после обрыва связи с тем же ключом не выполняется дважды: сервер вернёт первый ответ
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// NewIdempotencyKey - случайный ключ для одного логического изменения
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const (
+	profileFile = "profile.json"
+	stateFile   = "state.json"
+	uploadsDir  = "uploads"
+)
+
+// Profile - настройки профиля клиента
+type Profile struct {
+	Server string `json:"server"`
+	Login  string `json:"login"`
+	Token  string `json:"token"`
+	// RefreshToken - токен продления сессии; Token обновляется автоматически
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	CAFile   string `json:"caFile,omitempty"`
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// State - локальная копия записей, полученных через /v2/sync
+type State struct {
+	LastHistoryID int64                        `json:"lastHistoryID"`
+	Records       map[int64]structs.SecureData `json:"records"`
+	// Cursor - курсор следующей синхронизации; пустой - нужна синхронизация снимком
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// ProfileDir - директория профиля name внутри пользовательской директории конфигурации
+func ProfileDir(name string) (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error while getting config dir: %w", err)
+	}
+	return filepath.Join(base, "gophkeeper", name), nil
+}
+
+// LoadProfile - чтение профиля; отсутствующий файл не является ошибкой
+func LoadProfile(dir string) (Profile, error) {
+	var p Profile
+	err := readJSON(filepath.Join(dir, profileFile), &p)
+	return p, err
+}
+
+// SaveProfile - сохранение профиля
+func SaveProfile(dir string, p Profile) error {
+	return writeJSON(dir, profileFile, p)
+}
+
+// LoadState - чтение локального состояния синхронизации
+func LoadState(dir string) (State, error) {
+	s := State{Records: make(map[int64]structs.SecureData)}
+	if err := readJSON(filepath.Join(dir, stateFile), &s); err != nil {
+		return s, err
+	}
+	if s.Records == nil {
+		s.Records = make(map[int64]structs.SecureData)
+	}
+	return s, nil
+}
+
+// SaveState - сохранение локального состояния синхронизации
+func SaveState(dir string, s State) error {
+	return writeJSON(dir, stateFile, s)
+}
+
+// Apply - применение страницы изменений к локальному состоянию
+func (s *State) Apply(data []structs.SecureData) {
+	for _, d := range data {
+		s.Records[d.ID] = d
+		s.LastHistoryID = max(s.LastHistoryID, d.HistoryID, d.SyncID)
+	}
+}
+
+// ApplyPage - применение страницы синхронизации v2: удалённые записи убираются из копии
+func (s *State) ApplyPage(page structs.SyncPage) {
+	s.Apply(page.Records)
+	for _, t := range page.Tombstones {
+		delete(s.Records, t.ID)
+		s.LastHistoryID = max(s.LastHistoryID, t.HistoryID)
+	}
+	s.Cursor = page.Cursor
+}
+
+// Active - активные записи, отсортированные по ID
+func (s *State) Active() []structs.SecureData {
+	result := make([]structs.SecureData, 0, len(s.Records))
+	for _, d := range s.Records {
+		if d.IsActive {
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Upload - незавершённая загрузка бинарного содержимого записи.
+// Зашифрованная копия файла хранится до завершения, чтобы докачка отправляла те же байты.
+type Upload struct {
+	BlobID    int64  `json:"blobID"`
+	Source    string `json:"source"`
+	Encrypted string `json:"encrypted"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// UploadsDir - директория незавершённых загрузок профиля
+func UploadsDir(dir string) string {
+	return filepath.Join(dir, uploadsDir)
+}
+
+// LoadUpload - незавершённая загрузка для записи secureDataID; found=false, если её нет
+func LoadUpload(dir string, secureDataID int64) (u Upload, found bool, err error) {
+	err = readJSON(uploadFile(dir, secureDataID), &u)
+	return u, u.BlobID != 0, err
+}
+
+// SaveUpload - сохранение состояния загрузки
+func SaveUpload(dir string, secureDataID int64, u Upload) error {
+	return writeJSON(UploadsDir(dir), filepath.Base(uploadFile(dir, secureDataID)), u)
+}
+
+// RemoveUpload - удаление состояния загрузки и зашифрованной копии файла
+func RemoveUpload(dir string, secureDataID int64, u Upload) error {
+	if u.Encrypted != "" {
+		if err := os.Remove(u.Encrypted); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Remove(uploadFile(dir, secureDataID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func uploadFile(dir string, secureDataID int64) string {
+	return filepath.Join(UploadsDir(dir), fmt.Sprintf("%d.json", secureDataID))
+}
+
+func readJSON(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error while reading %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("error while parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON - атомарная запись файла с правами только для владельца (файл содержит токен)
+func writeJSON(dir string, name string, v any) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("error while creating profile dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while encoding %s: %w", name, err)
+	}
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return fmt.Errorf("error while writing %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error while writing %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error while writing %s: %w", name, err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
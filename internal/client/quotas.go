@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Usage - объём записей пользователя и его квоты
+func (c *Client) Usage(ctx context.Context) (structs.Usage, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/usage", nil)
+	if err != nil {
+		return structs.Usage{}, err
+	}
+	if resp.Usage == nil {
+		return structs.Usage{}, fmt.Errorf("server response has no usage")
+	}
+	return *resp.Usage, nil
+}
+
+// AdminSetQuota - квоты пользователя username; nil - возврат к значениям по умолчанию
+func (c *Client) AdminSetQuota(ctx context.Context, username string, quota *structs.Quota) (structs.Usage, error) {
+	method := http.MethodDelete
+	var body any
+	if quota != nil {
+		method, body = http.MethodPut, quota
+	}
+	resp, err := c.do(ctx, method, "/admin/users/"+url.PathEscape(username)+"/quota", body)
+	if err != nil {
+		return structs.Usage{}, err
+	}
+	if resp.Usage == nil {
+		return structs.Usage{}, fmt.Errorf("server response has no usage")
+	}
+	return *resp.Usage, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// refreshBefore - токен доступа продлевается заранее, чтобы не истечь во время запроса
+const refreshBefore = time.Minute
+
+// Refresh - новая пара токенов по refresh токену
+func (c *Client) Refresh(ctx context.Context) error {
+	if c.refreshToken == "" {
+		return fmt.Errorf("not logged in")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/refresh", nil)
+	if err != nil {
+		return fmt.Errorf("error while creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.AddCookie(&http.Cookie{Name: "Refresh", Value: c.refreshToken})
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := decode(resp); err != nil {
+		if resp.StatusCode == http.StatusUnauthorized {
+			c.token, c.refreshToken = "", ""
+			c.save()
+			return fmt.Errorf("session expired, log in again: %w", err)
+		}
+		return err
+	}
+	if err := c.readTokens(resp); err != nil {
+		return err
+	}
+	return c.save()
+}
+
+// Logout - отзыв текущей сессии на сервере
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/logout", nil)
+	c.token, c.refreshToken = "", ""
+	return err
+}
+
+// Sessions - активные сессии пользователя
+func (c *Client) Sessions(ctx context.Context) ([]structs.Session, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/sessions", nil)
+	return resp.Sessions, err
+}
+
+// RevokeSession - отзыв сессии id
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id), nil)
+	return err
+}
+
+// ensureToken - продление токена доступа, если он истёк или скоро истечёт
+func (c *Client) ensureToken(ctx context.Context) error {
+	if c.refreshToken == "" || time.Until(tokenExpiry(c.token)) > refreshBefore {
+		return nil
+	}
+	return c.Refresh(ctx)
+}
+
+// readTokens - токены из кук ответа /login и /refresh
+func (c *Client) readTokens(resp *http.Response) error {
+	token := ""
+	for _, cookie := range resp.Cookies() {
+		switch cookie.Name {
+		case "Authorization":
+			token = cookie.Value
+		case "Refresh":
+			c.refreshToken = cookie.Value
+		}
+	}
+	if token == "" {
+		return fmt.Errorf("authorization cookie not found in response")
+	}
+	c.token = token
+	return nil
+}
+
+func (c *Client) save() error {
+	if c.saveTokens == nil {
+		return nil
+	}
+	return c.saveTokens(c.token, c.refreshToken)
+}
+
+// tokenExpiry - срок действия JWT без проверки подписи (её проверяет сервер); нулевое время, если токен не разобран
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// PublicKey - открытый ключ пользователя mail для шифрования ключа общей записи
+func (c *Client) PublicKey(ctx context.Context, mail string) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/keys/public?mail="+url.QueryEscape(mail), nil)
+	return resp.PublicKey, err
+}
+
+// Share - открытие доступа к записи id (или изменение права доступа)
+func (c *Client) Share(ctx context.Context, id int64, request structs.ShareRequest) (structs.Response, error) {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/records/%d/shares", id), request)
+}
+
+// Shares - открытые и отозванные доступы к записи id
+func (c *Client) Shares(ctx context.Context, id int64) ([]structs.Share, error) {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/records/%d/shares", id), nil)
+	return resp.Shares, err
+}
+
+// RevokeShare - отзыв доступа пользователя recipient к записи id
+func (c *Client) RevokeShare(ctx context.Context, id int64, recipient string) (structs.Response, error) {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/records/%d/shares/%s", id, url.PathEscape(recipient)), nil)
+}
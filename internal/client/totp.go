@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// ErrTOTPRequired - у пользователя подключён второй фактор; Login нужно повторить с кодом TOTP
+var ErrTOTPRequired = errors.New("totp code required")
+
+// TOTP - состояние второго фактора
+func (c *Client) TOTP(ctx context.Context) (structs.TOTPStatus, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/totp", nil)
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	return totpFrom(resp)
+}
+
+// EnrollTOTP - новый секрет и otpauth URI для приложения-аутентификатора
+func (c *Client) EnrollTOTP(ctx context.Context) (structs.TOTPStatus, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/totp/enroll", nil)
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	return totpFrom(resp)
+}
+
+// ConfirmTOTP - включение второго фактора первым кодом из приложения; возвращает резервные коды
+func (c *Client) ConfirmTOTP(ctx context.Context, code string) (structs.TOTPStatus, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/totp/confirm", totpBody(code))
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	return totpFrom(resp)
+}
+
+// DisableTOTP - отключение второго фактора по коду TOTP или резервному коду
+func (c *Client) DisableTOTP(ctx context.Context, code string) error {
+	_, err := c.do(ctx, http.MethodPost, "/totp/disable", totpBody(code))
+	return err
+}
+
+// RegenerateBackupCodes - новый набор резервных кодов взамен старого
+func (c *Client) RegenerateBackupCodes(ctx context.Context, code string) (structs.TOTPStatus, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/totp/backup-codes", totpBody(code))
+	if err != nil {
+		return structs.TOTPStatus{}, err
+	}
+	return totpFrom(resp)
+}
+
+func totpBody(code string) map[string]string {
+	return map[string]string{"code": code}
+}
+
+func totpFrom(resp structs.Response) (structs.TOTPStatus, error) {
+	if resp.TOTP == nil {
+		return structs.TOTPStatus{}, fmt.Errorf("totp not found in response")
+	}
+	return *resp.TOTP, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Organizations - организации пользователя с его ролью и ключом организации
+func (c *Client) Organizations(ctx context.Context) ([]structs.Organization, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/orgs", nil)
+	return resp.Organizations, err
+}
+
+// CreateOrganization - новая организация; wrappedKey - ключ организации, зашифрованный открытым ключом пользователя
+func (c *Client) CreateOrganization(ctx context.Context, request structs.OrganizationRequest) (structs.Organization, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/orgs", request)
+	if err != nil {
+		return structs.Organization{}, err
+	}
+	if resp.Organization == nil {
+		return structs.Organization{}, fmt.Errorf("server response has no organization")
+	}
+	return *resp.Organization, nil
+}
+
+// Members - участники организации orgID
+func (c *Client) Members(ctx context.Context, orgID int64) ([]structs.Member, error) {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/orgs/%d/members", orgID), nil)
+	return resp.Members, err
+}
+
+// SetMember - добавление участника username в организацию orgID или изменение его роли
+func (c *Client) SetMember(ctx context.Context, orgID int64, username string, request structs.MemberRequest) (structs.Response, error) {
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/orgs/%d/members/%s", orgID, url.PathEscape(username)), request)
+}
+
+// RemoveMember - исключение участника username из организации orgID (или выход из неё)
+func (c *Client) RemoveMember(ctx context.Context, orgID int64, username string) (structs.Response, error) {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/orgs/%d/members/%s", orgID, url.PathEscape(username)), nil)
+}
+
+// Vaults - личные хранилища пользователя и хранилища его организаций
+func (c *Client) Vaults(ctx context.Context) ([]structs.Vault, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/vaults", nil)
+	return resp.Vaults, err
+}
+
+// CreateVault - новое хранилище; orgID = 0 - личное
+func (c *Client) CreateVault(ctx context.Context, request structs.VaultRequest) (structs.Vault, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/vaults", request)
+	if err != nil {
+		return structs.Vault{}, err
+	}
+	if resp.Vault == nil {
+		return structs.Vault{}, fmt.Errorf("server response has no vault")
+	}
+	return *resp.Vault, nil
+}
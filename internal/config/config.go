@@ -4,15 +4,58 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strconv"
 	"time"
 )
 
 var (
 	EndpointServer      = flag.String("a", "0.0.0.0:8085", "endpoint")
+	EndpointGRPC        = flag.String("g", "", "gRPC endpoint (disabled if empty)")
+	MetricsEnabled      = flag.Bool("metrics", true, "expose Prometheus metrics at /metrics")
+	EndpointMetrics     = flag.String("metrics-address", "", "separate endpoint for /metrics (served by the REST endpoint if empty)")
 	DatabaseDSN         = flag.String("d", "", "database_DSN")
+	StorageType         = flag.String("storage", "postgres", "storage: postgres or memory (data is lost on restart)")
 	RegistrationEnabled = flag.Bool("e", true, "enables registration page")
+	Admins              = flag.String("admins", "", "comma-separated mails of users with access to the admin API")
+	VerifyEmail         = flag.Bool("verify-email", true, "activate new accounts only after the mail address is confirmed")
+	VerificationTTL     = flag.Duration("verification-ttl", 24*time.Hour, "time to confirm a registration before it is discarded")
+	PublicURL           = flag.String("public-url", "", "external base URL of the REST API for links in mails (no link if empty)")
 	CleanupTime         = flag.Duration("t", time.Minute, "cache cleanup time")
 	jwtKeyString        = flag.String("j", "default", "JWT key string")
+	AccessTokenTTL      = flag.Duration("access-token-ttl", 15*time.Minute, "access token lifetime")
+	RefreshTokenTTL     = flag.Duration("refresh-token-ttl", 30*24*time.Hour, "refresh token lifetime (session expires if not refreshed)")
+	IdempotencyTTL      = flag.Duration("idempotency-retention", 24*time.Hour, "how long responses to requests with Idempotency-Key are kept for replay")
+	EventsHeartbeat     = flag.Duration("events-heartbeat", 15*time.Second, "interval of heartbeat comments in the /events stream")
+	BatchMaxSize        = flag.Int("batch-max-size", 100, "max operations in one /update batch")
+	BlobMaxSize         = flag.Int64("blob-max-size", 1<<30, "max size of a binary secret in bytes")
+	BlobChunkSize       = flag.Int("blob-chunk-size", 1<<20, "size of a stored binary secret chunk in bytes")
+	BlobUploadTTL       = flag.Duration("blob-upload-ttl", 24*time.Hour, "how long an unfinished binary secret upload can be resumed and counts toward quotas (0 - unlimited)")
+	HistoryRetention    = flag.Int("history-retention", 50, "revisions kept per record unless a user sets a lower limit (0 - unlimited)")
+	QuotaRecords        = flag.Int64("quota-records", 10000, "max active records per user unless an administrator sets a user quota (0 - unlimited)")
+	QuotaBytes          = flag.Int64("quota-bytes", 64<<20, "max total size of active records per user in bytes (0 - unlimited)")
+	QuotaRecordSize     = flag.Int64("quota-record-size", 1<<20, "max size of one record (data and metadata) in bytes (0 - unlimited)")
+	TLSCertFile         = flag.String("tls-cert", "", "TLS certificate file")
+	TLSKeyFile          = flag.String("tls-key", "", "TLS private key file")
+	TLSClientCAFile     = flag.String("tls-client-ca", "", "CA bundle for client certificate verification (mTLS)")
+	TLSSelfSigned       = flag.Bool("tls-self-signed", false, "generate a self-signed certificate on startup (development only)")
+	TLSReloadInterval   = flag.Duration("tls-reload-interval", time.Minute, "how often certificate files are checked for changes")
+	TOTPIssuer          = flag.String("totp-issuer", "GophKeeper", "issuer shown in authenticator apps")
+	ChallengeTTL        = flag.Duration("challenge-ttl", 5*time.Minute, "authorization code lifetime")
+	ChallengeAttempts   = flag.Int("challenge-attempts", 5, "invalid codes after which an authorization code is burned (0 - unlimited)")
+	RateLimitIP         = flag.Int("rate-limit-ip", 30, "login and register requests per IP per rate limit period (0 - unlimited)")
+	RateLimitAccount    = flag.Int("rate-limit-account", 10, "login and register requests per account per rate limit period (0 - unlimited)")
+	RateLimitPeriod     = flag.Duration("rate-limit-period", time.Minute, "rate limit period")
+	LockoutThreshold    = flag.Int("lockout-threshold", 5, "failed logins after which an account is temporarily locked (0 - never)")
+	LockoutBase         = flag.Duration("lockout-base", 30*time.Second, "first lockout duration, doubled on each further failure")
+	LockoutMax          = flag.Duration("lockout-max", time.Hour, "max lockout duration")
+	MailTransport       = flag.String("mail-transport", "", "mail transport: smtp, file or stdout (smtp if SMTP host is set, stdout otherwise)")
+	MailFrom            = flag.String("mail-from", "", "sender address")
+	MailFile            = flag.String("mail-file", "mail.log", "file for the file mail transport")
+	SMTPHost            = flag.String("smtp-host", "", "SMTP server host")
+	SMTPPort            = flag.Int("smtp-port", 587, "SMTP server port")
+	SMTPUser            = flag.String("smtp-user", "", "SMTP user")
+	SMTPPassword        = flag.String("smtp-password", "", "SMTP password (prefer SMTP_PASSWORD env)")
+	SMTPTLS             = flag.String("smtp-tls", "starttls", "SMTP TLS mode: none, starttls or tls")
 	JWTKey              []byte
 )
 
@@ -25,11 +68,136 @@ func ConfigServer() {
 	if found {
 		DatabaseDSN = &dsn
 	}
+	lookupEnvString("STORAGE", &StorageType)
+	lookupEnvString("ADMINS", &Admins)
+	lookupEnvString("GRPC_ADDRESS", &EndpointGRPC)
+	lookupEnvBool("METRICS", &MetricsEnabled)
+	lookupEnvString("METRICS_ADDRESS", &EndpointMetrics)
+	lookupEnvBool("VERIFY_EMAIL", &VerifyEmail)
+	lookupEnvDuration("VERIFICATION_TTL", &VerificationTTL)
+	lookupEnvString("PUBLIC_URL", &PublicURL)
+	lookupEnvInt("HISTORY_RETENTION", &HistoryRetention)
+	lookupEnvInt64("QUOTA_RECORDS", &QuotaRecords)
+	lookupEnvInt64("QUOTA_BYTES", &QuotaBytes)
+	lookupEnvInt64("QUOTA_RECORD_SIZE", &QuotaRecordSize)
+	lookupEnvInt("BATCH_MAX_SIZE", &BatchMaxSize)
+	lookupEnvDuration("BLOB_UPLOAD_TTL", &BlobUploadTTL)
+	lookupEnvDuration("EVENTS_HEARTBEAT", &EventsHeartbeat)
+	lookupEnvDuration("IDEMPOTENCY_RETENTION", &IdempotencyTTL)
+	lookupEnvString("TLS_CERT", &TLSCertFile)
+	lookupEnvString("TLS_KEY", &TLSKeyFile)
+	lookupEnvString("TLS_CLIENT_CA", &TLSClientCAFile)
+	lookupEnvBool("TLS_SELF_SIGNED", &TLSSelfSigned)
+	lookupEnvInt("CHALLENGE_ATTEMPTS", &ChallengeAttempts)
+	lookupEnvInt("RATE_LIMIT_IP", &RateLimitIP)
+	lookupEnvInt("RATE_LIMIT_ACCOUNT", &RateLimitAccount)
+	lookupEnvDuration("RATE_LIMIT_PERIOD", &RateLimitPeriod)
+	lookupEnvInt("LOCKOUT_THRESHOLD", &LockoutThreshold)
+	lookupEnvDuration("LOCKOUT_BASE", &LockoutBase)
+	lookupEnvDuration("LOCKOUT_MAX", &LockoutMax)
+	lookupEnvString("MAIL_TRANSPORT", &MailTransport)
+	lookupEnvString("MAIL_FROM", &MailFrom)
+	lookupEnvString("SMTP_HOST", &SMTPHost)
+	lookupEnvInt("SMTP_PORT", &SMTPPort)
+	lookupEnvString("SMTP_USER", &SMTPUser)
+	lookupEnvString("SMTP_PASSWORD", &SMTPPassword)
+	lookupEnvString("SMTP_TLS", &SMTPTLS)
 	flag.Parse()
 	JWTKey = []byte(*jwtKeyString)
 
+	if !*TLSSelfSigned && (*TLSCertFile == "") != (*TLSKeyFile == "") {
+		log.Fatalln("both TLS certificate and key must be set")
+	}
+	if *IdempotencyTTL <= 0 {
+		log.Fatalln("idempotency retention must be positive")
+	}
+	if *EventsHeartbeat <= 0 {
+		log.Fatalln("events heartbeat must be positive")
+	}
+	if *BatchMaxSize < 1 {
+		log.Fatalln("batch max size must be positive")
+	}
+	if *HistoryRetention < 0 {
+		log.Fatalln("history retention must not be negative")
+	}
+	if *QuotaRecords < 0 || *QuotaBytes < 0 || *QuotaRecordSize < 0 {
+		log.Fatalln("quotas must not be negative")
+	}
+	if *BlobUploadTTL < 0 {
+		log.Fatalln("blob upload TTL must not be negative")
+	}
+	if *VerificationTTL <= 0 {
+		log.Fatalln("verification TTL must be positive")
+	}
+	if *RateLimitPeriod <= 0 || *LockoutBase <= 0 || *LockoutMax < *LockoutBase {
+		log.Fatalln("rate limit period and lockout durations must be positive, lockout max not less than base")
+	}
+	if *TLSClientCAFile != "" && !TLSEnabled() {
+		log.Fatalln("client certificate verification requires TLS")
+	}
+
 	log.Println("Server configuration:",
 		"\nEndpointServer:", *EndpointServer,
+		"\nEndpointGRPC:", *EndpointGRPC,
+		"\nMetrics enabled:", *MetricsEnabled, *EndpointMetrics,
+		"\nStorage:", *StorageType,
 		"\nDatabaseDSN:", *DatabaseDSN,
-		"\nRegistration Page enabled:", *RegistrationEnabled)
+		"\nRegistration Page enabled:", *RegistrationEnabled,
+		"\nAdmins:", *Admins,
+		"\nQuotas (records/bytes/record size/revisions):", *QuotaRecords, *QuotaBytes, *QuotaRecordSize, *HistoryRetention,
+		"\nTLS enabled:", TLSEnabled(),
+		"\nRate limits (IP/account per period):", *RateLimitIP, *RateLimitAccount, *RateLimitPeriod,
+		"\nmTLS enabled:", *TLSClientCAFile != "",
+		"\nSMTP:", *SMTPHost, *SMTPPort, *SMTPTLS)
+}
+
+// TLSEnabled - сервер принимает только TLS соединения
+func TLSEnabled() bool {
+	return *TLSSelfSigned || *TLSCertFile != ""
+}
+
+func lookupEnvString(key string, target **string) {
+	if value, found := os.LookupEnv(key); found {
+		*target = &value
+	}
+}
+
+func lookupEnvInt(key string, target **int) {
+	if value, found := os.LookupEnv(key); found {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("%s: %v\n", key, err)
+		}
+		*target = &parsed
+	}
+}
+
+func lookupEnvInt64(key string, target **int64) {
+	if value, found := os.LookupEnv(key); found {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Fatalf("%s: %v\n", key, err)
+		}
+		*target = &parsed
+	}
+}
+
+func lookupEnvBool(key string, target **bool) {
+	if value, found := os.LookupEnv(key); found {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("%s: %v\n", key, err)
+		}
+		*target = &parsed
+	}
+}
+
+func lookupEnvDuration(key string, target **time.Duration) {
+	if value, found := os.LookupEnv(key); found {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("%s: %v\n", key, err)
+		}
+		*target = &parsed
+	}
 }
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// userSelect - сведения о пользователях для административного API (поля structs.UserInfo по порядку)
+const userSelect = `
+	SELECT u.id, u.username, COALESCE(u.created_at, NOW()), u.verification_hash IS NOT NULL, u.disabled_at,
+		(SELECT COUNT(*) FROM public.secure_data d WHERE d.user_id = u.id AND d.is_active),
+		(SELECT COUNT(*) FROM public.sessions s WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW()),
+		(SELECT MAX(s.last_seen) FROM public.sessions s WHERE s.user_id = u.id)
+	FROM public.users u
+	`
+
+// orgSuccessor - перенос записи организации удаляемого пользователя
+type orgSuccessor struct {
+	orgID  int64
+	userID int64
+}
+
+func (p *Postgres) SelectUsers(ctx context.Context, query string, afterID int64, limit int) ([]structs.UserInfo, error) {
+	sql := userSelect +
+	`
+	WHERE u.id > $2 AND strpos(lower(u.username), lower($1)) > 0
+	ORDER BY u.id
+	LIMIT $3;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, sql, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.UserInfo])
+}
+
+func (p *Postgres) SelectUser(ctx context.Context, username string) (structs.UserInfo, error) {
+	query := userSelect +
+	`
+	WHERE u.username = $1;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username)
+	if err != nil {
+		return structs.UserInfo{}, err
+	}
+
+	user, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[structs.UserInfo])
+	return user, notFound(err)
+}
+
+func (p *Postgres) SetUserDisabled(ctx context.Context, username string, disabled bool) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	UPDATE public.users
+	SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) ELSE NULL END
+	WHERE username = $1
+	RETURNING id;
+	`
+
+	var userID int64
+	if err := p.conn(ctx).QueryRow(ctx, query, username, disabled).Scan(&userID); err != nil {
+		return notFound(err)
+	}
+	if disabled {
+		if _, err := p.revokeSessions(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return p.CommitTransaction(ctx)
+}
+
+func (p *Postgres) RevokeSessions(ctx context.Context, username string) (int64, error) {
+	var userID int64
+	err := p.conn(ctx).QueryRow(ctx, `SELECT id FROM public.users WHERE username = $1;`, username).Scan(&userID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	return p.revokeSessions(ctx, userID)
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, username string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.lockChanges(ctx, username); err != nil {
+		return err
+	}
+	var userID int64
+	err = p.conn(ctx).QueryRow(ctx, `SELECT id FROM public.users WHERE username = $1 FOR UPDATE;`, username).Scan(&userID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	successors, dropped, err := p.orgSuccessors(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// записи организаций, остающихся без пользователя, переходят к преемнику вместе с историей и файлами
+	for _, s := range successors {
+		orgRecords := `SELECT d.id FROM public.secure_data d JOIN public.vaults v ON v.id = d.vault_id WHERE v.org_id = $2`
+		query :=
+		`
+		UPDATE public.history SET user_id = $3
+		WHERE user_id = $1 AND secure_data_id IN (` + orgRecords + `);
+		`
+		if _, err := p.conn(ctx).Exec(ctx, query, userID, s.orgID, s.userID); err != nil {
+			return err
+		}
+		query =
+		`
+		UPDATE public.blobs SET user_id = $3
+		WHERE user_id = $1 AND secure_data_id IN (` + orgRecords + `);
+		`
+		if _, err := p.conn(ctx).Exec(ctx, query, userID, s.orgID, s.userID); err != nil {
+			return err
+		}
+		query =
+		`
+		UPDATE public.secure_data SET user_id = $3
+		WHERE user_id = $1 AND id IN (` + orgRecords + `);
+		`
+		if _, err := p.conn(ctx).Exec(ctx, query, userID, s.orgID, s.userID); err != nil {
+			return err
+		}
+	}
+
+	query :=
+	`
+	SELECT id FROM public.secure_data
+	WHERE user_id = $1 AND ` + personalVault + `
+		OR vault_id IN (SELECT id FROM public.vaults WHERE org_id = ANY($2));
+	`
+	rows, err := p.conn(ctx).Query(ctx, query, userID, dropped)
+	if err != nil {
+		return err
+	}
+	records, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		`DELETE FROM public.blob_chunks WHERE blob_id IN (SELECT id FROM public.blobs WHERE secure_data_id = ANY($1));`,
+		`DELETE FROM public.blobs WHERE secure_data_id = ANY($1);`,
+		`DELETE FROM public.history WHERE secure_data_id = ANY($1);`,
+		`DELETE FROM public.shares WHERE secure_data_id = ANY($1);`,
+		`DELETE FROM public.secure_data WHERE id = ANY($1);`,
+	}
+	for _, query := range queries {
+		if _, err := p.conn(ctx).Exec(ctx, query, records); err != nil {
+			return err
+		}
+	}
+	queries = []string{
+		`DELETE FROM public.vaults WHERE user_id = $1 OR org_id = ANY($2);`,
+		`DELETE FROM public.org_members WHERE user_id = $1 OR org_id = ANY($2);`,
+		`DELETE FROM public.organizations WHERE id = ANY($2);`,
+	}
+	for _, query := range queries {
+		if _, err := p.conn(ctx).Exec(ctx, query, userID, dropped); err != nil {
+			return err
+		}
+	}
+	queries = []string{
+		`DELETE FROM public.shares WHERE recipient_id = $1;`,
+		`DELETE FROM public.sessions WHERE user_id = $1;`,
+		`DELETE FROM public.user_keys WHERE user_id = $1;`,
+		`DELETE FROM public.totp_backup_codes WHERE user_id = $1;`,
+		`DELETE FROM public.user_totp WHERE user_id = $1;`,
+		`DELETE FROM public.idempotency_keys WHERE user_id = $1;`,
+		`DELETE FROM public.user_quotas WHERE user_id = $1;`,
+		`DELETE FROM public.audit_events WHERE user_id = $1;`,
+		`DELETE FROM public.users WHERE id = $1;`,
+	}
+	for _, query := range queries {
+		if _, err := p.conn(ctx).Exec(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return p.CommitTransaction(ctx)
+}
+
+// orgSuccessors - организации пользователя userID с другими участниками и преемник в каждой (старейший
+// владелец, иначе старейший участник) и организации, где других участников нет. ErrForbidden, если
+// пользователь - единственный владелец организации с другими участниками
+func (p *Postgres) orgSuccessors(ctx context.Context, userID int64) ([]orgSuccessor, []int64, error) {
+	query :=
+	`
+	SELECT o.id, m.role = $2
+	FROM public.organizations o
+	JOIN public.org_members m ON m.org_id = o.id
+	WHERE m.user_id = $1 AND m.removed_at IS NULL
+	ORDER BY o.id
+	FOR UPDATE OF o;
+	`
+
+	type membership struct {
+		orgID int64
+		owner bool
+	}
+	rows, err := p.conn(ctx).Query(ctx, query, userID, structs.RoleOwner)
+	if err != nil {
+		return nil, nil, err
+	}
+	memberships, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (membership, error) {
+		var m membership
+		err := row.Scan(&m.orgID, &m.owner)
+		return m, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var successors []orgSuccessor
+	dropped := []int64{}
+	for _, m := range memberships {
+		query =
+		`
+		SELECT user_id
+		FROM public.org_members
+		WHERE org_id = $1 AND user_id <> $2 AND removed_at IS NULL
+		ORDER BY role = $3 DESC, created_at, user_id
+		LIMIT 1;
+		`
+		var successor int64
+		err := p.conn(ctx).QueryRow(ctx, query, m.orgID, userID, structs.RoleOwner).Scan(&successor)
+		if errors.Is(err, pgx.ErrNoRows) {
+			dropped = append(dropped, m.orgID)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if m.owner {
+			if err := p.checkOwners(ctx, m.orgID); err != nil {
+				return nil, nil, err
+			}
+		}
+		successors = append(successors, orgSuccessor{orgID: m.orgID, userID: successor})
+	}
+	return successors, dropped, nil
+}
+
+// revokeSessions - отзыв активных сессий пользователя userID
+func (p *Postgres) revokeSessions(ctx context.Context, userID int64) (int64, error) {
+	query :=
+	`
+	UPDATE public.sessions
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW();
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// auditSelect - события журнала аудита (поля structs.AuditEvent по порядку)
+const auditSelect = `
+	SELECT e.id, u.username, e.event, e.action, e.success, e.details, e.ip, e.user_agent, e.created_at
+	FROM public.audit_events e
+	JOIN public.users u ON u.id = e.user_id
+	`
+
+func (p *Postgres) AddAuditEvent(ctx context.Context, event structs.AuditEvent) error {
+	query :=
+	`
+	INSERT INTO public.audit_events("user_id", "event", "action", "success", "details", "ip", "user_agent", "created_at")
+	SELECT id, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW())
+	FROM public.users
+	WHERE username = $1;
+	`
+
+	var createdAt *time.Time
+	if !event.CreatedAt.IsZero() {
+		createdAt = &event.CreatedAt
+	}
+	_, err := p.conn(ctx).Exec(ctx, query, event.Username, event.Event, event.Action, event.Success,
+		event.Details, event.IP, event.UserAgent, createdAt)
+	return err
+}
+
+func (p *Postgres) SelectAuditEvents(ctx context.Context, username string, beforeID int64, limit int) ([]structs.AuditEvent, error) {
+	query := auditSelect +
+	`
+	WHERE u.username = $1 AND ($2 = 0 OR e.id < $2)
+	ORDER BY e.id DESC
+	LIMIT $3;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.AuditEvent])
+}
+
+func (p *Postgres) ExportAuditEvents(ctx context.Context, filter storage.AuditFilter, fn func(event structs.AuditEvent) error) error {
+	query := auditSelect +
+	`
+	WHERE ($1 = '' OR u.username = $1)
+		AND ($2::timestamptz IS NULL OR e.created_at >= $2)
+		AND ($3::timestamptz IS NULL OR e.created_at < $3)
+	ORDER BY e.id;
+	`
+
+	var since, until *time.Time
+	if !filter.Since.IsZero() {
+		since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		until = &filter.Until
+	}
+	rows, err := p.conn(ctx).Query(ctx, query, filter.Username, since, until)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := pgx.RowToStructByPos[structs.AuditEvent](rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
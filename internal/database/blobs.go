@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) CreateBlob(ctx context.Context, username string, secureDataID int64, size int64, sha256 string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	// файл записи хранилища организации загружается от имени владельца записи и учитывается в его квотах
+	username, err = p.lockRecord(ctx, secureDataID, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.checkBlobQuota(ctx, secureDataID, username, size); err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	INSERT INTO public.blobs("user_id", "secure_data_id", "size", "sha256")
+	SELECT 
+		user_id,
+		id AS secure_data_id,
+		$3 AS size,
+		$4 AS sha256
+	FROM public.secure_data
+	WHERE id = $2 AND is_active AND user_id = (SELECT id FROM public.users WHERE username = $1)
+	RETURNING id;
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, username, secureDataID, size, sha256)
+
+	var blobID int64
+	if err := row.Scan(&blobID); err != nil {
+		return 0, notFound(err)
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return blobID, nil
+}
+
+func (p *Postgres) SelectBlob(ctx context.Context, id int64, username string) (structs.Blob, error) {
+	query :=
+	`
+	SELECT id, secure_data_id, size, received, sha256, is_complete, COALESCE(history_id, 0)
+	FROM public.blobs
+	WHERE id = $1;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id)
+	if err != nil {
+		return structs.Blob{}, err
+	}
+
+	blob, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[structs.Blob])
+	if err != nil {
+		return structs.Blob{}, notFound(err)
+	}
+	_, err = p.recordOwner(ctx, blob.SecureDataID, username, accessVault)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = p.sharedBlob(ctx, blob, username)
+	}
+	if err != nil {
+		return structs.Blob{}, err
+	}
+	return blob, nil
+}
+
+// sharedBlob - ErrNotFound, если blob не текущий файл записи, открытой пользователю username
+func (p *Postgres) sharedBlob(ctx context.Context, blob structs.Blob, username string) error {
+	if _, err := p.recordOwner(ctx, blob.SecureDataID, username, accessRead); err != nil {
+		return err
+	}
+
+	query :=
+	`
+	SELECT id
+	FROM public.secure_data
+	WHERE id = $1 AND blob_id = $2;
+	`
+
+	var id int64
+	return notFound(p.conn(ctx).QueryRow(ctx, query, blob.SecureDataID, blob.ID).Scan(&id))
+}
+
+// AppendBlobChunk - сохранение части по смещению offset; возвращает новый объём принятых данных
+func (p *Postgres) AppendBlobChunk(ctx context.Context, id int64, username string, offset int64, data []byte) (int64, error) {
+	if err := p.checkBlobManage(ctx, id, username); err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	WITH blob AS (
+		UPDATE public.blobs
+		SET received = received + $3
+		WHERE id = $1 AND received = $2 AND NOT is_complete AND received + $3 <= size
+		RETURNING id, received
+	)
+	INSERT INTO public.blob_chunks("blob_id", "offset", "data")
+	SELECT id, $2, $4 FROM blob
+	RETURNING (SELECT received FROM blob);
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, id, offset, int64(len(data)), data)
+
+	var received int64
+	err := row.Scan(&received)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, storage.ErrBlobOffset
+	}
+
+	return received, err
+}
+
+// ResetBlob - удаление принятых частей, загрузка начинается с нуля
+func (p *Postgres) ResetBlob(ctx context.Context, id int64, username string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.checkBlobManage(ctx, id, username); err != nil {
+		return err
+	}
+
+	query :=
+	`
+	DELETE FROM public.blob_chunks
+	WHERE blob_id = $1;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, id); err != nil {
+		return err
+	}
+
+	query =
+	`
+	UPDATE public.blobs
+	SET received = 0
+	WHERE id = $1 AND NOT is_complete;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, id); err != nil {
+		return err
+	}
+
+	return p.CommitTransaction(ctx)
+}
+
+// ReadBlobChunks - последовательное чтение содержимого начиная со смещения offset
+func (p *Postgres) ReadBlobChunks(ctx context.Context, id int64, offset int64, fn func(data []byte) error) error {
+	query :=
+	`
+	SELECT "offset", data
+	FROM public.blob_chunks
+	WHERE blob_id = $1 AND "offset" + length(data) > $2
+	ORDER BY "offset";
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id, offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chunkOffset int64
+		var data []byte
+		if err := rows.Scan(&chunkOffset, &data); err != nil {
+			return err
+		}
+		if chunkOffset < offset {
+			data = data[offset-chunkOffset:]
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// CompleteBlob - завершение загрузки: содержимое становится текущим для записи и попадает в историю
+func (p *Postgres) CompleteBlob(ctx context.Context, id int64, username string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	var secureDataID int64
+	err = p.conn(ctx).QueryRow(ctx, `SELECT secure_data_id FROM public.blobs WHERE id = $1;`, id).Scan(&secureDataID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	username, err = p.lockRecord(ctx, secureDataID, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	UPDATE public.blobs
+	SET is_complete = true
+	WHERE id = $1 AND received = size AND NOT is_complete AND created_at > $3
+		AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username, p.uploadCutoff())
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, storage.ErrNotFound
+	}
+
+	query =
+	`
+	UPDATE public.secure_data
+	SET blob_id = $2
+	WHERE id = $1;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, secureDataID, id); err != nil {
+		return 0, err
+	}
+
+	historyID, err := p.updateHistory(ctx, secureDataID, username, "BLOB")
+	if err != nil {
+		return 0, err
+	}
+
+	query =
+	`
+	UPDATE public.blobs
+	SET history_id = $2
+	WHERE id = $1;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, id, historyID); err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, err
+}
+
+// checkBlobManage - пользователь username может загружать содержимое файла id (accessManage записи),
+// и срок загрузки не истёк
+func (p *Postgres) checkBlobManage(ctx context.Context, id int64, username string) error {
+	query :=
+	`
+	SELECT secure_data_id
+	FROM public.blobs
+	WHERE id = $1 AND (is_complete OR created_at > $2);
+	`
+
+	var secureDataID int64
+	err := p.conn(ctx).QueryRow(ctx, query, id, p.uploadCutoff()).Scan(&secureDataID)
+	if err != nil {
+		return notFound(err)
+	}
+	_, err = p.recordOwner(ctx, secureDataID, username, accessManage)
+	return err
+}
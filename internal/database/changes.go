@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// changesChannel - канал LISTEN/NOTIFY об изменениях записей; payload - "user_id:history_id".
+// Через него изменения, сделанные любым экземпляром сервера, доходят до подписчиков всех экземпляров
+const changesChannel = "secure_data_changes"
+
+// lockChanges - блокировка изменений записей пользователя до конца транзакции. Номера изменений
+// выдаются под ней, поэтому изменения пользователя фиксируются в порядке history_id и синхронизация
+// по history_id не пропускает изменение, зафиксированное позже более нового
+func (p *Postgres) lockChanges(ctx context.Context, username string) error {
+	_, err := p.conn(ctx).Exec(ctx, `SELECT pg_advisory_xact_lock(id) FROM public.users WHERE username = $1;`, username)
+	return err
+}
+
+// lockUsers - lockChanges пользователей из подзапроса users (столбец user_id) одним запросом в порядке
+// id: транзакции, блокирующие пересекающиеся наборы пользователей, не ждут друг друга по кругу.
+// Набор читается до получения блокировок, поэтому запрос повторяется: пользователи, добавленные в набор
+// за время ожидания, блокируются вторым запросом, уже полученные блокировки повторно не ожидаются
+func (p *Postgres) lockUsers(ctx context.Context, users string, args ...any) error {
+	query := `SELECT pg_advisory_xact_lock(user_id) FROM (` + users + ` ORDER BY user_id) AS users;`
+	for range 2 {
+		if _, err := p.conn(ctx).Exec(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyChange - NOTIFY об изменении записи secureDataID пользователя userID ему, получателям записи
+// и участникам организации её хранилища (запись хранилища организации её автору не принадлежит)
+func (p *Postgres) notifyChange(ctx context.Context, userID int64, secureDataID int64, historyID int64) error {
+	query :=
+	`
+	SELECT pg_notify($1, u.id || ':' || $3::bigint)
+	FROM (
+		SELECT $2::bigint AS id
+		FROM public.secure_data
+		WHERE id = $4 AND ` + personalVault + `
+		UNION
+		SELECT recipient_id FROM public.shares WHERE secure_data_id = $4 AND revoked_at IS NULL
+		UNION
+		SELECT m.user_id
+		FROM public.secure_data d
+		JOIN public.vaults v ON v.id = d.vault_id
+		JOIN public.org_members m ON m.org_id = v.org_id
+		WHERE d.id = $4 AND m.removed_at IS NULL
+	) AS u;
+	`
+
+	_, err := p.conn(ctx).Exec(ctx, query, changesChannel, userID, historyID, secureDataID)
+	return err
+}
+
+func (p *Postgres) SubscribeChanges(ctx context.Context, username string) (<-chan int64, error) {
+	var userID int64
+	err := p.conn(ctx).QueryRow(ctx, `SELECT id FROM public.users WHERE username = $1;`, username).Scan(&userID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	p.listenOnce.Do(func() {
+		go p.listen()
+	})
+	return p.broker.Subscribe(ctx, userID), nil
+}
+
+func (p *Postgres) SelectLastHistoryID(ctx context.Context, username string) (int64, error) {
+	var userID int64
+	err := p.conn(ctx).QueryRow(ctx, `SELECT id FROM public.users WHERE username = $1;`, username).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.lastHistoryID(ctx, p.conn(ctx), userID)
+}
+
+// lastHistoryID - наибольший history_id записей пользователя и записей, доступных ему (с учётом
+// открытия и отзыва доступа, вступления в организации и исключения из них)
+func (p *Postgres) lastHistoryID(ctx context.Context, conn querier, userID int64) (int64, error) {
+	query :=
+	`
+	SELECT GREATEST(
+		(SELECT COALESCE(MAX(history_id), 0) FROM public.secure_data WHERE user_id = $1 AND ` + personalVault + `),
+		(
+			SELECT COALESCE(MAX(CASE WHEN s.revoked_at IS NULL THEN GREATEST(d.history_id, s.history_id) ELSE s.history_id END), 0)
+			FROM public.shares s
+			JOIN public.secure_data d ON d.id = s.secure_data_id
+			WHERE s.recipient_id = $1
+		),
+		(
+			SELECT COALESCE(MAX(CASE WHEN m.removed_at IS NULL THEN GREATEST(d.history_id, m.history_id) ELSE m.history_id END), 0)
+			FROM public.org_members m
+			JOIN public.vaults v ON v.org_id = m.org_id
+			JOIN public.secure_data d ON d.vault_id = v.id
+			WHERE m.user_id = $1
+		)
+	);
+	`
+
+	var last int64
+	err := conn.QueryRow(ctx, query, userID).Scan(&last)
+	return last, err
+}
+
+// listen - приём уведомлений на выделенном соединении до Close; при обрыве соединение открывается заново
+func (p *Postgres) listen() {
+	for {
+		err := p.listenConn(p.listenCtx)
+		if p.listenCtx.Err() != nil {
+			return
+		}
+		log.Printf("error while listening for changes: %v\n", err)
+
+		select {
+		case <-p.listenCtx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *Postgres) listenConn(ctx context.Context) error {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+changesChannel)
+	if err != nil {
+		return err
+	}
+	// пока соединения не было, уведомления могли потеряться: подписчики получают текущее состояние
+	p.publishLast(ctx)
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// после ошибки соединение может остаться в состоянии LISTEN, в пул его не возвращаем
+			conn.Conn().Close(context.Background())
+			return err
+		}
+
+		userID, historyID, err := parseChange(notification.Payload)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		p.broker.Publish(userID, historyID)
+	}
+}
+
+// publishLast - последний history_id каждому пользователю с подписчиками
+func (p *Postgres) publishLast(ctx context.Context) {
+	for _, userID := range p.broker.UserIDs() {
+		last, err := p.lastHistoryID(ctx, p.pool, userID)
+		if err != nil {
+			log.Printf("error while selecting last history id: %v\n", err)
+			continue
+		}
+		if last > 0 {
+			p.broker.Publish(userID, last)
+		}
+	}
+}
+
+func parseChange(payload string) (userID int64, historyID int64, err error) {
+	user, history, found := strings.Cut(payload, ":")
+	if found {
+		userID, err = strconv.ParseInt(user, 10, 64)
+	}
+	if found && err == nil {
+		historyID, err = strconv.ParseInt(history, 10, 64)
+	}
+	if !found || err != nil {
+		return 0, 0, fmt.Errorf("invalid change notification %q", payload)
+	}
+	return userID, historyID, nil
+}
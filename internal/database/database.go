@@ -3,42 +3,88 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
-	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/contextKeys"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
 )
 
-var (
+// Postgres - реализация storage.Storage поверх pgxpool
+type Postgres struct {
 	pool *pgxpool.Pool
-)
+	// quota - квоты по умолчанию (storage.Options); Revisions - число ревизий записи по умолчанию
+	quota structs.Quota
+	// uploadTTL - срок незавершённой загрузки файла (storage.Options)
+	uploadTTL time.Duration
+
+	// broker - подписчики на изменения; уведомления принимает listen после первой подписки
+	broker     *storage.Broker
+	listenOnce sync.Once
+	stopListen context.CancelFunc
+	listenCtx  context.Context
+}
 
-func InitConnection() {
-	config, err := pgxpool.ParseConfig(*config.DatabaseDSN)
+var _ storage.Storage = (*Postgres)(nil)
+
+// querier - общие методы pgxpool.Pool и pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func New(dsn string, opts storage.Options) (*Postgres, error) {
+	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	config.MaxConns = 10
 	config.MinConns = 2
 	config.MaxConnLifetime = time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
 
-	pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
-		log.Fatalf("Error while init DB connection: %v\n", err)
+		return nil, fmt.Errorf("error while init DB connection: %w", err)
 	}
+	quota := opts.Quota
+	quota.Revisions = opts.HistoryRetention
+	listenCtx, stopListen := context.WithCancel(context.Background())
+	return &Postgres{
+		pool:       pool,
+		quota:      quota,
+		uploadTTL:  opts.UploadTTL,
+		broker:     storage.NewBroker(),
+		listenCtx:  listenCtx,
+		stopListen: stopListen,
+	}, nil
 }
 
-func RunMigrations() {
+// Close - остановка приёма уведомлений и закрытие пула соединений
+func (p *Postgres) Close() {
+	p.stopListen()
+	p.pool.Close()
+}
+
+// PoolStat - состояние пула соединений
+func (p *Postgres) PoolStat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
+func RunMigrations(dsn string) {
 	goose.SetDialect("pgx")
-	db, err := sql.Open("pgx", *config.DatabaseDSN)
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		log.Fatalf("goose: failed to open DB connection: %v\n", err)
 	}
@@ -53,69 +99,133 @@ func RunMigrations() {
 	}
 }
 
-func RegisterUser(mail string) error {
+func (p *Postgres) RegisterUser(ctx context.Context, mail string, verification *structs.Verification) error {
 	query :=
 		`
-	INSERT INTO public.users("username")
-	VALUES ($1);
+	INSERT INTO public.users("username", "verification_hash", "verification_expires_at")
+	VALUES ($1, $2, $3)
+	ON CONFLICT (username) DO UPDATE
+	SET verification_hash = EXCLUDED.verification_hash, verification_expires_at = EXCLUDED.verification_expires_at
+	WHERE users.verification_hash IS NOT NULL AND EXCLUDED.verification_hash IS NOT NULL;
 	`
 
-	_, err := pool.Exec(context.Background(), query, mail)
+	var codeHash, expiresAt any
+	if verification != nil {
+		codeHash, expiresAt = verification.CodeHash, verification.ExpiresAt
+	}
+	tag, err := p.conn(ctx).Exec(ctx, query, mail, codeHash, expiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrAlreadyExists
+	}
+	return nil
+}
+
+func (p *Postgres) VerifyUser(ctx context.Context, mail string, codeHash string) error {
+	query :=
+		`
+	UPDATE public.users
+	SET verification_hash = NULL, verification_expires_at = NULL
+	WHERE username = $1 AND verification_hash = $2 AND verification_expires_at > NOW();
+	`
 
-	return err
+	tag, err := p.conn(ctx).Exec(ctx, query, mail, codeHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
 }
-func CheckUser(mail string) error {
+
+func (p *Postgres) CheckUser(ctx context.Context, mail string) error {
 	query :=
 		`
-	SELECT username FROM public.users
+	SELECT verification_hash IS NOT NULL, disabled_at IS NOT NULL FROM public.users
 	WHERE username = $1;
 	`
 
-	row := pool.QueryRow(context.Background(), query, mail)
+	row := p.conn(ctx).QueryRow(ctx, query, mail)
 
-	var a interface{}
-	err := row.Scan(a)
+	var pending, disabled bool
+	if err := row.Scan(&pending, &disabled); err != nil {
+		return notFound(err)
+	}
+	if pending {
+		return storage.ErrPending
+	}
+	if disabled {
+		return storage.ErrDisabled
+	}
+	return nil
+}
 
-	return err
+func (p *Postgres) DeleteExpiredUsers(ctx context.Context) (int64, error) {
+	query :=
+		`
+	DELETE FROM public.users
+	WHERE verification_hash IS NOT NULL AND verification_expires_at < NOW();
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
 
-func AddSecureData(ctx context.Context, username string, data string, metadata string) (int64, int64, error) {
-	ctx, err := BeginTransaction(ctx)
+func (p *Postgres) AddSecureData(ctx context.Context, username string, vaultID int64, kind string, data string, metadata string) (int64, int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
 	if err != nil {
 		return 0, 0, fmt.Errorf("error while begin transaction: %w", err)
 	}
-	defer RollbackTransaction(ctx)
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.lockVault(ctx, vaultID, username); err != nil {
+		return 0, 0, err
+	}
+	if err := p.checkVault(ctx, vaultID, username); err != nil {
+		return 0, 0, err
+	}
+	if err := p.checkQuota(ctx, 0, username, data, metadata, 0); err != nil {
+		return 0, 0, err
+	}
 
 	query :=
-		`
-	INSERT INTO public.secure_data("user_id", "data", "metadata", "history_id", "is_active")
+	`
+	INSERT INTO public.secure_data("user_id", "data", "metadata", "history_id", "is_active", "kind", "vault_id")
 	SELECT 
 		id as user_id,
     	$2 AS data,
     	$3 AS metadata,
     	-1 AS history_id,
-		true AS is_active
+		true AS is_active,
+		$4 AS kind,
+		NULLIF($5::bigint, 0) AS vault_id
 	FROM users
 	where username = $1
 	RETURNING id;
 	`
 
-	row := pool.QueryRow(context.Background(), query, username, data, metadata)
+	row := p.conn(ctx).QueryRow(ctx, query, username, data, metadata, kind, vaultID)
 
 	var secureDataID int64
 	err = row.Scan(&secureDataID)
 
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, notFound(err)
 	}
 
-	historyID, err := UpdateHistory(ctx, secureDataID, username, "ADD")
+	historyID, err := p.updateHistory(ctx, secureDataID, username, "ADD")
 
 	if err != nil {
 		return 0, 0, err
 	}
 
-	err = CommitTransaction(ctx)
+	err = p.CommitTransaction(ctx)
 	if err != nil {
 		return 0, 0, fmt.Errorf("error while commit transaction: %w", err)
 	}
@@ -123,33 +233,46 @@ func AddSecureData(ctx context.Context, username string, data string, metadata s
 	return secureDataID, historyID, err
 }
 
-func DeleteSecureData(ctx context.Context, id int64, username string) (int64, error) {
-	ctx, err := BeginTransaction(ctx)
+func (p *Postgres) DeleteSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error while begin transaction: %w", err)
 	}
-	defer RollbackTransaction(ctx)
+	defer p.RollbackTransaction(ctx)
+
+	// участник организации удаляет запись её хранилища от имени владельца записи
+	username, err = p.lockRecord(ctx, id, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.checkHistoryID(ctx, id, username, expectedHistoryID, "DELETE"); err != nil {
+		return 0, err
+	}
+
 	query :=
-	
 	`
 	UPDATE public.secure_data
 	SET is_active = false
 	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2);
 	`
 
-	_, err = pool.Exec(ctx, query, id, username)
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username)
 
 	if err != nil {
 		return 0, err
 	}
+	if tag.RowsAffected() == 0 {
+		return 0, storage.ErrNotFound
+	}
 
-	historyID, err := UpdateHistory(ctx, id, username, "DELETE")
+	historyID, err := p.updateHistory(ctx, id, username, "DELETE")
 
 	if err != nil {
 		return 0, err
 	}
 
-	err = CommitTransaction(ctx)
+	err = p.CommitTransaction(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error while commit transaction: %w", err)
 	}
@@ -157,32 +280,49 @@ func DeleteSecureData(ctx context.Context, id int64, username string) (int64, er
 	return historyID, err
 }
 
-func UpdateSecureData(ctx context.Context, id int64, username string, data string, metadata string) (int64, error) {
-	ctx, err := BeginTransaction(ctx)
+func (p *Postgres) UpdateSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, kind string, data string, metadata string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error while begin transaction: %w", err)
 	}
-	defer RollbackTransaction(ctx)
+	defer p.RollbackTransaction(ctx)
+
+	// получатель с правом записи и участник организации изменяют запись от имени владельца
+	username, err = p.lockRecord(ctx, id, username, accessWrite)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.checkHistoryID(ctx, id, username, expectedHistoryID, "UPDATE"); err != nil {
+		return 0, err
+	}
+	if err := p.checkQuota(ctx, id, username, data, metadata, keepFile); err != nil {
+		return 0, err
+	}
+
 	query :=
 	`
 	UPDATE public.secure_data
-	SET data = $3, metadata = $4
-	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	SET data = $3, metadata = $4, kind = $5
+	WHERE id = $1 AND is_active AND user_id = (SELECT id FROM public.users WHERE username = $2);
 	`
 
-	_, err = pool.Exec(ctx, query, id, username, data, metadata)
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username, data, metadata, kind)
 
 	if err != nil {
 		return 0, err
 	}
+	if tag.RowsAffected() == 0 {
+		return 0, storage.ErrNotFound
+	}
 
-	historyID, err := UpdateHistory(ctx, id, username, "UPDATE")
+	historyID, err := p.updateHistory(ctx, id, username, "UPDATE")
 
 	if err != nil {
 		return 0, err
 	}
 
-	err = CommitTransaction(ctx)
+	err = p.CommitTransaction(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error while commit transaction: %w", err)
 	}
@@ -190,44 +330,185 @@ func UpdateSecureData(ctx context.Context, id int64, username string, data strin
 	return historyID, err
 }
 
+// checkHistoryID - блокировка записи до конца транзакции и сравнение её history_id с ожидаемым.
+// При несовпадении конфликт фиксируется в истории (транзакция подтверждается без изменения записи)
+// и возвращается *storage.ConflictError с текущим состоянием записи. Внутри InTransaction запись
+// конфликта отменяется вместе с пакетом, поэтому InTransaction повторяет её после отката (conflictError)
+func (p *Postgres) checkHistoryID(ctx context.Context, id int64, username string, expectedHistoryID int64, method string) error {
+	query :=
+	`
+	SELECT id, data, metadata, is_active, history_id, kind, COALESCE(blob_id, 0), COALESCE(vault_id, 0)
+	FROM public.secure_data
+	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2)
+	FOR UPDATE;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id, username)
+	if err != nil {
+		return err
+	}
+	current, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[structs.SecureData])
+	if err != nil {
+		return notFound(err)
+	}
+	if expectedHistoryID == 0 || expectedHistoryID == current.HistoryID {
+		return nil
+	}
+
+	conflict := &conflictError{
+		ConflictError:     &storage.ConflictError{Current: current},
+		username:          username,
+		method:            method,
+		expectedHistoryID: expectedHistoryID,
+	}
+	if err := p.insertConflict(ctx, conflict); err != nil {
+		return err
+	}
+	if err := p.CommitTransaction(ctx); err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return conflict
+}
+
+// conflictError - *storage.ConflictError с данными для записи конфликта в историю
+type conflictError struct {
+	*storage.ConflictError
+	username          string
+	method            string
+	expectedHistoryID int64
+}
+
+func (e *conflictError) Unwrap() error {
+	return e.ConflictError
+}
+
+// insertConflict - запись конфликта в историю
+func (p *Postgres) insertConflict(ctx context.Context, conflict *conflictError) error {
+	query :=
+	`
+	INSERT INTO public.history("user_id", "secure_data_id", "method", "expected_history_id")
+	SELECT 
+		id as user_id,
+		$1 AS secure_data_id,
+		$3 AS method,
+		$4 AS expected_history_id
+	FROM users
+	where username = $2;
+	`
+
+	_, err := p.conn(ctx).Exec(ctx, query, conflict.Current.ID, conflict.username, conflict.method+"_CONFLICT", conflict.expectedHistoryID)
+	return err
+}
+
+// recordConflict - запись конфликта, отменённого вместе с транзакцией InTransaction, в отдельной транзакции
+func (p *Postgres) recordConflict(ctx context.Context, conflict *conflictError) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.lockChanges(ctx, conflict.username); err != nil {
+		return err
+	}
+	if err := p.insertConflict(ctx, conflict); err != nil {
+		return err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SelectSecureDataKind(ctx context.Context, id int64, username string) (string, error) {
+	if _, err := p.recordOwner(ctx, id, username, accessRead); err != nil {
+		return "", err
+	}
 
-func SelectUpdatedSecureData(lastID int64, username string, limit int) ([]structs.SecureData, error) {
+	query :=
+	`
+	SELECT kind
+	FROM public.secure_data
+	WHERE id = $1;
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, id)
+
+	var kind string
+	err := row.Scan(&kind)
+
+	return kind, notFound(err)
+}
+
+func (p *Postgres) SelectUpdatedSecureData(ctx context.Context, lastID int64, username string, limit int) ([]structs.SecureData, error) {
 	query := 
 	`
-	SELECT id, data, metadata, is_active, history_id
+	SELECT id, data, metadata, is_active, history_id, kind, COALESCE(blob_id, 0), COALESCE(vault_id, 0)
 	FROM public.secure_data
-	WHERE history_id > $1 AND user_id = (SELECT id FROM users WHERE username = $2)
-	ORDER BY id
+	WHERE history_id > $1 AND user_id = (SELECT id FROM users WHERE username = $2) AND ` + personalVault + `
+	ORDER BY history_id
 	LIMIT $3;
 	`
 
-	rows, err := pool.Query(context.Background(), query, lastID, username, limit)
+	rows, err := p.conn(ctx).Query(ctx, query, lastID, username, limit)
+
+	if err != nil {
+		return nil, err
+	}
 
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByPos[structs.SecureData])
 	if err != nil {
-		rows.Close()
 		return nil, err
 	}
 
-	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.SecureData])
+	shared, err := p.selectUpdatedShared(ctx, lastID, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	org, err := p.selectUpdatedOrg(ctx, lastID, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(shared) == 0 && len(org) == 0 {
+		return data, nil
+	}
+	data = append(append(data, shared...), org...)
+	sort.SliceStable(data, func(i, j int) bool {
+		return max(data[i].HistoryID, data[i].SyncID) < max(data[j].HistoryID, data[j].SyncID)
+	})
+	if limit >= 0 && len(data) > limit {
+		data = data[:limit]
+	}
+	return data, nil
 }
 
-func UpdateHistory(ctx context.Context, id int64, username string, method string) (int64, error) {
+// updateHistory - запись изменения со снимком записи в историю, отметка записи номером изменения
+// и удаление ревизий сверх лимита; вызывается внутри транзакции после изменения записи
+func (p *Postgres) updateHistory(ctx context.Context, id int64, username string, method string) (int64, error) {
 	query := 
 	`
-	INSERT INTO public.history("user_id", "secure_data_id", "method")
+	INSERT INTO public.history("user_id", "secure_data_id", "method", "data", "metadata", "kind", "is_active", "blob_id")
 	SELECT 
-		id as user_id,
-    	$1 AS secure_data_id,
-    	$3 AS method
-	FROM users
-	where username = $2
-	RETURNING id;
+		user_id,
+		id AS secure_data_id,
+		$3 AS method,
+		data,
+		metadata,
+		kind,
+		is_active,
+		blob_id
+	FROM public.secure_data
+	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2)
+	RETURNING id, user_id;
 	`
 
-	row := pool.QueryRow(ctx, query, id, username, method)
+	row := p.conn(ctx).QueryRow(ctx, query, id, username, method)
 
-	var historyID int64
-	err := row.Scan(&historyID)
+	var historyID, userID int64
+	err := row.Scan(&historyID, &userID)
 
 	if err != nil {
 		return 0, err
@@ -239,13 +520,130 @@ func UpdateHistory(ctx context.Context, id int64, username string, method string
 	SET history_id = $3
 	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2);
 	`
-	_, err = pool.Exec(ctx, query, id, username, historyID)
+	_, err = p.conn(ctx).Exec(ctx, query, id, username, historyID)
+	if err != nil {
+		return 0, err
+	}
 
-	return historyID, err
+	// уведомление доставляется слушателям только после фиксации транзакции
+	err = p.notifyChange(ctx, userID, id, historyID)
+	if err != nil {
+		return 0, err
+	}
+
+	return historyID, p.pruneHistory(ctx, id, username)
 }
 
-func BeginTransaction(ctx context.Context) (context.Context, error) {
-	tx, err := pool.Begin(ctx)
+func (p *Postgres) SetUserKeys(ctx context.Context, username string, keys structs.UserKeys) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	SELECT COALESCE((SELECT version FROM public.user_keys WHERE user_id = u.id), 0)
+	FROM public.users u
+	WHERE username = $1
+	FOR UPDATE;
+	`
+
+	var version int64
+	if err := p.conn(ctx).QueryRow(ctx, query, username).Scan(&version); err != nil {
+		return 0, notFound(err)
+	}
+	if version != keys.Version {
+		return 0, storage.ErrConflict
+	}
+
+	query =
+	`
+	INSERT INTO public.user_keys("user_id", "kdf", "wrapped_key", "public_key", "wrapped_private_key", "version")
+	SELECT 
+		id as user_id,
+		$2 AS kdf,
+		$3 AS wrapped_key,
+		NULLIF($4, '') AS public_key,
+		NULLIF($5, '') AS wrapped_private_key,
+		$6 AS version
+	FROM users
+	where username = $1
+	ON CONFLICT (user_id) DO UPDATE
+	SET kdf = EXCLUDED.kdf, wrapped_key = EXCLUDED.wrapped_key, updated_at = NOW(), version = EXCLUDED.version,
+		public_key = COALESCE(EXCLUDED.public_key, user_keys.public_key),
+		wrapped_private_key = CASE WHEN EXCLUDED.public_key IS NULL THEN user_keys.wrapped_private_key ELSE EXCLUDED.wrapped_private_key END;
+	`
+
+	version++
+	if _, err := p.conn(ctx).Exec(ctx, query, username, keys.KDF, keys.WrappedKey, keys.PublicKey, keys.WrappedPrivateKey, version); err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return version, nil
+}
+
+func (p *Postgres) SelectUserKeys(ctx context.Context, username string) (structs.UserKeys, error) {
+	query :=
+	`
+	SELECT kdf, wrapped_key, COALESCE(public_key, ''), COALESCE(wrapped_private_key, ''), version
+	FROM public.user_keys
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, username)
+
+	var keys structs.UserKeys
+	err := row.Scan(&keys.KDF, &keys.WrappedKey, &keys.PublicKey, &keys.WrappedPrivateKey, &keys.Version)
+
+	return keys, notFound(err)
+}
+
+// InTransaction - fn в одной транзакции (во вложенной - в точке сохранения)
+func (p *Postgres) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, nested := ctx.Value(contextKeys.Transaction).(pgx.Tx)
+	outer := ctx
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := fn(ctx); err != nil {
+		// конфликт остаётся в истории и после отмены пакета; транзакция откатывается раньше,
+		// чтобы освободить блокировки записи
+		var conflict *conflictError
+		if errors.As(err, &conflict) && !nested {
+			p.RollbackTransaction(ctx)
+			if err := p.recordConflict(outer, conflict); err != nil {
+				log.Printf("error while recording conflict of secure data %d: %v", conflict.Current.ID, err)
+			}
+		}
+		return err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
+
+// BeginTransaction - транзакция, сохранённая в контексте: запросы с этим контекстом выполняются в ней;
+// если в контексте уже есть транзакция, создаётся вложенная (savepoint)
+func (p *Postgres) BeginTransaction(ctx context.Context) (context.Context, error) {
+	var tx pgx.Tx
+	var err error
+	if parent, ok := ctx.Value(contextKeys.Transaction).(pgx.Tx); ok {
+		tx, err = parent.Begin(ctx)
+	} else {
+		tx, err = p.pool.Begin(ctx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -253,7 +651,7 @@ func BeginTransaction(ctx context.Context) (context.Context, error) {
 	return ctx, nil
 }
 
-func CommitTransaction(ctx context.Context) error {
+func (p *Postgres) CommitTransaction(ctx context.Context) error {
 	tx, ok := ctx.Value(contextKeys.Transaction).(pgx.Tx)
 	if !ok {
 		return fmt.Errorf("no transaction found in context")
@@ -264,7 +662,7 @@ func CommitTransaction(ctx context.Context) error {
 	return nil
 }
 
-func RollbackTransaction(ctx context.Context) error {
+func (p *Postgres) RollbackTransaction(ctx context.Context) error {
 	tx, ok := ctx.Value(contextKeys.Transaction).(pgx.Tx)
 	if !ok {
 		return fmt.Errorf("no transaction found in context")
@@ -274,3 +672,19 @@ func RollbackTransaction(ctx context.Context) error {
 	}
 	return nil
 }
+
+// conn - транзакция из контекста или пул
+func (p *Postgres) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(contextKeys.Transaction).(pgx.Tx); ok {
+		return tx
+	}
+	return p.pool
+}
+
+// notFound - pgx.ErrNoRows в storage.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	return err
+}
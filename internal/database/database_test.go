@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pressly/goose/v3"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/storage/storagetest"
+)
+
+// testDSNEnv - переменная окружения с адресом отдельной тестовой БД; все её таблицы очищаются перед каждым подтестом
+const testDSNEnv = "GOPHKEEPER_TEST_DSN"
+
+func TestStorage(t *testing.T) {
+	dsn := migrate(t)
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return open(t, dsn)
+	})
+}
+
+// конфликт пакета записывается в историю отдельной транзакцией после отката пакета
+func TestBatchConflictRecorded(t *testing.T) {
+	p := open(t, migrate(t))
+	ctx := context.Background()
+	if err := p.RegisterUser(ctx, "alice@example.com", nil); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	id, first, err := p.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.first", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	if _, err := p.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.second", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+
+	err = p.InTransaction(ctx, func(ctx context.Context) error {
+		if _, _, err := p.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.added", "{}"); err != nil {
+			return err
+		}
+		_, err := p.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.stale", "{}")
+		return err
+	})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	var conflicts int
+	query := `SELECT COUNT(*) FROM public.history WHERE secure_data_id = $1 AND method = 'UPDATE_CONFLICT' AND expected_history_id = $2;`
+	if err := p.pool.QueryRow(ctx, query, id, first).Scan(&conflicts); err != nil {
+		t.Fatalf("select conflicts: %v", err)
+	}
+	if conflicts != 1 {
+		t.Fatalf("batch conflict recorded %d times", conflicts)
+	}
+}
+
+// migrate - адрес тестовой БД с применёнными миграциями; без testDSNEnv тест пропускается,
+// в CI (переменная CI) - завершается ошибкой
+func migrate(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" && os.Getenv("CI") != "" {
+		t.Fatalf("%s is not set", testDSNEnv)
+	}
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	goose.SetDialect("pgx")
+	if err := goose.Up(db, "../../migrations"); err != nil {
+		t.Fatalf("goose.Up: %v", err)
+	}
+	return dsn
+}
+
+// open - хранилище на очищенной тестовой БД
+func open(t *testing.T, dsn string) *Postgres {
+	t.Helper()
+	p, err := New(dsn, storage.Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(p.Close)
+	truncate(t, p)
+	return p
+}
+
+// truncate - очистка всех таблиц, кроме версии миграций, со сбросом последовательностей
+func truncate(t *testing.T, p *Postgres) {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := p.pool.Query(ctx, `SELECT tablename FROM pg_tables WHERE schemaname = 'public' AND tablename <> 'goose_db_version';`)
+	if err != nil {
+		t.Fatalf("select tables: %v", err)
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("select tables: %v", err)
+	}
+	for i, table := range tables {
+		tables[i] = pgx.Identifier{"public", table}.Sanitize()
+	}
+	query := `TRUNCATE ` + strings.Join(tables, ", ") + ` RESTART IDENTITY CASCADE;`
+	if _, err := p.pool.Exec(ctx, query); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+}
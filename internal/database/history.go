@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) SelectRevisions(ctx context.Context, id int64, username string) ([]structs.Revision, error) {
+	// запись без ревизий (изменена до появления снимков) отличается от чужой или несуществующей
+	username, err := p.recordOwner(ctx, id, username, accessVault)
+	if err != nil {
+		return nil, err
+	}
+
+	query :=
+	`
+	SELECT id, secure_data_id, method, data, metadata, kind, is_active, COALESCE(blob_id, 0), created_at
+	FROM public.history
+	WHERE secure_data_id = $1 AND data IS NOT NULL AND user_id = (SELECT id FROM public.users WHERE username = $2)
+	ORDER BY id DESC;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Revision])
+}
+
+func (p *Postgres) SelectRevision(ctx context.Context, id int64, username string, historyID int64) (structs.Revision, error) {
+	username, err := p.recordOwner(ctx, id, username, accessVault)
+	if err != nil {
+		return structs.Revision{}, err
+	}
+
+	query :=
+	`
+	SELECT id, secure_data_id, method, data, metadata, kind, is_active, COALESCE(blob_id, 0), created_at
+	FROM public.history
+	WHERE id = $3 AND secure_data_id = $1 AND data IS NOT NULL AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id, username, historyID)
+	if err != nil {
+		return structs.Revision{}, err
+	}
+
+	revision, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[structs.Revision])
+	return revision, notFound(err)
+}
+
+func (p *Postgres) RestoreSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, revisionID int64) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	username, err = p.lockRecord(ctx, id, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.checkHistoryID(ctx, id, username, expectedHistoryID, "RESTORE"); err != nil {
+		return 0, err
+	}
+
+	var data, metadata string
+	var blobID int64
+	query :=
+	`
+	SELECT data, metadata::text, COALESCE(blob_id, 0)
+	FROM public.history
+	WHERE id = $2 AND secure_data_id = $1 AND data IS NOT NULL;
+	`
+	if err := p.conn(ctx).QueryRow(ctx, query, id, revisionID).Scan(&data, &metadata, &blobID); err != nil {
+		return 0, notFound(err)
+	}
+	if err := p.checkQuota(ctx, id, username, data, metadata, blobID); err != nil {
+		return 0, err
+	}
+
+	query =
+	`
+	UPDATE public.secure_data s
+	SET data = h.data, metadata = h.metadata, kind = h.kind, blob_id = h.blob_id, is_active = true
+	FROM public.history h
+	WHERE s.id = $1 AND s.user_id = (SELECT id FROM public.users WHERE username = $2)
+		AND h.id = $3 AND h.secure_data_id = s.id AND h.data IS NOT NULL;
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username, revisionID)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, storage.ErrNotFound
+	}
+
+	historyID, err := p.updateHistory(ctx, id, username, "RESTORE")
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, nil
+}
+
+func (p *Postgres) SetHistoryRetention(ctx context.Context, username string, limit int) error {
+	query :=
+	`
+	UPDATE public.users
+	SET history_retention = $2
+	WHERE username = $1;
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, limit)
+	if err == nil && tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return err
+}
+
+func (p *Postgres) SelectHistoryRetention(ctx context.Context, username string) (int, error) {
+	query :=
+	`
+	SELECT history_retention
+	FROM public.users
+	WHERE username = $1;
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, username)
+
+	var limit int
+	err := row.Scan(&limit)
+
+	return limit, notFound(err)
+}
+
+// pruneHistory - удаление старых ревизий записи сверх лимита пользователя (не больше его квоты ревизий).
+// Записи о конфликтах не содержат снимков и не удаляются.
+func (p *Postgres) pruneHistory(ctx context.Context, id int64, username string) error {
+	query :=
+	`
+	WITH quota AS (
+		SELECT u.history_retention AS retention, COALESCE(q.max_revisions, $3) AS revisions
+		FROM public.users u
+		LEFT JOIN public.user_quotas q ON q.user_id = u.id
+		WHERE u.username = $2
+	), retention AS (
+		SELECT CASE WHEN retention = 0 OR (revisions > 0 AND retention > revisions) THEN revisions ELSE retention END AS keep
+		FROM quota
+	)
+	DELETE FROM public.history
+	WHERE secure_data_id = $1 AND data IS NOT NULL
+		AND (SELECT keep FROM retention) > 0
+		AND id NOT IN (
+			SELECT id
+			FROM public.history
+			WHERE secure_data_id = $1 AND data IS NOT NULL
+			ORDER BY id DESC
+			LIMIT (SELECT keep FROM retention)
+		);
+	`
+
+	_, err := p.conn(ctx).Exec(ctx, query, id, username, p.quota.Revisions)
+	return err
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) ReserveIdempotencyKey(ctx context.Context, username string, key string, requestHash string, expiresAt time.Time, staleBefore time.Time) (structs.IdempotentRequest, bool, error) {
+	query :=
+	`
+	INSERT INTO public.idempotency_keys("user_id", "key", "request_hash", "expires_at")
+	SELECT id, $2, $3, $4
+	FROM public.users
+	WHERE username = $1
+	ON CONFLICT (user_id, key) DO UPDATE
+	SET request_hash = EXCLUDED.request_hash, status = 0, body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at < NOW() OR (idempotency_keys.status = 0 AND idempotency_keys.created_at < $5)
+	RETURNING true;
+	`
+
+	var reserved bool
+	err := p.conn(ctx).QueryRow(ctx, query, username, key, requestHash, expiresAt, staleBefore).Scan(&reserved)
+	if err == nil {
+		return structs.IdempotentRequest{RequestHash: requestHash}, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return structs.IdempotentRequest{}, false, err
+	}
+
+	// ключ уже занят действующим запросом
+	query =
+	`
+	SELECT request_hash, status, body, created_at
+	FROM public.idempotency_keys
+	WHERE key = $2 AND user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	var request structs.IdempotentRequest
+	err = p.conn(ctx).QueryRow(ctx, query, username, key).Scan(&request.RequestHash, &request.Status, &request.Body, &request.CreatedAt)
+	return request, false, notFound(err)
+}
+
+func (p *Postgres) CompleteIdempotencyKey(ctx context.Context, username string, key string, status int, body []byte) error {
+	query :=
+	`
+	UPDATE public.idempotency_keys
+	SET status = $3, body = $4
+	WHERE key = $2 AND user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, key, status, body)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteIdempotencyKey(ctx context.Context, username string, key string) error {
+	query :=
+	`
+	DELETE FROM public.idempotency_keys
+	WHERE key = $2 AND user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	_, err := p.conn(ctx).Exec(ctx, query, username, key)
+	return err
+}
+
+func (p *Postgres) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	query :=
+	`
+	DELETE FROM public.idempotency_keys
+	WHERE expires_at < NOW();
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
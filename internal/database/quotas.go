@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) SelectUsage(ctx context.Context, username string) (structs.Usage, error) {
+	query :=
+	`
+	SELECT
+		(SELECT COUNT(*) FROM public.secure_data d WHERE d.user_id = u.id AND d.is_active),
+		(SELECT COALESCE(SUM(octet_length(d.data) + octet_length(d.metadata::text)), 0)
+			FROM public.secure_data d WHERE d.user_id = u.id AND d.is_active) +
+		(SELECT COALESCE(SUM(b.size), 0)
+			FROM public.blobs b JOIN public.secure_data d ON d.id = b.secure_data_id
+			WHERE d.user_id = u.id AND d.is_active AND ` + retainedBlob("$6") + `),
+		COALESCE(q.max_records, $2),
+		COALESCE(q.max_bytes, $3),
+		COALESCE(q.max_record_size, $4),
+		COALESCE(q.max_revisions, $5),
+		q.user_id IS NOT NULL
+	FROM public.users u
+	LEFT JOIN public.user_quotas q ON q.user_id = u.id
+	WHERE u.username = $1;
+	`
+
+	var usage structs.Usage
+	err := p.conn(ctx).QueryRow(ctx, query, username, p.quota.Records, p.quota.Bytes, p.quota.RecordSize, p.quota.Revisions, p.uploadCutoff()).Scan(
+		&usage.Records, &usage.Bytes,
+		&usage.Quota.Records, &usage.Quota.Bytes, &usage.Quota.RecordSize, &usage.Quota.Revisions,
+		&usage.Custom)
+	return usage, notFound(err)
+}
+
+func (p *Postgres) SetUserQuota(ctx context.Context, username string, quota *structs.Quota) error {
+	var userID int64
+	err := p.conn(ctx).QueryRow(ctx, `SELECT id FROM public.users WHERE username = $1;`, username).Scan(&userID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	if quota == nil {
+		_, err = p.conn(ctx).Exec(ctx, `DELETE FROM public.user_quotas WHERE user_id = $1;`, userID)
+		return err
+	}
+
+	query :=
+	`
+	INSERT INTO public.user_quotas("user_id", "max_records", "max_bytes", "max_record_size", "max_revisions")
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id) DO UPDATE
+	SET max_records = EXCLUDED.max_records, max_bytes = EXCLUDED.max_bytes,
+		max_record_size = EXCLUDED.max_record_size, max_revisions = EXCLUDED.max_revisions;
+	`
+
+	_, err = p.conn(ctx).Exec(ctx, query, userID, quota.Records, quota.Bytes, quota.RecordSize, quota.Revisions)
+	return err
+}
+
+// keepFile - файл записи при изменении не меняется (checkQuota)
+const keepFile int64 = -1
+
+// checkQuota - проверка квот автора username перед заменой данных записи id (0 - новая запись)
+// на data и metadata и её файла на blobID (keepFile - файл не меняется, 0 - без файла); вызывается
+// внутри транзакции после lockChanges автора, поэтому объём его записей не меняется до её фиксации
+func (p *Postgres) checkQuota(ctx context.Context, id int64, username string, data string, metadata string, blobID int64) error {
+	usage, err := p.SelectUsage(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	added, oldSize, file, files := true, int64(0), int64(0), int64(0)
+	if id != 0 {
+		var active bool
+		if active, oldSize, file, files, err = p.recordSize(ctx, id); err != nil {
+			return err
+		}
+		added = !active
+	}
+	if blobID != keepFile {
+		query := `SELECT COALESCE((SELECT size FROM public.blobs WHERE id = $1), 0);`
+		if err := p.conn(ctx).QueryRow(ctx, query, blobID).Scan(&file); err != nil {
+			return err
+		}
+	}
+
+	// файлы записи остаются в объёме, пока она активна; удалённая запись возвращает их при восстановлении
+	size := storage.RecordSize(data, metadata, 0)
+	grow := size - oldSize
+	if added {
+		grow = size + files
+	}
+	return storage.CheckQuota(usage.Quota, usage, added, size+file, grow)
+}
+
+// checkBlobQuota - проверка квот автора username перед загрузкой в запись id файла размером size,
+// который заменит текущий; вызывается, как и checkQuota, после lockChanges автора
+func (p *Postgres) checkBlobQuota(ctx context.Context, id int64, username string, size int64) error {
+	usage, err := p.SelectUsage(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	_, dataSize, _, _, err := p.recordSize(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return storage.CheckQuota(usage.Quota, usage, false, dataSize+size, size)
+}
+
+// recordSize - активность записи id, размер её данных и метаданных, размер текущего файла
+// и суммарный размер файлов, учитываемых в квотах (retainedBlob)
+func (p *Postgres) recordSize(ctx context.Context, id int64) (active bool, size int64, file int64, files int64, err error) {
+	query :=
+	`
+	SELECT d.is_active, octet_length(d.data) + octet_length(d.metadata::text),
+		COALESCE((SELECT b.size FROM public.blobs b WHERE b.id = d.blob_id), 0),
+		(SELECT COALESCE(SUM(b.size), 0) FROM public.blobs b WHERE b.secure_data_id = d.id AND ` + retainedBlob("$2") + `)
+	FROM public.secure_data d
+	WHERE d.id = $1;
+	`
+
+	err = p.conn(ctx).QueryRow(ctx, query, id, p.uploadCutoff()).Scan(&active, &size, &file, &files)
+	return active, size, file, files, notFound(err)
+}
+
+// retainedBlob - файл b записи d учитывается в квотах: это текущий файл записи, файл хранимой ревизии
+// или незавершённая загрузка, начатая позже cutoff (параметр запроса, см. uploadCutoff)
+func retainedBlob(cutoff string) string {
+	return `(b.id = d.blob_id
+		OR EXISTS (SELECT 1 FROM public.history h WHERE h.secure_data_id = d.id AND h.blob_id = b.id)
+		OR NOT b.is_complete AND b.created_at > ` + cutoff + `)`
+}
+
+// uploadCutoff - незавершённые загрузки, начатые не позже этого времени, истекли (Options.UploadTTL)
+func (p *Postgres) uploadCutoff() time.Time {
+	if p.uploadTTL == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-p.uploadTTL)
+}
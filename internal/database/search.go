@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// SearchSecureData - фильтры по метаданным используют jsonb @> и индекс idx_secure_data_gin_data.
+// Кроме своих записей и записей хранилищ организаций ищутся открытые пользователю записи (как в синхронизации)
+func (p *Postgres) SearchSecureData(ctx context.Context, username string, filter storage.SearchFilter) ([]structs.SecureData, *storage.SearchCursor, error) {
+	args := []any{username}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where := []string{"(user_id = (SELECT id FROM public.users WHERE username = $1) AND " + personalVault + " OR vault_id IN (" + memberVaults("$1") + ") OR id IN (" + sharedWith("$1") + "))"}
+	if filter.Metadata != "" {
+		where = append(where, "metadata @> "+arg(filter.Metadata)+"::jsonb")
+	}
+	if len(filter.Tags) != 0 {
+		tags, err := json.Marshal(map[string][]string{"tags": filter.Tags})
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, "metadata @> "+arg(string(tags))+"::jsonb")
+	}
+	if filter.Kind != "" {
+		where = append(where, "kind = "+arg(filter.Kind))
+	}
+	switch filter.State {
+	case storage.SearchActive:
+		where = append(where, "is_active")
+	case storage.SearchDeleted:
+		where = append(where, "NOT is_active")
+	}
+
+	// sortValue - поле сортировки в виде текста для курсора; ключ метаданных передаётся параметром
+	sortColumn, sortValue := "id", "''"
+	switch {
+	case filter.Sort == storage.SortHistoryID:
+		sortColumn, sortValue = "history_id", "history_id::text"
+	case filter.Sort != storage.SortID:
+		key := arg(strings.TrimPrefix(filter.Sort, storage.SortMetadataPrefix))
+		sortColumn = `COALESCE(metadata->>` + key + `, '') COLLATE "C"`
+		sortValue = sortColumn
+	}
+
+	direction, compare := "ASC", ">"
+	if filter.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if filter.After != nil {
+		switch {
+		case filter.Sort == storage.SortID:
+			where = append(where, "id "+compare+" "+arg(filter.After.ID)+"::bigint")
+		case filter.Sort == storage.SortHistoryID:
+			historyID, _ := strconv.ParseInt(filter.After.Value, 10, 64)
+			where = append(where, "(history_id, id) "+compare+" ("+arg(historyID)+"::bigint, "+arg(filter.After.ID)+"::bigint)")
+		default:
+			where = append(where, "("+sortColumn+", id) "+compare+" ("+arg(filter.After.Value)+"::text, "+arg(filter.After.ID)+"::bigint)")
+		}
+	}
+
+	query := `
+	SELECT id, data, metadata, is_active, history_id, kind, COALESCE(blob_id, 0), COALESCE(vault_id, 0), ` + sortValue + `
+	FROM public.secure_data
+	WHERE ` + strings.Join(where, " AND ") + `
+	ORDER BY ` + sortColumn + ` ` + direction + `, id ` + direction + `
+	LIMIT ` + arg(filter.Limit+1) + `;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	var (
+		data   []structs.SecureData
+		values []string
+	)
+	for rows.Next() {
+		var (
+			d     structs.SecureData
+			value string
+		)
+		err := rows.Scan(&d.ID, &d.Data, &d.Metadata, &d.IsActive, &d.HistoryID, &d.Kind, &d.BlobID, &d.VaultID, &value)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, d)
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *storage.SearchCursor
+	if len(data) > filter.Limit {
+		data = data[:filter.Limit]
+		next = &storage.SearchCursor{ID: data[len(data)-1].ID}
+		if filter.Sort != storage.SortID {
+			next.Value = values[len(data)-1]
+		}
+	}
+	if err := p.searchShared(ctx, username, data); err != nil {
+		return nil, nil, err
+	}
+	return data, next, nil
+}
+
+// sharedWith - подзапрос: записи, доступ к которым открыт пользователю user (параметр запроса)
+func sharedWith(user string) string {
+	return `
+		SELECT s.secure_data_id
+		FROM public.shares s
+		WHERE s.revoked_at IS NULL AND s.recipient_id = (SELECT id FROM public.users WHERE username = ` + user + `)`
+}
+
+// searchShared - открытые пользователю записи из data в том виде, в каком их видит получатель
+func (p *Postgres) searchShared(ctx context.Context, username string, data []structs.SecureData) error {
+	ids := make([]int64, 0, len(data))
+	for _, d := range data {
+		ids = append(ids, d.ID)
+	}
+
+	query := sharedSelect + `
+	WHERE r.username = $1 AND s.revoked_at IS NULL AND d.id = ANY($2);
+	`
+
+	shared, err := p.selectShared(ctx, query, username, ids)
+	if err != nil {
+		return err
+	}
+	for _, sd := range shared {
+		for i := range data {
+			if data[i].ID == sd.ID {
+				data[i] = sd
+			}
+		}
+	}
+	return nil
+}
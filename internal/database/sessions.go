@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) CreateSession(ctx context.Context, username string, session structs.Session, refreshHash string) error {
+	query :=
+	`
+	INSERT INTO public.sessions("id", "user_id", "refresh_hash", "user_agent", "ip", "expires_at")
+	SELECT 
+		$2 AS id,
+		id AS user_id,
+		$3 AS refresh_hash,
+		$4 AS user_agent,
+		$5 AS ip,
+		$6 AS expires_at
+	FROM users
+	where username = $1 AND disabled_at IS NULL;
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, session.ID, refreshHash, session.UserAgent, session.IP, session.ExpiresAt)
+	if err == nil && tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return err
+}
+
+func (p *Postgres) RotateSession(ctx context.Context, id string, refreshHash string, newRefreshHash string, expiresAt time.Time, ip string, userAgent string) (string, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	SELECT s.refresh_hash, u.username
+	FROM public.sessions s
+	JOIN public.users u ON u.id = s.user_id
+	WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+	FOR UPDATE OF s;
+	`
+
+	var storedHash, username string
+	err = p.conn(ctx).QueryRow(ctx, query, id).Scan(&storedHash, &username)
+	if err != nil {
+		return "", notFound(err)
+	}
+
+	if storedHash != refreshHash {
+		// токен уже был заменён: им воспользовался кто-то ещё, сессия больше не доверенная
+		query =
+		`
+		UPDATE public.sessions
+		SET revoked_at = NOW()
+		WHERE id = $1;
+		`
+		if _, err := p.conn(ctx).Exec(ctx, query, id); err != nil {
+			return "", err
+		}
+		if err := p.CommitTransaction(ctx); err != nil {
+			return "", fmt.Errorf("error while commit transaction: %w", err)
+		}
+		return "", storage.ErrNotFound
+	}
+
+	query =
+	`
+	UPDATE public.sessions
+	SET refresh_hash = $2, expires_at = $3, ip = $4, user_agent = $5, last_seen = NOW()
+	WHERE id = $1;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, id, newRefreshHash, expiresAt, ip, userAgent); err != nil {
+		return "", err
+	}
+
+	if err := p.CommitTransaction(ctx); err != nil {
+		return "", fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return username, nil
+}
+
+func (p *Postgres) CheckSession(ctx context.Context, id string, username string, ip string) error {
+	query :=
+	`
+	UPDATE public.sessions
+	SET last_seen = NOW(), ip = $3
+	WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username, ip)
+	if err == nil && tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return err
+}
+
+func (p *Postgres) SelectSessions(ctx context.Context, username string) ([]structs.Session, error) {
+	query :=
+	`
+	SELECT id, user_agent, ip, created_at, last_seen, expires_at
+	FROM public.sessions
+	WHERE revoked_at IS NULL AND expires_at > NOW()
+		AND user_id = (SELECT id FROM public.users WHERE username = $1)
+	ORDER BY created_at DESC;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Session])
+}
+
+func (p *Postgres) RevokeSession(ctx context.Context, username string, id string) error {
+	query :=
+	`
+	UPDATE public.sessions
+	SET revoked_at = NOW()
+	WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, id, username)
+	if err == nil && tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	return err
+}
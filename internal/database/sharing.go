@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// sharedSelect - записи, доступные получателю, в том виде, в каком он их видит: после отзыва
+// доступа запись неактивна, без содержимого и с history_id отзыва; из файлов передаётся только текущий.
+// sync_id - позиция в синхронизации получателя: записи, изменённые до открытия доступа, передаются
+// с history_id открытия
+const sharedSelect = `
+	SELECT d.id,
+		CASE WHEN s.revoked_at IS NULL THEN d.data ELSE '' END,
+		CASE WHEN s.revoked_at IS NULL THEN d.metadata ELSE '{}'::jsonb END,
+		d.is_active AND s.revoked_at IS NULL,
+		CASE WHEN s.revoked_at IS NULL THEN d.history_id ELSE s.history_id END,
+		d.kind,
+		CASE WHEN s.revoked_at IS NULL THEN COALESCE(d.blob_id, 0) ELSE 0 END,
+		o.username, r.username, s.permission, s.wrapped_key, s.history_id, s.created_at, s.revoked_at,
+		CASE WHEN s.revoked_at IS NULL THEN GREATEST(d.history_id, s.history_id) ELSE s.history_id END AS sync_id
+	FROM public.shares s
+	JOIN public.secure_data d ON d.id = s.secure_data_id
+	JOIN public.users o ON o.id = d.user_id
+	JOIN public.users r ON r.id = s.recipient_id
+	`
+
+func (p *Postgres) ShareSecureData(ctx context.Context, id int64, username string, recipient string, permission string, wrappedKey string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.lockRecordUsers(ctx, id, username, recipient); err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	SELECT r.id
+	FROM public.secure_data d, public.users r
+	WHERE d.id = $1 AND d.is_active AND d.user_id = (SELECT id FROM public.users WHERE username = $2)
+		AND ` + personalVault + ` AND r.username = $3 AND r.id <> d.user_id;
+	`
+
+	var recipientID int64
+	if err := p.conn(ctx).QueryRow(ctx, query, id, username, recipient).Scan(&recipientID); err != nil {
+		return 0, notFound(err)
+	}
+
+	query =
+	`
+	INSERT INTO public.shares("secure_data_id", "recipient_id", "permission", "wrapped_key")
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (secure_data_id, recipient_id) DO UPDATE
+	SET permission = EXCLUDED.permission, wrapped_key = EXCLUDED.wrapped_key,
+		created_at = CASE WHEN shares.revoked_at IS NULL THEN shares.created_at ELSE NOW() END,
+		revoked_at = NULL;
+	`
+
+	if _, err := p.conn(ctx).Exec(ctx, query, id, recipientID, permission, wrappedKey); err != nil {
+		return 0, err
+	}
+
+	historyID, err := p.shareHistory(ctx, id, "SHARE", recipientID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, nil
+}
+
+func (p *Postgres) RevokeShare(ctx context.Context, id int64, username string, recipient string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	if err := p.lockRecordUsers(ctx, id, username); err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	SELECT s.recipient_id
+	FROM public.shares s
+	JOIN public.secure_data d ON d.id = s.secure_data_id
+	WHERE s.secure_data_id = $1 AND s.revoked_at IS NULL
+		AND d.user_id = (SELECT id FROM public.users WHERE username = $2)
+		AND s.recipient_id = (SELECT id FROM public.users WHERE username = $3);
+	`
+
+	var recipientID int64
+	if err := p.conn(ctx).QueryRow(ctx, query, id, username, recipient).Scan(&recipientID); err != nil {
+		return 0, notFound(err)
+	}
+
+	// получатель узнаёт об отзыве из этого изменения, поэтому доступ отзывается после него
+	historyID, err := p.shareHistory(ctx, id, "UNSHARE", recipientID)
+	if err != nil {
+		return 0, err
+	}
+
+	query =
+	`
+	UPDATE public.shares
+	SET revoked_at = NOW()
+	WHERE secure_data_id = $1 AND recipient_id = $2;
+	`
+
+	if _, err := p.conn(ctx).Exec(ctx, query, id, recipientID); err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, nil
+}
+
+func (p *Postgres) SelectShares(ctx context.Context, id int64, username string) ([]structs.Share, error) {
+	if err := p.ownSecureData(ctx, id, username); err != nil {
+		return nil, err
+	}
+
+	query :=
+	`
+	SELECT s.secure_data_id, o.username, r.username, s.permission, s.wrapped_key, s.history_id, s.created_at, s.revoked_at
+	FROM public.shares s
+	JOIN public.secure_data d ON d.id = s.secure_data_id
+	JOIN public.users o ON o.id = d.user_id
+	JOIN public.users r ON r.id = s.recipient_id
+	WHERE s.secure_data_id = $1
+	ORDER BY s.created_at;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Share])
+}
+
+func (p *Postgres) SelectSharedSecureData(ctx context.Context, username string) ([]structs.SecureData, error) {
+	query := sharedSelect + `
+	WHERE r.username = $1 AND s.revoked_at IS NULL AND d.is_active
+	ORDER BY d.id;
+	`
+
+	return p.selectShared(ctx, query, username)
+}
+
+// selectUpdatedShared - записи, доступные пользователю, с позицией синхронизации > lastID
+func (p *Postgres) selectUpdatedShared(ctx context.Context, lastID int64, username string, limit int) ([]structs.SecureData, error) {
+	query := sharedSelect + `
+	WHERE r.username = $1
+		AND CASE WHEN s.revoked_at IS NULL THEN GREATEST(d.history_id, s.history_id) ELSE s.history_id END > $2
+	ORDER BY sync_id
+	LIMIT $3;
+	`
+
+	return p.selectShared(ctx, query, username, lastID, limit)
+}
+
+func (p *Postgres) selectShared(ctx context.Context, query string, args ...any) ([]structs.SecureData, error) {
+	rows, err := p.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var data []structs.SecureData
+	for rows.Next() {
+		d := structs.SecureData{Share: &structs.Share{}}
+		err := rows.Scan(&d.ID, &d.Data, &d.Metadata, &d.IsActive, &d.HistoryID, &d.Kind, &d.BlobID,
+			&d.Share.Owner, &d.Share.Recipient, &d.Share.Permission, &d.Share.WrappedKey,
+			&d.Share.HistoryID, &d.Share.CreatedAt, &d.Share.RevokedAt, &d.SyncID)
+		if err != nil {
+			return nil, err
+		}
+		if d.SyncID == d.HistoryID {
+			d.SyncID = 0
+		}
+		d.Share.SecureDataID = d.ID
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
+
+// ownSecureData - ErrNotFound, если запись id не из личного хранилища пользователя username
+func (p *Postgres) ownSecureData(ctx context.Context, id int64, username string) error {
+	query :=
+	`
+	SELECT id
+	FROM public.secure_data
+	WHERE id = $1 AND user_id = (SELECT id FROM public.users WHERE username = $2) AND ` + personalVault + `;
+	`
+
+	return notFound(p.conn(ctx).QueryRow(ctx, query, id, username).Scan(&id))
+}
+
+// lockRecordUsers - lockChanges владельца записи id, её получателей, участников организации её хранилища
+// и пользователей usernames (например, нового получателя): изменения записи попадают в их синхронизацию
+func (p *Postgres) lockRecordUsers(ctx context.Context, id int64, usernames ...string) error {
+	users :=
+	`
+	SELECT user_id
+	FROM public.secure_data
+	WHERE id = $1
+	UNION
+	SELECT recipient_id
+	FROM public.shares
+	WHERE secure_data_id = $1 AND revoked_at IS NULL
+	UNION
+	SELECT m.user_id
+	FROM public.secure_data d
+	JOIN public.vaults v ON v.id = d.vault_id
+	JOIN public.org_members m ON m.org_id = v.org_id
+	WHERE d.id = $1 AND m.removed_at IS NULL
+	UNION
+	SELECT id
+	FROM public.users
+	WHERE username = ANY($2)
+	`
+
+	return p.lockUsers(ctx, users, id, usernames)
+}
+
+// shareHistory - изменение, открывающее или отзывающее доступ получателя recipientID к записи id:
+// строка истории без снимка, как у конфликтов, поэтому она не ревизия и не удаляется pruneHistory;
+// history_id записи не меняется, получатель узнаёт об изменении из history_id доступа
+func (p *Postgres) shareHistory(ctx context.Context, id int64, method string, recipientID int64) (int64, error) {
+	query :=
+	`
+	INSERT INTO public.history("user_id", "secure_data_id", "method", "recipient_id")
+	SELECT user_id, id, $2, $3
+	FROM public.secure_data
+	WHERE id = $1
+	RETURNING id;
+	`
+
+	var historyID int64
+	if err := p.conn(ctx).QueryRow(ctx, query, id, method, recipientID).Scan(&historyID); err != nil {
+		return 0, notFound(err)
+	}
+
+	query =
+	`
+	UPDATE public.shares
+	SET history_id = $3
+	WHERE secure_data_id = $1 AND recipient_id = $2;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, id, recipientID, historyID); err != nil {
+		return 0, err
+	}
+
+	// уведомление доставляется получателю только после фиксации транзакции
+	_, err := p.conn(ctx).Exec(ctx, `SELECT pg_notify($1, $2::bigint || ':' || $3::bigint);`, changesChannel, recipientID, historyID)
+	return historyID, err
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (p *Postgres) SetTOTPSecret(ctx context.Context, username string, secret string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	INSERT INTO public.user_totp("user_id", "secret")
+	SELECT
+		id AS user_id,
+		$2 AS secret
+	FROM public.users
+	WHERE username = $1
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, last_counter = 0, updated_at = NOW()
+	WHERE NOT user_totp.enabled;
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if err := p.CheckUser(ctx, username); err != nil {
+			return err
+		}
+		return storage.ErrAlreadyExists
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SelectTOTP(ctx context.Context, username string) (structs.TOTP, error) {
+	query :=
+	`
+	SELECT t.secret, t.enabled, t.last_counter,
+		(SELECT COUNT(*) FROM public.totp_backup_codes c WHERE c.user_id = t.user_id AND c.used_at IS NULL)
+	FROM public.user_totp t
+	WHERE t.user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	row := p.conn(ctx).QueryRow(ctx, query, username)
+
+	var totp structs.TOTP
+	err := row.Scan(&totp.Secret, &totp.Enabled, &totp.LastCounter, &totp.BackupCodesLeft)
+
+	return totp, notFound(err)
+}
+
+func (p *Postgres) UseTOTPCounter(ctx context.Context, username string, counter int64) (bool, error) {
+	query :=
+	`
+	UPDATE public.user_totp
+	SET last_counter = $2
+	WHERE last_counter < $2 AND user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, counter)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (p *Postgres) EnableTOTP(ctx context.Context, username string, backupCodeHashes []string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	UPDATE public.user_totp
+	SET enabled = true, updated_at = NOW()
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	if err := p.SetBackupCodes(ctx, username, backupCodeHashes); err != nil {
+		return err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SetBackupCodes(ctx context.Context, username string, backupCodeHashes []string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	DELETE FROM public.totp_backup_codes
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, username); err != nil {
+		return err
+	}
+
+	query =
+	`
+	INSERT INTO public.totp_backup_codes("user_id", "code_hash")
+	SELECT u.id, h.code_hash
+	FROM public.users u, unnest($2::text[]) AS h(code_hash)
+	WHERE u.username = $1;
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, username, backupCodeHashes); err != nil {
+		return err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) UseBackupCode(ctx context.Context, username string, backupCodeHash string) (bool, error) {
+	query :=
+	`
+	UPDATE public.totp_backup_codes
+	SET used_at = NOW()
+	WHERE code_hash = $2 AND used_at IS NULL AND user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, backupCodeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (p *Postgres) DisableTOTP(ctx context.Context, username string) error {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	query :=
+	`
+	DELETE FROM public.totp_backup_codes
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+	if _, err := p.conn(ctx).Exec(ctx, query, username); err != nil {
+		return err
+	}
+
+	query =
+	`
+	DELETE FROM public.user_totp
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1);
+	`
+	tag, err := p.conn(ctx).Exec(ctx, query, username)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("error while commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// personalVault - условие для записи secure_data: основное или именованное личное хранилище
+const personalVault = `(vault_id IS NULL OR vault_id IN (SELECT id FROM public.vaults WHERE org_id IS NULL))`
+
+// orgSelect - записи хранилищ организаций в том виде, в каком их видит участник: после исключения
+// запись неактивна, без содержимого и с history_id исключения. sync_id - позиция в синхронизации
+// участника: записи, изменённые до вступления, передаются с history_id вступления
+const orgSelect = `
+	SELECT d.id,
+		CASE WHEN m.removed_at IS NULL THEN d.data ELSE '' END,
+		CASE WHEN m.removed_at IS NULL THEN d.metadata ELSE '{}'::jsonb END,
+		d.is_active AND m.removed_at IS NULL,
+		CASE WHEN m.removed_at IS NULL THEN d.history_id ELSE m.history_id END,
+		d.kind,
+		CASE WHEN m.removed_at IS NULL THEN COALESCE(d.blob_id, 0) ELSE 0 END,
+		d.vault_id,
+		CASE WHEN m.removed_at IS NULL THEN GREATEST(d.history_id, m.history_id) ELSE m.history_id END AS sync_id
+	FROM public.org_members m
+	JOIN public.vaults v ON v.org_id = m.org_id
+	JOIN public.secure_data d ON d.vault_id = v.id
+	`
+
+// access - кому разрешена операция с записью
+type access struct {
+	// permissions - права получателей доступа (Sharing)
+	permissions []string
+	// roles - роли участников организации
+	roles []string
+}
+
+var (
+	// accessRead - чтение: владелец, получатели доступа, участники организации
+	accessRead = access{
+		permissions: []string{structs.PermissionRead, structs.PermissionWrite},
+		roles:       []string{structs.RoleOwner, structs.RoleAdmin, structs.RoleMember, structs.RoleReadOnly},
+	}
+	// accessWrite - изменение содержимого: владелец, получатели с правом записи, участники кроме read-only
+	accessWrite = access{
+		permissions: []string{structs.PermissionWrite},
+		roles:       []string{structs.RoleOwner, structs.RoleAdmin, structs.RoleMember},
+	}
+	// accessManage - удаление, восстановление и загрузка файлов: владелец и участники кроме read-only
+	accessManage = access{
+		permissions: []string{},
+		roles:       []string{structs.RoleOwner, structs.RoleAdmin, structs.RoleMember},
+	}
+	// accessVault - ревизии и файлы: владелец и участники организации
+	accessVault = access{
+		permissions: []string{},
+		roles:       []string{structs.RoleOwner, structs.RoleAdmin, structs.RoleMember, structs.RoleReadOnly},
+	}
+)
+
+func (p *Postgres) CreateOrganization(ctx context.Context, username string, name string, wrappedKey string) (structs.Organization, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return structs.Organization{}, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	org := structs.Organization{Name: name, Role: structs.RoleOwner, WrappedKey: wrappedKey}
+
+	query :=
+	`
+	INSERT INTO public.organizations("name")
+	VALUES ($1)
+	RETURNING id, created_at;
+	`
+
+	if err := p.conn(ctx).QueryRow(ctx, query, name).Scan(&org.ID, &org.CreatedAt); err != nil {
+		return structs.Organization{}, err
+	}
+
+	query =
+	`
+	INSERT INTO public.org_members("org_id", "user_id", "role", "wrapped_key")
+	SELECT
+		$2 AS org_id,
+		id AS user_id,
+		$3 AS role,
+		$4 AS wrapped_key
+	FROM users
+	where username = $1;
+	`
+
+	tag, err := p.conn(ctx).Exec(ctx, query, username, org.ID, structs.RoleOwner, wrappedKey)
+	if err != nil {
+		return structs.Organization{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return structs.Organization{}, storage.ErrNotFound
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return structs.Organization{}, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return org, nil
+}
+
+func (p *Postgres) SelectOrganizations(ctx context.Context, username string) ([]structs.Organization, error) {
+	query :=
+	`
+	SELECT o.id, o.name, m.role, m.wrapped_key, o.created_at
+	FROM public.organizations o
+	JOIN public.org_members m ON m.org_id = o.id
+	WHERE m.removed_at IS NULL AND m.user_id = (SELECT id FROM public.users WHERE username = $1)
+	ORDER BY o.id;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Organization])
+}
+
+func (p *Postgres) SelectMembers(ctx context.Context, orgID int64, username string) ([]structs.Member, error) {
+	if _, err := p.memberRole(ctx, orgID, username); err != nil {
+		return nil, err
+	}
+
+	query :=
+	`
+	SELECT m.org_id, u.username, m.role, m.created_at
+	FROM public.org_members m
+	JOIN public.users u ON u.id = m.user_id
+	WHERE m.org_id = $1 AND m.removed_at IS NULL
+	ORDER BY m.created_at, u.username;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Member])
+}
+
+func (p *Postgres) SetMember(ctx context.Context, orgID int64, username string, member string, role string, wrappedKey string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	actorRole, memberID, current, err := p.lockMember(ctx, orgID, username, member)
+	if err != nil {
+		return 0, err
+	}
+	if !canManage(actorRole, role) || current != "" && !canManage(actorRole, current) {
+		return 0, storage.ErrForbidden
+	}
+	if current == structs.RoleOwner && role != structs.RoleOwner {
+		if err := p.checkOwners(ctx, orgID); err != nil {
+			return 0, err
+		}
+	}
+
+	// состав записей участника при изменении роли не меняется, поэтому оно не попадает в синхронизацию
+	var historyID int64
+	if current == "" {
+		historyID, err = p.memberHistory(ctx, memberID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	query :=
+	`
+	INSERT INTO public.org_members("org_id", "user_id", "role", "wrapped_key", "history_id")
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (org_id, user_id) DO UPDATE
+	SET role = EXCLUDED.role, wrapped_key = EXCLUDED.wrapped_key,
+		history_id = CASE WHEN EXCLUDED.history_id = 0 THEN org_members.history_id ELSE EXCLUDED.history_id END,
+		created_at = CASE WHEN org_members.removed_at IS NULL THEN org_members.created_at ELSE NOW() END,
+		removed_at = NULL
+	RETURNING history_id;
+	`
+
+	if err := p.conn(ctx).QueryRow(ctx, query, orgID, memberID, role, wrappedKey, historyID).Scan(&historyID); err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, nil
+}
+
+func (p *Postgres) RemoveMember(ctx context.Context, orgID int64, username string, member string) (int64, error) {
+	ctx, err := p.BeginTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while begin transaction: %w", err)
+	}
+	defer p.RollbackTransaction(ctx)
+
+	actorRole, memberID, current, err := p.lockMember(ctx, orgID, username, member)
+	if err != nil {
+		return 0, err
+	}
+	if current == "" {
+		return 0, storage.ErrNotFound
+	}
+	if member != username && !canManage(actorRole, current) {
+		return 0, storage.ErrForbidden
+	}
+	if current == structs.RoleOwner {
+		if err := p.checkOwners(ctx, orgID); err != nil {
+			return 0, err
+		}
+	}
+
+	historyID, err := p.memberHistory(ctx, memberID)
+	if err != nil {
+		return 0, err
+	}
+
+	query :=
+	`
+	UPDATE public.org_members
+	SET removed_at = NOW(), history_id = $3
+	WHERE org_id = $1 AND user_id = $2;
+	`
+
+	if _, err := p.conn(ctx).Exec(ctx, query, orgID, memberID, historyID); err != nil {
+		return 0, err
+	}
+
+	err = p.CommitTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while commit transaction: %w", err)
+	}
+
+	return historyID, nil
+}
+
+func (p *Postgres) CreateVault(ctx context.Context, username string, orgID int64, name string) (structs.Vault, error) {
+	if orgID != 0 {
+		role, err := p.memberRole(ctx, orgID, username)
+		if err != nil {
+			return structs.Vault{}, err
+		}
+		if !canManage(role, structs.RoleMember) {
+			return structs.Vault{}, storage.ErrForbidden
+		}
+	}
+
+	query :=
+	`
+	INSERT INTO public.vaults("name", "user_id", "org_id")
+	SELECT
+		$2 AS name,
+		CASE WHEN $3::bigint = 0 THEN id END AS user_id,
+		NULLIF($3::bigint, 0) AS org_id
+	FROM users
+	where username = $1
+	RETURNING id, name, COALESCE(org_id, 0), created_at;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username, name, orgID)
+	if err != nil {
+		return structs.Vault{}, err
+	}
+
+	vault, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[structs.Vault])
+	return vault, notFound(err)
+}
+
+func (p *Postgres) SelectVaults(ctx context.Context, username string) ([]structs.Vault, error) {
+	query :=
+	`
+	SELECT id, name, COALESCE(org_id, 0), created_at
+	FROM public.vaults
+	WHERE user_id = (SELECT id FROM public.users WHERE username = $1) OR id IN (` + memberVaults("$1") + `)
+	ORDER BY id;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[structs.Vault])
+}
+
+// selectUpdatedOrg - записи хранилищ организаций пользователя с позицией синхронизации > lastID
+func (p *Postgres) selectUpdatedOrg(ctx context.Context, lastID int64, username string, limit int) ([]structs.SecureData, error) {
+	query := orgSelect + `
+	WHERE m.user_id = (SELECT id FROM public.users WHERE username = $1)
+		AND CASE WHEN m.removed_at IS NULL THEN GREATEST(d.history_id, m.history_id) ELSE m.history_id END > $2
+	ORDER BY sync_id
+	LIMIT $3;
+	`
+
+	rows, err := p.conn(ctx).Query(ctx, query, username, lastID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var data []structs.SecureData
+	for rows.Next() {
+		var d structs.SecureData
+		err := rows.Scan(&d.ID, &d.Data, &d.Metadata, &d.IsActive, &d.HistoryID, &d.Kind, &d.BlobID, &d.VaultID, &d.SyncID)
+		if err != nil {
+			return nil, err
+		}
+		if d.SyncID == d.HistoryID {
+			d.SyncID = 0
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
+
+// recordOwner - владелец записи id, если операция с уровнем доступа level разрешена пользователю username.
+// Изменения записи выполняются от имени владельца, чтобы они попадали в её историю
+func (p *Postgres) recordOwner(ctx context.Context, id int64, username string, level access) (string, error) {
+	query :=
+	`
+	SELECT o.username
+	FROM public.secure_data d
+	JOIN public.users o ON o.id = d.user_id
+	WHERE d.id = $1 AND (o.username = $2 AND ` + personalVault + ` OR EXISTS (
+		SELECT 1
+		FROM public.shares s
+		WHERE s.secure_data_id = d.id AND s.revoked_at IS NULL AND s.permission = ANY($3)
+			AND s.recipient_id = (SELECT id FROM public.users WHERE username = $2)
+	) OR EXISTS (
+		SELECT 1
+		FROM public.vaults v
+		JOIN public.org_members m ON m.org_id = v.org_id
+		WHERE v.id = d.vault_id AND m.removed_at IS NULL AND m.role = ANY($4)
+			AND m.user_id = (SELECT id FROM public.users WHERE username = $2)
+	));
+	`
+
+	var owner string
+	err := p.conn(ctx).QueryRow(ctx, query, id, username, level.permissions, level.roles).Scan(&owner)
+	return owner, notFound(err)
+}
+
+// lockRecord - блокировка изменений записи id (lockRecordUsers) с проверкой доступа после блокировки;
+// возвращает владельца записи
+func (p *Postgres) lockRecord(ctx context.Context, id int64, username string, level access) (string, error) {
+	if _, err := p.recordOwner(ctx, id, username, level); err != nil {
+		return "", err
+	}
+	if err := p.lockRecordUsers(ctx, id); err != nil {
+		return "", err
+	}
+	// доступ мог быть отозван до блокировки
+	return p.recordOwner(ctx, id, username, level)
+}
+
+// checkVault - возможность добавить запись пользователя username в хранилище vaultID
+func (p *Postgres) checkVault(ctx context.Context, vaultID int64, username string) error {
+	if vaultID == 0 {
+		return nil
+	}
+
+	query :=
+	`
+	SELECT COALESCE(m.role, '')
+	FROM public.vaults v
+	LEFT JOIN public.org_members m ON m.org_id = v.org_id AND m.removed_at IS NULL
+		AND m.user_id = (SELECT id FROM public.users WHERE username = $2)
+	WHERE v.id = $1 AND (v.user_id = (SELECT id FROM public.users WHERE username = $2) OR m.user_id IS NOT NULL);
+	`
+
+	var role string
+	if err := p.conn(ctx).QueryRow(ctx, query, vaultID, username).Scan(&role); err != nil {
+		return notFound(err)
+	}
+	if role == structs.RoleReadOnly {
+		return storage.ErrForbidden
+	}
+	return nil
+}
+
+// lockVault - lockChanges автора username и участников организации хранилища vaultID (0 - личное
+// хранилище): новая запись попадает в их синхронизацию
+func (p *Postgres) lockVault(ctx context.Context, vaultID int64, username string) error {
+	users :=
+	`
+	SELECT id AS user_id
+	FROM public.users
+	WHERE username = $2
+	UNION
+	SELECT m.user_id
+	FROM public.vaults v
+	JOIN public.org_members m ON m.org_id = v.org_id
+	WHERE v.id = $1 AND m.removed_at IS NULL
+	`
+
+	return p.lockUsers(ctx, users, vaultID, username)
+}
+
+// memberRole - роль пользователя username в организации orgID; ErrNotFound, если он в неё не входит
+func (p *Postgres) memberRole(ctx context.Context, orgID int64, username string) (string, error) {
+	query :=
+	`
+	SELECT role
+	FROM public.org_members
+	WHERE org_id = $1 AND removed_at IS NULL AND user_id = (SELECT id FROM public.users WHERE username = $2);
+	`
+
+	var role string
+	err := p.conn(ctx).QueryRow(ctx, query, orgID, username).Scan(&role)
+	return role, notFound(err)
+}
+
+// lockMember - блокировка организации orgID (изменения её участников выполняются по очереди) и
+// изменений записей участника member; роль username, ID member и его текущая роль ("" - не участник)
+func (p *Postgres) lockMember(ctx context.Context, orgID int64, username string, member string) (actorRole string, memberID int64, current string, err error) {
+	query :=
+	`
+	SELECT id
+	FROM public.organizations
+	WHERE id = $1
+	FOR UPDATE;
+	`
+	if err := p.conn(ctx).QueryRow(ctx, query, orgID).Scan(&orgID); err != nil {
+		return "", 0, "", notFound(err)
+	}
+	if err := p.lockChanges(ctx, member); err != nil {
+		return "", 0, "", err
+	}
+
+	actorRole, err = p.memberRole(ctx, orgID, username)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	query =
+	`
+	SELECT u.id, COALESCE(m.role, '')
+	FROM public.users u
+	LEFT JOIN public.org_members m ON m.org_id = $1 AND m.user_id = u.id AND m.removed_at IS NULL
+	WHERE u.username = $2;
+	`
+	if err := p.conn(ctx).QueryRow(ctx, query, orgID, member).Scan(&memberID, &current); err != nil {
+		return "", 0, "", notFound(err)
+	}
+	return actorRole, memberID, current, nil
+}
+
+// checkOwners - ErrForbidden, если у организации один владелец (изменение лишило бы её владельца)
+func (p *Postgres) checkOwners(ctx context.Context, orgID int64) error {
+	query :=
+	`
+	SELECT COUNT(*)
+	FROM public.org_members
+	WHERE org_id = $1 AND removed_at IS NULL AND role = $2;
+	`
+
+	var owners int
+	if err := p.conn(ctx).QueryRow(ctx, query, orgID, structs.RoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return storage.ErrForbidden
+	}
+	return nil
+}
+
+// memberHistory - номер изменения, которым записи организации становятся доступны участнику userID
+// или перестают быть доступны ему; выдаётся под lockChanges участника
+func (p *Postgres) memberHistory(ctx context.Context, userID int64) (int64, error) {
+	var historyID int64
+	err := p.conn(ctx).QueryRow(ctx, `SELECT nextval(pg_get_serial_sequence('public.history', 'id'));`).Scan(&historyID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = p.conn(ctx).Exec(ctx, `SELECT pg_notify($1, $2::bigint || ':' || $3::bigint);`, changesChannel, userID, historyID)
+	return historyID, err
+}
+
+// memberVaults - подзапрос: хранилища организаций, активным участником которых является пользователь user
+// (параметр запроса)
+func memberVaults(user string) string {
+	return `
+		SELECT v.id
+		FROM public.vaults v
+		JOIN public.org_members m ON m.org_id = v.org_id
+		WHERE m.removed_at IS NULL AND m.user_id = (SELECT id FROM public.users WHERE username = ` + user + `)`
+}
+
+// canManage - роль actor позволяет назначать роль role и изменять участников с ней:
+// владельцы - любых, администраторы - всех, кроме владельцев
+func canManage(actor string, role string) bool {
+	switch actor {
+	case structs.RoleOwner:
+		return true
+	case structs.RoleAdmin:
+		return role != structs.RoleOwner
+	}
+	return false
+}
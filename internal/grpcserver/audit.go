@@ -0,0 +1,75 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	pb "github.com/stepanov-ds/GophKeeper/internal/proto"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/contextKeys"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// audited - методы, вызовы которых записываются в журнал аудита, и их события
+var audited = map[string]string{
+	pb.GophKeeper_RequestChallenge_FullMethodName: structs.AuditChallenge,
+	pb.GophKeeper_Login_FullMethodName:            structs.AuditLogin,
+	pb.GophKeeper_Logout_FullMethodName:           structs.AuditLogout,
+	pb.GophKeeper_Add_FullMethodName:              structs.AuditMutation,
+	pb.GophKeeper_Update_FullMethodName:           structs.AuditMutation,
+	pb.GophKeeper_Delete_FullMethodName:           structs.AuditMutation,
+}
+
+// unaryAudit - запись вызова в журнал аудита тем же способом, что и в middlewares.AuditMiddleware;
+// ставится после unaryAuth. Для входа пользователь берётся из запроса
+func (s *Server) unaryAudit(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	event, found := audited[info.FullMethod]
+	if !found {
+		return handler(ctx, req)
+	}
+
+	resp, err := handler(ctx, req)
+
+	username, _ := ctx.Value(contextKeys.Login).(string)
+	if r, ok := req.(interface{ GetMail() string }); ok {
+		username = r.GetMail()
+	}
+	if username == "" {
+		return resp, err
+	}
+
+	details := mutationDetails(req, resp)
+	if err != nil {
+		details = status.Convert(err).Message()
+	}
+	client := sessionClient(ctx)
+	// событие сохраняется, даже если клиент уже отключился
+	auditErr := s.store.AddAuditEvent(context.WithoutCancel(ctx), structs.AuditEvent{
+		Username:  username,
+		Event:     event,
+		Action:    info.FullMethod,
+		Success:   err == nil,
+		Details:   details,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+	if auditErr != nil {
+		log.Println("error while saving audit event:", auditErr)
+	}
+	return resp, err
+}
+
+// mutationDetails - изменённая запись в том же виде, что и в журнале REST API ("ADD 12")
+func mutationDetails(req any, resp any) string {
+	switch r := resp.(type) {
+	case *pb.AddResponse:
+		return fmt.Sprintf("ADD %d", r.GetSecureDataId())
+	case *pb.UpdateResponse:
+		return fmt.Sprintf("UPDATE %d", req.(*pb.UpdateRequest).GetId())
+	case *pb.DeleteResponse:
+		return fmt.Sprintf("DELETE %d", req.(*pb.DeleteRequest).GetId())
+	}
+	return ""
+}
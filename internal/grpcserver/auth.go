@@ -0,0 +1,118 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	pb "github.com/stepanov-ds/GophKeeper/internal/proto"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/contextKeys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// методы, доступные без токена
+var public = map[string]bool{
+	pb.GophKeeper_Register_FullMethodName:         true,
+	pb.GophKeeper_Verify_FullMethodName:           true,
+	pb.GophKeeper_RequestChallenge_FullMethodName: true,
+	pb.GophKeeper_Login_FullMethodName:            true,
+	pb.GophKeeper_Refresh_FullMethodName:          true,
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if public[info.FullMethod] {
+		return handler(srv, ss)
+	}
+	ctx, err := s.authorize(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorize - проверка токена из метаданных тем же способом, что и в middlewares.AuthMiddleware
+func (s *Server) authorize(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata not found")
+	}
+
+	claims, err := auth.Authorize(ctx, s.store, strings.TrimPrefix(values[0], "Bearer "), sessionClient(ctx).IP)
+	if errors.Is(err, auth.ErrUnauthorized) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	ctx = context.WithValue(ctx, contextKeys.Login, claims.Login)
+	ctx = context.WithValue(ctx, contextKeys.ExpiresAt, claims.ExpiresAt.Time)
+	return context.WithValue(ctx, contextKeys.Session, claims.ID), nil
+}
+
+// allow - ResourceExhausted с заголовком retry-after, если запрос к аккаунту login отклонён защитой от перебора
+func (s *Server) allow(ctx context.Context, login string) error {
+	err := s.guard.Allow(sessionClient(ctx).IP, login)
+	if err == nil {
+		return nil
+	}
+	var limitErr *auth.RateLimitError
+	if errors.As(err, &limitErr) {
+		seconds := strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds())))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", seconds))
+	}
+	return status.Error(codes.ResourceExhausted, err.Error())
+}
+
+// sessionClient - адрес и user-agent клиента для сессии
+func sessionClient(ctx context.Context) auth.Client {
+	var client auth.Client
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("user-agent"); len(values) != 0 {
+		client.UserAgent = values[0]
+	}
+	return client
+}
+
+func loginFrom(ctx context.Context) (string, error) {
+	login, ok := ctx.Value(contextKeys.Login).(string)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token error")
+	}
+	return login, nil
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,389 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	pb "github.com/stepanov-ds/GophKeeper/internal/proto"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/contextKeys"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/kinds"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// syncPageSize - размер страницы выборки из БД при потоковой синхронизации
+const syncPageSize = 100
+
+// Server - реализация gRPC API поверх пакета database
+type Server struct {
+	pb.UnimplementedGophKeeperServer
+	store storage.Storage
+	cache *utils.MemoryCache
+	guard *auth.Guard
+}
+
+// New - gRPC сервер; cache - кэш кодов авторизации и guard - защита от перебора, общие с REST обработчиками
+func New(store storage.Storage, cache *utils.MemoryCache, guard *auth.Guard, opts ...grpc.ServerOption) *grpc.Server {
+	server := &Server{store: store, cache: cache, guard: guard}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(server.unaryAuth, server.unaryAudit),
+		grpc.ChainStreamInterceptor(server.streamAuth),
+	)
+	s := grpc.NewServer(opts...)
+	pb.RegisterGophKeeperServer(s, server)
+	return s
+}
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if !*config.RegistrationEnabled {
+		return nil, status.Error(codes.PermissionDenied, "registration is disabled")
+	}
+	if err := s.allow(ctx, req.GetMail()); err != nil {
+		return nil, err
+	}
+	pending, err := auth.Register(ctx, s.store, req.GetMail())
+	if errors.Is(err, auth.ErrMailDelivery) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.RegisterResponse{Pending: pending}, nil
+}
+
+func (s *Server) Verify(ctx context.Context, req *pb.VerifyRequest) (*pb.VerifyResponse, error) {
+	if !*config.RegistrationEnabled {
+		return nil, status.Error(codes.PermissionDenied, "registration is disabled")
+	}
+	if err := s.allow(ctx, req.GetMail()); err != nil {
+		return nil, err
+	}
+	err := auth.ConfirmRegistration(ctx, s.store, req.GetMail(), req.GetCode())
+	if errors.Is(err, auth.ErrInvalidVerification) {
+		s.guard.Failure(req.GetMail())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.VerifyResponse{}, nil
+}
+
+func (s *Server) RequestChallenge(ctx context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
+	if err := s.allow(ctx, req.GetMail()); err != nil {
+		return nil, err
+	}
+	if err := auth.IssueChallenge(ctx, s.store, s.cache, req.GetMail()); err != nil {
+		if errors.Is(err, auth.ErrMailDelivery) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		if errors.Is(err, storage.ErrPending) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if errors.Is(err, storage.ErrDisabled) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.ChallengeResponse{}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	if err := s.allow(ctx, req.GetMail()); err != nil {
+		return nil, err
+	}
+	if err := auth.CheckChallenge(s.cache, req.GetMail(), req.GetCode()); err != nil {
+		if errors.Is(err, auth.ErrInvalidChallenge) || errors.Is(err, auth.ErrChallengeBurned) {
+			s.guard.Failure(req.GetMail())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	err := auth.CheckSecondFactor(ctx, s.store, req.GetMail(), req.GetTotpCode())
+	if errors.Is(err, auth.ErrSecondFactorRequired) || errors.Is(err, auth.ErrInvalidSecondFactor) {
+		if errors.Is(err, auth.ErrInvalidSecondFactor) {
+			s.guard.Failure(req.GetMail())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := auth.ConsumeChallenge(s.cache, req.GetMail(), req.GetCode()); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	tokens, err := auth.StartSession(ctx, s.store, req.GetMail(), sessionClient(ctx))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.guard.Success(req.GetMail())
+	return &pb.LoginResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+func (s *Server) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.LoginResponse, error) {
+	tokens, err := auth.RefreshSession(ctx, s.store, req.GetRefreshToken(), sessionClient(ctx))
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.LoginResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	login, err := loginFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, _ := ctx.Value(contextKeys.Session).(string)
+
+	err = s.store.RevokeSession(ctx, login, sessionID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.Internal, "error while revoking session in db: %v", err)
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+func (s *Server) Add(ctx context.Context, req *pb.AddRequest) (*pb.AddResponse, error) {
+	login, err := loginFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := req.GetKind()
+	if kind == "" {
+		kind = kinds.Default
+	}
+	metadata, err := validateRecord(kind, req.GetData(), req.GetMetadata(), req.GetValidate())
+	if err != nil {
+		return nil, err
+	}
+
+	secureDataID, historyID, err := s.store.AddSecureData(ctx, login, req.GetVaultId(), kind, req.GetData(), metadata)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "vault %d not found", req.GetVaultId())
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		return nil, status.Errorf(codes.PermissionDenied, "vault %d is read-only", req.GetVaultId())
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error while add secure data in db: %v", err)
+	}
+	return &pb.AddResponse{SecureDataId: secureDataID, HistoryId: historyID}, nil
+}
+
+func (s *Server) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	login, err := loginFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// без kind тип записи не меняется
+	kind := req.GetKind()
+	if kind == "" {
+		kind, err = s.store.SelectSecureDataKind(ctx, req.GetId(), login)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "secure data %d not found", req.GetId())
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error while selecting secure data kind from db: %v", err)
+		}
+	}
+	metadata, err := validateRecord(kind, req.GetData(), req.GetMetadata(), req.GetValidate())
+	if err != nil {
+		return nil, err
+	}
+
+	historyID, err := s.store.UpdateSecureData(ctx, req.GetId(), login, req.GetHistoryId(), kind, req.GetData(), metadata)
+	if err := conflictStatus(err); err != nil {
+		return nil, err
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "secure data %d not found", req.GetId())
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		return nil, status.Errorf(codes.PermissionDenied, "secure data %d is read-only", req.GetId())
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error while update secure data in db: %v", err)
+	}
+	return &pb.UpdateResponse{HistoryId: historyID}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	login, err := loginFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	historyID, err := s.store.DeleteSecureData(ctx, req.GetId(), login, req.GetHistoryId())
+	if err := conflictStatus(err); err != nil {
+		return nil, err
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "secure data %d not found", req.GetId())
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		return nil, status.Errorf(codes.PermissionDenied, "secure data %d is read-only", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error while delete secure data from db: %v", err)
+	}
+	return &pb.DeleteResponse{HistoryId: historyID}, nil
+}
+
+// Sync - потоковая выдача изменений; страницы выбираются из БД по syncPageSize записей
+func (s *Server) Sync(req *pb.SyncRequest, stream grpc.ServerStreamingServer[pb.SecureData]) error {
+	login, err := loginFrom(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	last := req.GetLastHistoryId()
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		data, err := s.store.SelectUpdatedSecureData(stream.Context(), last, login, syncPageSize)
+		if err != nil {
+			return status.Errorf(codes.Internal, "error while selecting data from db: %v", err)
+		}
+		metrics.ObserveSyncPage("grpc", len(data))
+
+		for _, d := range data {
+			if err := stream.Send(toProto(d)); err != nil {
+				return err
+			}
+			last = max(last, d.HistoryID, d.SyncID)
+		}
+
+		if len(data) < syncPageSize {
+			return nil
+		}
+	}
+}
+
+// Watch - поток изменений записей; пульс (history_id = 0) отправляется раз в config.EventsHeartbeat
+func (s *Server) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.Change]) error {
+	login, err := loginFrom(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	if expiresAt, ok := ctx.Value(contextKeys.ExpiresAt).(time.Time); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiresAt)
+		defer cancel()
+	}
+
+	changes, err := s.store.SubscribeChanges(ctx, login)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error while subscribing to changes: %v", err)
+	}
+	current, err := s.store.SelectLastHistoryID(ctx, login)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error while selecting last history id from db: %v", err)
+	}
+
+	last := req.GetLastHistoryId()
+	heartbeat := time.NewTicker(*config.EventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		if current > last {
+			last = current
+			if err := stream.Send(&pb.Change{HistoryId: current}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if stream.Context().Err() != nil {
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+			return status.Error(codes.Unauthenticated, "token expired")
+		case historyID, ok := <-changes:
+			if !ok {
+				// канал закрыт вместе с ctx, причину вернёт ветка ctx.Done
+				changes = nil
+				continue
+			}
+			current = historyID
+		case <-heartbeat.C:
+			if err := stream.Send(&pb.Change{}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toProto - запись в формате gRPC
+func toProto(d structs.SecureData) *pb.SecureData {
+	data := &pb.SecureData{
+		Id:        d.ID,
+		Data:      d.Data,
+		Metadata:  d.Metadata,
+		IsActive:  d.IsActive,
+		HistoryId: d.HistoryID,
+		Kind:      d.Kind,
+		BlobId:    d.BlobID,
+		VaultId:   d.VaultID,
+		SyncId:    d.SyncID,
+	}
+	if d.Share != nil {
+		data.Share = &pb.Share{
+			Owner:      d.Share.Owner,
+			Permission: d.Share.Permission,
+			WrappedKey: d.Share.WrappedKey,
+			Revoked:    d.Share.RevokedAt != nil,
+		}
+	}
+	return data
+}
+
+// conflictStatus - ABORTED с текущей записью в details для *storage.ConflictError, иначе nil
+func conflictStatus(err error) error {
+	var conflict *storage.ConflictError
+	if !errors.As(err, &conflict) {
+		return nil
+	}
+	st, detailsErr := status.New(codes.Aborted, conflict.Error()).WithDetails(toProto(conflict.Current))
+	if detailsErr != nil {
+		return status.Error(codes.Aborted, conflict.Error())
+	}
+	return st.Err()
+}
+
+// validateRecord - те же проверки записи, что и в handlers.Update; возвращает metadata для записи в БД
+func validateRecord(kind string, data string, metadata string, payload string) (string, error) {
+	if !vault.IsCiphertext(data) {
+		return "", status.Error(codes.InvalidArgument, "data must be encrypted on the client")
+	}
+	if metadata == "" {
+		metadata = "{}"
+	}
+	if err := kinds.Validate(kind, []byte(metadata), []byte(payload), time.Now()); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return metadata, nil
+}
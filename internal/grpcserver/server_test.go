@@ -0,0 +1,44 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	pb "github.com/stepanov-ds/GophKeeper/internal/proto"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/storage/memory"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/contextKeys"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// чужая или отсутствующая запись - NOT_FOUND, как и в Add
+func TestUpdateDeleteNotFound(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(storage.Options{})
+	for _, mail := range []string{"alice@example.com", "bob@example.com"} {
+		if err := store.RegisterUser(ctx, mail, nil); err != nil {
+			t.Fatalf("RegisterUser: %v", err)
+		}
+	}
+	data := vault.Prefix + base64.StdEncoding.EncodeToString(make([]byte, 48))
+	id, _, err := store.AddSecureData(ctx, "alice@example.com", 0, "text", data, "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+
+	s := &Server{store: store}
+	ctx = context.WithValue(ctx, contextKeys.Login, "bob@example.com")
+	for _, id := range []int64{id, id + 100} {
+		_, err := s.Update(ctx, &pb.UpdateRequest{Id: id, Kind: "text", Data: data})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Update of %d = %v, expected NotFound", id, err)
+		}
+		_, err = s.Delete(ctx, &pb.DeleteRequest{Id: id})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Delete of %d = %v, expected NotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const (
+	// adminDefaultLimit и adminMaxLimit - размер страницы списка пользователей
+	adminDefaultLimit = 100
+	adminMaxLimit     = 1000
+)
+
+// AdminUsersGet - пользователи, адрес которых содержит ?q=, по возрастанию ID. Следующая страница
+// запрашивается с ?after=<nextCursor>; nextCursor пустой на последней странице
+func AdminUsersGet(c *gin.Context, store storage.Storage) {
+	limit, after := adminDefaultLimit, int64(0)
+	var err error
+	if q := c.Query("limit"); q != "" {
+		if limit, err = strconv.Atoi(q); err != nil || limit <= 0 {
+			err = fmt.Errorf("limit query parameter must be a positive integer")
+		}
+	}
+	if q := c.Query("after"); q != "" && err == nil {
+		if after, err = strconv.ParseInt(q, 10, 64); err != nil || after < 0 {
+			err = fmt.Errorf("after query parameter must be a non-negative integer")
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	limit = min(limit, adminMaxLimit)
+
+	users, err := store.SelectUsers(c.Request.Context(), c.Query("q"), after, limit)
+	if err != nil {
+		err = fmt.Errorf("error while selecting users from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	response := structs.Response{
+		Users: users,
+	}
+	if len(users) == limit {
+		response.NextCursor = strconv.FormatInt(users[len(users)-1].ID, 10)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminUserGet - сведения о пользователе :username
+func AdminUserGet(c *gin.Context, store storage.Storage) {
+	user, err := store.SelectUser(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		adminError(c, "selecting user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		User: &user,
+	})
+}
+
+// AdminUserDisable - блокировка учётной записи :username с отзывом всех её сессий
+func AdminUserDisable(c *gin.Context, store storage.Storage) {
+	setUserDisabled(c, store, true)
+}
+
+// AdminUserEnable - разблокировка учётной записи :username
+func AdminUserEnable(c *gin.Context, store storage.Storage) {
+	setUserDisabled(c, store, false)
+}
+
+func setUserDisabled(c *gin.Context, store storage.Storage, disabled bool) {
+	username, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := store.SetUserDisabled(c.Request.Context(), username, disabled); err != nil {
+		adminError(c, "updating user", err)
+		return
+	}
+
+	message := "ENABLE success"
+	if disabled {
+		message = "DISABLE success"
+	}
+	c.JSON(http.StatusOK, structs.Response{
+		Message: message,
+	})
+}
+
+// AdminUserLogout - отзыв всех сессий пользователя :username
+func AdminUserLogout(c *gin.Context, store storage.Storage) {
+	revoked, err := store.RevokeSessions(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		adminError(c, "revoking sessions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "LOGOUT success",
+		Revoked: revoked,
+	})
+}
+
+// AdminUserDelete - удаление учётной записи :username со всеми её данными
+func AdminUserDelete(c *gin.Context, store storage.Storage) {
+	username, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteUser(c.Request.Context(), username); err != nil {
+		adminError(c, "deleting user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "DELETE success",
+	})
+}
+
+// adminTarget - пользователь :username; администратор не может заблокировать или удалить сам себя
+func adminTarget(c *gin.Context) (string, bool) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return "", false
+	}
+	username := c.Param("username")
+	if username == login {
+		err := fmt.Errorf("administrators cannot disable or delete their own account")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return "", false
+	}
+	return username, true
+}
+
+// adminError - ответ на ошибку операции с пользователем :username
+func adminError(c *gin.Context, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+		err = fmt.Errorf("user %s not found", c.Param("username"))
+	case errors.Is(err, storage.ErrForbidden):
+		status = http.StatusConflict
+		err = fmt.Errorf("user %s is the only owner of an organization with other members", c.Param("username"))
+	default:
+		err = fmt.Errorf("error while %s in db: %w", action, err)
+	}
+	c.Error(err)
+	c.JSON(status, structs.Response{
+		Error: err.Error(),
+	})
+}
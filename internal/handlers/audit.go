@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const (
+	// auditDefaultLimit и auditMaxLimit - размер страницы журнала аудита
+	auditDefaultLimit = 50
+	auditMaxLimit     = 500
+)
+
+// auditColumns - колонки выгрузки журнала аудита в CSV
+var auditColumns = []string{"id", "username", "event", "action", "success", "details", "ip", "userAgent", "createdAt"}
+
+// AuditGet - события журнала аудита пользователя от новых к старым. Следующая страница запрашивается
+// с ?before=<nextCursor>; nextCursor пустой на последней странице
+func AuditGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	limit, before := auditDefaultLimit, int64(0)
+	var err error
+	if q := c.Query("limit"); q != "" {
+		if limit, err = strconv.Atoi(q); err != nil || limit <= 0 {
+			err = fmt.Errorf("limit query parameter must be a positive integer")
+		}
+	}
+	if q := c.Query("before"); q != "" && err == nil {
+		if before, err = strconv.ParseInt(q, 10, 64); err != nil || before < 0 {
+			err = fmt.Errorf("before query parameter must be a non-negative integer")
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	limit = min(limit, auditMaxLimit)
+
+	events, err := store.SelectAuditEvents(c.Request.Context(), login, before, limit)
+	if err != nil {
+		err = fmt.Errorf("error while selecting audit events from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	response := structs.Response{
+		AuditEvents: events,
+	}
+	if len(events) == limit {
+		response.NextCursor = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminAuditExport - выгрузка журнала аудита в JSON Lines (?format=jsonl, по умолчанию) или CSV (?format=csv).
+// ?user= ограничивает выгрузку одним пользователем, ?since= и ?until= (RFC 3339) - интервалом времени
+func AdminAuditExport(c *gin.Context, store storage.Storage) {
+	filter := storage.AuditFilter{Username: c.Query("user")}
+	var err error
+	format := c.DefaultQuery("format", "jsonl")
+	if format != "jsonl" && format != "csv" {
+		err = fmt.Errorf("format query parameter must be jsonl or csv")
+	}
+	if q := c.Query("since"); q != "" && err == nil {
+		if filter.Since, err = time.Parse(time.RFC3339, q); err != nil {
+			err = fmt.Errorf("since query parameter must be a RFC 3339 time")
+		}
+	}
+	if q := c.Query("until"); q != "" && err == nil {
+		if filter.Until, err = time.Parse(time.RFC3339, q); err != nil {
+			err = fmt.Errorf("until query parameter must be a RFC 3339 time")
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var write func(event structs.AuditEvent) error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		w.Write(auditColumns)
+		w.Flush()
+		write = func(event structs.AuditEvent) error {
+			w.Write([]string{
+				strconv.FormatInt(event.ID, 10),
+				event.Username,
+				event.Event,
+				event.Action,
+				strconv.FormatBool(event.Success),
+				event.Details,
+				event.IP,
+				event.UserAgent,
+				event.CreatedAt.UTC().Format(time.RFC3339Nano),
+			})
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		write = func(event structs.AuditEvent) error {
+			return encoder.Encode(event)
+		}
+	}
+
+	if err := store.ExportAuditEvents(c.Request.Context(), filter, write); err != nil {
+		// заголовки уже отправлены, клиент увидит оборванный поток
+		c.Error(fmt.Errorf("error while exporting audit events: %w", err))
+	}
+}
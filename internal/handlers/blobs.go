@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// BlobCreate - начало загрузки бинарного содержимого записи
+func BlobCreate(c *gin.Context, store storage.Storage) {
+	var bodyJSON struct {
+		SecureDataID int64  `json:"secureDataID"`
+		Size         int64  `json:"size"`
+		SHA256       string `json:"sha256"`
+	}
+	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if bodyJSON.Size <= 0 || bodyJSON.Size > *config.BlobMaxSize {
+		err := fmt.Errorf("size must be between 1 and %d bytes", *config.BlobMaxSize)
+		c.Error(err)
+		c.JSON(http.StatusRequestEntityTooLarge, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if raw, err := hex.DecodeString(bodyJSON.SHA256); err != nil || len(raw) != sha256.Size {
+		err := fmt.Errorf("sha256 must be a hex encoded SHA-256 hash")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	blobID, err := store.CreateBlob(c.Request.Context(), login, bodyJSON.SecureDataID, bodyJSON.Size, bodyJSON.SHA256)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("secure data %d not found", bodyJSON.SecureDataID)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	// файл учитывается в квотах автора записи, как и её данные
+	var quota *storage.QuotaError
+	if errors.As(err, &quota) {
+		c.Error(err)
+		c.JSON(http.StatusRequestEntityTooLarge, structs.Response{
+			Error:         err.Error(),
+			QuotaExceeded: quota.Name,
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while creating blob in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "upload started",
+		Blob: &structs.Blob{
+			ID:           blobID,
+			SecureDataID: bodyJSON.SecureDataID,
+			Size:         bodyJSON.Size,
+			SHA256:       bodyJSON.SHA256,
+		},
+	})
+}
+
+// BlobGet - состояние загрузки; received - смещение, с которого продолжается прерванная загрузка
+func BlobGet(c *gin.Context, store storage.Storage) {
+	blob, ok := selectBlob(c, store)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Blob: &blob,
+	})
+}
+
+// BlobUpload - приём очередной порции содержимого, начиная со смещения offset
+func BlobUpload(c *gin.Context, store storage.Storage) {
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		err := fmt.Errorf("offset query parameter must be a non-negative integer")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	blob, ok := selectBlob(c, store)
+	if !ok {
+		return
+	}
+	if blob.IsComplete || offset != blob.Received {
+		err := fmt.Errorf("upload must continue from offset %d", blob.Received)
+		c.Error(err)
+		c.JSON(http.StatusConflict, structs.Response{
+			Error: err.Error(),
+			Blob:  &blob,
+		})
+		return
+	}
+
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, blob.Size-offset)
+	buf := make([]byte, *config.BlobChunkSize)
+
+	for {
+		n, readErr := io.ReadFull(body, buf)
+		if n > 0 {
+			blob.Received, err = store.AppendBlobChunk(ctx, blob.ID, login, blob.Received, buf[:n])
+			if errors.Is(err, storage.ErrNotFound) {
+				// участник read-only видит файл, но не может загружать его содержимое
+				err = fmt.Errorf("blob %d not found", blob.ID)
+				c.Error(err)
+				c.JSON(http.StatusNotFound, structs.Response{
+					Error: err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, storage.ErrBlobOffset) {
+				// параллельная загрузка того же содержимого
+				err = fmt.Errorf("concurrent upload detected, check blob state and resume")
+				c.Error(err)
+				c.JSON(http.StatusConflict, structs.Response{
+					Error: err.Error(),
+				})
+				return
+			}
+			if err != nil {
+				err = fmt.Errorf("error while saving blob chunk in db: %w", err)
+				c.Error(err)
+				c.JSON(http.StatusInternalServerError, structs.Response{
+					Error: err.Error(),
+					Blob:  &blob,
+				})
+				return
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			// принятые части сохранены, клиент продолжит с blob.Received
+			status := http.StatusBadRequest
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(readErr, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			err = fmt.Errorf("error while reading body: %w", readErr)
+			c.Error(err)
+			c.JSON(status, structs.Response{
+				Error: err.Error(),
+				Blob:  &blob,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "chunk saved",
+		Blob:    &blob,
+	})
+}
+
+// BlobComplete - проверка размера и хэша загруженного содержимого и привязка его к записи
+func BlobComplete(c *gin.Context, store storage.Storage) {
+	blob, ok := selectBlob(c, store)
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	if blob.IsComplete || blob.Received != blob.Size {
+		err := fmt.Errorf("upload is not finished: received %d of %d bytes", blob.Received, blob.Size)
+		c.Error(err)
+		c.JSON(http.StatusConflict, structs.Response{
+			Error: err.Error(),
+			Blob:  &blob,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	hash := sha256.New()
+	err := store.ReadBlobChunks(ctx, blob.ID, 0, func(data []byte) error {
+		_, err := hash.Write(data)
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("error while reading blob from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != blob.SHA256 {
+		// содержимое повреждено - загрузка начинается заново
+		err := store.ResetBlob(ctx, blob.ID, login)
+		if errors.Is(err, storage.ErrNotFound) {
+			err = fmt.Errorf("blob %d not found", blob.ID)
+			c.Error(err)
+			c.JSON(http.StatusNotFound, structs.Response{
+				Error: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.Error(fmt.Errorf("error while resetting blob in db: %w", err))
+		}
+		err = fmt.Errorf("sha256 mismatch, upload must be restarted")
+		c.Error(err)
+		c.JSON(http.StatusUnprocessableEntity, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	blob.HistoryID, err = store.CompleteBlob(ctx, blob.ID, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("blob %d not found", blob.ID)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while completing blob in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	blob.IsComplete = true
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message:   "upload complete",
+		HistoryID: blob.HistoryID,
+		Blob:      &blob,
+	})
+}
+
+// BlobDownload - потоковая выдача содержимого, начиная со смещения offset (для докачки)
+func BlobDownload(c *gin.Context, store storage.Storage) {
+	var offset int64
+	if q := c.Query("offset"); q != "" {
+		var err error
+		offset, err = strconv.ParseInt(q, 10, 64)
+		if err != nil || offset < 0 {
+			err := fmt.Errorf("offset query parameter must be a non-negative integer")
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, structs.Response{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	blob, ok := selectBlob(c, store)
+	if !ok {
+		return
+	}
+	if !blob.IsComplete {
+		err := fmt.Errorf("upload is not finished")
+		c.Error(err)
+		c.JSON(http.StatusConflict, structs.Response{
+			Error: err.Error(),
+			Blob:  &blob,
+		})
+		return
+	}
+	if offset > blob.Size {
+		err := fmt.Errorf("offset is beyond blob size %d", blob.Size)
+		c.Error(err)
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", strconv.FormatInt(blob.Size-offset, 10))
+	c.Header("X-Content-SHA256", blob.SHA256)
+	c.Status(http.StatusOK)
+
+	err := store.ReadBlobChunks(c.Request.Context(), blob.ID, offset, func(data []byte) error {
+		if _, err := c.Writer.Write(data); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// заголовки уже отправлены, клиент увидит оборванный поток и продолжит по offset
+		c.Error(fmt.Errorf("error while streaming blob: %w", err))
+	}
+}
+
+func selectBlob(c *gin.Context, store storage.Storage) (structs.Blob, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid blob id: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return structs.Blob{}, false
+	}
+
+	login, ok := contextLogin(c)
+	if !ok {
+		return structs.Blob{}, false
+	}
+
+	blob, err := store.SelectBlob(c.Request.Context(), id, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("blob %d not found", id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return blob, false
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting blob from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return blob, false
+	}
+
+	return blob, true
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// contextLogin - логин пользователя, сохранённый AuthMiddleware; при ошибке ответ уже отправлен
+func contextLogin(c *gin.Context) (string, bool) {
+	l, exist := c.Get("login")
+	if !exist {
+		err := fmt.Errorf("authorization token error")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return "", false
+	}
+
+	login, ok := l.(string)
+	if !ok {
+		err := fmt.Errorf("type assertion error (login)")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return "", false
+	}
+
+	return login, true
+}
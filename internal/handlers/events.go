@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// eventsRetry - пауза перед переподключением клиента после обрыва потока
+const eventsRetry = 3 * time.Second
+
+// Events - поток Server-Sent Events: событие change с historyID после каждого изменения записей пользователя.
+// С заголовком Last-Event-ID событие с последним historyID отправляется сразу, если изменения были пропущены.
+// Поток закрывается, когда истекает токен доступа, и клиент подключается заново с новым токеном
+func Events(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	var last int64
+	resume := c.GetHeader("Last-Event-ID")
+	if resume != "" {
+		var err error
+		last, err = strconv.ParseInt(resume, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("error while parsing Last-Event-ID: %w", err)
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, structs.Response{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if expiresAt, ok := c.Value("expiresAt").(time.Time); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiresAt)
+		defer cancel()
+	}
+
+	// подписка до выборки последнего historyID, чтобы не пропустить изменение между ними
+	changes, err := store.SubscribeChanges(ctx, login)
+	if err != nil {
+		err = fmt.Errorf("error while subscribing to changes: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	current, err := store.SelectLastHistoryID(ctx, login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting last history id from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if resume == "" {
+		last = current
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", eventsRetry.Milliseconds())
+
+	send := func(historyID int64) {
+		if historyID <= last {
+			return
+		}
+		last = historyID
+		fmt.Fprintf(c.Writer, "id: %d\nevent: change\ndata: {\"historyID\":%d}\n\n", historyID, historyID)
+	}
+	send(current)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(*config.EventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case historyID, ok := <-changes:
+			if !ok {
+				return
+			}
+			send(historyID)
+		case <-heartbeat.C:
+			// комментарий не виден клиенту, но не даёт прокси закрыть простаивающее соединение
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// KeysGet - выдача параметров KDF и обёрнутого ключа хранилища для разблокировки на новом устройстве
+func KeysGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	keys, err := store.SelectUserKeys(c.Request.Context(), login)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: "vault keys are not set",
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting keys from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Keys: &keys,
+	})
+}
+
+// KeysPut - сохранение параметров KDF и обёрнутого ключа (создание хранилища или смена мастер-пароля)
+// вместо версии ключей version из запроса; 409, если ключи уже заменены
+func KeysPut(c *gin.Context, store storage.Storage) {
+	var bodyJSON structs.UserKeys
+	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if err := bodyJSON.KDF.Validate(); err != nil {
+		err = fmt.Errorf("invalid KDF parameters: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if !vault.IsCiphertext(bodyJSON.WrappedKey) {
+		err := fmt.Errorf("wrappedKey must be encrypted on the client")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	// пара ключей для общих записей необязательна; без неё сохранённая пара не меняется
+	if bodyJSON.PublicKey != "" || bodyJSON.WrappedPrivateKey != "" {
+		err := vault.ValidatePublicKey(bodyJSON.PublicKey)
+		if err == nil && !vault.IsCiphertext(bodyJSON.WrappedPrivateKey) {
+			err = fmt.Errorf("wrappedPrivateKey must be encrypted on the client")
+		}
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, structs.Response{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	version, err := store.SetUserKeys(c.Request.Context(), login, bodyJSON)
+	// ключи заменены другим устройством (например, сменой мастер-пароля): клиент получает их заново
+	if errors.Is(err, storage.ErrConflict) {
+		err = fmt.Errorf("keys were changed after version %d", bodyJSON.Version)
+		c.Error(err)
+		c.JSON(http.StatusConflict, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while saving keys in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	bodyJSON.Version = version
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "keys saved",
+		Keys:    &bodyJSON,
+	})
+}
@@ -1,22 +1,20 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/stepanov-ds/GophKeeper/internal/config"
-	"github.com/stepanov-ds/GophKeeper/internal/database"
-	"github.com/stepanov-ds/GophKeeper/internal/mail"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 	"github.com/stepanov-ds/GophKeeper/internal/utils"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
 )
 
-func LoginGet(c *gin.Context, cache *utils.MemoryCache) {
+func LoginGet(c *gin.Context, store storage.Storage, cache *utils.MemoryCache, guard *auth.Guard) {
 	var bodyJSON struct {
 		Mail string `json:"mail"`
 	}
@@ -28,39 +26,38 @@ func LoginGet(c *gin.Context, cache *utils.MemoryCache) {
 		})
 		return
 	}
-
-	if err := database.CheckUser(bodyJSON.Mail); err != nil {
-		err = fmt.Errorf("error while checking user: %w", err)
-		c.Error(err)
-		c.JSON(http.StatusBadRequest, structs.Response{
-			Error: err.Error(),
-		})
+	c.Set("auditLogin", bodyJSON.Mail)
+	if rateLimited(c, guard, bodyJSON.Mail) {
 		return
 	}
 
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		err = fmt.Errorf("error while generating challenge string: %w", err)
+	err := auth.IssueChallenge(c.Request.Context(), store, cache, bodyJSON.Mail)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, auth.ErrMailDelivery) {
+			status = http.StatusBadGateway
+		}
+		if errors.Is(err, storage.ErrPending) || errors.Is(err, storage.ErrDisabled) {
+			status = http.StatusForbidden
+		}
 		c.Error(err)
-		c.JSON(http.StatusBadRequest, structs.Response{
+		c.JSON(status, structs.Response{
 			Error: err.Error(),
 		})
 		return
 	}
-	challenge := hex.EncodeToString(bytes)
 
-	cache.Set(bodyJSON.Mail, challenge, 5*time.Minute)
-
-	mail.Send(bodyJSON.Mail, challenge)
 	c.JSON(http.StatusOK, structs.Response{
-		Message: challenge,
+		Message: "code sent",
 	})
 }
 
-func LoginPost(c *gin.Context, cache *utils.MemoryCache) {
+func LoginPost(c *gin.Context, store storage.Storage, cache *utils.MemoryCache, guard *auth.Guard) {
 	var bodyJSON struct {
 		Login    string `json:"login"`
 		Password string `json:"password"`
+		// TOTP - код приложения-аутентификатора или резервный код, если подключён второй фактор
+		TOTP string `json:"totp"`
 	}
 	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
 		err = fmt.Errorf("error while parsing JSON: %w", err)
@@ -70,9 +67,15 @@ func LoginPost(c *gin.Context, cache *utils.MemoryCache) {
 		})
 		return
 	}
-	challenge, success := cache.Get(bodyJSON.Login)
-	if !success {
-		err := fmt.Errorf("no challenge in cache")
+	c.Set("auditLogin", bodyJSON.Login)
+	if rateLimited(c, guard, bodyJSON.Login) {
+		return
+	}
+
+	if err := auth.CheckChallenge(cache, bodyJSON.Login, bodyJSON.Password); err != nil {
+		if errors.Is(err, auth.ErrInvalidChallenge) || errors.Is(err, auth.ErrChallengeBurned) {
+			guard.Failure(bodyJSON.Login)
+		}
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, structs.Response{
 			Error: err.Error(),
@@ -80,8 +83,26 @@ func LoginPost(c *gin.Context, cache *utils.MemoryCache) {
 		return
 	}
 
-	if challenge != bodyJSON.Password {
-		err := fmt.Errorf("invalid challenge")
+	err := auth.CheckSecondFactor(c.Request.Context(), store, bodyJSON.Login, bodyJSON.TOTP)
+	if errors.Is(err, auth.ErrSecondFactorRequired) || errors.Is(err, auth.ErrInvalidSecondFactor) {
+		if errors.Is(err, auth.ErrInvalidSecondFactor) {
+			guard.Failure(bodyJSON.Login)
+		}
+		c.Error(err)
+		c.JSON(http.StatusUnauthorized, structs.Response{
+			Error:        err.Error(),
+			TOTPRequired: true,
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err := auth.ConsumeChallenge(cache, bodyJSON.Login, bodyJSON.Password); err != nil {
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, structs.Response{
 			Error: err.Error(),
@@ -89,37 +110,41 @@ func LoginPost(c *gin.Context, cache *utils.MemoryCache) {
 		return
 	}
 
-	tokenString, err := generateJWT(bodyJSON.Login)
+	tokens, err := auth.StartSession(c.Request.Context(), store, bodyJSON.Login, sessionClient(c))
 	if err != nil {
-		err := fmt.Errorf("error while generating token: %w", err)
+		status := http.StatusInternalServerError
+		// учётная запись заблокирована или удалена после выдачи кода
+		if errors.Is(err, storage.ErrNotFound) {
+			status = http.StatusForbidden
+		}
 		c.Error(err)
-		c.JSON(http.StatusBadRequest, structs.Response{
+		c.JSON(status, structs.Response{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("Authorization", tokenString, 86400, "", "", false, true)
+	guard.Success(bodyJSON.Login)
+	setSessionCookies(c, tokens)
 	c.JSON(http.StatusOK, structs.Response{
 		Message: "authorized",
 	})
 }
 
-type Claims struct {
-	jwt.RegisteredClaims
-	Login string
-}
+// rateLimited - 429 с Retry-After, если запрос к аккаунту login отклонён защитой от перебора
+func rateLimited(c *gin.Context, guard *auth.Guard, login string) bool {
+	err := guard.Allow(c.ClientIP(), login)
+	if err == nil {
+		return false
+	}
 
-func generateJWT(login string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Login: login,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	var limitErr *auth.RateLimitError
+	if errors.As(err, &limitErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(config.JWTKey)
+	c.Error(err)
+	c.JSON(http.StatusTooManyRequests, structs.Response{
+		Error: err.Error(),
+	})
+	return true
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/mail"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/storage/memory"
+	"github.com/stepanov-ds/GophKeeper/internal/utils"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// codeMailer - последний отправленный код авторизации
+type codeMailer struct {
+	mu   sync.Mutex
+	code string
+}
+
+func (m *codeMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.code = regexp.MustCompile(`[0-9a-f]{32}`).FindString(msg.Text)
+	return nil
+}
+
+// код из письма действует, пока вход не завершён вторым фактором
+func TestLoginWithTOTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := memory.New(storage.Options{})
+	if err := store.RegisterUser(ctx, "alice@example.com", nil); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	key := []byte("12345678901234567890")
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	if err := store.SetTOTPSecret(ctx, "alice@example.com", secret); err != nil {
+		t.Fatalf("SetTOTPSecret: %v", err)
+	}
+	if err := store.EnableTOTP(ctx, "alice@example.com", nil); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+
+	mailer := &codeMailer{}
+	mail.SetMailer(mailer)
+	t.Cleanup(func() { mail.SetMailer(mail.NewWriterMailer(io.Discard)) })
+
+	cache := utils.NewMemoryCache(time.Hour)
+	guard := auth.NewGuard()
+	r := gin.New()
+	r.GET("/login", func(c *gin.Context) {
+		LoginGet(c, store, cache, guard)
+	})
+	r.POST("/login", func(c *gin.Context) {
+		LoginPost(c, store, cache, guard)
+	})
+	do := func(method string, body any) (int, structs.Response) {
+		t.Helper()
+		raw, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/login", bytes.NewReader(raw)))
+		var resp structs.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s /login response %q: %v", method, w.Body.String(), err)
+		}
+		return w.Code, resp
+	}
+
+	if status, resp := do(http.MethodGet, map[string]string{"mail": "alice@example.com"}); status != http.StatusOK {
+		t.Fatalf("GET /login = %d %+v", status, resp)
+	}
+	code := mailer.code
+	if code == "" {
+		t.Fatal("no code in mail")
+	}
+
+	status, resp := do(http.MethodPost, map[string]string{"login": "alice@example.com", "password": code})
+	if status != http.StatusUnauthorized || !resp.TOTPRequired {
+		t.Fatalf("POST /login without totp = %d %+v", status, resp)
+	}
+
+	totp := totpCode(key, time.Now().Unix()/30)
+	status, resp = do(http.MethodPost, map[string]string{"login": "alice@example.com", "password": code, "totp": totp})
+	if status != http.StatusOK {
+		t.Fatalf("POST /login with totp = %d %+v", status, resp)
+	}
+
+	// после входа код одноразовый
+	status, resp = do(http.MethodPost, map[string]string{"login": "alice@example.com", "password": code, "totp": totp})
+	if status != http.StatusBadRequest {
+		t.Fatalf("POST /login with used code = %d %+v", status, resp)
+	}
+}
+
+// totpCode - код TOTP (RFC 6238, SHA1, 6 цифр) для шага counter
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1_000_000)
+}
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+)
+
+// AdminMiddleware - доступ только с ролью администратора в токене; ставится после AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != auth.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: admin role required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// AuditMiddleware - запись события event в журнал аудита после выполнения запроса. Пользователь берётся
+// из AuthMiddleware, а для входа - из ключа "auditLogin", который обработчик заполняет после разбора
+// запроса; запросы без пользователя и отклонённые ограничением частоты (429) не записываются, события
+// незарегистрированных адресов отбрасывает хранилище. В Details попадают параметры маршрута и пояснение
+// обработчика из ключа "audit", для неудачного запроса - последняя ошибка. Ставится после
+// IdempotencyMiddleware, чтобы повторы с сохранённым ответом не попадали в журнал
+func AuditMiddleware(store storage.Audit, event string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() == http.StatusTooManyRequests {
+			return
+		}
+		username := c.GetString("login")
+		if username == "" {
+			username = c.GetString("auditLogin")
+		}
+		if username == "" {
+			return
+		}
+
+		var details []string
+		for _, p := range c.Params {
+			details = append(details, p.Key+"="+p.Value)
+		}
+		success := c.Writer.Status() < http.StatusBadRequest
+		if note := c.GetString("audit"); note != "" && success {
+			details = append(details, note)
+		}
+		if err := c.Errors.Last(); err != nil && !success {
+			details = append(details, err.Error())
+		}
+
+		// событие сохраняется, даже если клиент уже отключился
+		err := store.AddAuditEvent(context.WithoutCancel(c.Request.Context()), structs.AuditEvent{
+			Username:  username,
+			Event:     event,
+			Action:    c.Request.Method + " " + c.FullPath(),
+			Success:   success,
+			Details:   strings.Join(details, " "),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		if err != nil {
+			c.Error(fmt.Errorf("error while saving audit event: %w", err))
+		}
+	}
+}
@@ -1,20 +1,15 @@
 package middlewares
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 )
 
-type Claims struct {
-	Login string `json:"login"`
-	jwt.RegisteredClaims
-}
-
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(store storage.Sessions) gin.HandlerFunc {
 	// Получаем токен из куки "Authorization"
 	return func(c *gin.Context) {
 		tokenString, err := c.Cookie("Authorization")
@@ -24,23 +19,25 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Парсим и валидируем токен
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return config.JWTKey, nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid token"})
+		// Парсим и валидируем токен, проверяем, что сессия не отозвана
+		claims, err := auth.Authorize(c.Request.Context(), store, tokenString, c.ClientIP())
+		if errors.Is(err, auth.ErrUnauthorized) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Сохраняем логин в контексте Gin для последующего использования
+		// Сохраняем логин и сессию в контексте Gin для последующего использования
 		c.Set("login", claims.Login)
+		c.Set("role", claims.Role)
+		c.Set("session", claims.ID)
+		c.Set("expiresAt", claims.ExpiresAt.Time)
 		
 		c.Next()
 	}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+)
+
+const (
+	// maxIdempotencyKeyLength - ограничение длины заголовка Idempotency-Key
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockTimeout - через столько захват ключа без ответа (например, после падения сервера) считается брошенным
+	idempotencyLockTimeout = time.Minute
+)
+
+// IdempotencyMiddleware - повтор запроса с тем же заголовком Idempotency-Key получает сохранённый ответ
+// вместо повторного выполнения. Ключ принадлежит пользователю и действует config.IdempotencyTTL;
+// тот же ключ с другим запросом отклоняется. Должен стоять после AuthMiddleware
+func IdempotencyMiddleware(store storage.Idempotency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must not be longer than %d", maxIdempotencyKeyLength)})
+			return
+		}
+		login := c.GetString("login")
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "error while reading body: " + err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", c.Request.Method, c.Request.URL.Path)
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		request, reserved, err := store.ReserveIdempotencyKey(ctx, login, key, requestHash,
+			now.Add(*config.IdempotencyTTL), now.Add(-idempotencyLockTimeout))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error while reserving idempotency key: " + err.Error()})
+			return
+		}
+		if !reserved {
+			switch {
+			case request.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case request.Status == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(request.Status, "application/json; charset=utf-8", request.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// ответ сохраняется, даже если клиент уже отключился
+		ctx = context.WithoutCancel(ctx)
+		status := w.Status()
+		if status >= http.StatusInternalServerError {
+			// запрос не выполнен - повтор с тем же ключом должен выполниться заново
+			err = store.DeleteIdempotencyKey(ctx, login, key)
+		} else {
+			err = store.CompleteIdempotencyKey(ctx, login, key, status, w.body.Bytes())
+		}
+		if err != nil {
+			c.Error(fmt.Errorf("error while saving idempotent response: %w", err))
+		}
+	}
+}
+
+// ExpireIdempotencyKeys - периодическое удаление ключей старше config.IdempotencyTTL
+func ExpireIdempotencyKeys(ctx context.Context, store storage.Idempotency, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+				log.Println("error while deleting expired idempotency keys:", err)
+			}
+		}
+	}
+}
+
+// recordingWriter - копия тела ответа для сохранения вместе с ключом
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+)
+
+// MetricsMiddleware - учёт числа и длительности запросов по маршруту gin и статусу ответа.
+// Запросы к несуществующим маршрутам учитываются вместе, чтобы не плодить метки
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
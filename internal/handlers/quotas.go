@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// UsageGet - объём записей пользователя и его квоты
+func UsageGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	usage, err := store.SelectUsage(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting usage from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Usage: &usage,
+	})
+}
+
+// AdminUserQuotaPut - квоты пользователя :username вместо значений по умолчанию
+func AdminUserQuotaPut(c *gin.Context, store storage.Storage) {
+	var quota structs.Quota
+	if err := c.ShouldBindBodyWithJSON(&quota); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if quota.Records < 0 || quota.Bytes < 0 || quota.RecordSize < 0 || quota.Revisions < 0 {
+		err := fmt.Errorf("quotas must not be negative (0 - unlimited)")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	setUserQuota(c, store, &quota)
+}
+
+// AdminUserQuotaDelete - возврат пользователя :username к квотам по умолчанию
+func AdminUserQuotaDelete(c *gin.Context, store storage.Storage) {
+	setUserQuota(c, store, nil)
+}
+
+func setUserQuota(c *gin.Context, store storage.Storage, quota *structs.Quota) {
+	ctx := c.Request.Context()
+	username := c.Param("username")
+	if err := store.SetUserQuota(ctx, username, quota); err != nil {
+		adminError(c, "saving quota", err)
+		return
+	}
+	usage, err := store.SelectUsage(ctx, username)
+	if err != nil {
+		adminError(c, "selecting usage", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "quota saved",
+		Usage:   &usage,
+	})
+}
@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stepanov-ds/GophKeeper/internal/database"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
 )
 
-func Register(c *gin.Context) {
+func Register(c *gin.Context, store storage.Storage, guard *auth.Guard) {
 	var bodyJSON struct {
 		Mail string `json:"mail"`
 	}
@@ -21,17 +23,80 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
-	if err := database.RegisterUser(bodyJSON.Mail); err != nil {
-		err = fmt.Errorf("error while inserting user in DB: %w", err)
+	if rateLimited(c, guard, bodyJSON.Mail) {
+		return
+	}
+	pending, err := auth.Register(c.Request.Context(), store, bodyJSON.Mail)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, auth.ErrMailDelivery) {
+			status = http.StatusBadGateway
+		}
 		c.Error(err)
-		c.JSON(http.StatusBadRequest, structs.Response{
+		c.JSON(status, structs.Response{
 			Error: err.Error(),
 		})
 		return
 	}
 
+	if pending {
+		c.JSON(http.StatusOK, structs.Response{
+			Message: "verification code sent to " + bodyJSON.Mail,
+			Pending: true,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, structs.Response{
 		Message: "registration success",
 	})
 
 }
+
+// VerifyPost - подтверждение регистрации кодом из письма
+func VerifyPost(c *gin.Context, store storage.Storage, guard *auth.Guard) {
+	var bodyJSON struct {
+		Mail string `json:"mail"`
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	verify(c, store, guard, bodyJSON.Mail, bodyJSON.Code)
+}
+
+// VerifyGet - подтверждение регистрации по ссылке из письма
+func VerifyGet(c *gin.Context, store storage.Storage, guard *auth.Guard) {
+	verify(c, store, guard, c.Query("mail"), c.Query("code"))
+}
+
+func verify(c *gin.Context, store storage.Storage, guard *auth.Guard, mail string, code string) {
+	if rateLimited(c, guard, mail) {
+		return
+	}
+
+	err := auth.ConfirmRegistration(c.Request.Context(), store, mail, code)
+	if errors.Is(err, auth.ErrInvalidVerification) {
+		guard.Failure(mail)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "registration confirmed",
+	})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// RevisionsGet - ревизии записи от новых к старым
+func RevisionsGet(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := store.SelectRevisions(c.Request.Context(), id, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("secure data %d not found", id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting revisions from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Revisions: revisions,
+	})
+}
+
+// RevisionGet - содержимое ревизии historyID записи
+func RevisionGet(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	historyID, ok := paramID(c, "historyID")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	revision, err := store.SelectRevision(c.Request.Context(), id, login, historyID)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("revision %d of secure data %d not found", historyID, id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting revision from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Revision: &revision,
+	})
+}
+
+// HistoryRetentionGet - число хранимых ревизий каждой записи пользователя
+func HistoryRetentionGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	limit, err := store.SelectHistoryRetention(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting history retention from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	revisions, ok := revisionsQuota(c, store, login)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		HistoryRetention: &structs.HistoryRetention{
+			Limit:   limit,
+			Default: revisions,
+		},
+	})
+}
+
+// HistoryRetentionPut - изменение числа хранимых ревизий; квоту ревизий пользователя превысить нельзя.
+// Лишние ревизии удаляются при следующем изменении записи.
+func HistoryRetentionPut(c *gin.Context, store storage.Storage) {
+	var bodyJSON struct {
+		Limit int `json:"limit"`
+	}
+	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+	revisions, ok := revisionsQuota(c, store, login)
+	if !ok {
+		return
+	}
+
+	if bodyJSON.Limit < 0 || (revisions > 0 && bodyJSON.Limit > revisions) {
+		err := fmt.Errorf("limit must be between 0 (server default) and %d", revisions)
+		if revisions == 0 {
+			err = fmt.Errorf("limit must not be negative")
+		}
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if err := store.SetHistoryRetention(c.Request.Context(), login, bodyJSON.Limit); err != nil {
+		err = fmt.Errorf("error while saving history retention in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "history retention saved",
+		HistoryRetention: &structs.HistoryRetention{
+			Limit:   bodyJSON.Limit,
+			Default: revisions,
+		},
+	})
+}
+
+// revisionsQuota - квота ревизий пользователя (значение сервера или заданное администратором);
+// при ошибке ответ уже отправлен
+func revisionsQuota(c *gin.Context, store storage.Storage, login string) (int, bool) {
+	usage, err := store.SelectUsage(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting quota from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return 0, false
+	}
+	return usage.Quota.Revisions, true
+}
+
+// paramID - числовой параметр пути name; при ошибке ответ уже отправлен
+func paramID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid %s: %w", name, err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return 0, false
+	}
+	return id, true
+}
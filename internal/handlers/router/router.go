@@ -2,34 +2,200 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
 	"github.com/stepanov-ds/GophKeeper/internal/config"
 	"github.com/stepanov-ds/GophKeeper/internal/handlers"
 	"github.com/stepanov-ds/GophKeeper/internal/handlers/middlewares"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 	"github.com/stepanov-ds/GophKeeper/internal/utils"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
 )
 
-// Устанавливает маршруты
-func Route(r *gin.Engine) {
+// Устанавливает маршруты; store - хранилище данных, cache - кэш кодов авторизации,
+// guard - защита входа, регистрации и кодов второго фактора от перебора
+func Route(r *gin.Engine, store storage.Storage, cache *utils.MemoryCache, guard *auth.Guard) {
 	r.RedirectTrailingSlash = true
+	authorized := middlewares.AuthMiddleware(store)
+	idempotent := middlewares.IdempotencyMiddleware(store)
+	audited := middlewares.AuditMiddleware(store, structs.AuditMutation)
+	r.Use(middlewares.MetricsMiddleware())
+	if *config.MetricsEnabled && *config.EndpointMetrics == "" {
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
 	if *config.RegistrationEnabled {
 		r.POST("/register", func(ctx *gin.Context) {
-			handlers.Register(ctx)
+			handlers.Register(ctx, store, guard)
+		})
+		r.POST("/verify", func(ctx *gin.Context) {
+			handlers.VerifyPost(ctx, store, guard)
+		})
+		r.GET("/verify", func(ctx *gin.Context) {
+			handlers.VerifyGet(ctx, store, guard)
 		})
 	}
-	cache := utils.NewMemoryCache(*config.CleanupTime)
 
-	r.GET("/login", func(ctx *gin.Context) {
-		handlers.LoginGet(ctx, cache)
+	r.GET("/login", middlewares.AuditMiddleware(store, structs.AuditChallenge), func(ctx *gin.Context) {
+		handlers.LoginGet(ctx, store, cache, guard)
+	})
+	r.POST("/login", middlewares.AuditMiddleware(store, structs.AuditLogin), func(ctx *gin.Context) {
+		handlers.LoginPost(ctx, store, cache, guard)
+	})
+	r.POST("/refresh", func(ctx *gin.Context) {
+		handlers.Refresh(ctx, store)
+	})
+
+
+	r.POST("/logout", authorized, middlewares.AuditMiddleware(store, structs.AuditLogout), func(ctx *gin.Context) {
+		handlers.Logout(ctx, store)
+	})
+	r.GET("/sessions", authorized, func(ctx *gin.Context) {
+		handlers.SessionsGet(ctx, store)
+	})
+	r.DELETE("/sessions/:id", authorized, audited, func(ctx *gin.Context) {
+		handlers.SessionDelete(ctx, store)
+	})
+
+	totp := r.Group("/totp", authorized)
+	totp.GET("", func(ctx *gin.Context) {
+		handlers.TOTPGet(ctx, store)
+	})
+	totp.POST("/enroll", audited, func(ctx *gin.Context) {
+		handlers.TOTPEnroll(ctx, store)
+	})
+	totp.POST("/confirm", audited, func(ctx *gin.Context) {
+		handlers.TOTPConfirm(ctx, store, guard)
+	})
+	totp.POST("/disable", audited, func(ctx *gin.Context) {
+		handlers.TOTPDisable(ctx, store, guard)
+	})
+	totp.POST("/backup-codes", audited, func(ctx *gin.Context) {
+		handlers.TOTPBackupCodes(ctx, store, guard)
+	})
+
+	r.POST("/update", authorized, idempotent, audited, func(ctx *gin.Context) {
+		handlers.Update(ctx, store)
+	})
+	r.POST("/sync", authorized, func(ctx *gin.Context) {
+		handlers.Sync(ctx, store)
+	})
+	r.POST("/v2/sync", authorized, func(ctx *gin.Context) {
+		handlers.SyncV2(ctx, store)
+	})
+	r.POST("/search", authorized, func(ctx *gin.Context) {
+		handlers.Search(ctx, store)
+	})
+	r.GET("/events", authorized, func(ctx *gin.Context) {
+		handlers.Events(ctx, store)
+	})
+	r.GET("/keys", authorized, func(ctx *gin.Context) {
+		handlers.KeysGet(ctx, store)
+	})
+	r.PUT("/keys", authorized, idempotent, audited, func(ctx *gin.Context) {
+		handlers.KeysPut(ctx, store)
+	})
+	r.GET("/keys/public", authorized, func(ctx *gin.Context) {
+		handlers.PublicKeyGet(ctx, store)
+	})
+
+	records := r.Group("/records", authorized)
+	records.GET("/:id/revisions", func(ctx *gin.Context) {
+		handlers.RevisionsGet(ctx, store)
+	})
+	records.GET("/:id/revisions/:historyID", func(ctx *gin.Context) {
+		handlers.RevisionGet(ctx, store)
+	})
+	records.GET("/:id/shares", func(ctx *gin.Context) {
+		handlers.SharesGet(ctx, store)
+	})
+	records.POST("/:id/shares", idempotent, audited, func(ctx *gin.Context) {
+		handlers.ShareCreate(ctx, store)
+	})
+	records.DELETE("/:id/shares/:recipient", idempotent, audited, func(ctx *gin.Context) {
+		handlers.ShareDelete(ctx, store)
+	})
+
+	orgs := r.Group("/orgs", authorized)
+	orgs.GET("", func(ctx *gin.Context) {
+		handlers.OrganizationsGet(ctx, store)
+	})
+	orgs.POST("", idempotent, audited, func(ctx *gin.Context) {
+		handlers.OrganizationCreate(ctx, store)
+	})
+	orgs.GET("/:id/members", func(ctx *gin.Context) {
+		handlers.MembersGet(ctx, store)
+	})
+	orgs.PUT("/:id/members/:username", idempotent, audited, func(ctx *gin.Context) {
+		handlers.MemberPut(ctx, store)
+	})
+	orgs.DELETE("/:id/members/:username", idempotent, audited, func(ctx *gin.Context) {
+		handlers.MemberDelete(ctx, store)
+	})
+
+	vaults := r.Group("/vaults", authorized)
+	vaults.GET("", func(ctx *gin.Context) {
+		handlers.VaultsGet(ctx, store)
+	})
+	vaults.POST("", idempotent, audited, func(ctx *gin.Context) {
+		handlers.VaultCreate(ctx, store)
+	})
+
+	r.GET("/history/retention", authorized, func(ctx *gin.Context) {
+		handlers.HistoryRetentionGet(ctx, store)
 	})
-	r.POST("/login", func(ctx *gin.Context) {
-		handlers.LoginPost(ctx, cache)
+	r.PUT("/history/retention", authorized, idempotent, audited, func(ctx *gin.Context) {
+		handlers.HistoryRetentionPut(ctx, store)
+	})
+	r.GET("/usage", authorized, func(ctx *gin.Context) {
+		handlers.UsageGet(ctx, store)
+	})
+	r.GET("/audit", authorized, func(ctx *gin.Context) {
+		handlers.AuditGet(ctx, store)
 	})
 
+	admin := r.Group("/admin", authorized, middlewares.AdminMiddleware())
+	admin.GET("/users", func(ctx *gin.Context) {
+		handlers.AdminUsersGet(ctx, store)
+	})
+	admin.GET("/users/:username", func(ctx *gin.Context) {
+		handlers.AdminUserGet(ctx, store)
+	})
+	admin.GET("/audit", func(ctx *gin.Context) {
+		handlers.AdminAuditExport(ctx, store)
+	})
+	admin.POST("/users/:username/disable", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserDisable(ctx, store)
+	})
+	admin.POST("/users/:username/enable", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserEnable(ctx, store)
+	})
+	admin.POST("/users/:username/logout", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserLogout(ctx, store)
+	})
+	admin.DELETE("/users/:username", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserDelete(ctx, store)
+	})
+	admin.PUT("/users/:username/quota", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserQuotaPut(ctx, store)
+	})
+	admin.DELETE("/users/:username/quota", idempotent, audited, func(ctx *gin.Context) {
+		handlers.AdminUserQuotaDelete(ctx, store)
+	})
 
-	r.POST("/update", middlewares.AuthMiddleware(), func(ctx *gin.Context) {
-		handlers.Update(ctx)
+	blobs := r.Group("/blobs", authorized)
+	blobs.POST("", idempotent, audited, func(ctx *gin.Context) {
+		handlers.BlobCreate(ctx, store)
+	})
+	blobs.GET("/:id", func(ctx *gin.Context) {
+		handlers.BlobGet(ctx, store)
+	})
+	blobs.PATCH("/:id", func(ctx *gin.Context) {
+		handlers.BlobUpload(ctx, store)
+	})
+	blobs.POST("/:id/complete", idempotent, audited, func(ctx *gin.Context) {
+		handlers.BlobComplete(ctx, store)
 	})
-	r.POST("/sync", middlewares.AuthMiddleware(), func(ctx *gin.Context) {
-		handlers.Sync(ctx)
+	blobs.GET("/:id/content", func(ctx *gin.Context) {
+		handlers.BlobDownload(ctx, store)
 	})
 }
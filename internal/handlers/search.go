@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const (
+	// searchDefaultLimit и searchMaxLimit - размер страницы поиска
+	searchDefaultLimit = 50
+	searchMaxLimit     = 500
+)
+
+// searchCursor - содержимое непрозрачного курсора; сортировка сохраняется, чтобы курсор
+// нельзя было применить к другому порядку записей
+type searchCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	storage.SearchCursor
+}
+
+// Search - страница записей по фильтрам метаданных, меткам, типу и состоянию
+func Search(c *gin.Context, store storage.Storage) {
+	var query structs.SearchQuery
+	if err := c.ShouldBindBodyWithJSON(&query); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	filter, err := searchFilter(query)
+	if err == nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		err = fmt.Errorf("error while parsing search query: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	data, next, err := store.SearchSecureData(c.Request.Context(), login, filter)
+	if err != nil {
+		err = fmt.Errorf("error while searching data in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	response := structs.Response{SecureData: data}
+	if next != nil {
+		raw, err := json.Marshal(searchCursor{Sort: filter.Sort, Desc: filter.Desc, SearchCursor: *next})
+		if err != nil {
+			err = fmt.Errorf("error while encoding cursor: %w", err)
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, structs.Response{
+				Error: err.Error(),
+			})
+			return
+		}
+		response.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// searchFilter - фильтр хранилища со значениями по умолчанию и позицией из курсора
+func searchFilter(query structs.SearchQuery) (storage.SearchFilter, error) {
+	filter := storage.SearchFilter{
+		Metadata: string(query.Metadata),
+		Tags:     query.Tags,
+		Kind:     query.Kind,
+		State:    query.State,
+		Sort:     query.Sort,
+		Limit:    query.Limit,
+	}
+	if filter.State == "" {
+		filter.State = storage.SearchActive
+	}
+	if filter.Sort == "" {
+		filter.Sort = storage.SortID
+	}
+	switch query.Order {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("unknown order %q", query.Order)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = searchDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, searchMaxLimit)
+
+	if query.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil {
+			return filter, fmt.Errorf("invalid cursor: %w", err)
+		}
+		var cursor searchCursor
+		if err := json.Unmarshal(raw, &cursor); err != nil {
+			return filter, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+			return filter, fmt.Errorf("cursor was issued for another sort order")
+		}
+		filter.After = &cursor.SearchCursor
+	}
+	return filter, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// refreshCookiePath - refresh токен отправляется браузером только на /refresh
+const refreshCookiePath = "/refresh"
+
+// Refresh - новая пара токенов по refresh токену из куки "Refresh"; старый refresh токен больше не действует
+func Refresh(c *gin.Context, store storage.Storage) {
+	refreshToken, err := c.Cookie("Refresh")
+	if err != nil {
+		err = fmt.Errorf("refresh cookie not found")
+		c.Error(err)
+		c.JSON(http.StatusUnauthorized, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	tokens, err := auth.RefreshSession(c.Request.Context(), store, refreshToken, sessionClient(c))
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		c.Error(err)
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	setSessionCookies(c, tokens)
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "refreshed",
+	})
+}
+
+// Logout - отзыв текущей сессии
+func Logout(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	err := store.RevokeSession(c.Request.Context(), login, c.GetString("session"))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("error while revoking session in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	clearSessionCookies(c)
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "logged out",
+	})
+}
+
+// SessionsGet - активные сессии пользователя
+func SessionsGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := store.SelectSessions(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting sessions from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == c.GetString("session")
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Sessions: sessions,
+	})
+}
+
+// SessionDelete - отзыв сессии id (например, потерянного устройства)
+func SessionDelete(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	err := store.RevokeSession(c.Request.Context(), login, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("session %s not found", id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while revoking session in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if id == c.GetString("session") {
+		clearSessionCookies(c)
+	}
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "session revoked",
+	})
+}
+
+// sessionClient - устройство, с которого выполнен запрос
+func sessionClient(c *gin.Context) auth.Client {
+	return auth.Client{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func setSessionCookies(c *gin.Context, tokens auth.Tokens) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("Authorization", tokens.Access, int(config.AccessTokenTTL.Seconds()), "", "", config.TLSEnabled(), true)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie("Refresh", tokens.Refresh, int(time.Until(tokens.RefreshExpiresAt).Seconds()), refreshCookiePath, "", config.TLSEnabled(), true)
+}
+
+func clearSessionCookies(c *gin.Context) {
+	c.SetCookie("Authorization", "", -1, "", "", config.TLSEnabled(), true)
+	c.SetCookie("Refresh", "", -1, refreshCookiePath, "", config.TLSEnabled(), true)
+}
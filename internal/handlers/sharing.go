@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// PublicKeyGet - открытый ключ пользователя ?mail= для шифрования ключа общей записи
+func PublicKeyGet(c *gin.Context, store storage.Storage) {
+	mail := c.Query("mail")
+	if mail == "" {
+		err := fmt.Errorf("mail is required")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	keys, err := store.SelectUserKeys(c.Request.Context(), mail)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && keys.PublicKey == "" {
+		err = fmt.Errorf("user %s has no public key", mail)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting keys from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		PublicKey: keys.PublicKey,
+	})
+}
+
+// ShareCreate - открытие доступа к записи другому пользователю или изменение права доступа
+func ShareCreate(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	var request structs.ShareRequest
+	if err := c.ShouldBindBodyWithJSON(&request); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch {
+	case request.Recipient == "" || request.Recipient == login:
+		err = fmt.Errorf("recipient must be another user")
+	case request.Permission != structs.PermissionRead && request.Permission != structs.PermissionWrite:
+		err = fmt.Errorf("permission must be %s or %s", structs.PermissionRead, structs.PermissionWrite)
+	case !vault.IsSealedKey(request.WrappedKey):
+		err = fmt.Errorf("wrappedKey must be sealed with the recipient public key")
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	// ключ записи зашифрован открытым ключом получателя, поэтому без ключа доступ бесполезен
+	keys, err := store.SelectUserKeys(ctx, request.Recipient)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && keys.PublicKey == "" {
+		err = fmt.Errorf("user %s has no public key", request.Recipient)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var historyID int64
+	if err == nil {
+		historyID, err = store.ShareSecureData(ctx, id, login, request.Recipient, request.Permission, request.WrappedKey)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("secure data %d not found", id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while sharing secure data in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message:      "SHARE success",
+		SecureDataID: id,
+		HistoryID:    historyID,
+	})
+}
+
+// SharesGet - открытые и отозванные доступы к записи
+func SharesGet(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	shares, err := store.SelectShares(c.Request.Context(), id, login)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("secure data %d not found", id)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting shares from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Shares: shares,
+	})
+}
+
+// ShareDelete - отзыв доступа пользователя :recipient к записи
+func ShareDelete(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+	recipient := c.Param("recipient")
+
+	historyID, err := store.RevokeShare(c.Request.Context(), id, login, recipient)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = fmt.Errorf("secure data %d is not shared with %s", id, recipient)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("error while revoking share in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message:      "UNSHARE success",
+		SecureDataID: id,
+		HistoryID:    historyID,
+	})
+}
@@ -5,11 +5,12 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stepanov-ds/GophKeeper/internal/database"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
 )
 
-func Sync(c *gin.Context) {
+func Sync(c *gin.Context, store storage.Storage) {
 	var bodyJSON struct {
 		Last     int64           `json:"lastHistoryID"`
 		Limit 	int `json:"limit"`
@@ -43,7 +44,7 @@ func Sync(c *gin.Context) {
 		return
 	}
 
-	data, err := database.SelectUpdatedSecureData(bodyJSON.Last, login, bodyJSON.Limit) 
+	data, err := store.SelectUpdatedSecureData(c.Request.Context(), bodyJSON.Last, login, bodyJSON.Limit) 
 	if err != nil {
 		err = fmt.Errorf("error while selecting data from db: %w", err)
 		c.Error(err)
@@ -52,6 +53,7 @@ func Sync(c *gin.Context) {
 		})
 		return
 	}
+	metrics.ObserveSyncPage("v1", len(data))
 
 	if len(data) != 0 {
 		fullySynced := false
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+const (
+	// syncDefaultLimit и syncMaxLimit - размер страницы синхронизации v2
+	syncDefaultLimit = 100
+	syncMaxLimit     = 1000
+	// syncCursorVersion - версия формата курсора; курсоры других версий отклоняются
+	syncCursorVersion = 2
+)
+
+// syncCursor - содержимое непрозрачного курсора синхронизации
+type syncCursor struct {
+	Version int `json:"v"`
+	// HistoryID - последнее выданное изменение; в снимке - последнее изменение на момент его начала
+	HistoryID int64 `json:"h"`
+	Snapshot  bool  `json:"s,omitempty"`
+	// SnapshotAfter - ID последней записи, выданной в снимке
+	SnapshotAfter int64 `json:"a,omitempty"`
+}
+
+// SyncV2 - синхронизация по курсору: изменённые записи и удалённые (tombstones) в порядке изменения.
+// Ответ есть всегда, в том числе без изменений, и содержит курсор последнего изменения (head).
+// Снимок для нового устройства выдаёт активные записи по ID и затем переходит к изменениям
+// с момента своего начала, поэтому изменения во время снимка не теряются
+func SyncV2(c *gin.Context, store storage.Storage) {
+	var request structs.SyncRequest
+	if err := c.ShouldBindBodyWithJSON(&request); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	limit := request.Limit
+	if limit == 0 {
+		limit = syncDefaultLimit
+	}
+	cursor, err := decodeSyncCursor(request.Cursor)
+	if err == nil && limit < 0 {
+		err = fmt.Errorf("limit must be positive")
+	}
+	if err != nil {
+		err = fmt.Errorf("error while parsing sync request: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	limit = min(limit, syncMaxLimit)
+
+	ctx := c.Request.Context()
+	var page structs.SyncPage
+	if request.Cursor == "" && request.Snapshot {
+		// изменения после этой точки догоняются обычной синхронизацией после снимка
+		cursor.Snapshot = true
+		cursor.HistoryID, err = store.SelectLastHistoryID(ctx, login)
+	}
+	if err == nil && cursor.Snapshot {
+		page, cursor, err = syncSnapshot(ctx, store, login, cursor, limit)
+	} else if err == nil {
+		page, cursor, err = syncChanges(ctx, store, login, cursor, limit)
+	}
+	var head int64
+	if err == nil {
+		head, err = store.SelectLastHistoryID(ctx, login)
+	}
+	if err != nil {
+		err = fmt.Errorf("error while selecting data from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	metrics.ObserveSyncPage("v2", len(page.Records)+len(page.Tombstones))
+	page.HasMore = page.HasMore || head > cursor.HistoryID
+	page.Cursor = encodeSyncCursor(cursor)
+	page.Head = encodeSyncCursor(syncCursor{Version: syncCursorVersion, HistoryID: head})
+	c.JSON(http.StatusOK, structs.Response{Sync: &page})
+}
+
+// syncSnapshot - страница активных записей по ID, включая открытые пользователю; после последней
+// страницы курсор указывает на изменения с начала снимка
+func syncSnapshot(ctx context.Context, store storage.Storage, login string, cursor syncCursor, limit int) (structs.SyncPage, syncCursor, error) {
+	filter := storage.SearchFilter{State: storage.SearchActive, Sort: storage.SortID, Limit: limit}
+	if cursor.SnapshotAfter != 0 {
+		filter.After = &storage.SearchCursor{ID: cursor.SnapshotAfter}
+	}
+	data, next, err := store.SearchSecureData(ctx, login, filter)
+	if err != nil {
+		return structs.SyncPage{}, cursor, err
+	}
+
+	page := structs.SyncPage{
+		Records:    append([]structs.SecureData{}, data...),
+		Tombstones: []structs.Tombstone{},
+		Snapshot:   true,
+	}
+	if next != nil {
+		cursor.SnapshotAfter = next.ID
+		page.HasMore = true
+	} else {
+		cursor = syncCursor{Version: syncCursorVersion, HistoryID: cursor.HistoryID}
+	}
+	return page, cursor, nil
+}
+
+// syncChanges - страница изменений после cursor.HistoryID; удалённые записи передаются без содержимого
+func syncChanges(ctx context.Context, store storage.Storage, login string, cursor syncCursor, limit int) (structs.SyncPage, syncCursor, error) {
+	data, err := store.SelectUpdatedSecureData(ctx, cursor.HistoryID, login, limit+1)
+	if err != nil {
+		return structs.SyncPage{}, cursor, err
+	}
+
+	page := structs.SyncPage{
+		Records:    []structs.SecureData{},
+		Tombstones: []structs.Tombstone{},
+		HasMore:    len(data) > limit,
+	}
+	for _, d := range data[:min(len(data), limit)] {
+		if d.IsActive {
+			page.Records = append(page.Records, d)
+		} else {
+			page.Tombstones = append(page.Tombstones, structs.Tombstone{ID: d.ID, HistoryID: d.HistoryID})
+		}
+		cursor.HistoryID = max(cursor.HistoryID, d.HistoryID, d.SyncID)
+	}
+	return page, cursor, nil
+}
+
+func encodeSyncCursor(cursor syncCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeSyncCursor - пустая строка - начало синхронизации
+func decodeSyncCursor(value string) (syncCursor, error) {
+	if value == "" {
+		return syncCursor{Version: syncCursorVersion}, nil
+	}
+	var cursor syncCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(raw, &cursor)
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if cursor.Version != syncCursorVersion {
+		return cursor, fmt.Errorf("unsupported cursor version %d", cursor.Version)
+	}
+	return cursor, nil
+}
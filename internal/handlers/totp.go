@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/auth"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// TOTPGet - подключён ли второй фактор и сколько осталось резервных кодов
+func TOTPGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	status, err := auth.TOTPStatus(c.Request.Context(), store, login)
+	if err != nil {
+		totpError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, structs.Response{
+		TOTP: &status,
+	})
+}
+
+// TOTPEnroll - новый секрет и otpauth URI; второй фактор включается только после TOTPConfirm
+func TOTPEnroll(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	status, err := auth.EnrollTOTP(c.Request.Context(), store, login)
+	if err != nil {
+		totpError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, structs.Response{
+		TOTP: &status,
+	})
+}
+
+// TOTPConfirm - включение второго фактора по первому коду из приложения; в ответе резервные коды
+func TOTPConfirm(c *gin.Context, store storage.Storage, guard *auth.Guard) {
+	login, code, ok := totpRequest(c, guard)
+	if !ok {
+		return
+	}
+
+	status, err := auth.ConfirmTOTP(c.Request.Context(), store, login, code)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidSecondFactor) {
+			guard.Failure(login)
+		}
+		totpError(c, err)
+		return
+	}
+	guard.Success(login)
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "two-factor authentication enabled",
+		TOTP:    &status,
+	})
+}
+
+// TOTPDisable - отключение второго фактора по свежему коду TOTP или резервному коду
+func TOTPDisable(c *gin.Context, store storage.Storage, guard *auth.Guard) {
+	login, code, ok := totpRequest(c, guard)
+	if !ok {
+		return
+	}
+
+	if err := auth.DisableTOTP(c.Request.Context(), store, login, code); err != nil {
+		if errors.Is(err, auth.ErrInvalidSecondFactor) {
+			guard.Failure(login)
+		}
+		totpError(c, err)
+		return
+	}
+	guard.Success(login)
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "two-factor authentication disabled",
+	})
+}
+
+// TOTPBackupCodes - новый набор резервных кодов; старые перестают действовать
+func TOTPBackupCodes(c *gin.Context, store storage.Storage, guard *auth.Guard) {
+	login, code, ok := totpRequest(c, guard)
+	if !ok {
+		return
+	}
+
+	status, err := auth.RegenerateBackupCodes(c.Request.Context(), store, login, code)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidSecondFactor) {
+			guard.Failure(login)
+		}
+		totpError(c, err)
+		return
+	}
+	guard.Success(login)
+	c.JSON(http.StatusOK, structs.Response{
+		TOTP: &status,
+	})
+}
+
+// totpRequest - пользователь и код из запроса; коды подбираются так же, как при входе,
+// поэтому запрос проходит через защиту от перебора
+func totpRequest(c *gin.Context, guard *auth.Guard) (string, string, bool) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return "", "", false
+	}
+
+	var bodyJSON struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return "", "", false
+	}
+	if rateLimited(c, guard, login) {
+		return "", "", false
+	}
+	return login, bodyJSON.Code, true
+}
+
+func totpError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, auth.ErrInvalidSecondFactor):
+		status = http.StatusForbidden
+	case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+		status = http.StatusConflict
+	case errors.Is(err, auth.ErrTOTPNotEnabled):
+		status = http.StatusBadRequest
+	}
+	c.Error(err)
+	c.JSON(status, structs.Response{
+		Error: err.Error(),
+	})
+}
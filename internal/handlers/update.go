@@ -1,31 +1,44 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stepanov-ds/GophKeeper/internal/database"
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/kinds"
 	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
 )
 
-func Update(c *gin.Context) {
-	//
-	// todo:
-	// добавить запросы в бд на добавление изменения
-	//
-	//
-	//
-	//
-	var bodyJSON struct {
-		ID       int64           `json:"ID,omitempty"`
-		Type     string          `json:"type"`
-		Data     string          `json:"data,omitempty"`
-		Metadata json.RawMessage `json:"metadata,omitempty"`
-	}
-	if err := c.ShouldBindBodyWithJSON(&bodyJSON); err != nil {
-		err = fmt.Errorf("error while parsing JSON: %w", err)
+// operation - одно изменение записи
+type operation struct {
+	ID   int64  `json:"ID,omitempty"`
+	Type string `json:"type"`
+	// HistoryID - последняя известная клиенту версия записи для UPDATE, DELETE и RESTORE; 0 - без проверки
+	HistoryID int64 `json:"historyID,omitempty"`
+	// RevisionID - ревизия, содержимое которой восстанавливает RESTORE
+	RevisionID int64 `json:"revisionID,omitempty"`
+	// VaultID - хранилище новой записи для ADD; 0 - основное личное хранилище
+	VaultID  int64           `json:"vaultID,omitempty"`
+	Kind     string          `json:"kind,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Validate json.RawMessage `json:"validate,omitempty"`
+}
+
+// Update - изменение записи; массив операций применяется в одной транзакции целиком или не применяется вовсе
+func Update(c *gin.Context, store storage.Storage) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		err = fmt.Errorf("error while reading body: %w", err)
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, structs.Response{
 			Error: err.Error(),
@@ -33,9 +46,19 @@ func Update(c *gin.Context) {
 		return
 	}
 
-	l, exist := c.Get("login")
-	if !exist {
-		err := fmt.Errorf("authorization token error")
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	if raw = bytes.TrimSpace(raw); len(raw) != 0 && raw[0] == '[' {
+		updateBatch(c, store, login, raw)
+		return
+	}
+
+	var op operation
+	if err := json.Unmarshal(raw, &op); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, structs.Response{
 			Error: err.Error(),
@@ -43,66 +66,165 @@ func Update(c *gin.Context) {
 		return
 	}
 
-	login, ok := l.(string)
-	if !ok {
-		err := fmt.Errorf("type assertion error (login)")
+	result, err := op.apply(c.Request.Context(), store, login)
+	if err != nil {
+		updateError(c, err)
+		return
+	}
+	auditResults(c, result)
+	c.JSON(http.StatusOK, structs.Response{
+		Message:      op.Type + " success",
+		SecureDataID: result.SecureDataID,
+		HistoryID:    result.HistoryID,
+	})
+}
+
+// updateBatch - операции применяются по порядку в одной транзакции; первая ошибка отменяет все
+func updateBatch(c *gin.Context, store storage.Storage, login string, raw []byte) {
+	var ops []operation
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, structs.Response{
 			Error: err.Error(),
 		})
 		return
 	}
+	if len(ops) == 0 || len(ops) > *config.BatchMaxSize {
+		status := http.StatusBadRequest
+		if len(ops) != 0 {
+			status = http.StatusRequestEntityTooLarge
+		}
+		err := fmt.Errorf("batch must contain from 1 to %d operations", *config.BatchMaxSize)
+		c.Error(err)
+		c.JSON(status, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
 
+	results := make([]structs.OperationResult, 0, len(ops))
+	err := store.InTransaction(c.Request.Context(), func(ctx context.Context) error {
+		for i := range ops {
+			result, err := ops[i].apply(ctx, store, login)
+			if err != nil {
+				return fmt.Errorf("operation %d: %w", i, err)
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		updateError(c, err)
+		return
+	}
+
+	auditResults(c, results...)
+	c.JSON(http.StatusOK, structs.Response{
+		Message: "BATCH success",
+		Results: results,
+	})
+}
+
+// apply - проверка и выполнение операции
+func (op *operation) apply(ctx context.Context, store storage.Storage, login string) (structs.OperationResult, error) {
+	result := structs.OperationResult{Type: op.Type, SecureDataID: op.ID}
 	var err error
-	var secureDataID int64
-	var historyID int64
 
-	ctx := c.Request.Context()
+	if op.Type == "ADD" || op.Type == "UPDATE" {
+		if err := op.prepare(ctx, store, login); err != nil {
+			return result, err
+		}
+	}
 
-	switch bodyJSON.Type {
+	switch op.Type {
 	case "ADD":
-		secureDataID, historyID, err = database.AddSecureData(ctx, login, bodyJSON.Data, string(bodyJSON.Metadata))
+		result.SecureDataID, result.HistoryID, err = store.AddSecureData(ctx, login, op.VaultID, op.Kind, op.Data, string(op.Metadata))
 		if err != nil {
 			err = fmt.Errorf("error while add secure data in db: %w", err)
-		} else {
-			c.JSON(http.StatusOK, structs.Response{
-				Message: "ADD success",
-				SecureDataID: secureDataID,
-				HistoryID: historyID,
-			})
 		}
-		
 	case "DELETE":
-		historyID, err = database.DeleteSecureData(ctx, bodyJSON.ID, login)
+		result.HistoryID, err = store.DeleteSecureData(ctx, op.ID, login, op.HistoryID)
 		if err != nil {
 			err = fmt.Errorf("error while delete secure data from db: %w", err)
-		} else {
-			c.JSON(http.StatusOK, structs.Response{
-				Message: "DELETE success",
-				HistoryID: historyID,
-			})
 		}
 	case "UPDATE":
-		historyID, err =  database.UpdateSecureData(ctx, bodyJSON.ID, login, bodyJSON.Data, string(bodyJSON.Metadata))
+		result.HistoryID, err = store.UpdateSecureData(ctx, op.ID, login, op.HistoryID, op.Kind, op.Data, string(op.Metadata))
 		if err != nil {
 			err = fmt.Errorf("error while update secure data from db: %w", err)
-		} else {
-			c.JSON(http.StatusOK, structs.Response{
-				Message: "UPDATE success",
-				HistoryID: historyID,
-			})
+		}
+	case "RESTORE":
+		result.HistoryID, err = store.RestoreSecureData(ctx, op.ID, login, op.HistoryID, op.RevisionID)
+		if err != nil {
+			err = fmt.Errorf("error while restore secure data revision %d: %w", op.RevisionID, err)
 		}
 	default:
-		err = fmt.Errorf("type variable must be ADD, UPDATE or DELETE")
+		err = fmt.Errorf("type variable must be ADD, UPDATE, DELETE or RESTORE")
 	}
-	if err != nil {
+	return result, err
+}
+
+// auditResults - выполненные операции для журнала аудита ("ADD 12, DELETE 5")
+func auditResults(c *gin.Context, results ...structs.OperationResult) {
+	operations := make([]string, 0, len(results))
+	for _, r := range results {
+		operations = append(operations, fmt.Sprintf("%s %d", r.Type, r.SecureDataID))
+	}
+	c.Set("audit", strings.Join(operations, ", "))
+}
+
+// prepare - проверка данных ADD и UPDATE и заполнение значений по умолчанию
+func (op *operation) prepare(ctx context.Context, store storage.Storage, login string) error {
+	// сервер хранит только шифротекст, открытые данные не принимаются
+	if !vault.IsCiphertext(op.Data) {
+		return fmt.Errorf("data must be encrypted on the client")
+	}
+
+	if len(op.Metadata) == 0 {
+		op.Metadata = json.RawMessage("{}")
+	}
+	// при UPDATE без kind тип записи не меняется
+	if op.Kind == "" && op.Type == "UPDATE" {
+		kind, err := store.SelectSecureDataKind(ctx, op.ID, login)
+		if err != nil {
+			return fmt.Errorf("error while selecting secure data kind from db: %w", err)
+		}
+		op.Kind = kind
+	}
+	if op.Kind == "" {
+		op.Kind = kinds.Default
+	}
+
+	return kinds.Validate(op.Kind, op.Metadata, op.Validate, time.Now())
+}
+
+func updateError(c *gin.Context, err error) {
+	// запись изменена другим устройством: клиент получает текущее состояние для слияния
+	var conflict *storage.ConflictError
+	if errors.As(err, &conflict) {
 		c.Error(err)
-		c.JSON(http.StatusBadRequest, structs.Response{
-			Error: err.Error(),
+		c.JSON(http.StatusConflict, structs.Response{
+			Error:   err.Error(),
+			Current: &conflict.Current,
 		})
 		return
 	}
-
-	
-
+	// квота автора записи: клиент показывает, какой предел достигнут
+	var quota *storage.QuotaError
+	if errors.As(err, &quota) {
+		c.Error(err)
+		c.JSON(http.StatusRequestEntityTooLarge, structs.Response{
+			Error:         err.Error(),
+			QuotaExceeded: quota.Name,
+		})
+		return
+	}
+	status := http.StatusBadRequest
+	if errors.Is(err, storage.ErrForbidden) {
+		status = http.StatusForbidden
+	}
+	c.Error(err)
+	c.JSON(status, structs.Response{
+		Error: err.Error(),
+	})
 }
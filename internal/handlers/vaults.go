@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// OrganizationsGet - организации пользователя с его ролью и ключом организации
+func OrganizationsGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	orgs, err := store.SelectOrganizations(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting organizations from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Organizations: orgs,
+	})
+}
+
+// OrganizationCreate - новая организация; создатель становится её владельцем
+func OrganizationCreate(c *gin.Context, store storage.Storage) {
+	var request structs.OrganizationRequest
+	if err := c.ShouldBindBodyWithJSON(&request); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch {
+	case strings.TrimSpace(request.Name) == "":
+		err = fmt.Errorf("name is required")
+	case !vault.IsSealedKey(request.WrappedKey):
+		err = fmt.Errorf("wrappedKey must be sealed with the owner public key")
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	org, err := store.CreateOrganization(c.Request.Context(), login, request.Name, request.WrappedKey)
+	if err != nil {
+		err = fmt.Errorf("error while creating organization in db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Organization: &org,
+	})
+}
+
+// MembersGet - участники организации
+func MembersGet(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	members, err := store.SelectMembers(c.Request.Context(), id, login)
+	if err != nil {
+		organizationError(c, id, "selecting members", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Members: members,
+	})
+}
+
+// MemberPut - добавление участника :username или изменение его роли. Ключ организации в запросе
+// зашифрован открытым ключом участника
+func MemberPut(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	var request structs.MemberRequest
+	if err := c.ShouldBindBodyWithJSON(&request); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+	member := c.Param("username")
+
+	var err error
+	switch {
+	case !validRole(request.Role):
+		err = fmt.Errorf("role must be %s, %s, %s or %s", structs.RoleOwner, structs.RoleAdmin, structs.RoleMember, structs.RoleReadOnly)
+	case !vault.IsSealedKey(request.WrappedKey):
+		err = fmt.Errorf("wrappedKey must be sealed with the member public key")
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	// ключ организации зашифрован открытым ключом участника, поэтому без ключа участие бесполезно
+	keys, err := store.SelectUserKeys(ctx, member)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && keys.PublicKey == "" {
+		err = fmt.Errorf("user %s has no public key", member)
+		c.Error(err)
+		c.JSON(http.StatusNotFound, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var historyID int64
+	if err == nil {
+		historyID, err = store.SetMember(ctx, id, login, member, request.Role, request.WrappedKey)
+	}
+	if err != nil {
+		organizationError(c, id, "setting member", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message:   "MEMBER success",
+		HistoryID: historyID,
+	})
+}
+
+// MemberDelete - исключение участника :username или выход пользователя из организации
+func MemberDelete(c *gin.Context, store storage.Storage) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	historyID, err := store.RemoveMember(c.Request.Context(), id, login, c.Param("username"))
+	if err != nil {
+		organizationError(c, id, "removing member", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Message:   "REMOVE success",
+		HistoryID: historyID,
+	})
+}
+
+// VaultsGet - личные хранилища пользователя и хранилища его организаций
+func VaultsGet(c *gin.Context, store storage.Storage) {
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+
+	vaults, err := store.SelectVaults(c.Request.Context(), login)
+	if err != nil {
+		err = fmt.Errorf("error while selecting vaults from db: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Vaults: vaults,
+	})
+}
+
+// VaultCreate - новое личное хранилище или хранилище организации
+func VaultCreate(c *gin.Context, store storage.Storage) {
+	var request structs.VaultRequest
+	if err := c.ShouldBindBodyWithJSON(&request); err != nil {
+		err = fmt.Errorf("error while parsing JSON: %w", err)
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+	login, ok := contextLogin(c)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		err := fmt.Errorf("name is required")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, structs.Response{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	v, err := store.CreateVault(c.Request.Context(), login, request.OrgID, request.Name)
+	if err != nil {
+		organizationError(c, request.OrgID, "creating vault", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, structs.Response{
+		Vault: &v,
+	})
+}
+
+// organizationError - ответ на ошибку операции с организацией id
+func organizationError(c *gin.Context, id int64, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+		err = fmt.Errorf("organization %d or user not found", id)
+	case errors.Is(err, storage.ErrForbidden):
+		status = http.StatusForbidden
+		err = fmt.Errorf("permission denied in organization %d (or it would be left without an owner)", id)
+	default:
+		err = fmt.Errorf("error while %s in db: %w", action, err)
+	}
+	c.Error(err)
+	c.JSON(status, structs.Response{
+		Error: err.Error(),
+	})
+}
+
+func validRole(role string) bool {
+	switch role {
+	case structs.RoleOwner, structs.RoleAdmin, structs.RoleMember, structs.RoleReadOnly:
+		return true
+	}
+	return false
+}
@@ -1,38 +1,204 @@
 package mail
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"log"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/config"
+	"github.com/stepanov-ds/GophKeeper/internal/metrics"
 )
 
-func Send(to string, body string) {
-	from := "dimitr1stepanov@yandex.ru"
-	password := "qvgepebdkenqvtbr"
-	smtpHost := "smtp.yandex.ru"
-	smtpPort := "587"
+// Message - письмо; HTML может быть пустым
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
+// Mailer - транспорт доставки писем
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
 
-	title := "authorization code"
+var (
+	mu     sync.RWMutex
+	mailer Mailer = NewWriterMailer(os.Stdout)
+	from          = "gophkeeper@localhost"
+)
 
-	header := make(map[string]string)
-	header["From"] = from
-	header["To"] = to
-	header["Subject"] = title
-	header["MIME-Version"] = "1.0"
-	header["Content-Type"] = "text/plain; charset=\"utf-8\""
-	header["Content-Transfer-Encoding"] = "base64"
+// Configure - выбор транспорта по конфигурации сервиса
+func Configure() error {
+	if *config.MailFrom != "" {
+		from = *config.MailFrom
+	}
 
-	message := ""
-	for k, v := range header {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	transport := *config.MailTransport
+	if transport == "" {
+		transport = "stdout"
+		if *config.SMTPHost != "" {
+			transport = "smtp"
+		}
 	}
-	message += "\r\n" + base64.StdEncoding.EncodeToString([]byte(body))
 
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(message))
+	switch transport {
+	case "smtp":
+		m, err := NewSMTPMailer(SMTPOptions{
+			Host:     *config.SMTPHost,
+			Port:     *config.SMTPPort,
+			Username: *config.SMTPUser,
+			Password: *config.SMTPPassword,
+			TLS:      *config.SMTPTLS,
+		})
+		if err != nil {
+			return err
+		}
+		SetMailer(m)
+	case "file":
+		f, err := os.OpenFile(*config.MailFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("error while opening mail file: %w", err)
+		}
+		SetMailer(NewWriterMailer(f))
+	case "stdout":
+		log.Println("mail: messages are written to stdout, configure SMTP for production")
+		SetMailer(NewWriterMailer(os.Stdout))
+	default:
+		return fmt.Errorf("unknown mail transport %q", transport)
+	}
+	return nil
+}
+
+// SetMailer - замена транспорта (например, на mailtest в тестах)
+func SetMailer(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	mailer = m
+}
+
+// SendChallenge - письмо с кодом авторизации
+func SendChallenge(ctx context.Context, to string, code string) error {
+	text, html, err := render("challenge", challengeData{Code: code, TTL: *config.ChallengeTTL})
+	if err != nil {
+		return err
+	}
+
+	return send(ctx, "challenge", Message{
+		To:      to,
+		Subject: "authorization code",
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// SendVerification - письмо с кодом и ссылкой подтверждения адреса при регистрации
+func SendVerification(ctx context.Context, to string, code string, link string) error {
+	text, html, err := render("verification", verificationData{Code: code, Link: link, TTL: *config.VerificationTTL})
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	return send(ctx, "verification", Message{
+		To:      to,
+		Subject: "confirm your registration",
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// send - отправка письма вида kind текущим транспортом от имени настроенного отправителя
+func send(ctx context.Context, kind string, msg Message) error {
+	mu.RLock()
+	m, sender := mailer, from
+	mu.RUnlock()
+
+	msg.From = sender
+	start := time.Now()
+	err := m.Send(ctx, msg)
+	metrics.ObserveMail(kind, time.Since(start), err)
+	return err
+}
+
+// Bytes - письмо в формате RFC 5322 с заголовками в фиксированном порядке
+func (m Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := func(key string, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(m.From))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(&buf, m.Text)
+		return buf.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+w.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{`text/plain; charset="utf-8"`, m.Text},
+		{`text/html; charset="utf-8"`, m.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		writeBase64(&body, part.body)
+		if _, err := pw.Write(body.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 - тело в base64 со строками по 76 символов
+func writeBase64(buf *bytes.Buffer, s string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = strings.Trim(from[i+1:], "> ")
 	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
 }
@@ -0,0 +1,120 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stepanov-ds/GophKeeper/internal/mail/mailtest"
+)
+
+func TestSendChallengeSMTP(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatalf("mailtest.NewServer: %v", err)
+	}
+	defer server.Close()
+	useMailer(t, smtpMailer(t, server.Host(), server.Port()))
+
+	if err := SendChallenge(context.Background(), "alice@example.com", "123456"); err != nil {
+		t.Fatalf("SendChallenge: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != from || !reflect.DeepEqual(msg.To, []string{"alice@example.com"}) {
+		t.Fatalf("envelope from %q to %v", msg.From, msg.To)
+	}
+
+	// заголовки в фиксированном порядке
+	head, _, _ := strings.Cut(msg.Raw, "\r\n\r\n")
+	var keys []string
+	for _, line := range strings.Split(head, "\r\n") {
+		key, _, _ := strings.Cut(line, ":")
+		keys = append(keys, key)
+	}
+	expected := []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("headers = %v, expected %v", keys, expected)
+	}
+	if msg.Header("Subject") != "authorization code" || msg.Header("To") != "alice@example.com" {
+		t.Fatalf("Subject %q, To %q", msg.Header("Subject"), msg.Header("To"))
+	}
+
+	// текстовая и HTML версии с кодом
+	parsed, err := netmail.ReadMessage(strings.NewReader(msg.Raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type %q: %v", parsed.Header.Get("Content-Type"), err)
+	}
+	r := multipart.NewReader(parsed.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		if part.Header.Get("Content-Transfer-Encoding") != "base64" {
+			t.Fatalf("part encoding %q", part.Header.Get("Content-Transfer-Encoding"))
+		}
+		body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatalf("decode part: %v", err)
+		}
+		if !strings.Contains(string(body), "123456") {
+			t.Fatalf("part %s without code: %s", part.Header.Get("Content-Type"), body)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+	}
+	if !reflect.DeepEqual(types, []string{`text/plain; charset="utf-8"`, `text/html; charset="utf-8"`}) {
+		t.Fatalf("parts = %v", types)
+	}
+}
+
+// недоставленное письмо - ошибка для обработчика, а не завершение процесса
+func TestSendChallengeDeliveryFailure(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatalf("mailtest.NewServer: %v", err)
+	}
+	host, port := server.Host(), server.Port()
+	server.Close()
+	useMailer(t, smtpMailer(t, host, port))
+
+	if err := SendChallenge(context.Background(), "alice@example.com", "123456"); err == nil {
+		t.Fatal("SendChallenge succeeded without SMTP server")
+	}
+}
+
+func smtpMailer(t *testing.T, host string, port int) *SMTPMailer {
+	t.Helper()
+	m, err := NewSMTPMailer(SMTPOptions{Host: host, Port: port, Username: "user", Password: "password", TLS: TLSNone})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+	return m
+}
+
+// useMailer - транспорт m до конца теста
+func useMailer(t *testing.T, m Mailer) {
+	mu.RLock()
+	prev := mailer
+	mu.RUnlock()
+	SetMailer(m)
+	t.Cleanup(func() { SetMailer(prev) })
+}
@@ -0,0 +1,158 @@
+// Package mailtest - SMTP сервер в памяти процесса для тестов
+package mailtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message - принятое письмо
+type Message struct {
+	From string
+	To   []string
+	// Raw - письмо целиком, как его передал клиент
+	Raw string
+}
+
+// Header - значение заголовка письма
+func (m Message) Header(key string) string {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Raw))
+	if err != nil {
+		return ""
+	}
+	return msg.Header.Get(key)
+}
+
+// Server - минимальный SMTP сервер: принимает любые AUTH PLAIN и сохраняет письма
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+// NewServer - запуск сервера на случайном порту 127.0.0.1
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host и Port - адрес сервера для mail.SMTPOptions
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages - копия принятых писем
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close - остановка сервера
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 mailtest ESMTP")
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-mailtest")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "HELO"):
+			reply("250 mailtest")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = Message{From: address(line[len("MAIL FROM:"):])}
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, address(line[len("RCPT TO:"):]))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" || l == ".\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Raw = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 ok")
+		case cmd == "RSET":
+			msg = Message{}
+			reply("250 ok")
+		case cmd == "NOOP":
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func address(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Режимы TLS для SMTP
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// SMTPOptions - параметры SMTP сервера
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+	// Timeout - ограничение на доставку письма, если контекст не задаёт меньшее
+	Timeout time.Duration
+}
+
+// SMTPMailer - доставка писем через SMTP сервер
+type SMTPMailer struct {
+	opts SMTPOptions
+}
+
+// NewSMTPMailer - SMTP транспорт с проверкой параметров
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	if opts.Host == "" {
+		return nil, fmt.Errorf("SMTP host is not set")
+	}
+	if opts.Port == 0 {
+		opts.Port = 587
+	}
+	switch opts.TLS {
+	case "":
+		opts.TLS = TLSStartTLS
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q", opts.TLS)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return &SMTPMailer{opts: opts}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("error while building message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port))
+	tlsConfig := &tls.Config{ServerName: m.opts.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	if m.opts.TLS == TLSImplicit {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("error while connecting to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		return fmt.Errorf("error while starting SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if m.opts.TLS == TLSStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("error while starting TLS: %w", err)
+		}
+	}
+	if m.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(msg.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP RCPT TO failed: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error while sending message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error while sending message: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templatesFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templatesFS, "templates/*.html"))
+)
+
+type challengeData struct {
+	Code string
+	TTL  time.Duration
+}
+
+type verificationData struct {
+	Code string
+	// Link - ссылка подтверждения; пустая, если не задан публичный адрес сервера
+	Link string
+	TTL  time.Duration
+}
+
+// render - текстовая и HTML версии письма по шаблонам templates/<name>.txt и templates/<name>.html
+func render(name string, data any) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
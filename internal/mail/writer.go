@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WriterMailer - запись писем в файл или stdout вместо доставки (для разработки)
+type WriterMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer - транспорт, пишущий письма в w
+func NewWriterMailer(w io.Writer) *WriterMailer {
+	return &WriterMailer{w: w}
+}
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "From: %s\nTo: %s\nSubject: %s\n\n%s\n----\n", msg.From, msg.To, msg.Subject, msg.Text)
+	return err
+}
@@ -0,0 +1,124 @@
+// Package metrics - метрики сервера в формате Prometheus
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophkeeper"
+
+// Результаты проверки кода входа для ChallengeChecked
+const (
+	ChallengeSucceeded = "succeeded"
+	ChallengeFailed    = "failed"
+)
+
+// registry - метрики сервера, процесса и среды выполнения Go
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "REST API requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "REST API request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	challenges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_challenges_total",
+		Help:      "Login challenges by result: issued, succeeded or failed.",
+	}, []string{"result"})
+	mailDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mail_send_duration_seconds",
+		Help:      "Mail delivery latency by kind of mail.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"kind"})
+	mailFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mail_send_failures_total",
+		Help:      "Failed mail deliveries by kind of mail.",
+	}, []string{"kind"})
+	syncPageSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_page_size",
+		Help:      "Records and tombstones returned per sync page by API.",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	}, []string{"api"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, challenges, mailDuration, mailFailures, syncPageSize,
+	)
+	// счётчики видны с нулевыми значениями до первого события
+	for _, result := range []string{"issued", ChallengeSucceeded, ChallengeFailed} {
+		challenges.WithLabelValues(result)
+	}
+}
+
+// Handler - выдача метрик по запросу Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveRequest - учёт запроса к REST API; route - шаблон маршрута gin
+func ObserveRequest(route string, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// ChallengeIssued - выдан код входа
+func ChallengeIssued() {
+	challenges.WithLabelValues("issued").Inc()
+}
+
+// ChallengeChecked - проверен код входа; result - ChallengeSucceeded или ChallengeFailed
+func ChallengeChecked(result string) {
+	challenges.WithLabelValues(result).Inc()
+}
+
+// ObserveMail - отправка письма вида kind (challenge, verification); err - ошибка доставки
+func ObserveMail(kind string, duration time.Duration, err error) {
+	mailDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	if err != nil {
+		mailFailures.WithLabelValues(kind).Inc()
+	}
+}
+
+// ObserveSyncPage - размер страницы синхронизации; api - v1, v2 или grpc
+func ObserveSyncPage(api string, size int) {
+	syncPageSize.WithLabelValues(api).Observe(float64(size))
+}
+
+// RegisterCacheSize - число элементов кэша name по запросу Prometheus
+func RegisterCacheSize(name string, size func() int) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "cache_items",
+		Help:        "Items in an in-memory cache, including expired ones not yet cleaned up.",
+		ConstLabels: prometheus.Labels{"cache": name},
+	}, func() float64 {
+		return float64(size())
+	}))
+}
+
+// RegisterPool - состояние пула соединений с БД по запросу Prometheus
+func RegisterPool(stat func() *pgxpool.Stat) {
+	registry.MustRegister(&poolCollector{stat: stat})
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolMetric - показатель pgxpool.Stat
+type poolMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(s *pgxpool.Stat) float64
+}
+
+func newPoolMetric(name string, help string, valueType prometheus.ValueType, value func(s *pgxpool.Stat) float64) poolMetric {
+	return poolMetric{
+		desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil),
+		valueType: valueType,
+		value:     value,
+	}
+}
+
+var poolMetrics = []poolMetric{
+	newPoolMetric("max_connections", "Maximum size of the connection pool.", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }),
+	newPoolMetric("total_connections", "Connections in the pool, including those being established.", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }),
+	newPoolMetric("acquired_connections", "Connections currently in use.", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }),
+	newPoolMetric("idle_connections", "Idle connections in the pool.", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }),
+	newPoolMetric("constructing_connections", "Connections being established.", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.ConstructingConns()) }),
+	newPoolMetric("acquires_total", "Successful connection acquires.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }),
+	newPoolMetric("acquire_duration_seconds_total", "Total time spent acquiring connections.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }),
+	newPoolMetric("empty_acquires_total", "Acquires that had to wait for a connection.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }),
+	newPoolMetric("canceled_acquires_total", "Acquires canceled by context.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) }),
+	newPoolMetric("new_connections_total", "Connections opened.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.NewConnsCount()) }),
+	newPoolMetric("max_lifetime_destroys_total", "Connections closed after reaching the max lifetime.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.MaxLifetimeDestroyCount()) }),
+	newPoolMetric("max_idle_destroys_total", "Connections closed after reaching the max idle time.", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.MaxIdleDestroyCount()) }),
+}
+
+// poolCollector - показатели пула соединений, снимаемые при каждом запросе метрик
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range poolMetrics {
+		ch <- m.desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+	for _, m := range poolMetrics {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(stat))
+	}
+}
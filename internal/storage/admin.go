@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Admin - управление учётными записями из административного API
+type Admin interface {
+	// SelectUsers - пользователи, адрес которых содержит query (все, если query пустой), по возрастанию ID
+	// начиная после afterID
+	SelectUsers(ctx context.Context, query string, afterID int64, limit int) ([]structs.UserInfo, error)
+	// SelectUser - сведения о пользователе; ErrNotFound, если он не зарегистрирован
+	SelectUser(ctx context.Context, username string) (structs.UserInfo, error)
+	// SetUserDisabled - блокировка (с отзывом всех сессий) или разблокировка учётной записи.
+	// Заблокированный пользователь не может войти (CheckUser и CreateSession)
+	SetUserDisabled(ctx context.Context, username string, disabled bool) error
+	// RevokeSessions - отзыв всех активных сессий пользователя; число отозванных сессий
+	RevokeSessions(ctx context.Context, username string) (int64, error)
+	// DeleteUser - удаление пользователя с его личными хранилищами, записями, историей, файлами, сессиями
+	// и ключами. Доступы к его записям исчезают у получателей, записи хранилищ организаций остаются
+	// в организации. Организации, где он был единственным участником, удаляются вместе с записями;
+	// ErrForbidden, если он единственный владелец организации с другими участниками
+	DeleteUser(ctx context.Context, username string) error
+}
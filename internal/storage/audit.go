@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Audit - журнал аудита: события входа и изменяющие запросы зарегистрированных пользователей.
+// События удаляются вместе с учётной записью (DeleteUser)
+type Audit interface {
+	// AddAuditEvent - новое событие; ID и CreatedAt (если не задано) назначает хранилище.
+	// Событие незарегистрированного адреса не записывается
+	AddAuditEvent(ctx context.Context, event structs.AuditEvent) error
+	// SelectAuditEvents - события пользователя от новых к старым с ID меньше beforeID (0 - с последнего)
+	SelectAuditEvents(ctx context.Context, username string, beforeID int64, limit int) ([]structs.AuditEvent, error)
+	// ExportAuditEvents - последовательная выдача событий по фильтру в порядке ID
+	ExportAuditEvents(ctx context.Context, filter AuditFilter, fn func(event structs.AuditEvent) error) error
+}
+
+// AuditFilter - отбор событий для выгрузки; пустые поля не ограничивают выборку
+type AuditFilter struct {
+	Username string
+	// Since и Until - интервал времени событий [Since, Until)
+	Since time.Time
+	Until time.Time
+}
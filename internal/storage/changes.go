@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// Changes - уведомления об изменениях записей пользователя
+type Changes interface {
+	// SubscribeChanges - historyID изменений записей пользователя и доступных ему, зафиксированных после подписки.
+	// Канал закрывается при отмене ctx. Уведомления не копятся: если подписчик не успевает их читать,
+	// промежуточные historyID теряются, но последний не меньше любого потерянного
+	SubscribeChanges(ctx context.Context, username string) (<-chan int64, error)
+	// SelectLastHistoryID - наибольший history_id записей пользователя и доступных ему (см. Sharing и Vaults); 0, если записей нет
+	SelectLastHistoryID(ctx context.Context, username string) (int64, error)
+}
+
+// Broker - рассылка зафиксированных изменений подписчикам внутри процесса
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan int64]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[int64]map[chan int64]struct{})}
+}
+
+// Subscribe - канал изменений пользователя userID до отмены ctx
+func (b *Broker) Subscribe(ctx context.Context, userID int64) <-chan int64 {
+	ch := make(chan int64, 1)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan int64]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers[userID], ch)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+		b.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish - уведомление подписчиков userID; непрочитанное уведомление заменяется более новым
+func (b *Broker) Publish(userID int64, historyID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[userID] {
+		select {
+		case ch <- historyID:
+			continue
+		default:
+		}
+		// в буфере старое уведомление: заменяем его, чтобы подписчик увидел последнее изменение
+		latest := historyID
+		select {
+		case previous := <-ch:
+			latest = max(latest, previous)
+		default:
+		}
+		select {
+		case ch <- latest:
+		default:
+		}
+	}
+}
+
+// UserIDs - пользователи, у которых есть подписчики
+func (b *Broker) UserIDs() []int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids := make([]int64, 0, len(b.subscribers))
+	for id := range b.subscribers {
+		ids = append(ids, id)
+	}
+	return ids
+}
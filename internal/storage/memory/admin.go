@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (s *Storage) SelectUsers(ctx context.Context, query string, afterID int64, limit int) ([]structs.UserInfo, error) {
+	defer s.rlock(ctx)()
+
+	var result []structs.UserInfo
+	for mail, u := range s.users {
+		if u.id > afterID && strings.Contains(strings.ToLower(mail), strings.ToLower(query)) {
+			result = append(result, s.userInfo(mail, u))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Storage) SelectUser(ctx context.Context, username string) (structs.UserInfo, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return structs.UserInfo{}, storage.ErrNotFound
+	}
+	return s.userInfo(username, u), nil
+}
+
+func (s *Storage) SetUserDisabled(ctx context.Context, username string, disabled bool) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return storage.ErrNotFound
+	}
+	if !disabled {
+		u.disabledAt = nil
+		return nil
+	}
+	if u.disabledAt == nil {
+		now := time.Now()
+		u.disabledAt = &now
+	}
+	s.revokeSessions(username)
+	return nil
+}
+
+func (s *Storage) RevokeSessions(ctx context.Context, username string) (int64, error) {
+	defer s.lock(ctx)()
+
+	if _, found := s.users[username]; !found {
+		return 0, storage.ErrNotFound
+	}
+	return s.revokeSessions(username), nil
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, username string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return storage.ErrNotFound
+	}
+
+	// организации, которые остаются без пользователя, и новые авторы его записей в них
+	successors := make(map[int64]int64)
+	var dropped []int64
+	for orgID := range s.orgs {
+		m, ok := s.activeMember(orgID, u.id)
+		if !ok {
+			continue
+		}
+		successor := s.successor(orgID, u.id)
+		switch {
+		case successor == 0:
+			dropped = append(dropped, orgID)
+		case m.role == structs.RoleOwner && s.owners(orgID) == 1:
+			return storage.ErrForbidden
+		default:
+			successors[orgID] = successor
+		}
+	}
+
+	for orgID, successor := range successors {
+		for id, d := range s.secureData {
+			if d.userID == u.id && s.orgOf(d) == orgID {
+				d.userID = successor
+				s.reassign(id, successor)
+			}
+		}
+	}
+
+	for id, d := range s.secureData {
+		orgID := s.orgOf(d)
+		if orgID == 0 && d.userID == u.id || slices.Contains(dropped, orgID) {
+			s.deleteSecureData(id)
+		}
+	}
+	for id, v := range s.vaults {
+		if v.userID == u.id || slices.Contains(dropped, v.orgID) {
+			delete(s.vaults, id)
+		}
+	}
+	for _, orgID := range dropped {
+		delete(s.orgs, orgID)
+	}
+	for _, o := range s.orgs {
+		delete(o.members, u.id)
+	}
+	for key := range s.shares {
+		if key.recipientID == u.id {
+			delete(s.shares, key)
+		}
+	}
+	for id, r := range s.sessions {
+		if r.username == username {
+			delete(s.sessions, id)
+		}
+	}
+	for key := range s.idempotency {
+		if key.userID == u.id {
+			delete(s.idempotency, key)
+		}
+	}
+	s.audit = slices.DeleteFunc(s.audit, func(e structs.AuditEvent) bool {
+		return e.Username == username
+	})
+	delete(s.users, username)
+	delete(s.usersByID, u.id)
+	return nil
+}
+
+// revokeSessions - отзыв активных сессий пользователя username
+func (s *Storage) revokeSessions(username string) int64 {
+	var revoked int64
+	now := time.Now()
+	for _, r := range s.sessions {
+		if r.username == username && r.active(now) {
+			r.revoked = true
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// successor - активный участник организации orgID, кроме userID, к которому переходят записи userID:
+// старейший владелец, иначе старейший участник; 0, если других участников нет
+func (s *Storage) successor(orgID int64, userID int64) int64 {
+	var best int64
+	var bestMember *member
+	better := func(id int64, m *member) bool {
+		switch {
+		case bestMember == nil:
+			return true
+		case (m.role == structs.RoleOwner) != (bestMember.role == structs.RoleOwner):
+			return m.role == structs.RoleOwner
+		case !m.createdAt.Equal(bestMember.createdAt):
+			return m.createdAt.Before(bestMember.createdAt)
+		}
+		return id < best
+	}
+	for id, m := range s.orgs[orgID].members {
+		if id != userID && m.removedAt == nil && better(id, m) {
+			best, bestMember = id, m
+		}
+	}
+	return best
+}
+
+// reassign - история и файлы записи id переходят к пользователю userID
+func (s *Storage) reassign(id int64, userID int64) {
+	for i := range s.history {
+		if s.history[i].secureDataID == id {
+			s.history[i].userID = userID
+		}
+	}
+	for _, b := range s.blobs {
+		if b.blob.SecureDataID == id {
+			b.userID = userID
+		}
+	}
+}
+
+// deleteSecureData - удаление записи с историей, файлами и доступами
+func (s *Storage) deleteSecureData(id int64) {
+	delete(s.secureData, id)
+	kept := s.history[:0]
+	for _, h := range s.history {
+		if h.secureDataID != id {
+			kept = append(kept, h)
+		}
+	}
+	s.history = kept
+	for blobID, b := range s.blobs {
+		if b.blob.SecureDataID == id {
+			delete(s.blobs, blobID)
+		}
+	}
+	for key := range s.shares {
+		if key.secureDataID == id {
+			delete(s.shares, key)
+		}
+	}
+}
+
+func (s *Storage) userInfo(mail string, u *user) structs.UserInfo {
+	info := structs.UserInfo{
+		ID:         u.id,
+		Username:   mail,
+		CreatedAt:  u.createdAt,
+		Pending:    u.verification != nil,
+		DisabledAt: u.disabledAt,
+	}
+	for _, d := range s.secureData {
+		if d.userID == u.id && d.data.IsActive {
+			info.Records++
+		}
+	}
+	now := time.Now()
+	for _, r := range s.sessions {
+		if r.username != mail {
+			continue
+		}
+		if r.active(now) {
+			info.Sessions++
+		}
+		if info.LastActivity == nil || r.session.LastSeen.After(*info.LastActivity) {
+			lastSeen := r.session.LastSeen
+			info.LastActivity = &lastSeen
+		}
+	}
+	return info
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (s *Storage) AddAuditEvent(ctx context.Context, event structs.AuditEvent) error {
+	defer s.lock(ctx)()
+
+	if _, found := s.users[event.Username]; !found {
+		return nil
+	}
+	s.lastAuditID++
+	event.ID = s.lastAuditID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	s.audit = append(s.audit, event)
+	return nil
+}
+
+func (s *Storage) SelectAuditEvents(ctx context.Context, username string, beforeID int64, limit int) ([]structs.AuditEvent, error) {
+	defer s.rlock(ctx)()
+
+	var result []structs.AuditEvent
+	for i := len(s.audit) - 1; i >= 0 && len(result) < limit; i-- {
+		e := s.audit[i]
+		if e.Username == username && (beforeID == 0 || e.ID < beforeID) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (s *Storage) ExportAuditEvents(ctx context.Context, filter storage.AuditFilter, fn func(event structs.AuditEvent) error) error {
+	// копия под блокировкой: fn может писать ответ клиенту долго
+	unlock := s.rlock(ctx)
+	events := make([]structs.AuditEvent, 0, len(s.audit))
+	for _, e := range s.audit {
+		if matchAudit(filter, e) {
+			events = append(events, e)
+		}
+	}
+	unlock()
+
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchAudit(f storage.AuditFilter, e structs.AuditEvent) bool {
+	return (f.Username == "" || e.Username == f.Username) &&
+		(f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.CreatedAt.Before(f.Until))
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+)
+
+// change - изменение записи пользователя, ещё не разосланное подписчикам
+type change struct {
+	userID    int64
+	historyID int64
+}
+
+func (s *Storage) SubscribeChanges(ctx context.Context, username string) (<-chan int64, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	return s.broker.Subscribe(ctx, u.id), nil
+}
+
+func (s *Storage) SelectLastHistoryID(ctx context.Context, username string) (int64, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return 0, nil
+	}
+	var last int64
+	for _, d := range s.secureData {
+		if d.userID == u.id && s.orgOf(d) == 0 {
+			last = max(last, d.data.HistoryID)
+		}
+	}
+	for _, data := range s.orgData(u.id) {
+		last = max(last, data.HistoryID, data.SyncID)
+	}
+	for key, sh := range s.shares {
+		if key.recipientID == u.id {
+			data := s.sharedData(key, sh)
+			last = max(last, data.HistoryID, data.SyncID)
+		}
+	}
+	return last, nil
+}
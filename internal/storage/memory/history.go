@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (s *Storage) SelectRevisions(ctx context.Context, id int64, username string) ([]structs.Revision, error) {
+	defer s.rlock(ctx)()
+
+	if _, err := s.findAccess(id, username, accessVault); err != nil {
+		return nil, err
+	}
+
+	var result []structs.Revision
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if h := s.history[i]; h.secureDataID == id && h.snapshot != nil {
+			result = append(result, h.revision())
+		}
+	}
+	return result, nil
+}
+
+func (s *Storage) SelectRevision(ctx context.Context, id int64, username string, historyID int64) (structs.Revision, error) {
+	defer s.rlock(ctx)()
+
+	if _, err := s.findAccess(id, username, accessVault); err != nil {
+		return structs.Revision{}, err
+	}
+	h, found := s.revision(id, historyID)
+	if !found {
+		return structs.Revision{}, storage.ErrNotFound
+	}
+	return h.revision(), nil
+}
+
+func (s *Storage) RestoreSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, revisionID int64) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.findAccess(id, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.checkHistoryID(d, expectedHistoryID, "RESTORE"); err != nil {
+		return 0, err
+	}
+	h, found := s.revision(id, revisionID)
+	if !found {
+		return 0, storage.ErrNotFound
+	}
+	if err := s.checkQuota(nil, d, h.snapshot.Data, h.snapshot.Metadata, h.snapshot.BlobID); err != nil {
+		return 0, err
+	}
+
+	d.data.Data = h.snapshot.Data
+	d.data.Metadata = h.snapshot.Metadata
+	d.data.Kind = h.snapshot.Kind
+	d.data.BlobID = h.snapshot.BlobID
+	d.data.IsActive = true
+	return s.updateHistory(d, "RESTORE"), nil
+}
+
+func (s *Storage) SetHistoryRetention(ctx context.Context, username string, limit int) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return storage.ErrNotFound
+	}
+	u.historyRetention = limit
+	return nil
+}
+
+func (s *Storage) SelectHistoryRetention(ctx context.Context, username string) (int, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return 0, storage.ErrNotFound
+	}
+	return u.historyRetention, nil
+}
+
+// revision - ревизия historyID записи id
+func (s *Storage) revision(id int64, historyID int64) (history, bool) {
+	i := sort.Search(len(s.history), func(i int) bool {
+		return s.history[i].id >= historyID
+	})
+	if i == len(s.history) || s.history[i].id != historyID || s.history[i].secureDataID != id || s.history[i].snapshot == nil {
+		return history{}, false
+	}
+	return s.history[i], true
+}
+
+// pruneHistory - удаление старых ревизий записи сверх лимита пользователя (не больше его квоты ревизий)
+func (s *Storage) pruneHistory(d *secureData) {
+	u := s.usersByID[d.userID]
+	keep := storage.Retention(u.historyRetention, s.userQuota(u))
+	if keep <= 0 {
+		return
+	}
+
+	kept := 0
+	for i := len(s.history) - 1; i >= 0; i-- {
+		h := s.history[i]
+		if h.secureDataID != d.data.ID || h.snapshot == nil {
+			continue
+		}
+		kept++
+		if kept > keep {
+			s.history = append(s.history[:i], s.history[i+1:]...)
+		}
+	}
+}
+
+func (h history) revision() structs.Revision {
+	return structs.Revision{
+		HistoryID:    h.id,
+		SecureDataID: h.secureDataID,
+		Method:       h.method,
+		Data:         h.snapshot.Data,
+		Metadata:     h.snapshot.Metadata,
+		Kind:         h.snapshot.Kind,
+		IsActive:     h.snapshot.IsActive,
+		BlobID:       h.snapshot.BlobID,
+		CreatedAt:    h.createdAt,
+	}
+}
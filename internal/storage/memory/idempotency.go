@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type idempotencyKey struct {
+	userID int64
+	key    string
+}
+
+type idempotentRequest struct {
+	request   structs.IdempotentRequest
+	expiresAt time.Time
+}
+
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, username string, key string, requestHash string, expiresAt time.Time, staleBefore time.Time) (structs.IdempotentRequest, bool, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return structs.IdempotentRequest{}, false, storage.ErrNotFound
+	}
+
+	now := time.Now()
+	k := idempotencyKey{userID: u.id, key: key}
+	if r, found := s.idempotency[k]; found && !r.expiresAt.Before(now) && (r.request.Status != 0 || !r.request.CreatedAt.Before(staleBefore)) {
+		request := r.request
+		request.Body = append([]byte(nil), r.request.Body...)
+		return request, false, nil
+	}
+
+	s.idempotency[k] = &idempotentRequest{
+		request: structs.IdempotentRequest{
+			RequestHash: requestHash,
+			CreatedAt:   now,
+		},
+		expiresAt: expiresAt,
+	}
+	return structs.IdempotentRequest{RequestHash: requestHash, CreatedAt: now}, true, nil
+}
+
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, username string, key string, status int, body []byte) error {
+	defer s.lock(ctx)()
+
+	r, err := s.findIdempotencyKey(username, key)
+	if err != nil {
+		return err
+	}
+	r.request.Status = status
+	r.request.Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (s *Storage) DeleteIdempotencyKey(ctx context.Context, username string, key string) error {
+	defer s.lock(ctx)()
+
+	if u, found := s.users[username]; found {
+		delete(s.idempotency, idempotencyKey{userID: u.id, key: key})
+	}
+	return nil
+}
+
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	defer s.lock(ctx)()
+
+	var deleted int64
+	now := time.Now()
+	for k, r := range s.idempotency {
+		if r.expiresAt.Before(now) {
+			delete(s.idempotency, k)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Storage) findIdempotencyKey(username string, key string) (*idempotentRequest, error) {
+	u, found := s.users[username]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	r, found := s.idempotency[idempotencyKey{userID: u.id, key: key}]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	return r, nil
+}
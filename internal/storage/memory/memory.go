@@ -0,0 +1,529 @@
+// Package memory - реализация storage.Storage в памяти процесса (тесты, запуск без Postgres)
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type user struct {
+	id               int64
+	keys             *structs.UserKeys
+	historyRetention int
+	totp             *totp
+	// verification - nil у подтверждённых пользователей
+	verification *structs.Verification
+	createdAt    time.Time
+	disabledAt   *time.Time
+	// quota - квоты, заданные администратором; nil - значения по умолчанию
+	quota *structs.Quota
+}
+
+type secureData struct {
+	userID int64
+	data   structs.SecureData
+}
+
+type history struct {
+	id                int64
+	userID            int64
+	secureDataID      int64
+	method            string
+	expectedHistoryID int64
+	// recipient - получатель доступа для SHARE и UNSHARE (записи без снимка)
+	recipient string
+	// snapshot - состояние записи после изменения; nil для конфликтов, SHARE и UNSHARE
+	snapshot  *structs.SecureData
+	createdAt time.Time
+}
+
+type blob struct {
+	userID    int64
+	blob      structs.Blob
+	chunks    [][]byte
+	createdAt time.Time
+}
+
+// Storage - хранилище в памяти; все операции выполняются под одной блокировкой
+type Storage struct {
+	mu sync.RWMutex
+
+	users      map[string]*user
+	usersByID  map[int64]*user
+	sessions   map[string]*sessionRecord
+	secureData map[int64]*secureData
+	history    []history
+	blobs      map[int64]*blob
+	shares     map[shareKey]*share
+	orgs       map[int64]*organization
+	vaults     map[int64]*vault
+
+	// idempotency - ключи Idempotency-Key; не входят в снимок транзакции
+	idempotency map[idempotencyKey]*idempotentRequest
+	// audit - журнал аудита в порядке ID; не входит в снимок транзакции
+	audit       []structs.AuditEvent
+	lastAuditID int64
+
+	// changes - изменения, рассылаемые подписчикам после снятия блокировки
+	changes []change
+	broker  *storage.Broker
+
+	lastUserID       int64
+	lastSecureDataID int64
+	lastHistoryID    int64
+	lastBlobID       int64
+	lastOrgID        int64
+	lastVaultID      int64
+
+	// quota - квоты по умолчанию (storage.Options)
+	quota structs.Quota
+	// uploadTTL - срок незавершённой загрузки файла (storage.Options)
+	uploadTTL time.Duration
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+// New - пустое хранилище
+func New(opts storage.Options) *Storage {
+	quota := opts.Quota
+	quota.Revisions = opts.HistoryRetention
+	return &Storage{
+		users:       make(map[string]*user),
+		usersByID:   make(map[int64]*user),
+		sessions:    make(map[string]*sessionRecord),
+		idempotency: make(map[idempotencyKey]*idempotentRequest),
+		secureData:  make(map[int64]*secureData),
+		blobs:       make(map[int64]*blob),
+		shares:      make(map[shareKey]*share),
+		orgs:        make(map[int64]*organization),
+		vaults:      make(map[int64]*vault),
+		broker:      storage.NewBroker(),
+		quota:       quota,
+		uploadTTL:   opts.UploadTTL,
+	}
+}
+
+func (s *Storage) RegisterUser(ctx context.Context, mail string, verification *structs.Verification) error {
+	defer s.lock(ctx)()
+
+	if u, found := s.users[mail]; found {
+		if u.verification == nil || verification == nil {
+			return storage.ErrAlreadyExists
+		}
+		v := *verification
+		u.verification = &v
+		return nil
+	}
+	s.lastUserID++
+	u := &user{id: s.lastUserID, createdAt: time.Now()}
+	if verification != nil {
+		v := *verification
+		u.verification = &v
+	}
+	s.users[mail] = u
+	s.usersByID[u.id] = u
+	return nil
+}
+
+func (s *Storage) VerifyUser(ctx context.Context, mail string, codeHash string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[mail]
+	if !found || u.verification == nil || u.verification.CodeHash != codeHash || !time.Now().Before(u.verification.ExpiresAt) {
+		return storage.ErrNotFound
+	}
+	u.verification = nil
+	return nil
+}
+
+func (s *Storage) CheckUser(ctx context.Context, mail string) error {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[mail]
+	if !found {
+		return storage.ErrNotFound
+	}
+	if u.verification != nil {
+		return storage.ErrPending
+	}
+	if u.disabledAt != nil {
+		return storage.ErrDisabled
+	}
+	return nil
+}
+
+func (s *Storage) DeleteExpiredUsers(ctx context.Context) (int64, error) {
+	defer s.lock(ctx)()
+
+	var deleted int64
+	now := time.Now()
+	for mail, u := range s.users {
+		if u.verification != nil && u.verification.ExpiresAt.Before(now) {
+			delete(s.users, mail)
+			delete(s.usersByID, u.id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Storage) SetUserKeys(ctx context.Context, username string, keys structs.UserKeys) (int64, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return 0, storage.ErrNotFound
+	}
+	var version int64
+	if u.keys != nil {
+		version = u.keys.Version
+	}
+	if version != keys.Version {
+		return 0, storage.ErrConflict
+	}
+	keys.KDF.Salt = append([]byte(nil), keys.KDF.Salt...)
+	if keys.PublicKey == "" && u.keys != nil {
+		keys.PublicKey, keys.WrappedPrivateKey = u.keys.PublicKey, u.keys.WrappedPrivateKey
+	}
+	keys.Version++
+	u.keys = &keys
+	return keys.Version, nil
+}
+
+func (s *Storage) SelectUserKeys(ctx context.Context, username string) (structs.UserKeys, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.keys == nil {
+		return structs.UserKeys{}, storage.ErrNotFound
+	}
+	return *u.keys, nil
+}
+
+func (s *Storage) AddSecureData(ctx context.Context, username string, vaultID int64, kind string, data string, metadata string) (int64, int64, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return 0, 0, storage.ErrNotFound
+	}
+	if err := s.checkVault(vaultID, u.id); err != nil {
+		return 0, 0, err
+	}
+	if err := s.checkQuota(u, nil, data, metadata, 0); err != nil {
+		return 0, 0, err
+	}
+
+	s.lastSecureDataID++
+	d := &secureData{
+		userID: u.id,
+		data: structs.SecureData{
+			ID:       s.lastSecureDataID,
+			Data:     data,
+			Metadata: metadata,
+			IsActive: true,
+			Kind:     kind,
+			VaultID:  vaultID,
+		},
+	}
+	s.secureData[d.data.ID] = d
+
+	return d.data.ID, s.updateHistory(d, "ADD"), nil
+}
+
+func (s *Storage) UpdateSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, kind string, data string, metadata string) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.findAccess(id, username, accessWrite)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.checkHistoryID(d, expectedHistoryID, "UPDATE"); err != nil {
+		return 0, err
+	}
+	if !d.data.IsActive {
+		return 0, storage.ErrNotFound
+	}
+	if err := s.checkQuota(nil, d, data, metadata, keepFile); err != nil {
+		return 0, err
+	}
+
+	d.data.Kind = kind
+	d.data.Data = data
+	d.data.Metadata = metadata
+	return s.updateHistory(d, "UPDATE"), nil
+}
+
+func (s *Storage) DeleteSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.findAccess(id, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.checkHistoryID(d, expectedHistoryID, "DELETE"); err != nil {
+		return 0, err
+	}
+
+	d.data.IsActive = false
+	return s.updateHistory(d, "DELETE"), nil
+}
+
+func (s *Storage) SelectSecureDataKind(ctx context.Context, id int64, username string) (string, error) {
+	defer s.rlock(ctx)()
+
+	d, err := s.findAccess(id, username, accessRead)
+	if err != nil {
+		return "", err
+	}
+	return d.data.Kind, nil
+}
+
+func (s *Storage) SelectUpdatedSecureData(ctx context.Context, lastID int64, username string, limit int) ([]structs.SecureData, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, nil
+	}
+
+	var result []structs.SecureData
+	for _, d := range s.secureData {
+		if d.userID == u.id && s.orgOf(d) == 0 && d.data.HistoryID > lastID {
+			result = append(result, d.data)
+		}
+	}
+	for _, data := range s.orgData(u.id) {
+		if max(data.HistoryID, data.SyncID) > lastID {
+			result = append(result, data)
+		}
+	}
+	for key, sh := range s.shares {
+		if key.recipientID == u.id {
+			if data := s.sharedData(key, sh); max(data.HistoryID, data.SyncID) > lastID {
+				result = append(result, data)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return max(result[i].HistoryID, result[i].SyncID) < max(result[j].HistoryID, result[j].SyncID)
+	})
+	if limit >= 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Storage) CreateBlob(ctx context.Context, username string, secureDataID int64, size int64, sha256 string) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.findAccess(secureDataID, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+	if !d.data.IsActive {
+		return 0, storage.ErrNotFound
+	}
+	if err := s.checkBlobQuota(d, size); err != nil {
+		return 0, err
+	}
+
+	s.lastBlobID++
+	s.blobs[s.lastBlobID] = &blob{
+		userID: d.userID,
+		blob: structs.Blob{
+			ID:           s.lastBlobID,
+			SecureDataID: secureDataID,
+			Size:         size,
+			SHA256:       sha256,
+		},
+		createdAt: time.Now(),
+	}
+	return s.lastBlobID, nil
+}
+
+func (s *Storage) SelectBlob(ctx context.Context, id int64, username string) (structs.Blob, error) {
+	defer s.rlock(ctx)()
+
+	b, found := s.blobs[id]
+	if !found {
+		return structs.Blob{}, storage.ErrNotFound
+	}
+	if _, err := s.findAccess(b.blob.SecureDataID, username, accessVault); err == nil {
+		return b.blob, nil
+	}
+	// получателю доступа - только текущий файл записи
+	d, err := s.findAccess(b.blob.SecureDataID, username, accessRead)
+	if err != nil || d.data.BlobID != id {
+		return structs.Blob{}, storage.ErrNotFound
+	}
+	return b.blob, nil
+}
+
+func (s *Storage) AppendBlobChunk(ctx context.Context, id int64, username string, offset int64, data []byte) (int64, error) {
+	defer s.lock(ctx)()
+
+	b, err := s.manageBlob(id, username)
+	if err != nil {
+		return 0, err
+	}
+	if b.blob.IsComplete || b.blob.Received != offset || offset+int64(len(data)) > b.blob.Size {
+		return 0, storage.ErrBlobOffset
+	}
+
+	b.chunks = append(b.chunks, append([]byte(nil), data...))
+	b.blob.Received += int64(len(data))
+	return b.blob.Received, nil
+}
+
+func (s *Storage) ResetBlob(ctx context.Context, id int64, username string) error {
+	defer s.lock(ctx)()
+
+	b, err := s.manageBlob(id, username)
+	if err != nil {
+		return err
+	}
+	if !b.blob.IsComplete {
+		b.chunks = nil
+		b.blob.Received = 0
+	}
+	return nil
+}
+
+// manageBlob - файл id, если пользователь username может загружать его содержимое
+func (s *Storage) manageBlob(id int64, username string) (*blob, error) {
+	b, found := s.blobs[id]
+	if !found || s.uploadExpired(b) {
+		return nil, storage.ErrNotFound
+	}
+	if _, err := s.findAccess(b.blob.SecureDataID, username, accessManage); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Storage) ReadBlobChunks(ctx context.Context, id int64, offset int64, fn func(data []byte) error) error {
+	unlock := s.rlock(ctx)
+	b, found := s.blobs[id]
+	var chunks [][]byte
+	if found {
+		chunks = append(chunks, b.chunks...)
+	}
+	unlock()
+
+	// части не изменяются после записи, поэтому fn вызывается без блокировки
+	var chunkOffset int64
+	for _, chunk := range chunks {
+		end := chunkOffset + int64(len(chunk))
+		if end > offset {
+			start := max(offset-chunkOffset, 0)
+			if err := fn(chunk[start:]); err != nil {
+				return err
+			}
+		}
+		chunkOffset = end
+	}
+	return nil
+}
+
+func (s *Storage) CompleteBlob(ctx context.Context, id int64, username string) (int64, error) {
+	defer s.lock(ctx)()
+
+	b, found := s.blobs[id]
+	if !found || b.blob.IsComplete || b.blob.Received != b.blob.Size || s.uploadExpired(b) {
+		return 0, storage.ErrNotFound
+	}
+	d, err := s.findAccess(b.blob.SecureDataID, username, accessManage)
+	if err != nil {
+		return 0, err
+	}
+
+	b.blob.IsComplete = true
+	d.data.BlobID = id
+	b.blob.HistoryID = s.updateHistory(d, "BLOB")
+	return b.blob.HistoryID, nil
+}
+
+// find - запись id личного хранилища пользователя username
+func (s *Storage) find(id int64, username string) (*secureData, error) {
+	u, found := s.users[username]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	d, found := s.secureData[id]
+	if !found || d.userID != u.id || s.orgOf(d) != 0 {
+		return nil, storage.ErrNotFound
+	}
+	return d, nil
+}
+
+// checkHistoryID - при несовпадении history_id конфликт записывается в историю без изменения записи
+func (s *Storage) checkHistoryID(d *secureData, expectedHistoryID int64, method string) error {
+	if expectedHistoryID == 0 || expectedHistoryID == d.data.HistoryID {
+		return nil
+	}
+	conflict := &conflictError{
+		ConflictError: &storage.ConflictError{Current: d.data},
+		history: history{
+			userID:            d.userID,
+			secureDataID:      d.data.ID,
+			method:            method + "_CONFLICT",
+			expectedHistoryID: expectedHistoryID,
+			createdAt:         time.Now(),
+		},
+	}
+	s.recordConflict(conflict)
+	return conflict
+}
+
+// conflictError - *storage.ConflictError с записью истории о нём: InTransaction повторяет её после отката
+type conflictError struct {
+	*storage.ConflictError
+	history history
+}
+
+func (e *conflictError) Unwrap() error {
+	return e.ConflictError
+}
+
+// recordConflict - запись конфликта в историю
+func (s *Storage) recordConflict(conflict *conflictError) {
+	s.lastHistoryID++
+	h := conflict.history
+	h.id = s.lastHistoryID
+	s.history = append(s.history, h)
+}
+
+// updateHistory - запись изменения со снимком записи в историю, отметка записи номером изменения
+// и удаление ревизий сверх лимита
+func (s *Storage) updateHistory(d *secureData, method string) int64 {
+	s.lastHistoryID++
+	snapshot := d.data
+	s.history = append(s.history, history{
+		id:           s.lastHistoryID,
+		userID:       d.userID,
+		secureDataID: d.data.ID,
+		method:       method,
+		snapshot:     &snapshot,
+		createdAt:    time.Now(),
+	})
+	d.data.HistoryID = s.lastHistoryID
+	if s.orgOf(d) == 0 {
+		s.changes = append(s.changes, change{userID: d.userID, historyID: s.lastHistoryID})
+	}
+	for _, userID := range s.members(d) {
+		s.changes = append(s.changes, change{userID: userID, historyID: s.lastHistoryID})
+	}
+	for key, sh := range s.shares {
+		if key.secureDataID == d.data.ID && sh.revokedAt == nil {
+			s.changes = append(s.changes, change{userID: key.recipientID, historyID: s.lastHistoryID})
+		}
+	}
+	s.pruneHistory(d)
+	return s.lastHistoryID
+}
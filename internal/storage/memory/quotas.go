@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+func (s *Storage) SelectUsage(ctx context.Context, username string) (structs.Usage, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return structs.Usage{}, storage.ErrNotFound
+	}
+	return s.usage(u), nil
+}
+
+func (s *Storage) SetUserQuota(ctx context.Context, username string, quota *structs.Quota) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return storage.ErrNotFound
+	}
+	u.quota = nil
+	if quota != nil {
+		q := *quota
+		u.quota = &q
+	}
+	return nil
+}
+
+// userQuota - квоты пользователя u
+func (s *Storage) userQuota(u *user) structs.Quota {
+	if u.quota != nil {
+		return *u.quota
+	}
+	return s.quota
+}
+
+// usage - объём активных записей автора u и его квоты
+func (s *Storage) usage(u *user) structs.Usage {
+	usage := structs.Usage{Quota: s.userQuota(u), Custom: u.quota != nil}
+	for _, d := range s.secureData {
+		if d.userID == u.id && d.data.IsActive {
+			usage.Records++
+			usage.Bytes += storage.RecordSize(d.data.Data, d.data.Metadata, 0)
+		}
+	}
+	for _, b := range s.blobs {
+		if d, found := s.secureData[b.blob.SecureDataID]; found && d.userID == u.id && d.data.IsActive && s.retained(d, b) {
+			usage.Bytes += b.blob.Size
+		}
+	}
+	return usage
+}
+
+// files - суммарный размер файлов записи d, учитываемых в квотах (retained)
+func (s *Storage) files(d *secureData) int64 {
+	var size int64
+	for _, b := range s.blobs {
+		if b.blob.SecureDataID == d.data.ID && s.retained(d, b) {
+			size += b.blob.Size
+		}
+	}
+	return size
+}
+
+// retained - файл b записи d учитывается в квотах: это текущий файл записи, файл хранимой ревизии
+// или незавершённая загрузка, срок которой не истёк
+func (s *Storage) retained(d *secureData, b *blob) bool {
+	if b.blob.ID == d.data.BlobID {
+		return true
+	}
+	if !b.blob.IsComplete {
+		return !s.uploadExpired(b)
+	}
+	for _, h := range s.history {
+		if h.secureDataID == d.data.ID && h.snapshot != nil && h.snapshot.BlobID == b.blob.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadExpired - незавершённую загрузку b нельзя продолжить (Options.UploadTTL)
+func (s *Storage) uploadExpired(b *blob) bool {
+	return !b.blob.IsComplete && s.uploadTTL != 0 && time.Since(b.createdAt) >= s.uploadTTL
+}
+
+// keepFile - файл записи при изменении не меняется (checkQuota)
+const keepFile int64 = -1
+
+// checkQuota - проверка квот автора записи d перед заменой её данных на data и metadata и её файла
+// на blobID (keepFile - файл не меняется, 0 - без файла); d - nil для новой записи автора u
+func (s *Storage) checkQuota(u *user, d *secureData, data string, metadata string, blobID int64) error {
+	added, oldSize, file, files := true, int64(0), int64(0), int64(0)
+	if d != nil {
+		u = s.usersByID[d.userID]
+		added = !d.data.IsActive
+		oldSize = storage.RecordSize(d.data.Data, d.data.Metadata, 0)
+		files = s.files(d)
+		if b, found := s.blobs[d.data.BlobID]; found {
+			file = b.blob.Size
+		}
+	}
+	if blobID != keepFile {
+		file = 0
+		if b, found := s.blobs[blobID]; found {
+			file = b.blob.Size
+		}
+	}
+
+	// файлы записи остаются в объёме, пока она активна; удалённая запись возвращает их при восстановлении
+	size := storage.RecordSize(data, metadata, 0)
+	grow := size - oldSize
+	if added {
+		grow = size + files
+	}
+	usage := s.usage(u)
+	return storage.CheckQuota(usage.Quota, usage, added, size+file, grow)
+}
+
+// checkBlobQuota - проверка квот автора активной записи d перед загрузкой в неё файла размером size,
+// который заменит текущий
+func (s *Storage) checkBlobQuota(d *secureData, size int64) error {
+	dataSize := storage.RecordSize(d.data.Data, d.data.Metadata, 0)
+	usage := s.usage(s.usersByID[d.userID])
+	return storage.CheckQuota(usage.Quota, usage, false, dataSize+size, size)
+}
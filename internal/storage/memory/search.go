@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// match - запись, прошедшая фильтр, со значением поля сортировки
+type match struct {
+	data  structs.SecureData
+	value string
+	// number - значение для SortHistoryID
+	number int64
+}
+
+func (s *Storage) SearchSecureData(ctx context.Context, username string, filter storage.SearchFilter) ([]structs.SecureData, *storage.SearchCursor, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, nil, nil
+	}
+
+	var contains []any
+	if filter.Metadata != "" {
+		var v any
+		if err := json.Unmarshal([]byte(filter.Metadata), &v); err != nil {
+			return nil, nil, err
+		}
+		contains = append(contains, v)
+	}
+	if len(filter.Tags) != 0 {
+		tags := make([]any, 0, len(filter.Tags))
+		for _, tag := range filter.Tags {
+			tags = append(tags, tag)
+		}
+		contains = append(contains, map[string]any{"tags": tags})
+	}
+
+	var result []match
+	for _, d := range s.secureData {
+		data, ok := s.searchable(d, u.id)
+		if !ok || !matchState(data.IsActive, filter.State) {
+			continue
+		}
+		if filter.Kind != "" && data.Kind != filter.Kind {
+			continue
+		}
+		if len(contains) != 0 && !matchMetadata(data.Metadata, contains) {
+			continue
+		}
+		f := match{data: data, number: data.HistoryID}
+		if key, ok := strings.CutPrefix(filter.Sort, storage.SortMetadataPrefix); ok {
+			f.value = metadataText(data.Metadata, key)
+		}
+		result = append(result, f)
+	}
+
+	compare := func(a match, b match) int {
+		var c int
+		switch {
+		case filter.Sort == storage.SortHistoryID:
+			c = cmp.Compare(a.number, b.number)
+		case filter.Sort != storage.SortID:
+			c = strings.Compare(a.value, b.value)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.data.ID, b.data.ID)
+		}
+		if filter.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(result, compare)
+
+	if filter.After != nil {
+		after := match{data: structs.SecureData{ID: filter.After.ID}, value: filter.After.Value}
+		after.number, _ = strconv.ParseInt(filter.After.Value, 10, 64)
+		start, _ := slices.BinarySearchFunc(result, after, compare)
+		if start < len(result) && compare(result[start], after) == 0 {
+			start++
+		}
+		result = result[start:]
+	}
+
+	var next *storage.SearchCursor
+	if len(result) > filter.Limit {
+		result = result[:filter.Limit]
+		last := result[len(result)-1]
+		next = &storage.SearchCursor{ID: last.data.ID}
+		switch {
+		case filter.Sort == storage.SortHistoryID:
+			next.Value = strconv.FormatInt(last.number, 10)
+		case filter.Sort != storage.SortID:
+			next.Value = last.value
+		}
+	}
+
+	data := make([]structs.SecureData, 0, len(result))
+	for _, f := range result {
+		data = append(data, f.data)
+	}
+	return data, next, nil
+}
+
+// searchable - запись d в том виде, в каком её видит пользователь userID: своя, хранилища организации
+// или открытая ему (как в синхронизации); ok=false, если запись ему недоступна
+func (s *Storage) searchable(d *secureData, userID int64) (data structs.SecureData, ok bool) {
+	if s.visible(d, userID) {
+		return d.data, true
+	}
+	key := shareKey{secureDataID: d.data.ID, recipientID: userID}
+	if sh, shared := s.shares[key]; shared && sh.revokedAt == nil {
+		return s.sharedData(key, sh), true
+	}
+	return structs.SecureData{}, false
+}
+
+func matchState(isActive bool, state string) bool {
+	switch state {
+	case storage.SearchActive:
+		return isActive
+	case storage.SearchDeleted:
+		return !isActive
+	}
+	return true
+}
+
+func matchMetadata(metadata string, contains []any) bool {
+	var v any
+	if err := json.Unmarshal([]byte(metadata), &v); err != nil {
+		return false
+	}
+	for _, c := range contains {
+		if !jsonContains(v, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonContains - a @> b по правилам jsonb: объект содержит ключи b с содержащимися значениями,
+// массив - каждый элемент b в каком-либо своём элементе, скаляры равны
+func jsonContains(a any, b any) bool {
+	switch b := b.(type) {
+	case map[string]any:
+		object, ok := a.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range b {
+			field, found := object[key]
+			if !found || !jsonContains(field, value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		array, ok := a.([]any)
+		if !ok {
+			return false
+		}
+		for _, value := range b {
+			if !slices.ContainsFunc(array, func(element any) bool { return jsonContains(element, value) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// metadataText - значение ключа верхнего уровня метаданных как jsonb ->>
+func metadataText(metadata string, key string) string {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(metadata), &object); err != nil {
+		return ""
+	}
+	raw, found := object[key]
+	if !found || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	return string(raw)
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type sessionRecord struct {
+	username    string
+	refreshHash string
+	revoked     bool
+	session     structs.Session
+}
+
+func (s *sessionRecord) active(now time.Time) bool {
+	return !s.revoked && s.session.ExpiresAt.After(now)
+}
+
+func (s *Storage) CreateSession(ctx context.Context, username string, session structs.Session, refreshHash string) error {
+	defer s.lock(ctx)()
+
+	if u, found := s.users[username]; !found || u.disabledAt != nil {
+		return storage.ErrNotFound
+	}
+	now := time.Now()
+	session.CreatedAt, session.LastSeen = now, now
+	s.sessions[session.ID] = &sessionRecord{
+		username:    username,
+		refreshHash: refreshHash,
+		session:     session,
+	}
+	return nil
+}
+
+func (s *Storage) RotateSession(ctx context.Context, id string, refreshHash string, newRefreshHash string, expiresAt time.Time, ip string, userAgent string) (string, error) {
+	defer s.lock(ctx)()
+
+	now := time.Now()
+	r, found := s.sessions[id]
+	if !found || !r.active(now) {
+		return "", storage.ErrNotFound
+	}
+	if r.refreshHash != refreshHash {
+		r.revoked = true
+		return "", storage.ErrNotFound
+	}
+
+	r.refreshHash = newRefreshHash
+	r.session.ExpiresAt = expiresAt
+	r.session.IP = ip
+	r.session.UserAgent = userAgent
+	r.session.LastSeen = now
+	return r.username, nil
+}
+
+func (s *Storage) CheckSession(ctx context.Context, id string, username string, ip string) error {
+	defer s.lock(ctx)()
+
+	now := time.Now()
+	r, found := s.sessions[id]
+	if !found || r.username != username || !r.active(now) {
+		return storage.ErrNotFound
+	}
+	r.session.LastSeen = now
+	r.session.IP = ip
+	return nil
+}
+
+func (s *Storage) SelectSessions(ctx context.Context, username string) ([]structs.Session, error) {
+	defer s.rlock(ctx)()
+
+	now := time.Now()
+	var result []structs.Session
+	for _, r := range s.sessions {
+		if r.username == username && r.active(now) {
+			result = append(result, r.session)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, username string, id string) error {
+	defer s.lock(ctx)()
+
+	r, found := s.sessions[id]
+	if !found || r.username != username || !r.active(time.Now()) {
+		return storage.ErrNotFound
+	}
+	r.revoked = true
+	return nil
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type shareKey struct {
+	secureDataID int64
+	recipientID  int64
+}
+
+type share struct {
+	permission string
+	wrappedKey string
+	// historyID - изменение, которым доступ открыт или отозван
+	historyID int64
+	createdAt time.Time
+	revokedAt *time.Time
+}
+
+func (s *Storage) ShareSecureData(ctx context.Context, id int64, username string, recipient string, permission string, wrappedKey string) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.find(id, username)
+	if err != nil {
+		return 0, err
+	}
+	r, found := s.users[recipient]
+	if !d.data.IsActive || !found || r.id == d.userID {
+		return 0, storage.ErrNotFound
+	}
+
+	key := shareKey{secureDataID: id, recipientID: r.id}
+	sh, found := s.shares[key]
+	if !found || sh.revokedAt != nil {
+		sh = &share{createdAt: time.Now()}
+		s.shares[key] = sh
+	}
+	sh.permission = permission
+	sh.wrappedKey = wrappedKey
+	sh.historyID = s.shareHistory(d, "SHARE", r.id, recipient)
+	return sh.historyID, nil
+}
+
+func (s *Storage) RevokeShare(ctx context.Context, id int64, username string, recipient string) (int64, error) {
+	defer s.lock(ctx)()
+
+	d, err := s.find(id, username)
+	if err != nil {
+		return 0, err
+	}
+	r, found := s.users[recipient]
+	if !found {
+		return 0, storage.ErrNotFound
+	}
+	sh, found := s.shares[shareKey{secureDataID: id, recipientID: r.id}]
+	if !found || sh.revokedAt != nil {
+		return 0, storage.ErrNotFound
+	}
+
+	// получатель узнаёт об отзыве из этого изменения, поэтому доступ отзывается после него
+	historyID := s.shareHistory(d, "UNSHARE", r.id, recipient)
+	now := time.Now()
+	sh.revokedAt = &now
+	sh.historyID = historyID
+	return historyID, nil
+}
+
+func (s *Storage) SelectShares(ctx context.Context, id int64, username string) ([]structs.Share, error) {
+	defer s.rlock(ctx)()
+
+	if _, err := s.find(id, username); err != nil {
+		return nil, err
+	}
+
+	var result []structs.Share
+	for key, sh := range s.shares {
+		if key.secureDataID == id {
+			result = append(result, s.shareInfo(key, sh))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (s *Storage) SelectSharedSecureData(ctx context.Context, username string) ([]structs.SecureData, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, nil
+	}
+
+	var result []structs.SecureData
+	for key, sh := range s.shares {
+		if key.recipientID != u.id {
+			continue
+		}
+		if data := s.sharedData(key, sh); data.IsActive {
+			result = append(result, data)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// shareHistory - изменение, открывающее или отзывающее доступ получателя recipientID к записи d:
+// запись истории без снимка (не ревизия), history_id записи не меняется
+func (s *Storage) shareHistory(d *secureData, method string, recipientID int64, recipient string) int64 {
+	s.lastHistoryID++
+	s.history = append(s.history, history{
+		id:           s.lastHistoryID,
+		userID:       d.userID,
+		secureDataID: d.data.ID,
+		method:       method,
+		recipient:    recipient,
+		createdAt:    time.Now(),
+	})
+	s.changes = append(s.changes, change{userID: recipientID, historyID: s.lastHistoryID})
+	return s.lastHistoryID
+}
+
+// sharedData - запись в том виде, в каком её видит получатель; после отзыва - неактивная и без содержимого.
+// Запись, изменённая до открытия доступа, передаётся с SyncID открытия
+func (s *Storage) sharedData(key shareKey, sh *share) structs.SecureData {
+	d := s.secureData[key.secureDataID]
+	data := structs.SecureData{
+		ID:        d.data.ID,
+		Metadata:  "{}",
+		HistoryID: sh.historyID,
+		Kind:      d.data.Kind,
+	}
+	if sh.revokedAt == nil {
+		data = d.data
+		data.VaultID = 0
+		if sh.historyID > data.HistoryID {
+			data.SyncID = sh.historyID
+		}
+	}
+	info := s.shareInfo(key, sh)
+	data.Share = &info
+	return data
+}
+
+func (s *Storage) shareInfo(key shareKey, sh *share) structs.Share {
+	return structs.Share{
+		SecureDataID: key.secureDataID,
+		Owner:        s.username(s.secureData[key.secureDataID].userID),
+		Recipient:    s.username(key.recipientID),
+		Permission:   sh.permission,
+		WrappedKey:   sh.wrappedKey,
+		HistoryID:    sh.historyID,
+		CreatedAt:    sh.createdAt,
+		RevokedAt:    sh.revokedAt,
+	}
+}
+
+// username - адрес пользователя id
+func (s *Storage) username(id int64) string {
+	for mail, u := range s.users {
+		if u.id == id {
+			return mail
+		}
+	}
+	return ""
+}
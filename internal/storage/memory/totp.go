@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type totp struct {
+	secret      string
+	enabled     bool
+	lastCounter int64
+	// backupCodes - хэш кода -> код ещё не использован
+	backupCodes map[string]bool
+}
+
+func (s *Storage) SetTOTPSecret(ctx context.Context, username string, secret string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return storage.ErrNotFound
+	}
+	if u.totp != nil && u.totp.enabled {
+		return storage.ErrAlreadyExists
+	}
+	u.totp = &totp{secret: secret}
+	return nil
+}
+
+func (s *Storage) SelectTOTP(ctx context.Context, username string) (structs.TOTP, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil {
+		return structs.TOTP{}, storage.ErrNotFound
+	}
+	left := 0
+	for _, unused := range u.totp.backupCodes {
+		if unused {
+			left++
+		}
+	}
+	return structs.TOTP{
+		Secret:          u.totp.secret,
+		Enabled:         u.totp.enabled,
+		LastCounter:     u.totp.lastCounter,
+		BackupCodesLeft: left,
+	}, nil
+}
+
+func (s *Storage) UseTOTPCounter(ctx context.Context, username string, counter int64) (bool, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil || counter <= u.totp.lastCounter {
+		return false, nil
+	}
+	u.totp.lastCounter = counter
+	return true, nil
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, username string, backupCodeHashes []string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil {
+		return storage.ErrNotFound
+	}
+	u.totp.enabled = true
+	u.totp.backupCodes = codeSet(backupCodeHashes)
+	return nil
+}
+
+func (s *Storage) SetBackupCodes(ctx context.Context, username string, backupCodeHashes []string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil {
+		return storage.ErrNotFound
+	}
+	u.totp.backupCodes = codeSet(backupCodeHashes)
+	return nil
+}
+
+func (s *Storage) UseBackupCode(ctx context.Context, username string, backupCodeHash string) (bool, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil || !u.totp.backupCodes[backupCodeHash] {
+		return false, nil
+	}
+	u.totp.backupCodes[backupCodeHash] = false
+	return true, nil
+}
+
+func (s *Storage) DisableTOTP(ctx context.Context, username string) error {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found || u.totp == nil {
+		return storage.ErrNotFound
+	}
+	u.totp = nil
+	return nil
+}
+
+func codeSet(hashes []string) map[string]bool {
+	set := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		set[h] = true
+	}
+	return set
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+)
+
+// txKey - признак того, что блокировка хранилища уже захвачена InTransaction
+type txKey struct{}
+
+// InTransaction - fn выполняется под блокировкой хранилища; при ошибке состояние восстанавливается из снимка
+func (s *Storage) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == s {
+		return fn(ctx)
+	}
+	s.mu.Lock()
+	defer s.unlock()
+
+	saved := s.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.restore(saved)
+		// конфликт остаётся в истории и после отмены пакета
+		var conflict *conflictError
+		if errors.As(err, &conflict) {
+			s.recordConflict(conflict)
+		}
+		return err
+	}
+	return nil
+}
+
+// lock - блокировка на запись, если она ещё не захвачена транзакцией из ctx
+func (s *Storage) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.unlock
+}
+
+// unlock - снятие блокировки на запись и рассылка изменений, сделанных под ней
+func (s *Storage) unlock() {
+	changes := s.changes
+	s.changes = nil
+	s.mu.Unlock()
+
+	for _, c := range changes {
+		s.broker.Publish(c.userID, c.historyID)
+	}
+}
+
+// rlock - блокировка на чтение, если блокировка ещё не захвачена транзакцией из ctx
+func (s *Storage) rlock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) == s {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// state - копия изменяемого состояния хранилища для отката транзакции
+type state struct {
+	users      map[string]*user
+	sessions   map[string]*sessionRecord
+	secureData map[int64]*secureData
+	history    []history
+	blobs      map[int64]*blob
+	shares     map[shareKey]*share
+	orgs       map[int64]*organization
+	vaults     map[int64]*vault
+
+	lastUserID       int64
+	lastSecureDataID int64
+	lastHistoryID    int64
+	lastBlobID       int64
+	lastOrgID        int64
+	lastVaultID      int64
+}
+
+func (s *Storage) snapshot() state {
+	saved := state{
+		users:            make(map[string]*user, len(s.users)),
+		sessions:         make(map[string]*sessionRecord, len(s.sessions)),
+		secureData:       make(map[int64]*secureData, len(s.secureData)),
+		history:          slices.Clone(s.history),
+		blobs:            make(map[int64]*blob, len(s.blobs)),
+		shares:           make(map[shareKey]*share, len(s.shares)),
+		orgs:             make(map[int64]*organization, len(s.orgs)),
+		vaults:           maps.Clone(s.vaults),
+		lastUserID:       s.lastUserID,
+		lastSecureDataID: s.lastSecureDataID,
+		lastHistoryID:    s.lastHistoryID,
+		lastBlobID:       s.lastBlobID,
+		lastOrgID:        s.lastOrgID,
+		lastVaultID:      s.lastVaultID,
+	}
+	for mail, u := range s.users {
+		copied := *u
+		if u.totp != nil {
+			t := *u.totp
+			t.backupCodes = maps.Clone(u.totp.backupCodes)
+			copied.totp = &t
+		}
+		saved.users[mail] = &copied
+	}
+	for id, r := range s.sessions {
+		copied := *r
+		saved.sessions[id] = &copied
+	}
+	for id, d := range s.secureData {
+		copied := *d
+		saved.secureData[id] = &copied
+	}
+	for id, b := range s.blobs {
+		copied := *b
+		copied.chunks = slices.Clone(b.chunks)
+		saved.blobs[id] = &copied
+	}
+	for key, sh := range s.shares {
+		copied := *sh
+		saved.shares[key] = &copied
+	}
+	for id, o := range s.orgs {
+		copied := *o
+		copied.members = make(map[int64]*member, len(o.members))
+		for userID, m := range o.members {
+			copiedMember := *m
+			copied.members[userID] = &copiedMember
+		}
+		saved.orgs[id] = &copied
+	}
+	return saved
+}
+
+func (s *Storage) restore(saved state) {
+	s.users = saved.users
+	s.usersByID = make(map[int64]*user, len(saved.users))
+	for _, u := range saved.users {
+		s.usersByID[u.id] = u
+	}
+	s.sessions = saved.sessions
+	s.secureData = saved.secureData
+	s.history = saved.history
+	s.blobs = saved.blobs
+	s.shares = saved.shares
+	s.orgs = saved.orgs
+	s.vaults = saved.vaults
+	s.lastUserID = saved.lastUserID
+	s.lastSecureDataID = saved.lastSecureDataID
+	s.lastHistoryID = saved.lastHistoryID
+	s.lastBlobID = saved.lastBlobID
+	s.lastOrgID = saved.lastOrgID
+	s.lastVaultID = saved.lastVaultID
+	s.changes = nil
+}
@@ -0,0 +1,400 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+type organization struct {
+	name      string
+	createdAt time.Time
+	members   map[int64]*member
+}
+
+type member struct {
+	role       string
+	wrappedKey string
+	// historyID - изменение, которым участник вступил в организацию или исключён из неё
+	historyID int64
+	createdAt time.Time
+	removedAt *time.Time
+}
+
+type vault struct {
+	name string
+	// userID - владелец личного хранилища; 0 для хранилищ организации
+	userID    int64
+	orgID     int64
+	createdAt time.Time
+}
+
+// access - уровень доступа к записи
+type access int
+
+const (
+	// accessRead - чтение: владелец, получатели доступа, участники организации
+	accessRead access = iota
+	// accessWrite - изменение содержимого: владелец, получатели с правом записи, участники кроме read-only
+	accessWrite
+	// accessManage - удаление, восстановление и загрузка файлов: владелец и участники кроме read-only
+	accessManage
+	// accessVault - ревизии и файлы: владелец и участники организации
+	accessVault
+)
+
+func (s *Storage) CreateOrganization(ctx context.Context, username string, name string, wrappedKey string) (structs.Organization, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return structs.Organization{}, storage.ErrNotFound
+	}
+
+	now := time.Now()
+	s.lastOrgID++
+	s.orgs[s.lastOrgID] = &organization{
+		name:      name,
+		createdAt: now,
+		members: map[int64]*member{
+			u.id: {role: structs.RoleOwner, wrappedKey: wrappedKey, createdAt: now},
+		},
+	}
+	return s.organizationInfo(s.lastOrgID, u.id), nil
+}
+
+func (s *Storage) SelectOrganizations(ctx context.Context, username string) ([]structs.Organization, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, nil
+	}
+
+	var result []structs.Organization
+	for id := range s.orgs {
+		if _, ok := s.activeMember(id, u.id); ok {
+			result = append(result, s.organizationInfo(id, u.id))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (s *Storage) SelectMembers(ctx context.Context, orgID int64, username string) ([]structs.Member, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	if _, ok := s.activeMember(orgID, u.id); !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	var result []structs.Member
+	for userID, m := range s.orgs[orgID].members {
+		if m.removedAt == nil {
+			result = append(result, structs.Member{
+				OrgID:     orgID,
+				Username:  s.username(userID),
+				Role:      m.role,
+				CreatedAt: m.createdAt,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt) ||
+			result[i].CreatedAt.Equal(result[j].CreatedAt) && result[i].Username < result[j].Username
+	})
+	return result, nil
+}
+
+func (s *Storage) SetMember(ctx context.Context, orgID int64, username string, memberName string, role string, wrappedKey string) (int64, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	target, targetFound := s.users[memberName]
+	if !found || !targetFound {
+		return 0, storage.ErrNotFound
+	}
+	actor, ok := s.activeMember(orgID, u.id)
+	if !ok {
+		return 0, storage.ErrNotFound
+	}
+	current, isMember := s.activeMember(orgID, target.id)
+	if !canManage(actor.role, role) || isMember && !canManage(actor.role, current.role) {
+		return 0, storage.ErrForbidden
+	}
+	if isMember && current.role == structs.RoleOwner && role != structs.RoleOwner && s.owners(orgID) == 1 {
+		return 0, storage.ErrForbidden
+	}
+
+	if isMember {
+		// состав записей участника не меняется, поэтому изменение роли не попадает в синхронизацию
+		current.role = role
+		current.wrappedKey = wrappedKey
+		return current.historyID, nil
+	}
+	m := &member{role: role, wrappedKey: wrappedKey, createdAt: time.Now()}
+	s.orgs[orgID].members[target.id] = m
+	m.historyID = s.memberHistory(target.id)
+	return m.historyID, nil
+}
+
+func (s *Storage) RemoveMember(ctx context.Context, orgID int64, username string, memberName string) (int64, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	target, targetFound := s.users[memberName]
+	if !found || !targetFound {
+		return 0, storage.ErrNotFound
+	}
+	actor, ok := s.activeMember(orgID, u.id)
+	if !ok {
+		return 0, storage.ErrNotFound
+	}
+	current, isMember := s.activeMember(orgID, target.id)
+	if !isMember {
+		return 0, storage.ErrNotFound
+	}
+	if target.id != u.id && !canManage(actor.role, current.role) {
+		return 0, storage.ErrForbidden
+	}
+	if current.role == structs.RoleOwner && s.owners(orgID) == 1 {
+		return 0, storage.ErrForbidden
+	}
+
+	now := time.Now()
+	current.removedAt = &now
+	current.historyID = s.memberHistory(target.id)
+	return current.historyID, nil
+}
+
+func (s *Storage) CreateVault(ctx context.Context, username string, orgID int64, name string) (structs.Vault, error) {
+	defer s.lock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return structs.Vault{}, storage.ErrNotFound
+	}
+	v := &vault{name: name, userID: u.id, orgID: orgID, createdAt: time.Now()}
+	if orgID != 0 {
+		m, ok := s.activeMember(orgID, u.id)
+		if !ok {
+			return structs.Vault{}, storage.ErrNotFound
+		}
+		if !canManage(m.role, structs.RoleMember) {
+			return structs.Vault{}, storage.ErrForbidden
+		}
+		v.userID = 0
+	}
+
+	s.lastVaultID++
+	s.vaults[s.lastVaultID] = v
+	return v.info(s.lastVaultID), nil
+}
+
+func (s *Storage) SelectVaults(ctx context.Context, username string) ([]structs.Vault, error) {
+	defer s.rlock(ctx)()
+
+	u, found := s.users[username]
+	if !found {
+		return nil, nil
+	}
+
+	var result []structs.Vault
+	for id, v := range s.vaults {
+		if _, ok := s.activeMember(v.orgID, u.id); v.userID == u.id || ok {
+			result = append(result, v.info(id))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// findAccess - запись id, доступная пользователю username на уровне level
+func (s *Storage) findAccess(id int64, username string, level access) (*secureData, error) {
+	u, found := s.users[username]
+	d, exists := s.secureData[id]
+	if !found || !exists {
+		return nil, storage.ErrNotFound
+	}
+
+	if orgID := s.orgOf(d); orgID != 0 {
+		m, ok := s.activeMember(orgID, u.id)
+		if ok && (level == accessRead || level == accessVault || m.role != structs.RoleReadOnly) {
+			return d, nil
+		}
+		return nil, storage.ErrNotFound
+	}
+	if d.userID == u.id {
+		return d, nil
+	}
+	if level == accessRead || level == accessWrite {
+		sh, shared := s.shares[shareKey{secureDataID: id, recipientID: u.id}]
+		if shared && sh.revokedAt == nil && (level == accessRead || sh.permission == structs.PermissionWrite) {
+			return d, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// checkVault - возможность добавить запись пользователя userID в хранилище vaultID
+func (s *Storage) checkVault(vaultID int64, userID int64) error {
+	if vaultID == 0 {
+		return nil
+	}
+	v, found := s.vaults[vaultID]
+	if !found {
+		return storage.ErrNotFound
+	}
+	if v.orgID == 0 {
+		if v.userID != userID {
+			return storage.ErrNotFound
+		}
+		return nil
+	}
+	m, ok := s.activeMember(v.orgID, userID)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if m.role == structs.RoleReadOnly {
+		return storage.ErrForbidden
+	}
+	return nil
+}
+
+// visible - запись d доступна пользователю userID как владельцу или участнику организации
+func (s *Storage) visible(d *secureData, userID int64) bool {
+	if orgID := s.orgOf(d); orgID != 0 {
+		_, ok := s.activeMember(orgID, userID)
+		return ok
+	}
+	return d.userID == userID
+}
+
+// orgData - записи хранилищ организаций, в которые пользователь userID входит или входил, в том
+// виде, в каком он их видит: после исключения - неактивные и без содержимого
+func (s *Storage) orgData(userID int64) []structs.SecureData {
+	var result []structs.SecureData
+	for _, d := range s.secureData {
+		orgID := s.orgOf(d)
+		if orgID == 0 {
+			continue
+		}
+		m, found := s.orgs[orgID].members[userID]
+		if !found {
+			continue
+		}
+		data := d.data
+		if m.removedAt != nil {
+			data = structs.SecureData{
+				ID:        d.data.ID,
+				Metadata:  "{}",
+				HistoryID: m.historyID,
+				Kind:      d.data.Kind,
+				VaultID:   d.data.VaultID,
+			}
+		} else if m.historyID > data.HistoryID {
+			data.SyncID = m.historyID
+		}
+		result = append(result, data)
+	}
+	return result
+}
+
+// orgOf - организация хранилища записи; 0 для личных хранилищ
+func (s *Storage) orgOf(d *secureData) int64 {
+	if v, found := s.vaults[d.data.VaultID]; found {
+		return v.orgID
+	}
+	return 0
+}
+
+// activeMember - участник организации orgID, не исключённый из неё
+func (s *Storage) activeMember(orgID int64, userID int64) (*member, bool) {
+	o, found := s.orgs[orgID]
+	if !found {
+		return nil, false
+	}
+	m, found := o.members[userID]
+	if !found || m.removedAt != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// members - активные участники организации записи d
+func (s *Storage) members(d *secureData) []int64 {
+	orgID := s.orgOf(d)
+	if orgID == 0 {
+		return nil
+	}
+	var result []int64
+	for userID, m := range s.orgs[orgID].members {
+		if m.removedAt == nil {
+			result = append(result, userID)
+		}
+	}
+	return result
+}
+
+// owners - число владельцев организации
+func (s *Storage) owners(orgID int64) int {
+	count := 0
+	for _, m := range s.orgs[orgID].members {
+		if m.removedAt == nil && m.role == structs.RoleOwner {
+			count++
+		}
+	}
+	return count
+}
+
+// memberHistory - номер изменения, которым записи организации становятся доступны участнику userID или
+// перестают быть доступны ему
+func (s *Storage) memberHistory(userID int64) int64 {
+	s.lastHistoryID++
+	s.changes = append(s.changes, change{userID: userID, historyID: s.lastHistoryID})
+	return s.lastHistoryID
+}
+
+func (s *Storage) organizationInfo(id int64, userID int64) structs.Organization {
+	o := s.orgs[id]
+	m := o.members[userID]
+	return structs.Organization{
+		ID:         id,
+		Name:       o.name,
+		Role:       m.role,
+		WrappedKey: m.wrappedKey,
+		CreatedAt:  o.createdAt,
+	}
+}
+
+func (v *vault) info(id int64) structs.Vault {
+	return structs.Vault{
+		ID:        id,
+		Name:      v.name,
+		OrgID:     v.orgID,
+		CreatedAt: v.createdAt,
+	}
+}
+
+// canManage - роль actor позволяет назначать роль role и изменять участников с ней:
+// владельцы - любых, администраторы - всех, кроме владельцев
+func canManage(actor string, role string) bool {
+	switch actor {
+	case structs.RoleOwner:
+		return true
+	case structs.RoleAdmin:
+		return role != structs.RoleOwner
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Quotas - ограничения записей пользователя (structs.Quota). AddSecureData, UpdateSecureData,
+// RestoreSecureData и CreateBlob проверяют квоты автора записи в своей транзакции и возвращают *QuotaError;
+// квота ревизий ограничивает число ревизий, остающихся после удаления старых
+type Quotas interface {
+	// SelectUsage - объём записей пользователя и действующие квоты: заданные администратором
+	// или значения по умолчанию (Options.Quota)
+	SelectUsage(ctx context.Context, username string) (structs.Usage, error)
+	// SetUserQuota - квоты пользователя вместо значений по умолчанию; nil - возврат к значениям по умолчанию
+	SetUserQuota(ctx context.Context, username string, quota *structs.Quota) error
+}
+
+// QuotaError - изменение превысило бы квоту Name (structs.QuotaRecords, QuotaBytes или QuotaRecordSize)
+type QuotaError struct {
+	Name  string
+	Limit int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded (limit %d)", e.Name, e.Limit)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// CheckQuota - проверка изменения, после которого запись занимает size байт (RecordSize), а объём
+// записей автора растёт на grow; usage - объём записей автора до изменения. Изменения, не увеличивающие
+// число записей или их объём, разрешены и сверх квоты (например, после её снижения), кроме превышения
+// размера записи
+func CheckQuota(quota structs.Quota, usage structs.Usage, added bool, size int64, grow int64) error {
+	switch {
+	case quota.RecordSize > 0 && size > quota.RecordSize:
+		return &QuotaError{Name: structs.QuotaRecordSize, Limit: quota.RecordSize}
+	case added && quota.Records > 0 && usage.Records >= quota.Records:
+		return &QuotaError{Name: structs.QuotaRecords, Limit: quota.Records}
+	case quota.Bytes > 0 && grow > 0 && usage.Bytes+grow > quota.Bytes:
+		return &QuotaError{Name: structs.QuotaBytes, Limit: quota.Bytes}
+	}
+	return nil
+}
+
+// RecordSize - размер записи для квоты размера записи: данные, метаданные и file - размер её текущего файла.
+// В объёме записей (structs.Usage.Bytes) учитываются также файлы, на которые ссылаются хранимые ревизии,
+// и незавершённые загрузки, срок которых (Options.UploadTTL) не истёк
+func RecordSize(data string, metadata string, file int64) int64 {
+	return int64(len(data)+len(metadata)) + file
+}
+
+// Retention - число хранимых ревизий записи: значение пользователя limit (0 - квота ревизий),
+// не больше квоты ревизий; 0 - без ограничения
+func Retention(limit int, quota structs.Quota) int {
+	if limit == 0 || (quota.Revisions > 0 && limit > quota.Revisions) {
+		return quota.Revisions
+	}
+	return limit
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Состояния записей для SearchFilter.State
+const (
+	SearchActive  = "active"
+	SearchDeleted = "deleted"
+	SearchAll     = "all"
+)
+
+// Поля сортировки для SearchFilter.Sort
+const (
+	// SortID - порядок создания записей
+	SortID = "id"
+	// SortHistoryID - порядок последнего изменения записей
+	SortHistoryID = "historyID"
+	// SortMetadataPrefix - "metadata.<key>": текстовое значение ключа верхнего уровня метаданных
+	// (отсутствующий ключ - пустая строка), строки сравниваются побайтно
+	SortMetadataPrefix = "metadata."
+)
+
+// SearchFilter - фильтры, сортировка и страница поиска записей.
+// Записи упорядочены по полю сортировки, при равенстве - по ID; следующая страница
+// начинается после курсора, возвращённого с предыдущей
+type SearchFilter struct {
+	// Metadata - JSON, который должны содержать метаданные записи (jsonb @>)
+	Metadata string
+	// Tags - метки, которые все должны быть в массиве "tags" метаданных
+	Tags  []string
+	Kind  string
+	State string
+	Sort  string
+	Desc  bool
+	// After - последняя запись предыдущей страницы; nil - первая страница
+	After *SearchCursor
+	Limit int
+}
+
+// SearchCursor - позиция записи в порядке сортировки
+type SearchCursor struct {
+	// Value - значение поля сортировки записи (для SortID не используется)
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// Validate - проверка фильтра перед поиском
+func (f *SearchFilter) Validate() error {
+	if f.Metadata != "" {
+		var object map[string]any
+		if err := json.Unmarshal([]byte(f.Metadata), &object); err != nil {
+			return fmt.Errorf("metadata filter must be a JSON object: %w", err)
+		}
+	}
+	switch f.State {
+	case SearchActive, SearchDeleted, SearchAll:
+	default:
+		return fmt.Errorf("unknown state %q", f.State)
+	}
+	switch {
+	case f.Sort == SortID || f.Sort == SortHistoryID:
+	case strings.HasPrefix(f.Sort, SortMetadataPrefix) && len(f.Sort) > len(SortMetadataPrefix):
+	default:
+		return fmt.Errorf("unknown sort %q", f.Sort)
+	}
+	if f.After != nil && f.Sort == SortHistoryID {
+		if _, err := strconv.ParseInt(f.After.Value, 10, 64); err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	if f.Limit < 1 {
+		return fmt.Errorf("limit must be positive")
+	}
+	return nil
+}
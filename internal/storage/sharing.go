@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Sharing - доступ других пользователей к отдельным записям. Открытие и отзыв доступа записываются
+// в историю записи (SHARE, UNSHARE) без снимка: это не ревизии, history_id записи не меняется.
+// Общая запись попадает в SelectUpdatedSecureData получателя с тем же ID и history_id, что у владельца,
+// и с SyncID открытия доступа, если он больше; после отзыва - неактивной и без содержимого.
+// Получатель с правом записи может изменять содержимое записи (UpdateSecureData), но не удалять её
+type Sharing interface {
+	// ShareSecureData - открытие или изменение доступа recipient к активной записи личного хранилища
+	// пользователя username (записи организаций доступны через участие, см. Vaults).
+	// ErrNotFound, если записи нет или получатель не зарегистрирован
+	ShareSecureData(ctx context.Context, id int64, username string, recipient string, permission string, wrappedKey string) (historyID int64, err error)
+	// RevokeShare - отзыв доступа; ErrNotFound, если доступ не открыт
+	RevokeShare(ctx context.Context, id int64, username string, recipient string) (historyID int64, err error)
+	// SelectShares - открытые и отозванные доступы к записи владельца
+	SelectShares(ctx context.Context, id int64, username string) ([]structs.Share, error)
+	// SelectSharedSecureData - активные записи, доступные пользователю, в порядке ID
+	SelectSharedSecureData(ctx context.Context, username string) ([]structs.SecureData, error)
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+var (
+	// ErrNotFound - запись не найдена или принадлежит другому пользователю
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists - нарушение уникальности (например, повторная регистрация)
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrPending - адрес пользователя ещё не подтверждён
+	ErrPending = errors.New("account is not verified")
+	// ErrDisabled - учётная запись заблокирована администратором
+	ErrDisabled = errors.New("account is disabled")
+	// ErrBlobOffset - смещение части не совпадает с уже принятым объёмом (загрузка должна продолжиться с received)
+	ErrBlobOffset = errors.New("blob offset mismatch")
+	// ErrConflict - запись изменена после версии, известной клиенту (см. ConflictError)
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden - роли пользователя в организации недостаточно для операции
+	ErrForbidden = errors.New("forbidden")
+	// ErrQuotaExceeded - изменение превысило бы квоту пользователя (см. QuotaError)
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// ConflictError - ожидаемый history_id не совпал с текущим; Current - актуальное состояние записи
+type ConflictError struct {
+	Current structs.SecureData
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("record %d was changed (current historyID %d)", e.Current.ID, e.Current.HistoryID)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Storage - хранилище пользователей, записей, истории изменений и бинарного содержимого.
+// Все методы, изменяющие записи, атомарно добавляют запись в историю и возвращают её ID.
+type Storage interface {
+	Users
+	Transactor
+	Sessions
+	TOTP
+	Idempotency
+	Keys
+	SecureData
+	History
+	Blobs
+	Changes
+	Sharing
+	Vaults
+	Admin
+	Quotas
+	Audit
+}
+
+// Options - настройки, общие для реализаций хранилища
+type Options struct {
+	// HistoryRetention - число хранимых ревизий записи, если пользователь не задал своё; 0 - без ограничения
+	HistoryRetention int
+	// Quota - квоты пользователей по умолчанию; квота ревизий по умолчанию - HistoryRetention
+	Quota structs.Quota
+	// UploadTTL - срок незавершённой загрузки файла: после него загрузку нельзя продолжить и она
+	// не учитывается в квотах; 0 - без ограничения
+	UploadTTL time.Duration
+}
+
+// Transactor - атомарное выполнение нескольких операций хранилища
+type Transactor interface {
+	// InTransaction - операции с ctx из fn применяются вместе; при ошибке fn все они отменяются.
+	// Конфликт версий (*ConflictError) тоже отменяет транзакцию, но записывается в историю после её отмены
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Users - пользователи
+type Users interface {
+	// RegisterUser - новый пользователь; с verification он остаётся неподтверждённым до VerifyUser.
+	// Повторная регистрация неподтверждённого адреса заменяет код подтверждения
+	RegisterUser(ctx context.Context, mail string, verification *structs.Verification) error
+	// VerifyUser - подтверждение адреса; ErrNotFound, если код неверный, истёк или адрес уже подтверждён
+	VerifyUser(ctx context.Context, mail string, codeHash string) error
+	// CheckUser - ErrNotFound, если пользователь не зарегистрирован, ErrPending, если адрес не подтверждён,
+	// ErrDisabled, если учётная запись заблокирована
+	CheckUser(ctx context.Context, mail string) error
+	// DeleteExpiredUsers - удаление регистраций, не подтверждённых вовремя
+	DeleteExpiredUsers(ctx context.Context) (int64, error)
+}
+
+// Sessions - сессии пользователей; хранится только хэш refresh токена.
+// Отозванные и истёкшие сессии для всех методов считаются отсутствующими (ErrNotFound).
+type Sessions interface {
+	// CreateSession - ErrNotFound, если пользователь не зарегистрирован или заблокирован
+	CreateSession(ctx context.Context, username string, session structs.Session, refreshHash string) error
+	// RotateSession - замена refresh токена и продление сессии. Если хэш не совпал (повторно предъявлен
+	// уже заменённый токен), сессия отзывается и возвращается ErrNotFound.
+	RotateSession(ctx context.Context, id string, refreshHash string, newRefreshHash string, expiresAt time.Time, ip string, userAgent string) (username string, err error)
+	// CheckSession - проверка, что сессия пользователя активна; обновляет время и адрес последнего обращения
+	CheckSession(ctx context.Context, id string, username string, ip string) error
+	// SelectSessions - активные сессии пользователя, новые первыми
+	SelectSessions(ctx context.Context, username string) ([]structs.Session, error)
+	RevokeSession(ctx context.Context, username string, id string) error
+}
+
+// TOTP - второй фактор и резервные коды (хранятся только хэши кодов)
+type TOTP interface {
+	// SetTOTPSecret - новый секрет, ожидающий подтверждения; подключённый второй фактор не заменяется (ErrAlreadyExists)
+	SetTOTPSecret(ctx context.Context, username string, secret string) error
+	// SelectTOTP - ErrNotFound, если пользователь не начинал подключение
+	SelectTOTP(ctx context.Context, username string) (structs.TOTP, error)
+	// UseTOTPCounter - отметка использованного шага; false, если шаг не новее последнего использованного
+	UseTOTPCounter(ctx context.Context, username string, counter int64) (bool, error)
+	// EnableTOTP - подтверждение подключения с новым набором резервных кодов
+	EnableTOTP(ctx context.Context, username string, backupCodeHashes []string) error
+	// SetBackupCodes - замена резервных кодов
+	SetBackupCodes(ctx context.Context, username string, backupCodeHashes []string) error
+	// UseBackupCode - одноразовое использование резервного кода; false, если код неизвестен или уже использован
+	UseBackupCode(ctx context.Context, username string, backupCodeHash string) (bool, error)
+	DisableTOTP(ctx context.Context, username string) error
+}
+
+// Idempotency - ответы на повторяемые запросы с заголовком Idempotency-Key
+type Idempotency interface {
+	// ReserveIdempotencyKey - захват ключа перед выполнением запроса (reserved=true).
+	// Если ключ уже использован, возвращается сохранённый запрос и reserved=false;
+	// истёкшие ключи и захваты, брошенные до staleBefore без ответа, занимаются заново
+	ReserveIdempotencyKey(ctx context.Context, username string, key string, requestHash string, expiresAt time.Time, staleBefore time.Time) (request structs.IdempotentRequest, reserved bool, err error)
+	// CompleteIdempotencyKey - сохранение ответа на запрос
+	CompleteIdempotencyKey(ctx context.Context, username string, key string, status int, body []byte) error
+	// DeleteIdempotencyKey - освобождение ключа, если запрос не выполнен и его можно повторить
+	DeleteIdempotencyKey(ctx context.Context, username string, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// Keys - параметры KDF и обёрнутый ключ хранилища пользователя
+type Keys interface {
+	// SetUserKeys - сохранение ключей вместо версии keys.Version; новая версия или ErrConflict,
+	// если сохранённые ключи уже заменены
+	SetUserKeys(ctx context.Context, username string, keys structs.UserKeys) (version int64, err error)
+	SelectUserKeys(ctx context.Context, username string) (structs.UserKeys, error)
+}
+
+// SecureData - записи пользователя и синхронизация
+type SecureData interface {
+	// AddSecureData - новая запись в хранилище vaultID (0 - основное личное хранилище, см. Vaults)
+	AddSecureData(ctx context.Context, username string, vaultID int64, kind string, data string, metadata string) (secureDataID int64, historyID int64, err error)
+	// UpdateSecureData и DeleteSecureData при expectedHistoryID != 0 проверяют, что запись не менялась
+	// после этой версии; иначе конфликт записывается в историю и возвращается *ConflictError
+	UpdateSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, kind string, data string, metadata string) (historyID int64, err error)
+	DeleteSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64) (historyID int64, err error)
+	// SelectSecureDataKind - тип записи пользователя или записи, доступной ему (см. Sharing и Vaults)
+	SelectSecureDataKind(ctx context.Context, id int64, username string) (string, error)
+	// SelectUpdatedSecureData - записи (в том числе доступные пользователю) с history_id > lastID в порядке history_id
+	SelectUpdatedSecureData(ctx context.Context, lastID int64, username string, limit int) ([]structs.SecureData, error)
+	// SearchSecureData - страница записей по фильтру (см. SearchFilter) среди своих, записей хранилищ организаций
+	// и открытых пользователю (в том виде, в каком их видит получатель); next - nil на последней странице
+	SearchSecureData(ctx context.Context, username string, filter SearchFilter) (data []structs.SecureData, next *SearchCursor, err error)
+}
+
+// History - ревизии записей: снимок данных после каждого ADD, UPDATE, DELETE, RESTORE и загрузки содержимого.
+// Старые ревизии сверх лимита пользователя удаляются при записи новой; текущая ревизия не удаляется.
+type History interface {
+	// SelectRevisions - ревизии записи от новых к старым
+	SelectRevisions(ctx context.Context, id int64, username string) ([]structs.Revision, error)
+	SelectRevision(ctx context.Context, id int64, username string, historyID int64) (structs.Revision, error)
+	// RestoreSecureData - новая ревизия с содержимым ревизии revisionID; удалённая запись становится активной.
+	// expectedHistoryID - как в UpdateSecureData
+	RestoreSecureData(ctx context.Context, id int64, username string, expectedHistoryID int64, revisionID int64) (historyID int64, err error)
+	// SetHistoryRetention - число хранимых ревизий каждой записи пользователя; 0 - значение по умолчанию
+	SetHistoryRetention(ctx context.Context, username string, limit int) error
+	SelectHistoryRetention(ctx context.Context, username string) (int, error)
+}
+
+// Blobs - бинарное содержимое записей, загружаемое частями
+type Blobs interface {
+	CreateBlob(ctx context.Context, username string, secureDataID int64, size int64, sha256 string) (int64, error)
+	SelectBlob(ctx context.Context, id int64, username string) (structs.Blob, error)
+	// SelectBlob доступен всем, кто видит файлы записи (включая read-only участников организации),
+	// получателям доступа к записи - только для её текущего файла;
+	// AppendBlobChunk, ResetBlob и CompleteBlob - только тем, кто может загружать файлы (ErrNotFound иначе)
+
+	// AppendBlobChunk - сохранение части по смещению offset; возвращает новый объём принятых данных
+	AppendBlobChunk(ctx context.Context, id int64, username string, offset int64, data []byte) (int64, error)
+	// ResetBlob - удаление принятых частей, загрузка начинается с нуля
+	ResetBlob(ctx context.Context, id int64, username string) error
+	// ReadBlobChunks - последовательное чтение содержимого начиная со смещения offset
+	ReadBlobChunks(ctx context.Context, id int64, offset int64, fn func(data []byte) error) error
+	// CompleteBlob - содержимое становится текущим для записи и попадает в историю
+	CompleteBlob(ctx context.Context, id int64, username string) (historyID int64, err error)
+}
@@ -0,0 +1,15 @@
+package storagetest
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// jsonEqual - сравнение JSON без учёта форматирования (jsonb в Postgres нормализует пробелы)
+func jsonEqual(a string, b string) bool {
+	var x, y any
+	if json.Unmarshal([]byte(a), &x) != nil || json.Unmarshal([]byte(b), &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
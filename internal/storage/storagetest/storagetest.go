@@ -0,0 +1,1677 @@
+// Package storagetest - общий набор проверок для реализаций storage.Storage.
+//
+// Реализация проверяется вызовом Run из её теста:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage { return memory.New(storage.Options{}) })
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stepanov-ds/GophKeeper/internal/storage"
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+	"github.com/stepanov-ds/GophKeeper/internal/vault"
+)
+
+// Factory - новое пустое хранилище для каждого подтеста.
+// History и Quotas ожидают storage.Options{} (без ограничений по умолчанию).
+type Factory func(t *testing.T) storage.Storage
+
+// Run - запуск всех проверок
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"Users", testUsers},
+		{"Verification", testVerification},
+		{"Sessions", testSessions},
+		{"TOTP", testTOTP},
+		{"Idempotency", testIdempotency},
+		{"Keys", testKeys},
+		{"SecureData", testSecureData},
+		{"Ownership", testOwnership},
+		{"Conflicts", testConflicts},
+		{"Transactions", testTransactions},
+		{"History", testHistory},
+		{"Sync", testSync},
+		{"Blobs", testBlobs},
+		{"Changes", testChanges},
+		{"Search", testSearch},
+		{"Sharing", testSharing},
+		{"Vaults", testVaults},
+		{"Admin", testAdmin},
+		{"Quotas", testQuotas},
+		{"Audit", testAudit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func register(t *testing.T, s storage.Storage, mail string) {
+	t.Helper()
+	if err := s.RegisterUser(context.Background(), mail, nil); err != nil {
+		t.Fatalf("RegisterUser(%q): %v", mail, err)
+	}
+}
+
+func expectErr(t *testing.T, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	expectErr(t, s.CheckUser(ctx, "alice@example.com"), storage.ErrNotFound)
+	register(t, s, "alice@example.com")
+	if err := s.CheckUser(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("CheckUser: %v", err)
+	}
+	expectErr(t, s.RegisterUser(ctx, "alice@example.com", nil), storage.ErrAlreadyExists)
+	expectErr(t, s.RegisterUser(ctx, "alice@example.com", &structs.Verification{CodeHash: "h", ExpiresAt: time.Now().Add(time.Hour)}), storage.ErrAlreadyExists)
+	expectErr(t, s.VerifyUser(ctx, "alice@example.com", "h"), storage.ErrNotFound)
+}
+
+func testVerification(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	pending := &structs.Verification{CodeHash: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.RegisterUser(ctx, "bob@example.com", pending); err != nil {
+		t.Fatalf("RegisterUser pending: %v", err)
+	}
+	expectErr(t, s.CheckUser(ctx, "bob@example.com"), storage.ErrPending)
+	expectErr(t, s.RegisterUser(ctx, "bob@example.com", nil), storage.ErrAlreadyExists)
+
+	// повторная регистрация заменяет код
+	if err := s.RegisterUser(ctx, "bob@example.com", &structs.Verification{CodeHash: "second", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("RegisterUser again: %v", err)
+	}
+	expectErr(t, s.VerifyUser(ctx, "bob@example.com", "first"), storage.ErrNotFound)
+	expectErr(t, s.VerifyUser(ctx, "nobody@example.com", "second"), storage.ErrNotFound)
+	if err := s.VerifyUser(ctx, "bob@example.com", "second"); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+	if err := s.CheckUser(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("CheckUser after verify: %v", err)
+	}
+	expectErr(t, s.VerifyUser(ctx, "bob@example.com", "second"), storage.ErrNotFound)
+
+	expired := &structs.Verification{CodeHash: "old", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := s.RegisterUser(ctx, "carol@example.com", expired); err != nil {
+		t.Fatalf("RegisterUser expired: %v", err)
+	}
+	expectErr(t, s.VerifyUser(ctx, "carol@example.com", "old"), storage.ErrNotFound)
+
+	deleted, err := s.DeleteExpiredUsers(ctx)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredUsers = %d, %v", deleted, err)
+	}
+	expectErr(t, s.CheckUser(ctx, "carol@example.com"), storage.ErrNotFound)
+	if err := s.CheckUser(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("CheckUser after cleanup: %v", err)
+	}
+	// адрес удалённой регистрации снова свободен
+	register(t, s, "carol@example.com")
+}
+
+func testSessions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+
+	session := structs.Session{
+		ID:        "0123456789abcdef0123456789abcdef",
+		UserAgent: "laptop",
+		IP:        "192.0.2.1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expectErr(t, s.CreateSession(ctx, "nobody@example.com", session, "hash1"), storage.ErrNotFound)
+	if err := s.CreateSession(ctx, "alice@example.com", session, "hash1"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	expired := structs.Session{ID: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := s.CreateSession(ctx, "alice@example.com", expired, "hash"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := s.CheckSession(ctx, session.ID, "alice@example.com", "192.0.2.2"); err != nil {
+		t.Fatalf("CheckSession: %v", err)
+	}
+	expectErr(t, s.CheckSession(ctx, session.ID, "bob@example.com", ""), storage.ErrNotFound)
+	expectErr(t, s.CheckSession(ctx, expired.ID, "alice@example.com", ""), storage.ErrNotFound)
+
+	sessions, err := s.SelectSessions(ctx, "alice@example.com")
+	if err != nil || len(sessions) != 1 || sessions[0].ID != session.ID || sessions[0].IP != "192.0.2.2" ||
+		sessions[0].UserAgent != "laptop" || sessions[0].LastSeen.IsZero() {
+		t.Fatalf("SelectSessions = %+v, %v", sessions, err)
+	}
+
+	username, err := s.RotateSession(ctx, session.ID, "hash1", "hash2", time.Now().Add(2*time.Hour), "192.0.2.3", "phone")
+	if err != nil || username != "alice@example.com" {
+		t.Fatalf("RotateSession = %q, %v", username, err)
+	}
+	_, err = s.RotateSession(ctx, expired.ID, "hash", "hash2", time.Now().Add(time.Hour), "", "")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// повторное предъявление заменённого токена отзывает сессию
+	_, err = s.RotateSession(ctx, session.ID, "hash1", "hash3", time.Now().Add(time.Hour), "", "")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.RotateSession(ctx, session.ID, "hash2", "hash3", time.Now().Add(time.Hour), "", "")
+	expectErr(t, err, storage.ErrNotFound)
+	expectErr(t, s.CheckSession(ctx, session.ID, "alice@example.com", ""), storage.ErrNotFound)
+
+	second := structs.Session{ID: "second", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateSession(ctx, "alice@example.com", second, "hash"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	expectErr(t, s.RevokeSession(ctx, "bob@example.com", second.ID), storage.ErrNotFound)
+	if err := s.RevokeSession(ctx, "alice@example.com", second.ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	expectErr(t, s.RevokeSession(ctx, "alice@example.com", second.ID), storage.ErrNotFound)
+	expectErr(t, s.CheckSession(ctx, second.ID, "alice@example.com", ""), storage.ErrNotFound)
+
+	sessions, err = s.SelectSessions(ctx, "alice@example.com")
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("SelectSessions after revoke = %+v, %v", sessions, err)
+	}
+}
+
+func testTOTP(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	_, err := s.SelectTOTP(ctx, "alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	expectErr(t, s.SetTOTPSecret(ctx, "nobody@example.com", "SECRET"), storage.ErrNotFound)
+
+	if err := s.SetTOTPSecret(ctx, "alice@example.com", "FIRST"); err != nil {
+		t.Fatalf("SetTOTPSecret: %v", err)
+	}
+	// до подтверждения секрет можно заменить
+	if err := s.SetTOTPSecret(ctx, "alice@example.com", "SECOND"); err != nil {
+		t.Fatalf("SetTOTPSecret again: %v", err)
+	}
+	totp, err := s.SelectTOTP(ctx, "alice@example.com")
+	if err != nil || totp.Secret != "SECOND" || totp.Enabled {
+		t.Fatalf("SelectTOTP = %+v, %v", totp, err)
+	}
+
+	if used, err := s.UseTOTPCounter(ctx, "alice@example.com", 100); err != nil || !used {
+		t.Fatalf("UseTOTPCounter = %v, %v", used, err)
+	}
+	if used, err := s.UseTOTPCounter(ctx, "alice@example.com", 100); err != nil || used {
+		t.Fatalf("UseTOTPCounter replay = %v, %v", used, err)
+	}
+	if used, err := s.UseTOTPCounter(ctx, "alice@example.com", 99); err != nil || used {
+		t.Fatalf("UseTOTPCounter older = %v, %v", used, err)
+	}
+
+	if err := s.EnableTOTP(ctx, "alice@example.com", []string{"h1", "h2"}); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	expectErr(t, s.SetTOTPSecret(ctx, "alice@example.com", "THIRD"), storage.ErrAlreadyExists)
+	totp, err = s.SelectTOTP(ctx, "alice@example.com")
+	if err != nil || totp.Secret != "SECOND" || !totp.Enabled || totp.LastCounter != 100 || totp.BackupCodesLeft != 2 {
+		t.Fatalf("SelectTOTP after enable = %+v, %v", totp, err)
+	}
+
+	if used, err := s.UseBackupCode(ctx, "alice@example.com", "h1"); err != nil || !used {
+		t.Fatalf("UseBackupCode = %v, %v", used, err)
+	}
+	if used, err := s.UseBackupCode(ctx, "alice@example.com", "h1"); err != nil || used {
+		t.Fatalf("UseBackupCode again = %v, %v", used, err)
+	}
+	if used, err := s.UseBackupCode(ctx, "alice@example.com", "unknown"); err != nil || used {
+		t.Fatalf("UseBackupCode unknown = %v, %v", used, err)
+	}
+
+	if err := s.SetBackupCodes(ctx, "alice@example.com", []string{"h3", "h4", "h5"}); err != nil {
+		t.Fatalf("SetBackupCodes: %v", err)
+	}
+	if used, err := s.UseBackupCode(ctx, "alice@example.com", "h2"); err != nil || used {
+		t.Fatalf("UseBackupCode replaced = %v, %v", used, err)
+	}
+	totp, err = s.SelectTOTP(ctx, "alice@example.com")
+	if err != nil || totp.BackupCodesLeft != 3 {
+		t.Fatalf("SelectTOTP after SetBackupCodes = %+v, %v", totp, err)
+	}
+
+	if err := s.DisableTOTP(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("DisableTOTP: %v", err)
+	}
+	_, err = s.SelectTOTP(ctx, "alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	if used, err := s.UseBackupCode(ctx, "alice@example.com", "h3"); err != nil || used {
+		t.Fatalf("UseBackupCode after disable = %v, %v", used, err)
+	}
+	expectErr(t, s.DisableTOTP(ctx, "alice@example.com"), storage.ErrNotFound)
+}
+
+func testIdempotency(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+	expires := time.Now().Add(time.Hour)
+	stale := time.Now().Add(-time.Minute)
+
+	_, _, err := s.ReserveIdempotencyKey(ctx, "nobody@example.com", "k1", "hash", expires, stale)
+	expectErr(t, err, storage.ErrNotFound)
+
+	_, reserved, err := s.ReserveIdempotencyKey(ctx, "alice@example.com", "k1", "hash1", expires, stale)
+	if err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey = %v, %v", reserved, err)
+	}
+	// пока ответа нет, повтор видит незавершённый запрос
+	request, reserved, err := s.ReserveIdempotencyKey(ctx, "alice@example.com", "k1", "hash1", expires, stale)
+	if err != nil || reserved || request.RequestHash != "hash1" || request.Status != 0 {
+		t.Fatalf("ReserveIdempotencyKey in progress = %+v, %v, %v", request, reserved, err)
+	}
+	// ключи разных пользователей независимы
+	if _, reserved, err := s.ReserveIdempotencyKey(ctx, "bob@example.com", "k1", "other", expires, stale); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey bob = %v, %v", reserved, err)
+	}
+
+	if err := s.CompleteIdempotencyKey(ctx, "alice@example.com", "k1", 200, []byte(`{"message":"ok"}`)); err != nil {
+		t.Fatalf("CompleteIdempotencyKey: %v", err)
+	}
+	expectErr(t, s.CompleteIdempotencyKey(ctx, "alice@example.com", "missing", 200, nil), storage.ErrNotFound)
+	request, reserved, err = s.ReserveIdempotencyKey(ctx, "alice@example.com", "k1", "hash2", expires, stale)
+	if err != nil || reserved || request.RequestHash != "hash1" || request.Status != 200 || string(request.Body) != `{"message":"ok"}` {
+		t.Fatalf("ReserveIdempotencyKey completed = %+v, %v, %v", request, reserved, err)
+	}
+
+	// брошенный захват занимается заново
+	if _, reserved, err := s.ReserveIdempotencyKey(ctx, "bob@example.com", "k1", "again", expires, time.Now().Add(time.Minute)); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey stale = %v, %v", reserved, err)
+	}
+
+	if err := s.DeleteIdempotencyKey(ctx, "alice@example.com", "k1"); err != nil {
+		t.Fatalf("DeleteIdempotencyKey: %v", err)
+	}
+	if _, reserved, err := s.ReserveIdempotencyKey(ctx, "alice@example.com", "k1", "hash3", time.Now().Add(-time.Second), stale); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey after delete = %v, %v", reserved, err)
+	}
+	// истёкший ключ занимается заново и удаляется очисткой
+	if _, reserved, err := s.ReserveIdempotencyKey(ctx, "alice@example.com", "k1", "hash4", expires, stale); err != nil || !reserved {
+		t.Fatalf("ReserveIdempotencyKey expired = %v, %v", reserved, err)
+	}
+	if _, _, err := s.ReserveIdempotencyKey(ctx, "alice@example.com", "k2", "hash", time.Now().Add(-time.Second), stale); err != nil {
+		t.Fatalf("ReserveIdempotencyKey: %v", err)
+	}
+	deleted, err := s.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredIdempotencyKeys = %d, %v", deleted, err)
+	}
+}
+
+func testKeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	_, err := s.SelectUserKeys(ctx, "alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SetUserKeys(ctx, "nobody@example.com", structs.UserKeys{})
+	expectErr(t, err, storage.ErrNotFound)
+
+	for i, wrapped := range []string{"v1.first", "v1.second"} {
+		keys := structs.UserKeys{
+			KDF: vault.KDFParams{
+				Algorithm: vault.AlgorithmArgon2id,
+				Salt:      []byte("0123456789abcdef"),
+				Time:      3,
+				Memory:    64 * 1024,
+				Threads:   4,
+			},
+			WrappedKey: wrapped,
+			Version:    int64(i),
+		}
+		if version, err := s.SetUserKeys(ctx, "alice@example.com", keys); err != nil || version != int64(i+1) {
+			t.Fatalf("SetUserKeys: %d, %v", version, err)
+		}
+		got, err := s.SelectUserKeys(ctx, "alice@example.com")
+		if err != nil {
+			t.Fatalf("SelectUserKeys: %v", err)
+		}
+		if got.WrappedKey != wrapped || !bytes.Equal(got.KDF.Salt, keys.KDF.Salt) || got.KDF.Memory != keys.KDF.Memory || got.Version != int64(i+1) {
+			t.Fatalf("SelectUserKeys = %+v, want %+v", got, keys)
+		}
+	}
+
+	// пара ключей для общих записей сохраняется, пока не передана новая
+	keys, err := s.SelectUserKeys(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("SelectUserKeys: %v", err)
+	}
+	keys.PublicKey, keys.WrappedPrivateKey = "public", "v1.private"
+	if keys.Version, err = s.SetUserKeys(ctx, "alice@example.com", keys); err != nil {
+		t.Fatalf("SetUserKeys: %v", err)
+	}
+	keys.PublicKey, keys.WrappedPrivateKey, keys.WrappedKey = "", "", "v1.third"
+	if _, err := s.SetUserKeys(ctx, "alice@example.com", keys); err != nil {
+		t.Fatalf("SetUserKeys: %v", err)
+	}
+	got, err := s.SelectUserKeys(ctx, "alice@example.com")
+	if err != nil || got.WrappedKey != "v1.third" || got.PublicKey != "public" || got.WrappedPrivateKey != "v1.private" {
+		t.Fatalf("SelectUserKeys after key change = %+v, %v", got, err)
+	}
+
+	// сохранение вместо устаревшей версии (ключи сменило другое устройство) отклоняется
+	keys.WrappedKey = "v1.stale"
+	_, err = s.SetUserKeys(ctx, "alice@example.com", keys)
+	expectErr(t, err, storage.ErrConflict)
+	keys.Version = 0
+	_, err = s.SetUserKeys(ctx, "alice@example.com", keys)
+	expectErr(t, err, storage.ErrConflict)
+	if got, err := s.SelectUserKeys(ctx, "alice@example.com"); err != nil || got.WrappedKey != "v1.third" {
+		t.Fatalf("SelectUserKeys after conflict = %+v, %v", got, err)
+	}
+}
+
+func testSecureData(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	_, _, err := s.AddSecureData(ctx, "nobody@example.com", 0, "text", "v1.data", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+
+	id, addHistory, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.data", `{"title": "a"}`)
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	if id <= 0 || addHistory <= 0 {
+		t.Fatalf("AddSecureData returned id=%d historyID=%d", id, addHistory)
+	}
+
+	kind, err := s.SelectSecureDataKind(ctx, id, "alice@example.com")
+	if err != nil || kind != "text" {
+		t.Fatalf("SelectSecureDataKind = %q, %v", kind, err)
+	}
+
+	updateHistory, err := s.UpdateSecureData(ctx, id, "alice@example.com", addHistory, "credentials", "v1.updated", `{"title": "b"}`)
+	if err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+	if updateHistory <= addHistory {
+		t.Fatalf("history ID did not grow: %d after %d", updateHistory, addHistory)
+	}
+
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || data[0].ID != id || data[0].Data != "v1.updated" || data[0].Kind != "credentials" ||
+		!data[0].IsActive || data[0].HistoryID != updateHistory {
+		t.Fatalf("unexpected record after update: %+v", data)
+	}
+	if !jsonEqual(data[0].Metadata, `{"title": "b"}`) {
+		t.Fatalf("metadata = %s", data[0].Metadata)
+	}
+
+	deleteHistory, err := s.DeleteSecureData(ctx, id, "alice@example.com", 0)
+	if err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	if deleteHistory <= updateHistory {
+		t.Fatalf("history ID did not grow: %d after %d", deleteHistory, updateHistory)
+	}
+
+	data = syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || data[0].IsActive || data[0].HistoryID != deleteHistory {
+		t.Fatalf("unexpected record after delete: %+v", data)
+	}
+
+	_, err = s.UpdateSecureData(ctx, id, "alice@example.com", 0, "text", "v1.again", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.UpdateSecureData(ctx, id+1000, "alice@example.com", 0, "text", "v1.again", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.DeleteSecureData(ctx, id+1000, "alice@example.com", 0)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectSecureDataKind(ctx, id+1000, "alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+}
+
+func testOwnership(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+
+	id, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.data", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+
+	_, err = s.UpdateSecureData(ctx, id, "bob@example.com", 0, "text", "v1.stolen", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.DeleteSecureData(ctx, id, "bob@example.com", 0)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectSecureDataKind(ctx, id, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.CreateBlob(ctx, "bob@example.com", id, 10, "00")
+	expectErr(t, err, storage.ErrNotFound)
+
+	if data := syncAll(t, s, "bob@example.com"); len(data) != 0 {
+		t.Fatalf("bob sees alice's records: %+v", data)
+	}
+	if data := syncAll(t, s, "alice@example.com"); len(data) != 1 || data[0].Data != "v1.data" {
+		t.Fatalf("alice's record changed: %+v", data)
+	}
+}
+
+func testTransactions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	id, first, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.first", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+
+	// ошибка в середине отменяет все изменения транзакции
+	failed := errors.New("failed")
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		if _, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.added", "{}"); err != nil {
+			return err
+		}
+		if _, err := s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.changed", "{}"); err != nil {
+			return err
+		}
+		return failed
+	})
+	expectErr(t, err, failed)
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || data[0].Data != "v1.first" || data[0].HistoryID != first {
+		t.Fatalf("records after rollback: %+v", data)
+	}
+
+	// конфликт версий тоже отменяет транзакцию
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.DeleteSecureData(ctx, id, "alice@example.com", first); err != nil {
+			return err
+		}
+		_, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.added", "{}")
+		if err != nil {
+			return err
+		}
+		_, err = s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.stale", "{}")
+		return err
+	})
+	expectErr(t, err, storage.ErrConflict)
+	if data := syncAll(t, s, "alice@example.com"); len(data) != 1 || !data[0].IsActive {
+		t.Fatalf("records after conflict: %+v", data)
+	}
+
+	var added, updated int64
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if added, _, err = s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.added", "{}"); err != nil {
+			return err
+		}
+		updated, err = s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.changed", "{}")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTransaction: %v", err)
+	}
+	data = syncAll(t, s, "alice@example.com")
+	if len(data) != 2 || data[0].ID != added || data[1].ID != id || data[1].HistoryID != updated || data[1].Data != "v1.changed" {
+		t.Fatalf("records after commit: %+v", data)
+	}
+}
+
+func testConflicts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	id, first, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.first", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	second, err := s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.second", "{}")
+	if err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+
+	// второе устройство ещё не видело изменения second
+	for _, try := range []func() error{
+		func() error {
+			_, err := s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.stale", "{}")
+			return err
+		},
+		func() error {
+			_, err := s.DeleteSecureData(ctx, id, "alice@example.com", first)
+			return err
+		},
+	} {
+		err := try()
+		var conflict *storage.ConflictError
+		if !errors.As(err, &conflict) || !errors.Is(err, storage.ErrConflict) {
+			t.Fatalf("expected conflict, got %v", err)
+		}
+		if conflict.Current.ID != id || conflict.Current.HistoryID != second || conflict.Current.Data != "v1.second" {
+			t.Fatalf("conflict returned %+v", conflict.Current)
+		}
+	}
+
+	// конфликт не меняет запись и её версию
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || data[0].Data != "v1.second" || data[0].HistoryID != second || !data[0].IsActive {
+		t.Fatalf("record after conflicts = %+v", data)
+	}
+
+	// конфликт, отменивший транзакцию, остаётся в истории
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		_, err := s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.stale", "{}")
+		return err
+	})
+	expectErr(t, err, storage.ErrConflict)
+
+	deleted, err := s.DeleteSecureData(ctx, id, "alice@example.com", second)
+	if err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	if deleted <= second+3 {
+		t.Fatalf("conflicts were not recorded in history: delete history ID %d after %d", deleted, second)
+	}
+}
+
+func testHistory(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+
+	id, first, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.first", `{"n": 1}`)
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	second, err := s.UpdateSecureData(ctx, id, "alice@example.com", 0, "credentials", "v1.second", `{"n": 2}`)
+	if err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+	deleted, err := s.DeleteSecureData(ctx, id, "alice@example.com", 0)
+	if err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	// конфликт попадает в историю, но не является ревизией
+	_, err = s.UpdateSecureData(ctx, id, "alice@example.com", first, "text", "v1.stale", "{}")
+	expectErr(t, err, storage.ErrConflict)
+
+	revisions, err := s.SelectRevisions(ctx, id, "alice@example.com")
+	if err != nil {
+		t.Fatalf("SelectRevisions: %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].HistoryID != deleted || revisions[1].HistoryID != second || revisions[2].HistoryID != first {
+		t.Fatalf("revisions = %+v", revisions)
+	}
+	if revisions[0].Method != "DELETE" || revisions[0].IsActive || revisions[0].Data != "v1.second" {
+		t.Fatalf("delete revision = %+v", revisions[0])
+	}
+
+	revision, err := s.SelectRevision(ctx, id, "alice@example.com", first)
+	if err != nil || revision.Method != "ADD" || revision.Data != "v1.first" || revision.Kind != "text" ||
+		!jsonEqual(revision.Metadata, `{"n": 1}`) || revision.CreatedAt.IsZero() {
+		t.Fatalf("SelectRevision = %+v, %v", revision, err)
+	}
+	_, err = s.SelectRevision(ctx, id, "bob@example.com", first)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectRevisions(ctx, id, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.RestoreSecureData(ctx, id, "bob@example.com", 0, first)
+	expectErr(t, err, storage.ErrNotFound)
+
+	// восстановление удалённой записи из первой ревизии
+	_, err = s.RestoreSecureData(ctx, id, "alice@example.com", second, first)
+	expectErr(t, err, storage.ErrConflict)
+	_, err = s.RestoreSecureData(ctx, id, "alice@example.com", deleted, deleted+1000)
+	expectErr(t, err, storage.ErrNotFound)
+	restored, err := s.RestoreSecureData(ctx, id, "alice@example.com", deleted, first)
+	if err != nil {
+		t.Fatalf("RestoreSecureData: %v", err)
+	}
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || !data[0].IsActive || data[0].Data != "v1.first" || data[0].Kind != "text" || data[0].HistoryID != restored {
+		t.Fatalf("record after restore = %+v", data)
+	}
+
+	// лимит пользователя: остаются две последние ревизии
+	limit, err := s.SelectHistoryRetention(ctx, "alice@example.com")
+	if err != nil || limit != 0 {
+		t.Fatalf("SelectHistoryRetention = %d, %v", limit, err)
+	}
+	if err := s.SetHistoryRetention(ctx, "alice@example.com", 2); err != nil {
+		t.Fatalf("SetHistoryRetention: %v", err)
+	}
+	expectErr(t, s.SetHistoryRetention(ctx, "nobody@example.com", 2), storage.ErrNotFound)
+	last, err := s.UpdateSecureData(ctx, id, "alice@example.com", restored, "text", "v1.last", "{}")
+	if err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+	revisions, err = s.SelectRevisions(ctx, id, "alice@example.com")
+	if err != nil || len(revisions) != 2 || revisions[0].HistoryID != last || revisions[1].HistoryID != restored {
+		t.Fatalf("revisions after retention = %+v, %v", revisions, err)
+	}
+	_, err = s.SelectRevision(ctx, id, "alice@example.com", first)
+	expectErr(t, err, storage.ErrNotFound)
+}
+
+func testSync(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	var ids []int64
+	for range 5 {
+		id, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.data", "{}")
+		if err != nil {
+			t.Fatalf("AddSecureData: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	// первая запись меняется последней и должна прийти последней
+	last, err := s.UpdateSecureData(ctx, ids[0], "alice@example.com", 0, "text", "v1.changed", "{}")
+	if err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+
+	page, err := s.SelectUpdatedSecureData(ctx, 0, "alice@example.com", 2)
+	if err != nil {
+		t.Fatalf("SelectUpdatedSecureData: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[2] {
+		t.Fatalf("first page = %+v", page)
+	}
+
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 5 {
+		t.Fatalf("synced %d records, want 5", len(data))
+	}
+	for i := 1; i < len(data); i++ {
+		if data[i].HistoryID <= data[i-1].HistoryID {
+			t.Fatalf("records are not ordered by history ID: %+v", data)
+		}
+	}
+	if data[4].ID != ids[0] || data[4].HistoryID != last {
+		t.Fatalf("last change = %+v", data[4])
+	}
+
+	page, err = s.SelectUpdatedSecureData(ctx, last, "alice@example.com", 10)
+	if err != nil || len(page) != 0 {
+		t.Fatalf("no changes expected after %d, got %+v, %v", last, page, err)
+	}
+}
+
+func testBlobs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	id, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "binary", "v1.data", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	_, err = s.CreateBlob(ctx, "alice@example.com", id+1000, 10, "00")
+	expectErr(t, err, storage.ErrNotFound)
+
+	content := []byte("0123456789")
+	blobID, err := s.CreateBlob(ctx, "alice@example.com", id, int64(len(content)), "hash")
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+
+	received, err := s.AppendBlobChunk(ctx, blobID, "alice@example.com", 0, content[:4])
+	if err != nil || received != 4 {
+		t.Fatalf("AppendBlobChunk = %d, %v", received, err)
+	}
+	_, err = s.AppendBlobChunk(ctx, blobID, "alice@example.com", 0, content[:4])
+	expectErr(t, err, storage.ErrBlobOffset)
+	_, err = s.AppendBlobChunk(ctx, blobID, "alice@example.com", 4, append(content[4:], 'x'))
+	expectErr(t, err, storage.ErrBlobOffset)
+
+	_, err = s.CompleteBlob(ctx, blobID, "alice@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	if err := s.ResetBlob(ctx, blobID, "alice@example.com"); err != nil {
+		t.Fatalf("ResetBlob: %v", err)
+	}
+	blob, err := s.SelectBlob(ctx, blobID, "alice@example.com")
+	if err != nil || blob.Received != 0 {
+		t.Fatalf("SelectBlob after reset = %+v, %v", blob, err)
+	}
+
+	for offset := 0; offset < len(content); offset += 3 {
+		end := min(offset+3, len(content))
+		if _, err := s.AppendBlobChunk(ctx, blobID, "alice@example.com", int64(offset), content[offset:end]); err != nil {
+			t.Fatalf("AppendBlobChunk(%d): %v", offset, err)
+		}
+	}
+
+	for _, offset := range []int64{0, 2, 3, 9, 10} {
+		var got []byte
+		err := s.ReadBlobChunks(ctx, blobID, offset, func(data []byte) error {
+			got = append(got, data...)
+			return nil
+		})
+		if err != nil || !bytes.Equal(got, content[offset:]) {
+			t.Fatalf("ReadBlobChunks(%d) = %q, %v", offset, got, err)
+		}
+	}
+
+	historyID, err := s.CompleteBlob(ctx, blobID, "alice@example.com")
+	if err != nil {
+		t.Fatalf("CompleteBlob: %v", err)
+	}
+	blob, err = s.SelectBlob(ctx, blobID, "alice@example.com")
+	if err != nil || !blob.IsComplete || blob.HistoryID != historyID || blob.Received != blob.Size || blob.SHA256 != "hash" {
+		t.Fatalf("SelectBlob after complete = %+v, %v", blob, err)
+	}
+	_, err = s.AppendBlobChunk(ctx, blobID, "alice@example.com", blob.Size, []byte("x"))
+	expectErr(t, err, storage.ErrBlobOffset)
+
+	data := syncAll(t, s, "alice@example.com")
+	if len(data) != 1 || data[0].BlobID != blobID || data[0].HistoryID != historyID {
+		t.Fatalf("record after blob upload = %+v", data)
+	}
+
+	register(t, s, "bob@example.com")
+	_, err = s.SelectBlob(ctx, blobID, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// получатель доступа видит текущий файл записи, но не файлы прежних ревизий
+	if _, err := s.ShareSecureData(ctx, id, "alice@example.com", "bob@example.com", structs.PermissionRead, "s1.key"); err != nil {
+		t.Fatalf("ShareSecureData: %v", err)
+	}
+	if data := syncAll(t, s, "bob@example.com"); len(data) != 1 || data[0].BlobID != blobID {
+		t.Fatalf("shared record with blob = %+v", data)
+	}
+	if blob, err := s.SelectBlob(ctx, blobID, "bob@example.com"); err != nil || blob.ID != blobID || !blob.IsComplete {
+		t.Fatalf("SelectBlob of recipient = %+v, %v", blob, err)
+	}
+	next, err := s.CreateBlob(ctx, "alice@example.com", id, 1, "next")
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+	if _, err := s.AppendBlobChunk(ctx, next, "alice@example.com", 0, []byte("x")); err != nil {
+		t.Fatalf("AppendBlobChunk: %v", err)
+	}
+	_, err = s.SelectBlob(ctx, next, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	if _, err := s.CompleteBlob(ctx, next, "alice@example.com"); err != nil {
+		t.Fatalf("CompleteBlob: %v", err)
+	}
+	if blob, err := s.SelectBlob(ctx, next, "bob@example.com"); err != nil || blob.ID != next {
+		t.Fatalf("SelectBlob of recipient after replace = %+v, %v", blob, err)
+	}
+	_, err = s.SelectBlob(ctx, blobID, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	if _, err := s.RevokeShare(ctx, id, "alice@example.com", "bob@example.com"); err != nil {
+		t.Fatalf("RevokeShare: %v", err)
+	}
+	_, err = s.SelectBlob(ctx, next, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+}
+
+// syncAll - все записи пользователя постранично
+func testChanges(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+
+	_, err := s.SubscribeChanges(ctx, "nobody@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	if last, err := s.SelectLastHistoryID(ctx, "alice@example.com"); err != nil || last != 0 {
+		t.Fatalf("SelectLastHistoryID without records: %d, %v", last, err)
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes, err := s.SubscribeChanges(subCtx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("SubscribeChanges: %v", err)
+	}
+	expectChange := func(want int64) {
+		t.Helper()
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("change %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("change %d not received", want)
+		}
+	}
+
+	id, added, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.first", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	expectChange(added)
+
+	// изменения другого пользователя и отменённые транзакции не рассылаются
+	if _, _, err := s.AddSecureData(ctx, "bob@example.com", 0, "text", "v1.bob", "{}"); err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	failed := errors.New("failed")
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.UpdateSecureData(ctx, id, "alice@example.com", added, "text", "v1.rolled-back", "{}"); err != nil {
+			return err
+		}
+		return failed
+	})
+	expectErr(t, err, failed)
+
+	deleted, err := s.DeleteSecureData(ctx, id, "alice@example.com", 0)
+	if err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	expectChange(deleted)
+	if last, err := s.SelectLastHistoryID(ctx, "alice@example.com"); err != nil || last != deleted {
+		t.Fatalf("SelectLastHistoryID: %d, %v, want %d", last, err, deleted)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Fatalf("unexpected change after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("channel not closed after cancel")
+	}
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+
+	add := func(username string, kind string, metadata string) int64 {
+		t.Helper()
+		id, _, err := s.AddSecureData(ctx, username, 0, kind, "v1.data", metadata)
+		if err != nil {
+			t.Fatalf("AddSecureData: %v", err)
+		}
+		return id
+	}
+	mail := add("alice@example.com", "credentials", `{"name": "mail", "tags": ["work", "personal"], "site": {"host": "mail.example.com"}}`)
+	bank := add("alice@example.com", "card", `{"name": "bank", "tags": ["personal"]}`)
+	vpn := add("alice@example.com", "credentials", `{"name": "VPN", "tags": ["work"]}`)
+	old := add("alice@example.com", "text", `{"name": "old", "tags": ["work"]}`)
+	noName := add("alice@example.com", "text", `{}`)
+	bobMail := add("bob@example.com", "credentials", `{"name": "mail", "tags": ["work"]}`)
+	if _, err := s.DeleteSecureData(ctx, old, "alice@example.com", 0); err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	if _, err := s.UpdateSecureData(ctx, mail, "alice@example.com", 0, "credentials", "v1.changed", `{"name": "mail", "tags": ["work", "personal"], "site": {"host": "mail.example.com"}}`); err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+
+	search := func(filter storage.SearchFilter) ([]int64, *storage.SearchCursor) {
+		t.Helper()
+		if filter.State == "" {
+			filter.State = storage.SearchActive
+		}
+		if filter.Sort == "" {
+			filter.Sort = storage.SortID
+		}
+		if filter.Limit == 0 {
+			filter.Limit = 100
+		}
+		data, next, err := s.SearchSecureData(ctx, "alice@example.com", filter)
+		if err != nil {
+			t.Fatalf("SearchSecureData(%+v): %v", filter, err)
+		}
+		ids := make([]int64, 0, len(data))
+		for _, d := range data {
+			ids = append(ids, d.ID)
+		}
+		return ids, next
+	}
+	expect := func(filter storage.SearchFilter, want ...int64) {
+		t.Helper()
+		got, next := search(filter)
+		if !slices.Equal(got, want) || next != nil {
+			t.Fatalf("search %+v: %v (next %v), want %v", filter, got, next, want)
+		}
+	}
+
+	expect(storage.SearchFilter{}, mail, bank, vpn, noName)
+	expect(storage.SearchFilter{Tags: []string{"work"}}, mail, vpn)
+	expect(storage.SearchFilter{Tags: []string{"work", "personal"}}, mail)
+	expect(storage.SearchFilter{Metadata: `{"site": {"host": "mail.example.com"}}`}, mail)
+	expect(storage.SearchFilter{Metadata: `{"name": "bank"}`, Tags: []string{"work"}})
+	expect(storage.SearchFilter{Kind: "credentials"}, mail, vpn)
+	expect(storage.SearchFilter{State: storage.SearchDeleted}, old)
+	expect(storage.SearchFilter{State: storage.SearchAll, Tags: []string{"work"}}, mail, vpn, old)
+	expect(storage.SearchFilter{Desc: true}, noName, vpn, bank, mail)
+	expect(storage.SearchFilter{Sort: storage.SortHistoryID}, bank, vpn, noName, mail)
+	// строки сравниваются побайтно, без ключа - пустая строка
+	expect(storage.SearchFilter{Sort: storage.SortMetadataPrefix + "name"}, noName, vpn, bank, mail)
+	expect(storage.SearchFilter{Sort: storage.SortMetadataPrefix + "name", Desc: true}, mail, bank, vpn, noName)
+
+	// постраничный обход возвращает каждую запись один раз для любой сортировки
+	for _, filter := range []storage.SearchFilter{
+		{Sort: storage.SortID},
+		{Sort: storage.SortHistoryID, Desc: true},
+		{Sort: storage.SortMetadataPrefix + "name"},
+		{Sort: storage.SortMetadataPrefix + "name", Desc: true},
+	} {
+		all, _ := search(filter)
+		filter.Limit = 1
+		var paged []int64
+		for {
+			ids, next := search(filter)
+			paged = append(paged, ids...)
+			if next == nil {
+				break
+			}
+			if len(paged) > len(all) {
+				t.Fatalf("pagination %+v does not end: %v", filter, paged)
+			}
+			filter.After = next
+		}
+		if !slices.Equal(paged, all) {
+			t.Fatalf("pagination %+v: %v, want %v", filter, paged, all)
+		}
+	}
+
+	// открытые пользователю записи ищутся в том виде, в каком приходят в синхронизации
+	if _, err := s.ShareSecureData(ctx, bobMail, "bob@example.com", "alice@example.com", structs.PermissionRead, "s1.key"); err != nil {
+		t.Fatalf("ShareSecureData: %v", err)
+	}
+	expect(storage.SearchFilter{Metadata: `{"name": "mail"}`}, mail, bobMail)
+	expect(storage.SearchFilter{Sort: storage.SortMetadataPrefix + "name", Tags: []string{"work"}}, vpn, mail, bobMail)
+	data, _, err := s.SearchSecureData(ctx, "alice@example.com", storage.SearchFilter{Kind: "credentials", Sort: storage.SortID, Limit: 100, After: &storage.SearchCursor{ID: vpn}})
+	if err != nil || len(data) != 1 || data[0].ID != bobMail || data[0].Share == nil || data[0].Share.Owner != "bob@example.com" || data[0].VaultID != 0 {
+		t.Fatalf("shared search result = %+v, %v", data, err)
+	}
+	if _, err := s.RevokeShare(ctx, bobMail, "bob@example.com", "alice@example.com"); err != nil {
+		t.Fatalf("RevokeShare: %v", err)
+	}
+	expect(storage.SearchFilter{Metadata: `{"name": "mail"}`}, mail)
+}
+
+func testSharing(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+	register(t, s, "carol@example.com")
+
+	id, added, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "r1.key.shared", `{"title":"shared"}`)
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	if _, _, err := s.AddSecureData(ctx, "bob@example.com", 0, "text", "v1.bob", "{}"); err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+
+	_, err = s.ShareSecureData(ctx, id, "bob@example.com", "carol@example.com", structs.PermissionRead, "s1.key")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.ShareSecureData(ctx, id, "alice@example.com", "nobody@example.com", structs.PermissionRead, "s1.key")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.RevokeShare(ctx, id, "alice@example.com", "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	bobLast, err := s.SelectLastHistoryID(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatalf("SelectLastHistoryID: %v", err)
+	}
+	shared, err := s.ShareSecureData(ctx, id, "alice@example.com", "bob@example.com", structs.PermissionRead, "s1.key")
+	if err != nil {
+		t.Fatalf("ShareSecureData: %v", err)
+	}
+	if shared <= bobLast {
+		t.Fatalf("share historyID %d is not after %d", shared, bobLast)
+	}
+
+	// общая запись приходит получателю в синхронизации с тем же history_id, что у владельца,
+	// и позицией открытия доступа; у владельца запись не меняется
+	page, err := s.SelectUpdatedSecureData(ctx, bobLast, "bob@example.com", 10)
+	if err != nil || len(page) != 1 {
+		t.Fatalf("SelectUpdatedSecureData after share: %+v, %v", page, err)
+	}
+	got := page[0]
+	if got.ID != id || got.HistoryID != added || got.SyncID != shared || got.Data != "r1.key.shared" || !got.IsActive || got.Share == nil ||
+		got.Share.Owner != "alice@example.com" || got.Share.Permission != structs.PermissionRead || got.Share.WrappedKey != "s1.key" {
+		t.Fatalf("shared record = %+v (share %+v)", got, got.Share)
+	}
+	if last, err := s.SelectLastHistoryID(ctx, "bob@example.com"); err != nil || last != shared {
+		t.Fatalf("SelectLastHistoryID of recipient: %d, %v, want %d", last, err, shared)
+	}
+	if page, err := s.SelectUpdatedSecureData(ctx, shared, "bob@example.com", 10); err != nil || len(page) != 0 {
+		t.Fatalf("SelectUpdatedSecureData of recipient after share: %+v, %v", page, err)
+	}
+	if page := syncAll(t, s, "alice@example.com"); len(page) != 1 || page[0].HistoryID != added {
+		t.Fatalf("owner sync after share: %+v", page)
+	}
+	if list, err := s.SelectSharedSecureData(ctx, "bob@example.com"); err != nil || len(list) != 1 || list[0].ID != id {
+		t.Fatalf("SelectSharedSecureData: %+v, %v", list, err)
+	}
+	if kind, err := s.SelectSecureDataKind(ctx, id, "bob@example.com"); err != nil || kind != "text" {
+		t.Fatalf("SelectSecureDataKind of recipient: %q, %v", kind, err)
+	}
+	if page, err := s.SelectUpdatedSecureData(ctx, 0, "carol@example.com", 10); err != nil || len(page) != 0 {
+		t.Fatalf("SelectUpdatedSecureData of another user: %+v, %v", page, err)
+	}
+
+	// только чтение: изменять, удалять, смотреть историю и доступы может только владелец
+	_, err = s.UpdateSecureData(ctx, id, "bob@example.com", 0, "text", "r1.key.bob", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.DeleteSecureData(ctx, id, "bob@example.com", 0)
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectRevisions(ctx, id, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectShares(ctx, id, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	if _, err := s.ShareSecureData(ctx, id, "alice@example.com", "bob@example.com", structs.PermissionWrite, "s1.key2"); err != nil {
+		t.Fatalf("ShareSecureData rw: %v", err)
+	}
+	updated, err := s.UpdateSecureData(ctx, id, "bob@example.com", added, "text", "r1.key.bob", "{}")
+	if err != nil {
+		t.Fatalf("UpdateSecureData by recipient: %v", err)
+	}
+	_, err = s.UpdateSecureData(ctx, id, "bob@example.com", added, "text", "r1.key.stale", "{}")
+	expectErr(t, err, storage.ErrConflict)
+	_, err = s.DeleteSecureData(ctx, id, "bob@example.com", 0)
+	expectErr(t, err, storage.ErrNotFound)
+	if page := syncAll(t, s, "alice@example.com"); len(page) != 1 || page[0].HistoryID != updated || page[0].Data != "r1.key.bob" || page[0].Share != nil {
+		t.Fatalf("owner sync after recipient update: %+v", page)
+	}
+
+	shares, err := s.SelectShares(ctx, id, "alice@example.com")
+	if err != nil || len(shares) != 1 || shares[0].Recipient != "bob@example.com" || shares[0].Permission != structs.PermissionWrite ||
+		shares[0].WrappedKey != "s1.key2" || shares[0].RevokedAt != nil {
+		t.Fatalf("SelectShares: %+v, %v", shares, err)
+	}
+
+	revoked, err := s.RevokeShare(ctx, id, "alice@example.com", "bob@example.com")
+	if err != nil {
+		t.Fatalf("RevokeShare: %v", err)
+	}
+	page, err = s.SelectUpdatedSecureData(ctx, updated, "bob@example.com", 10)
+	if err != nil || len(page) != 1 || page[0].ID != id || page[0].IsActive || page[0].Data != "" || page[0].HistoryID != revoked {
+		t.Fatalf("SelectUpdatedSecureData after revoke: %+v, %v", page, err)
+	}
+	_, err = s.UpdateSecureData(ctx, id, "bob@example.com", 0, "text", "r1.key.revoked", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	if list, err := s.SelectSharedSecureData(ctx, "bob@example.com"); err != nil || len(list) != 0 {
+		t.Fatalf("SelectSharedSecureData after revoke: %+v, %v", list, err)
+	}
+
+	// после отзыва изменения владельца получателю не приходят
+	if _, err := s.UpdateSecureData(ctx, id, "alice@example.com", 0, "text", "r1.key.later", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+	if page, err := s.SelectUpdatedSecureData(ctx, revoked, "bob@example.com", 10); err != nil || len(page) != 0 {
+		t.Fatalf("SelectUpdatedSecureData after revoked update: %+v, %v", page, err)
+	}
+	if last, err := s.SelectLastHistoryID(ctx, "bob@example.com"); err != nil || last != revoked {
+		t.Fatalf("SelectLastHistoryID after revoke: %d, %v, want %d", last, err, revoked)
+	}
+
+	// открытие и отзыв доступа не создают ревизий
+	revisions, err := s.SelectRevisions(ctx, id, "alice@example.com")
+	if err != nil {
+		t.Fatalf("SelectRevisions: %v", err)
+	}
+	var methods []string
+	for _, r := range revisions {
+		methods = append(methods, r.Method)
+	}
+	want := []string{"UPDATE", "UPDATE", "ADD"}
+	if !slices.Equal(methods, want) {
+		t.Fatalf("revisions %v, want %v", methods, want)
+	}
+
+	shares, err = s.SelectShares(ctx, id, "alice@example.com")
+	if err != nil || len(shares) != 1 || shares[0].RevokedAt == nil || shares[0].HistoryID != revoked {
+		t.Fatalf("SelectShares after revoke: %+v, %v", shares, err)
+	}
+}
+
+func testVaults(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+	register(t, s, "carol@example.com")
+
+	org, err := s.CreateOrganization(ctx, "alice@example.com", "team", "s1.alice")
+	if err != nil || org.ID == 0 || org.Role != structs.RoleOwner || org.WrappedKey != "s1.alice" {
+		t.Fatalf("CreateOrganization: %+v, %v", org, err)
+	}
+	if orgs, err := s.SelectOrganizations(ctx, "bob@example.com"); err != nil || len(orgs) != 0 {
+		t.Fatalf("SelectOrganizations of non-member: %+v, %v", orgs, err)
+	}
+	_, err = s.SelectMembers(ctx, org.ID, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.CreateVault(ctx, "bob@example.com", org.ID, "shared")
+	expectErr(t, err, storage.ErrNotFound)
+
+	team, err := s.CreateVault(ctx, "alice@example.com", org.ID, "shared")
+	if err != nil || team.OrgID != org.ID {
+		t.Fatalf("CreateVault: %+v, %v", team, err)
+	}
+	personal, err := s.CreateVault(ctx, "bob@example.com", 0, "work")
+	if err != nil || personal.OrgID != 0 {
+		t.Fatalf("CreateVault personal: %+v, %v", personal, err)
+	}
+	_, _, err = s.AddSecureData(ctx, "alice@example.com", personal.ID, "text", "v1.x", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, _, err = s.AddSecureData(ctx, "bob@example.com", team.ID, "text", "v1.x", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	if _, _, err := s.AddSecureData(ctx, "bob@example.com", personal.ID, "text", "v1.work", "{}"); err != nil {
+		t.Fatalf("AddSecureData to personal vault: %v", err)
+	}
+
+	id, _, err := s.AddSecureData(ctx, "alice@example.com", team.ID, "text", "v1.team", `{"title":"team"}`)
+	if err != nil {
+		t.Fatalf("AddSecureData to team vault: %v", err)
+	}
+
+	// новый участник получает записи хранилищ организации в синхронизации после вступления
+	bobLast, err := s.SelectLastHistoryID(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatalf("SelectLastHistoryID: %v", err)
+	}
+	joined, err := s.SetMember(ctx, org.ID, "alice@example.com", "bob@example.com", structs.RoleReadOnly, "s1.bob")
+	if err != nil || joined <= bobLast {
+		t.Fatalf("SetMember: %d, %v (last %d)", joined, err, bobLast)
+	}
+	page, err := s.SelectUpdatedSecureData(ctx, bobLast, "bob@example.com", 10)
+	if err != nil || len(page) != 1 || page[0].ID != id || page[0].VaultID != team.ID || page[0].SyncID != joined || page[0].Data != "v1.team" {
+		t.Fatalf("SelectUpdatedSecureData after join: %+v, %v", page, err)
+	}
+	if last, err := s.SelectLastHistoryID(ctx, "bob@example.com"); err != nil || last != joined {
+		t.Fatalf("SelectLastHistoryID after join: %d, %v, want %d", last, err, joined)
+	}
+	if orgs, err := s.SelectOrganizations(ctx, "bob@example.com"); err != nil || len(orgs) != 1 || orgs[0].Role != structs.RoleReadOnly || orgs[0].WrappedKey != "s1.bob" {
+		t.Fatalf("SelectOrganizations of member: %+v, %v", orgs, err)
+	}
+	if vaults, err := s.SelectVaults(ctx, "bob@example.com"); err != nil || len(vaults) != 2 {
+		t.Fatalf("SelectVaults: %+v, %v", vaults, err)
+	}
+
+	// участник только для чтения не может изменять записи и управлять участниками
+	_, _, err = s.AddSecureData(ctx, "bob@example.com", team.ID, "text", "v1.x", "{}")
+	expectErr(t, err, storage.ErrForbidden)
+	_, err = s.UpdateSecureData(ctx, id, "bob@example.com", 0, "text", "v1.x", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SetMember(ctx, org.ID, "bob@example.com", "carol@example.com", structs.RoleMember, "s1.carol")
+	expectErr(t, err, storage.ErrForbidden)
+	if kind, err := s.SelectSecureDataKind(ctx, id, "bob@example.com"); err != nil || kind != "text" {
+		t.Fatalf("SelectSecureDataKind of member: %q, %v", kind, err)
+	}
+	if _, err := s.SelectRevisions(ctx, id, "bob@example.com"); err != nil {
+		t.Fatalf("SelectRevisions of member: %v", err)
+	}
+
+	// участник только для чтения видит загрузку файла, но не может дописать, сбросить или завершить её
+	blobID, err := s.CreateBlob(ctx, "alice@example.com", id, 2, "00")
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+	_, err = s.CreateBlob(ctx, "bob@example.com", id, 2, "00")
+	expectErr(t, err, storage.ErrNotFound)
+	if _, err := s.AppendBlobChunk(ctx, blobID, "alice@example.com", 0, []byte("a")); err != nil {
+		t.Fatalf("AppendBlobChunk by owner: %v", err)
+	}
+	if blob, err := s.SelectBlob(ctx, blobID, "bob@example.com"); err != nil || blob.Received != 1 {
+		t.Fatalf("SelectBlob of read-only member: %+v, %v", blob, err)
+	}
+	_, err = s.AppendBlobChunk(ctx, blobID, "bob@example.com", 1, []byte("b"))
+	expectErr(t, err, storage.ErrNotFound)
+	expectErr(t, s.ResetBlob(ctx, blobID, "bob@example.com"), storage.ErrNotFound)
+	if _, err := s.AppendBlobChunk(ctx, blobID, "alice@example.com", 1, []byte("b")); err != nil {
+		t.Fatalf("AppendBlobChunk by owner: %v", err)
+	}
+	_, err = s.CompleteBlob(ctx, blobID, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	if blob, err := s.SelectBlob(ctx, blobID, "alice@example.com"); err != nil || blob.Received != 2 || blob.IsComplete {
+		t.Fatalf("SelectBlob after read-only attempts: %+v, %v", blob, err)
+	}
+
+	if _, err := s.SetMember(ctx, org.ID, "alice@example.com", "bob@example.com", structs.RoleAdmin, "s1.bob"); err != nil {
+		t.Fatalf("SetMember role change: %v", err)
+	}
+	_, err = s.SetMember(ctx, org.ID, "bob@example.com", "carol@example.com", structs.RoleOwner, "s1.carol")
+	expectErr(t, err, storage.ErrForbidden)
+	if _, err := s.SetMember(ctx, org.ID, "bob@example.com", "carol@example.com", structs.RoleMember, "s1.carol"); err != nil {
+		t.Fatalf("SetMember by admin: %v", err)
+	}
+	_, err = s.RemoveMember(ctx, org.ID, "bob@example.com", "alice@example.com")
+	expectErr(t, err, storage.ErrForbidden)
+	_, err = s.RemoveMember(ctx, org.ID, "alice@example.com", "alice@example.com")
+	expectErr(t, err, storage.ErrForbidden)
+	_, err = s.SetMember(ctx, org.ID, "alice@example.com", "alice@example.com", structs.RoleMember, "s1.alice")
+	expectErr(t, err, storage.ErrForbidden)
+	members, err := s.SelectMembers(ctx, org.ID, "carol@example.com")
+	if err != nil || len(members) != 3 {
+		t.Fatalf("SelectMembers: %+v, %v", members, err)
+	}
+
+	// участники с правом записи изменяют и удаляют записи организации, изменения видят все участники
+	updated, err := s.UpdateSecureData(ctx, id, "carol@example.com", page[0].HistoryID, "text", "v1.carol", "{}")
+	if err != nil {
+		t.Fatalf("UpdateSecureData by member: %v", err)
+	}
+	page, err = s.SelectUpdatedSecureData(ctx, joined, "bob@example.com", 10)
+	if err != nil || len(page) != 1 || page[0].HistoryID != updated || page[0].Data != "v1.carol" {
+		t.Fatalf("SelectUpdatedSecureData after member update: %+v, %v", page, err)
+	}
+	_, err = s.ShareSecureData(ctx, id, "alice@example.com", "carol@example.com", structs.PermissionRead, "s1.key")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// исключённый участник получает записи организации неактивными и теряет к ним доступ,
+	// даже если сам их создал
+	own, _, err := s.AddSecureData(ctx, "carol@example.com", team.ID, "text", "v1.own", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData by member: %v", err)
+	}
+	removed, err := s.RemoveMember(ctx, org.ID, "carol@example.com", "carol@example.com")
+	if err != nil {
+		t.Fatalf("RemoveMember (leave): %v", err)
+	}
+	page, err = s.SelectUpdatedSecureData(ctx, updated, "carol@example.com", 10)
+	if err != nil || len(page) != 2 {
+		t.Fatalf("SelectUpdatedSecureData after removal: %+v, %v", page, err)
+	}
+	for _, d := range page {
+		if d.IsActive || d.Data != "" || d.HistoryID != removed {
+			t.Fatalf("record after removal = %+v", d)
+		}
+	}
+	_, err = s.UpdateSecureData(ctx, own, "carol@example.com", 0, "text", "v1.x", "{}")
+	expectErr(t, err, storage.ErrNotFound)
+	_, err = s.SelectSecureDataKind(ctx, own, "carol@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	if _, err := s.DeleteSecureData(ctx, own, "bob@example.com", 0); err != nil {
+		t.Fatalf("DeleteSecureData by admin: %v", err)
+	}
+	if page, err := s.SelectUpdatedSecureData(ctx, removed, "carol@example.com", 10); err != nil || len(page) != 0 {
+		t.Fatalf("SelectUpdatedSecureData after removal: %+v, %v", page, err)
+	}
+	if orgs, err := s.SelectOrganizations(ctx, "carol@example.com"); err != nil || len(orgs) != 0 {
+		t.Fatalf("SelectOrganizations after removal: %+v, %v", orgs, err)
+	}
+}
+
+func testAdmin(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+	register(t, s, "bob@example.com")
+	register(t, s, "carol@example.com")
+
+	users, err := s.SelectUsers(ctx, "BO", 0, 10)
+	if err != nil || len(users) != 1 || users[0].Username != "bob@example.com" {
+		t.Fatalf("SelectUsers: %+v, %v", users, err)
+	}
+	users, err = s.SelectUsers(ctx, "", 0, 2)
+	if err != nil || len(users) != 2 || users[0].Username != "alice@example.com" {
+		t.Fatalf("SelectUsers first page: %+v, %v", users, err)
+	}
+	users, err = s.SelectUsers(ctx, "", users[1].ID, 2)
+	if err != nil || len(users) != 1 || users[0].Username != "carol@example.com" {
+		t.Fatalf("SelectUsers second page: %+v, %v", users, err)
+	}
+
+	if _, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.x", "{}"); err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	session := structs.Session{ID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateSession(ctx, "alice@example.com", session, "hash"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	user, err := s.SelectUser(ctx, "alice@example.com")
+	if err != nil || user.Records != 1 || user.Sessions != 1 || user.LastActivity == nil || user.DisabledAt != nil || user.Pending {
+		t.Fatalf("SelectUser: %+v, %v", user, err)
+	}
+	_, err = s.SelectUser(ctx, "nobody@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// блокировка отзывает сессии и запрещает вход до разблокировки
+	if err := s.SetUserDisabled(ctx, "alice@example.com", true); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+	expectErr(t, s.CheckUser(ctx, "alice@example.com"), storage.ErrDisabled)
+	expectErr(t, s.CheckSession(ctx, session.ID, "alice@example.com", ""), storage.ErrNotFound)
+	expectErr(t, s.CreateSession(ctx, "alice@example.com", structs.Session{ID: "new", ExpiresAt: time.Now().Add(time.Hour)}, "hash"), storage.ErrNotFound)
+	if user, err := s.SelectUser(ctx, "alice@example.com"); err != nil || user.DisabledAt == nil || user.Sessions != 0 {
+		t.Fatalf("SelectUser after disable: %+v, %v", user, err)
+	}
+	if err := s.SetUserDisabled(ctx, "alice@example.com", false); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+	if err := s.CheckUser(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("CheckUser after enable: %v", err)
+	}
+	expectErr(t, s.SetUserDisabled(ctx, "nobody@example.com", true), storage.ErrNotFound)
+
+	for _, id := range []string{"s1", "s2"} {
+		if err := s.CreateSession(ctx, "bob@example.com", structs.Session{ID: id, ExpiresAt: time.Now().Add(time.Hour)}, "hash"); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	if revoked, err := s.RevokeSessions(ctx, "bob@example.com"); err != nil || revoked != 2 {
+		t.Fatalf("RevokeSessions = %d, %v", revoked, err)
+	}
+	_, err = s.RevokeSessions(ctx, "nobody@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// записи организации с другими участниками остаются у них, организация без других участников удаляется
+	org, err := s.CreateOrganization(ctx, "alice@example.com", "team", "s1.alice")
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	team, err := s.CreateVault(ctx, "alice@example.com", org.ID, "shared")
+	if err != nil {
+		t.Fatalf("CreateVault: %v", err)
+	}
+	if _, err := s.SetMember(ctx, org.ID, "alice@example.com", "bob@example.com", structs.RoleMember, "s1.bob"); err != nil {
+		t.Fatalf("SetMember: %v", err)
+	}
+	teamRecord, _, err := s.AddSecureData(ctx, "alice@example.com", team.ID, "text", "v1.team", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	solo, err := s.CreateOrganization(ctx, "alice@example.com", "solo", "s1.alice")
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	expectErr(t, s.DeleteUser(ctx, "alice@example.com"), storage.ErrForbidden)
+	if _, err := s.SetMember(ctx, org.ID, "alice@example.com", "bob@example.com", structs.RoleOwner, "s1.bob"); err != nil {
+		t.Fatalf("SetMember owner: %v", err)
+	}
+	if err := s.DeleteUser(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	expectErr(t, s.CheckUser(ctx, "alice@example.com"), storage.ErrNotFound)
+	expectErr(t, s.DeleteUser(ctx, "alice@example.com"), storage.ErrNotFound)
+	if _, err := s.UpdateSecureData(ctx, teamRecord, "bob@example.com", 0, "text", "v1.bob", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData after author deletion: %v", err)
+	}
+	if _, err := s.SelectRevisions(ctx, teamRecord, "bob@example.com"); err != nil {
+		t.Fatalf("SelectRevisions after author deletion: %v", err)
+	}
+	members, err := s.SelectMembers(ctx, org.ID, "bob@example.com")
+	if err != nil || len(members) != 1 {
+		t.Fatalf("SelectMembers after deletion: %+v, %v", members, err)
+	}
+	_, err = s.SelectMembers(ctx, solo.ID, "bob@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+
+	// адрес удалённого пользователя снова свободен
+	register(t, s, "alice@example.com")
+	if user, err := s.SelectUser(ctx, "alice@example.com"); err != nil || user.Records != 0 {
+		t.Fatalf("SelectUser after re-registration: %+v, %v", user, err)
+	}
+}
+
+func testQuotas(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	usage, err := s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage != (structs.Usage{}) {
+		t.Fatalf("SelectUsage without records = %+v, %v", usage, err)
+	}
+	_, err = s.SelectUsage(ctx, "nobody@example.com")
+	expectErr(t, err, storage.ErrNotFound)
+	expectErr(t, s.SetUserQuota(ctx, "nobody@example.com", nil), storage.ErrNotFound)
+
+	quota := structs.Quota{Records: 2, Bytes: 20, RecordSize: 12, Revisions: 2}
+	if err := s.SetUserQuota(ctx, "alice@example.com", &quota); err != nil {
+		t.Fatalf("SetUserQuota: %v", err)
+	}
+	expectQuota := func(err error, name string) {
+		t.Helper()
+		var quotaErr *storage.QuotaError
+		if !errors.As(err, &quotaErr) || quotaErr.Name != name || !errors.Is(err, storage.ErrQuotaExceeded) {
+			t.Fatalf("expected %s quota error, got %v", name, err)
+		}
+	}
+
+	// размер записи - данные и метаданные
+	_, _, err = s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.aaaaaaaa", "{}")
+	expectQuota(err, structs.QuotaRecordSize)
+	first, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.aaaaaaa", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	_, _, err = s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.bbbbbb", "{}")
+	expectQuota(err, structs.QuotaBytes)
+	second, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.b", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData: %v", err)
+	}
+	_, _, err = s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.c", "{}")
+	expectQuota(err, structs.QuotaRecords)
+
+	usage, err = s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage.Records != 2 || usage.Bytes != 18 || usage.Quota != quota || !usage.Custom {
+		t.Fatalf("SelectUsage = %+v, %v", usage, err)
+	}
+
+	// изменение учитывает прежний размер записи
+	if _, err := s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.a", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData shrinking: %v", err)
+	}
+	if _, err := s.UpdateSecureData(ctx, second, "alice@example.com", 0, "text", "v1.bbbbbbb", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData within quota: %v", err)
+	}
+	_, err = s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.aaaaaaa", "{}")
+	expectQuota(err, structs.QuotaBytes)
+
+	// квота ревизий ограничивает историю без лимита пользователя
+	if _, err := s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.x", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData: %v", err)
+	}
+	revisions, err := s.SelectRevisions(ctx, first, "alice@example.com")
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("SelectRevisions with revisions quota = %+v, %v", revisions, err)
+	}
+
+	// восстановление удалённой записи снова занимает место
+	if _, err := s.DeleteSecureData(ctx, second, "alice@example.com", 0); err != nil {
+		t.Fatalf("DeleteSecureData: %v", err)
+	}
+	third, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.c", "{}")
+	if err != nil {
+		t.Fatalf("AddSecureData after delete: %v", err)
+	}
+	revisions, err = s.SelectRevisions(ctx, second, "alice@example.com")
+	if err != nil || len(revisions) == 0 {
+		t.Fatalf("SelectRevisions: %+v, %v", revisions, err)
+	}
+	_, err = s.RestoreSecureData(ctx, second, "alice@example.com", 0, revisions[len(revisions)-1].HistoryID)
+	expectQuota(err, structs.QuotaRecords)
+
+	// ошибка квоты отменяет всю транзакцию
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.y", "{}"); err != nil {
+			return err
+		}
+		_, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.d", "{}")
+		return err
+	})
+	expectQuota(err, structs.QuotaRecords)
+	for _, d := range syncAll(t, s, "alice@example.com") {
+		if d.ID == first && d.Data != "v1.x" {
+			t.Fatalf("record after rolled back transaction = %+v", d)
+		}
+	}
+
+	// файлы учитываются в размере записи и в объёме записей автора, незавершённая загрузка - сразу
+	_, err = s.CreateBlob(ctx, "alice@example.com", first, 7, "00")
+	expectQuota(err, structs.QuotaRecordSize)
+	uploadBlob := func(id int64, size int64) int64 {
+		t.Helper()
+		blobID, err := s.CreateBlob(ctx, "alice@example.com", id, size, "00")
+		if err != nil {
+			t.Fatalf("CreateBlob within quota: %v", err)
+		}
+		if _, err := s.AppendBlobChunk(ctx, blobID, "alice@example.com", 0, make([]byte, size)); err != nil {
+			t.Fatalf("AppendBlobChunk: %v", err)
+		}
+		return blobID
+	}
+	replaced := uploadBlob(first, 6)
+	usage, err = s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage.Records != 2 || usage.Bytes != 18 {
+		t.Fatalf("SelectUsage with blob = %+v, %v", usage, err)
+	}
+	_, err = s.CreateBlob(ctx, "alice@example.com", third, 3, "00")
+	expectQuota(err, structs.QuotaBytes)
+	if _, err := s.CompleteBlob(ctx, replaced, "alice@example.com"); err != nil {
+		t.Fatalf("CompleteBlob: %v", err)
+	}
+	_, err = s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.xy", "{}")
+	expectQuota(err, structs.QuotaRecordSize)
+
+	// заменённый файл учитывается, пока хранится ревизия с ним, в размер записи входит только текущий
+	larger := quota
+	larger.Bytes = 40
+	if err := s.SetUserQuota(ctx, "alice@example.com", &larger); err != nil {
+		t.Fatalf("SetUserQuota: %v", err)
+	}
+	if _, err := s.CompleteBlob(ctx, uploadBlob(first, 6), "alice@example.com"); err != nil {
+		t.Fatalf("CompleteBlob replacing file: %v", err)
+	}
+	usage, err = s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage.Bytes != 24 {
+		t.Fatalf("SelectUsage with replaced blob = %+v, %v", usage, err)
+	}
+	if _, err := s.UpdateSecureData(ctx, first, "alice@example.com", 0, "text", "v1.x", "{}"); err != nil {
+		t.Fatalf("UpdateSecureData with replaced blob: %v", err)
+	}
+	usage, err = s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage.Bytes != 18 {
+		t.Fatalf("SelectUsage after replaced blob revision pruned = %+v, %v", usage, err)
+	}
+
+	if err := s.SetUserQuota(ctx, "alice@example.com", nil); err != nil {
+		t.Fatalf("SetUserQuota reset: %v", err)
+	}
+	if _, _, err := s.AddSecureData(ctx, "alice@example.com", 0, "text", "v1.dddddddddddddddddddd", "{}"); err != nil {
+		t.Fatalf("AddSecureData after reset: %v", err)
+	}
+	usage, err = s.SelectUsage(ctx, "alice@example.com")
+	if err != nil || usage.Records != 3 || usage.Custom || usage.Quota != (structs.Quota{}) {
+		t.Fatalf("SelectUsage after reset = %+v, %v", usage, err)
+	}
+}
+
+func testAudit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	register(t, s, "alice@example.com")
+
+	start := time.Now().Add(-time.Minute)
+	events := []structs.AuditEvent{
+		{Username: "alice@example.com", Event: structs.AuditChallenge, Action: "GET /login", Success: true, IP: "10.0.0.1", UserAgent: "test"},
+		{Username: "bob@example.com", Event: structs.AuditLogin, Action: "POST /login", Details: "invalid challenge"},
+		{Username: "alice@example.com", Event: structs.AuditLogin, Action: "POST /login", Success: true},
+		{Username: "alice@example.com", Event: structs.AuditMutation, Action: "POST /update", Success: true, Details: "ADD 1"},
+		{Username: "alice@example.com", Event: structs.AuditLogout, Action: "POST /logout", Success: true,
+			CreatedAt: start.Add(-time.Hour)},
+	}
+	for _, e := range events {
+		if err := s.AddAuditEvent(ctx, e); err != nil {
+			t.Fatalf("AddAuditEvent: %v", err)
+		}
+	}
+
+	// события пользователя от новых к старым
+	page, err := s.SelectAuditEvents(ctx, "alice@example.com", 0, 3)
+	if err != nil || len(page) != 3 || page[0].Event != structs.AuditLogout || page[2].Event != structs.AuditLogin {
+		t.Fatalf("SelectAuditEvents = %+v, %v", page, err)
+	}
+	if page[0].ID <= page[1].ID || page[0].CreatedAt.Sub(events[4].CreatedAt).Abs() > time.Millisecond {
+		t.Fatalf("SelectAuditEvents order or time = %+v", page)
+	}
+	if page[1].Details != "ADD 1" || page[1].CreatedAt.Before(start) {
+		t.Fatalf("SelectAuditEvents mutation = %+v", page[1])
+	}
+	rest, err := s.SelectAuditEvents(ctx, "alice@example.com", page[2].ID, 3)
+	if err != nil || len(rest) != 1 || rest[0].IP != "10.0.0.1" || rest[0].UserAgent != "test" || !rest[0].Success {
+		t.Fatalf("SelectAuditEvents next page = %+v, %v", rest, err)
+	}
+
+	// выгрузка по порядку ID с фильтрами
+	export := func(filter storage.AuditFilter) []string {
+		t.Helper()
+		var result []string
+		var last int64
+		err := s.ExportAuditEvents(ctx, filter, func(e structs.AuditEvent) error {
+			if e.ID <= last {
+				t.Fatalf("ExportAuditEvents out of order: %d after %d", e.ID, last)
+			}
+			last = e.ID
+			result = append(result, e.Username+" "+e.Event)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ExportAuditEvents: %v", err)
+		}
+		return result
+	}
+	// событие незарегистрированного адреса не записывается
+	if got := export(storage.AuditFilter{}); len(got) != 4 || got[1] != "alice@example.com login" {
+		t.Fatalf("ExportAuditEvents all = %v", got)
+	}
+	if got := export(storage.AuditFilter{Username: "bob@example.com"}); len(got) != 0 {
+		t.Fatalf("ExportAuditEvents by user = %v", got)
+	}
+	if got := export(storage.AuditFilter{Since: start}); len(got) != 3 {
+		t.Fatalf("ExportAuditEvents since = %v", got)
+	}
+	if got := export(storage.AuditFilter{Username: "alice@example.com", Until: start}); len(got) != 1 || got[0] != "alice@example.com logout" {
+		t.Fatalf("ExportAuditEvents until = %v", got)
+	}
+
+	// ошибка fn прерывает выгрузку
+	stop := errors.New("stop")
+	calls := 0
+	err = s.ExportAuditEvents(ctx, storage.AuditFilter{}, func(structs.AuditEvent) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("ExportAuditEvents with error = %v after %d calls", err, calls)
+	}
+
+	// события удаляются вместе с пользователем и не переходят к новой учётной записи с тем же адресом
+	if err := s.DeleteUser(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	register(t, s, "alice@example.com")
+	page, err = s.SelectAuditEvents(ctx, "alice@example.com", 0, 10)
+	if err != nil || len(page) != 0 {
+		t.Fatalf("SelectAuditEvents after DeleteUser = %+v, %v", page, err)
+	}
+}
+
+func syncAll(t *testing.T, s storage.Storage, username string) []structs.SecureData {
+	t.Helper()
+	var result []structs.SecureData
+	var last int64
+	for {
+		page, err := s.SelectUpdatedSecureData(context.Background(), last, username, 2)
+		if err != nil {
+			t.Fatalf("SelectUpdatedSecureData: %v", err)
+		}
+		result = append(result, page...)
+		if len(page) < 2 {
+			return result
+		}
+		last = max(page[len(page)-1].HistoryID, page[len(page)-1].SyncID)
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/stepanov-ds/GophKeeper/internal/utils/structs"
+)
+
+// Vaults - организации и именованные хранилища записей. Записи личных хранилищ принадлежат
+// пользователю; записи хранилищ организации доступны всем её участникам: владельцы, администраторы
+// и участники изменяют и удаляют их, участники только для чтения - читают. Записи хранилищ
+// организации попадают в SelectUpdatedSecureData участника с тем же ID и history_id, что у всех;
+// при вступлении в организацию - все сразу (с history_id вступления), после исключения -
+// неактивными и без содержимого (с history_id исключения)
+type Vaults interface {
+	// CreateOrganization - организация с пользователем username в роли владельца
+	CreateOrganization(ctx context.Context, username string, name string, wrappedKey string) (structs.Organization, error)
+	// SelectOrganizations - организации пользователя с его ролью в порядке ID
+	SelectOrganizations(ctx context.Context, username string) ([]structs.Organization, error)
+	// SelectMembers - участники организации; ErrNotFound, если пользователь в неё не входит
+	SelectMembers(ctx context.Context, orgID int64, username string) ([]structs.Member, error)
+	// SetMember - добавление участника member или изменение его роли владельцем или администратором.
+	// ErrNotFound, если организации или пользователя member нет; ErrForbidden, если роли username
+	// недостаточно или в организации не остаётся владельца
+	SetMember(ctx context.Context, orgID int64, username string, member string, role string, wrappedKey string) (historyID int64, err error)
+	// RemoveMember - исключение участника владельцем или администратором либо выход из организации
+	// (member = username); ошибки - как в SetMember
+	RemoveMember(ctx context.Context, orgID int64, username string, member string) (historyID int64, err error)
+	// CreateVault - личное хранилище (orgID = 0) или хранилище организации, которое создаёт её
+	// владелец или администратор
+	CreateVault(ctx context.Context, username string, orgID int64, name string) (structs.Vault, error)
+	// SelectVaults - личные хранилища пользователя и хранилища его организаций в порядке ID
+	SelectVaults(ctx context.Context, username string) ([]structs.Vault, error)
+}
@@ -0,0 +1,177 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Options - параметры TLS сервера
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile - CA для проверки клиентских сертификатов (mTLS); пустое значение отключает проверку
+	ClientCAFile string
+	// SelfSigned - режим разработки: сертификат генерируется при старте
+	SelfSigned bool
+	// Hosts - имена и адреса для самоподписанного сертификата
+	Hosts []string
+	// ReloadInterval - период проверки изменения файлов; перечитать их можно также сигналом SIGHUP
+	ReloadInterval time.Duration
+}
+
+// Reloader - сертификат сервера и CA клиентов, которые можно заменить без перезапуска
+type Reloader struct {
+	opts Options
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// New - загрузка сертификатов и запуск их перечитывания
+func New(opts Options) (*Reloader, error) {
+	r := &Reloader{
+		opts:     opts,
+		modTimes: make(map[string]time.Time),
+	}
+
+	if opts.SelfSigned {
+		cert, err := SelfSigned(opts.Hosts)
+		if err != nil {
+			return nil, err
+		}
+		r.cert = &cert
+		log.Println("TLS: using generated self-signed certificate, do not use it in production")
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	go r.watch()
+	return r, nil
+}
+
+// Config - конфигурация TLS для http.Server и gRPC.
+// Сертификат и CA берутся при каждом рукопожатии, поэтому перечитывание не требует перезапуска.
+func (r *Reloader) Config() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if r.opts.ClientCAFile != "" {
+		// цепочка проверяется в VerifyConnection по текущему набору CA
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyConnection = r.verifyClient
+	}
+	return config
+}
+
+func (r *Reloader) verifyClient(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("client certificate required")
+	}
+
+	r.mu.RLock()
+	roots := r.clientCA
+	r.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %w", err)
+	}
+	return nil
+}
+
+// load - чтение файлов, изменившихся с прошлой загрузки
+func (r *Reloader) load() error {
+	if !r.opts.SelfSigned && (r.changed(r.opts.CertFile) || r.changed(r.opts.KeyFile)) {
+		cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("error while loading TLS certificate: %w", err)
+		}
+		r.mu.Lock()
+		r.cert = &cert
+		r.mu.Unlock()
+		r.remember(r.opts.CertFile, r.opts.KeyFile)
+	}
+
+	if r.opts.ClientCAFile != "" && r.changed(r.opts.ClientCAFile) {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("error while reading client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.opts.ClientCAFile)
+		}
+		r.mu.Lock()
+		r.clientCA = pool
+		r.mu.Unlock()
+		r.remember(r.opts.ClientCAFile)
+	}
+
+	return nil
+}
+
+func (r *Reloader) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		// ошибку покажет загрузка файла
+		return true
+	}
+	return !info.ModTime().Equal(r.modTimes[path])
+}
+
+func (r *Reloader) remember(paths ...string) {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			r.modTimes[path] = info.ModTime()
+		}
+	}
+}
+
+// watch - перечитывание по SIGHUP и по таймеру; при ошибке остаются прежние сертификаты
+func (r *Reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if r.opts.ReloadInterval > 0 {
+		ticker := time.NewTicker(r.opts.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			// по сигналу файлы перечитываются даже без изменения времени модификации
+			clear(r.modTimes)
+		case <-tick:
+		}
+		if err := r.load(); err != nil {
+			log.Printf("TLS: reload failed, keeping previous certificates: %v\n", err)
+		}
+	}
+}
@@ -1,7 +1,10 @@
-package contextKeys
-
-type ContextKey string
-
-const (
-	Transaction ContextKey = "transaction"
+package contextKeys
+
+type ContextKey string
+
+const (
+	Transaction ContextKey = "transaction"
+	Login ContextKey = "login"
+	Session ContextKey = "session"
+	ExpiresAt ContextKey = "expiresAt"
 )
\ No newline at end of file
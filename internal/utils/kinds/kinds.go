@@ -0,0 +1,234 @@
+package kinds
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы записей. Секретная часть записи (data) зашифрована на клиенте, поэтому сервер
+// проверяет только открытую часть (metadata) и, если клиент согласен, payload проверки.
+const (
+	Credentials = "credentials"
+	Text        = "text"
+	Binary      = "binary"
+	Card        = "card"
+	OTP         = "otp"
+)
+
+// Default - тип записи, если клиент его не указал
+const Default = Text
+
+var all = []string{Credentials, Text, Binary, Card, OTP}
+
+// Valid - проверка, что kind - известный тип записи
+func Valid(kind string) bool {
+	for _, k := range all {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate - проверка типа записи, открытой части и, если клиент передал payload, секретной части
+func Validate(kind string, metadata []byte, payload []byte, now time.Time) error {
+	if !Valid(kind) {
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	if err := ValidateMetadata(kind, metadata); err != nil {
+		return fmt.Errorf("invalid %s metadata: %w", kind, err)
+	}
+	if len(payload) != 0 {
+		if err := ValidatePayload(kind, payload, now); err != nil {
+			return fmt.Errorf("%s validation failed: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+type credentialsMetadata struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Login string `json:"login"`
+}
+
+type textMetadata struct {
+	Title string `json:"title"`
+}
+
+type binaryMetadata struct {
+	Title       string `json:"title"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        *int64 `json:"size"`
+}
+
+type cardMetadata struct {
+	Title  string `json:"title"`
+	Brand  string `json:"brand"`
+	Last4  string `json:"last4"`
+	Expiry string `json:"expiry"`
+}
+
+type otpMetadata struct {
+	Title     string `json:"title"`
+	Issuer    string `json:"issuer"`
+	Account   string `json:"account"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+}
+
+// ValidateMetadata - проверка открытой части записи для типа kind.
+// Неизвестные поля допускаются, известные должны иметь правильный тип и формат.
+func ValidateMetadata(kind string, metadata []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(metadata, &object); err != nil || object == nil {
+		return fmt.Errorf("metadata must be a JSON object")
+	}
+
+	switch kind {
+	case Credentials:
+		var m credentialsMetadata
+		return unmarshal(metadata, &m)
+	case Text:
+		var m textMetadata
+		return unmarshal(metadata, &m)
+	case Binary:
+		var m binaryMetadata
+		if err := unmarshal(metadata, &m); err != nil {
+			return err
+		}
+		if m.Size != nil && *m.Size < 0 {
+			return fmt.Errorf("metadata.size must not be negative")
+		}
+		return nil
+	case Card:
+		var m cardMetadata
+		if err := unmarshal(metadata, &m); err != nil {
+			return err
+		}
+		if m.Last4 != "" && (len(m.Last4) != 4 || !digits(m.Last4)) {
+			return fmt.Errorf("metadata.last4 must be 4 digits")
+		}
+		if m.Expiry != "" {
+			if _, err := parseExpiry(m.Expiry); err != nil {
+				return fmt.Errorf("metadata.expiry: %w", err)
+			}
+		}
+		return nil
+	case OTP:
+		var m otpMetadata
+		if err := unmarshal(metadata, &m); err != nil {
+			return err
+		}
+		switch strings.ToUpper(m.Algorithm) {
+		case "", "SHA1", "SHA256", "SHA512":
+		default:
+			return fmt.Errorf("metadata.algorithm must be SHA1, SHA256 or SHA512")
+		}
+		if m.Digits != 0 && m.Digits != 6 && m.Digits != 8 {
+			return fmt.Errorf("metadata.digits must be 6 or 8")
+		}
+		if m.Period < 0 {
+			return fmt.Errorf("metadata.period must be positive")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+}
+
+// cardPayload - данные карты, которые клиент передаёт только для проверки; сервер их не сохраняет
+type cardPayload struct {
+	Number string `json:"number"`
+	Expiry string `json:"expiry"`
+}
+
+// otpPayload - секрет OTP, который клиент передаёт только для проверки; сервер его не сохраняет
+type otpPayload struct {
+	Secret string `json:"secret"`
+}
+
+// ValidatePayload - необязательная серверная проверка секретной части записи.
+// Клиент сам решает, раскрывать ли эти данные серверу.
+func ValidatePayload(kind string, payload []byte, now time.Time) error {
+	switch kind {
+	case Card:
+		var p cardPayload
+		if err := unmarshal(payload, &p); err != nil {
+			return err
+		}
+		number := strings.ReplaceAll(strings.ReplaceAll(p.Number, " ", ""), "-", "")
+		if len(number) < 12 || len(number) > 19 || !digits(number) || !luhn(number) {
+			return fmt.Errorf("invalid card number")
+		}
+		expiry, err := parseExpiry(p.Expiry)
+		if err != nil {
+			return fmt.Errorf("expiry: %w", err)
+		}
+		if now.After(expiry) {
+			return fmt.Errorf("card is expired")
+		}
+		return nil
+	case OTP:
+		var p otpPayload
+		if err := unmarshal(payload, &p); err != nil {
+			return err
+		}
+		secret := strings.ToUpper(strings.ReplaceAll(p.Secret, " ", ""))
+		raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+		if err != nil || len(raw) == 0 {
+			return fmt.Errorf("OTP secret must be base32")
+		}
+		return nil
+	default:
+		return fmt.Errorf("server validation is not supported for kind %q", kind)
+	}
+}
+
+func unmarshal(raw []byte, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid fields: %w", err)
+	}
+	return nil
+}
+
+// parseExpiry - срок действия карты в формате MM/YY, карта действует до конца месяца
+func parseExpiry(s string) (time.Time, error) {
+	t, err := time.Parse("01/06", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be MM/YY")
+	}
+	return t.AddDate(0, 1, 0).Add(-time.Nanosecond), nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhn - проверка контрольной цифры номера карты
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,133 @@
+package kinds
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{"4111111111111111", true},
+		{"5555555555554444", true},
+		{"378282246310005", true},
+		{"79927398713", true},
+		{"4111111111111112", false},
+		{"5555555555554443", false},
+		{"79927398710", false},
+		{"1234567812345678", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.number); got != tt.valid {
+			t.Errorf("luhn(%q) = %v, expected %v", tt.number, got, tt.valid)
+		}
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		expiry   string
+		expected time.Time
+		err      bool
+	}{
+		// карта действует до последней наносекунды месяца
+		{expiry: "01/25", expected: time.Date(2025, 1, 31, 23, 59, 59, 999999999, time.UTC)},
+		{expiry: "02/24", expected: time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC)},
+		{expiry: "02/25", expected: time.Date(2025, 2, 28, 23, 59, 59, 999999999, time.UTC)},
+		{expiry: "04/30", expected: time.Date(2030, 4, 30, 23, 59, 59, 999999999, time.UTC)},
+		{expiry: "12/25", expected: time.Date(2025, 12, 31, 23, 59, 59, 999999999, time.UTC)},
+		{expiry: "00/25", err: true},
+		{expiry: "13/25", err: true},
+		{expiry: "1/25", err: true},
+		{expiry: "12/2025", err: true},
+		{expiry: "12-25", err: true},
+		{expiry: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseExpiry(tt.expiry)
+		if (err != nil) != tt.err {
+			t.Errorf("parseExpiry(%q) error = %v, expected error %v", tt.expiry, err, tt.err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("parseExpiry(%q) = %v, expected %v", tt.expiry, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		kind     string
+		metadata string
+		valid    bool
+	}{
+		{Text, `{"title": "note"}`, true},
+		{Text, `{"title": "note", "extra": [1, 2]}`, true},
+		{Text, `{}`, true},
+		{Text, `{"title": 1}`, false},
+		{Text, `[]`, false},
+		{Text, `null`, false},
+		{Text, `"title"`, false},
+		{Credentials, `{"title": "mail", "url": "https://example.com", "login": "alice"}`, true},
+		{Credentials, `{"login": true}`, false},
+		{Binary, `{"fileName": "a.pdf", "contentType": "application/pdf", "size": 0}`, true},
+		{Binary, `{"size": -1}`, false},
+		{Binary, `{"size": "1"}`, false},
+		{Card, `{"brand": "visa", "last4": "1111", "expiry": "12/29"}`, true},
+		{Card, `{"last4": "111"}`, false},
+		{Card, `{"last4": "11a1"}`, false},
+		{Card, `{"expiry": "13/29"}`, false},
+		{Card, `{"expiry": "12/2029"}`, false},
+		{OTP, `{"issuer": "example", "algorithm": "sha256", "digits": 8, "period": 60}`, true},
+		{OTP, `{"algorithm": "SHA512", "digits": 6}`, true},
+		{OTP, `{"algorithm": "MD5"}`, false},
+		{OTP, `{"digits": 7}`, false},
+		{OTP, `{"period": -30}`, false},
+		{"unknown", `{}`, false},
+	}
+	for _, tt := range tests {
+		err := ValidateMetadata(tt.kind, []byte(tt.metadata))
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateMetadata(%s, %s) = %v, expected valid %v", tt.kind, tt.metadata, err, tt.valid)
+		}
+	}
+}
+
+func TestValidatePayload(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		kind    string
+		payload string
+		valid   bool
+	}{
+		{Card, `{"number": "4111111111111111", "expiry": "12/29"}`, true},
+		{Card, `{"number": "4111 1111 1111 1111", "expiry": "12/29"}`, true},
+		{Card, `{"number": "5555-5555-5555-4444", "expiry": "12/29"}`, true},
+		// срок истекает в конце месяца, а не в его начале
+		{Card, `{"number": "4111111111111111", "expiry": "06/25"}`, true},
+		{Card, `{"number": "4111111111111111", "expiry": "05/25"}`, false},
+		{Card, `{"number": "4111111111111112", "expiry": "12/29"}`, false},
+		{Card, `{"number": "42424242", "expiry": "12/29"}`, false},
+		{Card, `{"number": "41111111111111111111", "expiry": "12/29"}`, false},
+		{Card, `{"number": "4111x11111111111", "expiry": "12/29"}`, false},
+		{Card, `{"number": "4111111111111111", "expiry": "12/29/30"}`, false},
+		{Card, `{"number": 4111111111111111}`, false},
+		{OTP, `{"secret": "JBSWY3DPEHPK3PXP"}`, true},
+		{OTP, `{"secret": "jbswy3dpehpk3pxp"}`, true},
+		{OTP, `{"secret": "JBSW Y3DP EHPK 3PXP"}`, true},
+		{OTP, `{"secret": "MZXW6==="}`, true},
+		{OTP, `{"secret": "JBSWY3DP1"}`, false},
+		{OTP, `{"secret": "JBSWY3DP!"}`, false},
+		{OTP, `{"secret": "A"}`, false},
+		{OTP, `{"secret": ""}`, false},
+		{Text, `{"secret": "JBSWY3DPEHPK3PXP"}`, false},
+	}
+	for _, tt := range tests {
+		err := ValidatePayload(tt.kind, []byte(tt.payload), now)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidatePayload(%s, %s) = %v, expected valid %v", tt.kind, tt.payload, err, tt.valid)
+		}
+	}
+}
@@ -63,6 +63,13 @@ func (mc *MemoryCache) Delete(key string) {
 	delete(mc.items, key)
 }
 
+// Len - число элементов в кэше, включая просроченные, которые ещё не очищены
+func (mc *MemoryCache) Len() int {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return len(mc.items)
+}
+
 // cleanup - очистка просроченных элементов из кэша
 func (mc *MemoryCache) cleanup(n time.Duration) {
 	ticker := time.NewTicker(n) // проверяем кэш через каждые n времени
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter - ограничение частоты событий по ключу (token bucket):
+// не более limit событий подряд, затем limit событий за period
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	rate    float64 // токенов в секунду
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter - ограничитель с очисткой заполненных корзин каждые cleanupTime; limit <= 0 отключает ограничение
+func NewRateLimiter(limit int, period time.Duration, cleanupTime time.Duration) *RateLimiter {
+	rl := &RateLimiter{
+		limit:   float64(limit),
+		rate:    float64(limit) / period.Seconds(),
+		buckets: make(map[string]*bucket),
+	}
+	if limit > 0 {
+		go rl.cleanup(cleanupTime)
+	}
+	return rl
+}
+
+// Allow - учёт события для key; если лимит исчерпан, событие не учитывается
+// и возвращается время, через которое можно повторить
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if rl.limit <= 0 {
+		return true, 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	b, found := rl.buckets[key]
+	if !found {
+		b = &bucket{tokens: rl.limit, updated: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(rl.limit, b.tokens+now.Sub(b.updated).Seconds()*rl.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup - удаление корзин, которые успели заполниться (эквивалентны отсутствующим)
+func (rl *RateLimiter) cleanup(n time.Duration) {
+	ticker := time.NewTicker(n)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.mu.Lock()
+		for key, b := range rl.buckets {
+			if b.tokens+time.Since(b.updated).Seconds()*rl.rate >= rl.limit {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
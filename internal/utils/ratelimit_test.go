@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	rl := NewRateLimiter(3, 3*time.Second, time.Hour)
+
+	for i := range 3 {
+		if ok, wait := rl.Allow("a"); !ok || wait != 0 {
+			t.Fatalf("event %d: Allow = %v, %v", i, ok, wait)
+		}
+	}
+	// корзина пуста: один токен восстанавливается за period / limit
+	ok, wait := rl.Allow("a")
+	if ok || wait <= 900*time.Millisecond || wait > time.Second {
+		t.Fatalf("over limit: Allow = %v, %v", ok, wait)
+	}
+	// отклонённое событие не расходует токен
+	if _, again := rl.Allow("a"); again < wait-100*time.Millisecond || again > wait {
+		t.Fatalf("retry after %v, then %v", wait, again)
+	}
+	if ok, _ := rl.Allow("b"); !ok {
+		t.Fatal("other key is limited")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := NewRateLimiter(3, 3*time.Second, time.Hour)
+	for range 3 {
+		rl.Allow("a")
+	}
+	rewind := func(d time.Duration) {
+		rl.mu.Lock()
+		rl.buckets["a"].updated = rl.buckets["a"].updated.Add(-d)
+		rl.mu.Unlock()
+	}
+
+	// за половину шага восстанавливается половина токена
+	rewind(500 * time.Millisecond)
+	ok, wait := rl.Allow("a")
+	if ok || wait <= 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Fatalf("half token: Allow = %v, %v", ok, wait)
+	}
+
+	rewind(2 * time.Second)
+	for i := range 2 {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("refilled event %d rejected", i)
+		}
+	}
+	if ok, _ := rl.Allow("a"); ok {
+		t.Fatal("more events than refilled tokens")
+	}
+
+	// корзина заполняется не больше чем до limit
+	rewind(time.Hour)
+	for i := range 3 {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("event %d after full refill rejected", i)
+		}
+	}
+	if ok, _ := rl.Allow("a"); ok {
+		t.Fatal("bucket refilled over limit")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	rl := NewRateLimiter(0, time.Second, time.Hour)
+	for i := range 100 {
+		if ok, wait := rl.Allow("a"); !ok || wait != 0 {
+			t.Fatalf("event %d: Allow = %v, %v", i, ok, wait)
+		}
+	}
+}
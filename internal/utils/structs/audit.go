@@ -0,0 +1,29 @@
+package structs
+
+import "time"
+
+// События журнала аудита
+const (
+	// AuditChallenge - запрос кода входа
+	AuditChallenge = "challenge"
+	// AuditLogin - вход по коду (неудачный - с причиной в Details)
+	AuditLogin  = "login"
+	AuditLogout = "logout"
+	// AuditMutation - изменяющий запрос к API
+	AuditMutation = "mutation"
+)
+
+// AuditEvent - событие журнала аудита
+type AuditEvent struct {
+	ID       int64  `json:"ID"`
+	Username string `json:"username"`
+	Event    string `json:"event"`
+	// Action - маршрут REST ("POST /update") или метод gRPC, через который выполнено действие
+	Action  string `json:"action"`
+	Success bool   `json:"success"`
+	// Details - параметры действия или причина ошибки
+	Details   string    `json:"details,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
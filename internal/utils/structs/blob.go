@@ -0,0 +1,12 @@
+package structs
+
+// Blob - бинарное содержимое записи, загружаемое частями
+type Blob struct {
+	ID           int64  `json:"ID"`
+	SecureDataID int64  `json:"secureDataID"`
+	Size         int64  `json:"size"`
+	Received     int64  `json:"received"`
+	SHA256       string `json:"sha256"`
+	IsComplete   bool   `json:"isComplete"`
+	HistoryID    int64  `json:"historyID"`
+}
@@ -0,0 +1,13 @@
+package structs
+
+import "time"
+
+// IdempotentRequest - запрос, выполненный с заголовком Idempotency-Key, и сохранённый ответ на него
+type IdempotentRequest struct {
+	// RequestHash - хэш метода, пути и тела запроса
+	RequestHash string
+	// Status - код ответа; 0, пока запрос выполняется
+	Status    int
+	Body      []byte
+	CreatedAt time.Time
+}
@@ -0,0 +1,8 @@
+package structs
+
+// OperationResult - результат одной операции пакетного изменения
+type OperationResult struct {
+	Type         string `json:"type"`
+	SecureDataID int64  `json:"SecureDataID,omitempty"`
+	HistoryID    int64  `json:"historyID,omitempty"`
+}
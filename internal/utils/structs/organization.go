@@ -0,0 +1,62 @@
+package structs
+
+import "time"
+
+const (
+	// RoleOwner - управляет участниками, в том числе владельцами, и хранилищами организации
+	RoleOwner = "owner"
+	// RoleAdmin - управляет участниками (кроме владельцев) и хранилищами организации
+	RoleAdmin = "admin"
+	// RoleMember - читает и изменяет записи хранилищ организации
+	RoleMember = "member"
+	// RoleReadOnly - только читает записи хранилищ организации
+	RoleReadOnly = "read-only"
+)
+
+// Organization - организация, участником которой является пользователь. WrappedKey - ключ
+// организации, зашифрованный открытым ключом участника (vault.SealKey); им шифруются записи
+// хранилищ организации
+type Organization struct {
+	ID         int64     `json:"ID"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	WrappedKey string    `json:"wrappedKey"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Member - участник организации
+type Member struct {
+	OrgID     int64     `json:"orgID"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Vault - именованное хранилище записей: личное (OrgID = 0) или хранилище организации.
+// Основное личное хранилище не создаётся явно, его записи имеют VaultID = 0
+type Vault struct {
+	ID        int64     `json:"ID"`
+	Name      string    `json:"name"`
+	OrgID     int64     `json:"orgID,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrganizationRequest - создание организации (POST /orgs); WrappedKey - ключ организации,
+// зашифрованный открытым ключом создателя
+type OrganizationRequest struct {
+	Name       string `json:"name"`
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// MemberRequest - добавление участника или изменение его роли (PUT /orgs/:id/members/:username);
+// WrappedKey - ключ организации, зашифрованный открытым ключом участника
+type MemberRequest struct {
+	Role       string `json:"role"`
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// VaultRequest - создание хранилища (POST /vaults); OrgID = 0 - личное хранилище
+type VaultRequest struct {
+	Name  string `json:"name"`
+	OrgID int64  `json:"orgID,omitempty"`
+}
@@ -0,0 +1,31 @@
+package structs
+
+// Названия квот в QuotaError и в ответе 413
+const (
+	QuotaRecords    = "records"
+	QuotaBytes      = "bytes"
+	QuotaRecordSize = "recordSize"
+)
+
+// Quota - ограничения записей пользователя; 0 - без ограничения.
+// Размер записи - длина данных и метаданных в байтах вместе с размером её текущего файла; учитываются активные записи, автором которых
+// является пользователь (в том числе в хранилищах организаций)
+type Quota struct {
+	// Records - число активных записей
+	Records int64 `json:"records"`
+	// Bytes - суммарный размер активных записей и их файлов, включая файлы хранимых ревизий и незавершённые загрузки
+	Bytes int64 `json:"bytes"`
+	// RecordSize - размер одной записи
+	RecordSize int64 `json:"recordSize"`
+	// Revisions - число хранимых ревизий каждой записи (верхняя граница HistoryRetention пользователя)
+	Revisions int `json:"revisions"`
+}
+
+// Usage - объём активных записей пользователя и действующие для него квоты
+type Usage struct {
+	Records int64 `json:"records"`
+	Bytes   int64 `json:"bytes"`
+	Quota   Quota `json:"quota"`
+	// Custom - квоты заданы администратором для этого пользователя
+	Custom bool `json:"custom"`
+}
@@ -7,4 +7,39 @@ type Response struct {
     HistoryID int64 `json:"historyID,omitempty"`
 	SecureData []SecureData `json:"secureData,omitempty"`
 	FullySynced bool `json:"fullySynced,omitempty"`
+	// Sync - страница синхронизации v2; есть в каждом успешном ответе /v2/sync
+	Sync *SyncPage `json:"sync,omitempty"`
+	// NextCursor - курсор следующей страницы поиска; пустой на последней странице
+	NextCursor string `json:"nextCursor,omitempty"`
+	Keys *UserKeys `json:"keys,omitempty"`
+	// PublicKey - открытый ключ получателя общей записи
+	PublicKey string `json:"publicKey,omitempty"`
+	Shares []Share `json:"shares,omitempty"`
+	Organizations []Organization `json:"organizations,omitempty"`
+	Organization *Organization `json:"organization,omitempty"`
+	Members []Member `json:"members,omitempty"`
+	Vaults []Vault `json:"vaults,omitempty"`
+	Vault *Vault `json:"vault,omitempty"`
+	Blob *Blob `json:"blob,omitempty"`
+	// Results - результаты операций пакетного изменения в порядке запроса
+	Results []OperationResult `json:"results,omitempty"`
+	// Current - актуальное состояние записи при конфликте версий (409)
+	Current *SecureData `json:"current,omitempty"`
+	Revisions []Revision `json:"revisions,omitempty"`
+	Revision *Revision `json:"revision,omitempty"`
+	HistoryRetention *HistoryRetention `json:"historyRetention,omitempty"`
+	Sessions []Session `json:"sessions,omitempty"`
+	// Pending - регистрация ждёт подтверждения адреса
+	Pending bool `json:"pending,omitempty"`
+	// TOTPRequired - для входа нужен код второго фактора
+	TOTPRequired bool `json:"totpRequired,omitempty"`
+	TOTP *TOTPStatus `json:"totp,omitempty"`
+	Users []UserInfo `json:"users,omitempty"`
+	User *UserInfo `json:"user,omitempty"`
+	// Revoked - число отозванных сессий
+	Revoked int64 `json:"revoked,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+	// QuotaExceeded - квота, которую превысило бы изменение (413): records, bytes или recordSize
+	QuotaExceeded string `json:"quotaExceeded,omitempty"`
+	AuditEvents []AuditEvent `json:"auditEvents,omitempty"`
 }
@@ -0,0 +1,24 @@
+package structs
+
+import "time"
+
+// Revision - снимок записи после изменения HistoryID
+type Revision struct {
+	HistoryID    int64     `json:"historyID"`
+	SecureDataID int64     `json:"secureDataID"`
+	Method       string    `json:"method"`
+	Data         string    `json:"data"`
+	Metadata     string    `json:"metadata"`
+	Kind         string    `json:"kind"`
+	IsActive     bool      `json:"isActive"`
+	BlobID       int64     `json:"blobID,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// HistoryRetention - число хранимых ревизий каждой записи
+type HistoryRetention struct {
+	// Limit - значение пользователя; 0 - используется Default
+	Limit int `json:"limit"`
+	// Default - квота ревизий пользователя: значение по умолчанию и верхняя граница Limit; 0 - без ограничения
+	Default int `json:"default"`
+}
@@ -0,0 +1,21 @@
+package structs
+
+import "encoding/json"
+
+// SearchQuery - запрос поиска записей (POST /search)
+type SearchQuery struct {
+	// Metadata - JSON-объект, который должны содержать метаданные записи
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Tags - метки, которые все должны быть в массиве "tags" метаданных
+	Tags []string `json:"tags,omitempty"`
+	Kind string   `json:"kind,omitempty"`
+	// State - active (по умолчанию), deleted или all
+	State string `json:"state,omitempty"`
+	// Sort - id (по умолчанию), historyID или metadata.<key>
+	Sort string `json:"sort,omitempty"`
+	// Order - asc (по умолчанию) или desc
+	Order string `json:"order,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+	// Cursor - nextCursor предыдущей страницы с теми же фильтрами и сортировкой
+	Cursor string `json:"cursor,omitempty"`
+}
@@ -1,9 +1,18 @@
-package structs
-
-type SecureData struct {
-	ID int64 `json:"ID"`
-	Data string `json:"data"`
-	Metadata string `json:"metadata"`
-	IsActive bool `json:"isActive"`
-	HistoryID int64 `json:"historyID"`
+package structs
+
+type SecureData struct {
+	ID int64 `json:"ID"`
+	Data string `json:"data"`
+	Metadata string `json:"metadata"`
+	IsActive bool `json:"isActive"`
+	HistoryID int64 `json:"historyID"`
+	Kind string `json:"kind"`
+	BlobID int64 `json:"blobID,omitempty"`
+	// VaultID - хранилище записи; 0 - основное личное хранилище
+	VaultID int64 `json:"vaultID,omitempty"`
+	// SyncID - позиция записи в синхронизации пользователя, если она больше HistoryID: записи хранилищ
+	// организации передаются новому участнику с history_id его вступления
+	SyncID int64 `json:"syncID,omitempty" db:"-"`
+	// Share - доступ к чужой записи, открытый пользователю; nil для собственных записей
+	Share *Share `json:"share,omitempty" db:"-"`
 }
\ No newline at end of file
@@ -0,0 +1,15 @@
+package structs
+
+import "time"
+
+// Session - сессия пользователя (устройство с refresh токеном)
+type Session struct {
+	ID        string    `json:"ID"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Current - сессия, которой выполнен запрос
+	Current bool `json:"current,omitempty" db:"-"`
+}
@@ -0,0 +1,30 @@
+package structs
+
+import "time"
+
+const (
+	// PermissionRead - получатель только читает запись
+	PermissionRead = "ro"
+	// PermissionWrite - получатель может изменять содержимое записи (но не удалять её)
+	PermissionWrite = "rw"
+)
+
+// Share - доступ получателя к записи владельца. WrappedKey - ключ записи, зашифрованный
+// открытым ключом получателя (vault.SealKey)
+type Share struct {
+	SecureDataID int64      `json:"secureDataID"`
+	Owner        string     `json:"owner"`
+	Recipient    string     `json:"recipient"`
+	Permission   string     `json:"permission"`
+	WrappedKey   string     `json:"wrappedKey"`
+	HistoryID    int64      `json:"historyID"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ShareRequest - открытие доступа к записи (POST /records/:id/shares)
+type ShareRequest struct {
+	Recipient  string `json:"recipient"`
+	Permission string `json:"permission"`
+	WrappedKey string `json:"wrappedKey"`
+}
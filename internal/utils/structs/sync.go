@@ -0,0 +1,34 @@
+package structs
+
+// SyncRequest - запрос синхронизации v2 (POST /v2/sync)
+type SyncRequest struct {
+	// Cursor - cursor из предыдущего ответа; пустой - синхронизация с начала
+	Cursor string `json:"cursor,omitempty"`
+	// Snapshot - с пустым курсором: текущие активные записи без удалённых вместо всей истории
+	// изменений; после снимка ответы продолжаются изменениями, сделанными с его начала
+	Snapshot bool `json:"snapshot,omitempty"`
+	Limit    int  `json:"limit,omitempty"`
+}
+
+// SyncPage - страница синхронизации v2
+type SyncPage struct {
+	// Records - записи, изменённые или созданные после курсора запроса, в порядке изменения
+	// (в снимке - в порядке ID)
+	Records []SecureData `json:"records"`
+	// Tombstones - записи, удалённые после курсора запроса
+	Tombstones []Tombstone `json:"tombstones"`
+	// Cursor - курсор для следующего запроса; сохраняется после применения страницы
+	Cursor string `json:"cursor"`
+	// Head - курсор последнего изменения на момент ответа
+	Head string `json:"head"`
+	// HasMore - после Cursor есть ещё изменения (или страницы снимка)
+	HasMore bool `json:"hasMore"`
+	// Snapshot - страница снимка; пока снимок не завершён, удалённые записи не передаются
+	Snapshot bool `json:"snapshot,omitempty"`
+}
+
+// Tombstone - удалённая запись
+type Tombstone struct {
+	ID        int64 `json:"ID"`
+	HistoryID int64 `json:"historyID"`
+}
@@ -0,0 +1,19 @@
+package structs
+
+// TOTP - второй фактор пользователя (RFC 6238)
+type TOTP struct {
+	Secret  string
+	Enabled bool
+	// LastCounter - последний использованный шаг; коды этого и более ранних шагов не принимаются
+	LastCounter     int64
+	BackupCodesLeft int
+}
+
+// TOTPStatus - ответ API о втором факторе; URI, Secret и BackupCodes выдаются только при подключении
+type TOTPStatus struct {
+	Enabled         bool     `json:"enabled"`
+	URI             string   `json:"uri,omitempty"`
+	Secret          string   `json:"secret,omitempty"`
+	BackupCodes     []string `json:"backupCodes,omitempty"`
+	BackupCodesLeft int      `json:"backupCodesLeft"`
+}
@@ -0,0 +1,19 @@
+package structs
+
+import "time"
+
+// UserInfo - учётная запись в административном API
+type UserInfo struct {
+	ID        int64     `json:"ID"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Pending - адрес ещё не подтверждён
+	Pending    bool       `json:"pending,omitempty"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+	// Records - активные записи, созданные пользователем
+	Records int64 `json:"records"`
+	// Sessions - активные сессии
+	Sessions int64 `json:"sessions"`
+	// LastActivity - последнее обращение в любой из сессий; nil, если пользователь не входил
+	LastActivity *time.Time `json:"lastActivity,omitempty"`
+}
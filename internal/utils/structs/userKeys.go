@@ -0,0 +1,17 @@
+package structs
+
+import "github.com/stepanov-ds/GophKeeper/internal/vault"
+
+// UserKeys - параметры KDF и обёрнутый ключ хранилища пользователя
+type UserKeys struct {
+	KDF        vault.KDFParams `json:"kdf"`
+	WrappedKey string          `json:"wrappedKey"`
+	// PublicKey - открытый ключ X25519 для получения общих записей; при сохранении пустой ключ
+	// не заменяет сохранённый
+	PublicKey string `json:"publicKey,omitempty"`
+	// WrappedPrivateKey - закрытый ключ, зашифрованный ключом хранилища
+	WrappedPrivateKey string `json:"wrappedPrivateKey,omitempty"`
+	// Version - номер сохранения ключей; при сохранении - версия, которую заменяет клиент
+	// (0 - ключи ещё не сохранены), чтобы смена пароля на другом устройстве не была потеряна
+	Version int64 `json:"version"`
+}
@@ -0,0 +1,9 @@
+package structs
+
+import "time"
+
+// Verification - ожидаемое подтверждение адреса при регистрации; хранится только хэш кода
+type Verification struct {
+	CodeHash  string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,216 @@
+package vault
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Общие записи шифруются собственным случайным ключом записи вместо ключа хранилища.
+// Ключ записи, обёрнутый ключом хранилища владельца, хранится в самом шифротексте
+// ("r2.<обёрнутый ключ>.<данные>", данные привязаны к ID и типу записи, как в Encrypt;
+// "r1." - без привязки), поэтому владелец расшифровывает запись через Decrypt,
+// а получатель, изменяя запись, сохраняет обёрнутый ключ владельца. Получателю ключ записи
+// передаётся зашифрованным его открытым ключом X25519; закрытый ключ хранится на сервере
+// обёрнутым ключом хранилища.
+
+const (
+	// RecordPrefix - префикс шифротекста общей записи
+	RecordPrefix = "r1."
+	// BoundRecordPrefix - префикс шифротекста общей записи, привязанного к её ID и типу
+	BoundRecordPrefix = "r2."
+	// SealedPrefix - префикс ключа, зашифрованного открытым ключом получателя
+	SealedPrefix = "s1."
+)
+
+var (
+	recordKeyAD  = []byte("gophkeeper record key")
+	privateKeyAD = []byte("gophkeeper private key")
+	sealedInfo   = []byte("gophkeeper sealed key")
+)
+
+// NewKeyPair - пара ключей X25519 для получения общих записей; открытый ключ в base64
+func NewKeyPair() (publicKey string, privateKey []byte, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("error while generating key pair: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), key.Bytes(), nil
+}
+
+// ValidatePublicKey - проверка формата открытого ключа (используется сервером)
+func ValidatePublicKey(publicKey string) error {
+	_, err := parsePublicKey(publicKey)
+	return err
+}
+
+// WrapPrivateKey - шифрование закрытого ключа ключом хранилища
+func WrapPrivateKey(vaultKey []byte, privateKey []byte) (string, error) {
+	return seal(vaultKey, privateKey, privateKeyAD)
+}
+
+// UnwrapPrivateKey - расшифровка закрытого ключа ключом хранилища
+func UnwrapPrivateKey(vaultKey []byte, wrapped string) ([]byte, error) {
+	return open(vaultKey, wrapped, privateKeyAD)
+}
+
+// WrapRecordKey - шифрование ключа записи ключом хранилища владельца
+func WrapRecordKey(vaultKey []byte, recordKey []byte) (string, error) {
+	return seal(vaultKey, recordKey, recordKeyAD)
+}
+
+// UnwrapRecordKey - расшифровка ключа записи ключом хранилища владельца
+func UnwrapRecordKey(vaultKey []byte, wrapped string) ([]byte, error) {
+	return open(vaultKey, wrapped, recordKeyAD)
+}
+
+// RecordKey - обёрнутый ключ общей записи из её шифротекста; ok=false, если запись зашифрована ключом хранилища
+func RecordKey(ciphertext string) (wrapped string, ok bool) {
+	rest, found := strings.CutPrefix(ciphertext, BoundRecordPrefix)
+	if !found {
+		rest, found = strings.CutPrefix(ciphertext, RecordPrefix)
+	}
+	if !found {
+		return "", false
+	}
+	key, _, found := strings.Cut(rest, ".")
+	return Prefix + key, found
+}
+
+// EncryptRecord - шифрование данных общей записи id типа kind ключом записи; wrappedKey - обёрнутый
+// ключ владельца
+func EncryptRecord(recordKey []byte, wrappedKey string, id int64, kind string, plaintext []byte) (string, error) {
+	key, found := strings.CutPrefix(wrappedKey, Prefix)
+	if !found {
+		return "", fmt.Errorf("unsupported wrapped key format")
+	}
+	sealed, err := seal(recordKey, plaintext, recordAD(id, kind))
+	if err != nil {
+		return "", err
+	}
+	return BoundRecordPrefix + key + "." + strings.TrimPrefix(sealed, Prefix), nil
+}
+
+// DecryptRecord - расшифровка данных общей записи id типа kind ключом записи
+func DecryptRecord(recordKey []byte, id int64, kind string, ciphertext string) ([]byte, error) {
+	rest, bound := strings.CutPrefix(ciphertext, BoundRecordPrefix)
+	if !bound {
+		var found bool
+		if rest, found = strings.CutPrefix(ciphertext, RecordPrefix); !found {
+			return nil, fmt.Errorf("record is not encrypted with a record key")
+		}
+	}
+	_, data, found := strings.Cut(rest, ".")
+	if !found {
+		return nil, fmt.Errorf("unsupported ciphertext format")
+	}
+	if bound {
+		return openData(recordKey, id, kind, Prefix+data)
+	}
+	return open(recordKey, Prefix+data, nil)
+}
+
+// SealKey - шифрование ключа открытым ключом получателя (эфемерный X25519, HKDF-SHA256, XChaCha20-Poly1305)
+func SealKey(publicKey string, key []byte) (string, error) {
+	recipient, err := parsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("error while generating ephemeral key: %w", err)
+	}
+	aead, err := sealedAEAD(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error while generating nonce: %w", err)
+	}
+	sealed := append(ephemeral.PublicKey().Bytes(), nonce...)
+	sealed = aead.Seal(sealed, nonce, key, nil)
+	return SealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSealedKey - расшифровка ключа, зашифрованного SealKey, закрытым ключом получателя
+func OpenSealedKey(privateKey []byte, sealed string) ([]byte, error) {
+	private, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	raw, err := decodeSealed(sealed)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(raw[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	aead, err := sealedAEAD(private, ephemeral, ephemeral, private.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	nonce := raw[32 : 32+aead.NonceSize()]
+	key, err := aead.Open(nil, nonce, raw[32+aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening sealed key: %w", err)
+	}
+	return key, nil
+}
+
+// IsSealedKey - проверка формата ключа, зашифрованного SealKey (используется сервером)
+func IsSealedKey(s string) bool {
+	_, err := decodeSealed(s)
+	return err == nil
+}
+
+// sealedAEAD - шифр для ключа из общего секрета private и peer; открытые ключи сторон входят в HKDF
+func sealedAEAD(private *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral *ecdh.PublicKey, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("error while computing shared secret: %w", err)
+	}
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, sealedInfo), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+func parsePublicKey(publicKey string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+func decodeSealed(sealed string) ([]byte, error) {
+	encoded, found := strings.CutPrefix(sealed, SealedPrefix)
+	if !found {
+		return nil, fmt.Errorf("unsupported sealed key format")
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 32+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("sealed key too short")
+	}
+	return raw, nil
+}
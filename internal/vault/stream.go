@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Потоковое шифрование больших бинарных секретов: содержимое делится на сегменты,
+// каждый сегмент шифруется отдельно. Номер сегмента и признак последнего сегмента
+// входят в additional data, поэтому перестановка и обрезка шифротекста обнаруживаются.
+
+// SegmentSize - размер сегмента открытых данных
+const SegmentSize = 64 * 1024
+
+const encryptedSegmentSize = chacha20poly1305.NonceSizeX + SegmentSize + chacha20poly1305.Overhead
+
+// EncryptStream - шифрование src в dst ключом хранилища
+func EncryptStream(vaultKey []byte, dst io.Writer, src io.Reader) error {
+	aead, err := chacha20poly1305.NewX(vaultKey)
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewReaderSize(src, SegmentSize)
+	segment := make([]byte, SegmentSize)
+	out := make([]byte, 0, encryptedSegmentSize)
+
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(in, segment)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("error while reading plaintext: %w", err)
+		}
+		final := n < SegmentSize
+		if !final {
+			// полный сегмент последний, если за ним нет данных
+			if _, peekErr := in.Peek(1); peekErr == io.EOF {
+				final = true
+			}
+		}
+
+		out = out[:aead.NonceSize()]
+		if _, err := rand.Read(out); err != nil {
+			return fmt.Errorf("error while generating nonce: %w", err)
+		}
+		out = aead.Seal(out, out, segment[:n], segmentAD(index, final))
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// DecryptStream - расшифровка src в dst ключом хранилища
+func DecryptStream(vaultKey []byte, dst io.Writer, src io.Reader) error {
+	aead, err := chacha20poly1305.NewX(vaultKey)
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewReaderSize(src, encryptedSegmentSize)
+	segment := make([]byte, encryptedSegmentSize)
+	out := make([]byte, 0, SegmentSize)
+
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(in, segment)
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("ciphertext is truncated")
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("error while reading ciphertext: %w", err)
+		}
+		if n < aead.NonceSize()+aead.Overhead() {
+			return fmt.Errorf("ciphertext is truncated")
+		}
+		final := n < encryptedSegmentSize
+		if !final {
+			if _, peekErr := in.Peek(1); peekErr == io.EOF {
+				final = true
+			}
+		}
+
+		nonce, sealed := segment[:aead.NonceSize()], segment[aead.NonceSize():n]
+		out, err = aead.Open(out[:0], nonce, sealed, segmentAD(index, final))
+		if err != nil {
+			return fmt.Errorf("error while decrypting segment %d: %w", index, err)
+		}
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func segmentAD(index uint64, final bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, index)
+	if final {
+		ad[8] = 1
+	}
+	return ad
+}
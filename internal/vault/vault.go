@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Шифрование выполняется только на клиенте: мастер-пароль через Argon2id даёт мастер-ключ,
+// которым шифруется (оборачивается) случайный ключ хранилища. Сервер хранит параметры KDF,
+// обёрнутый ключ и зашифрованные записи, но не может их расшифровать.
+//
+// Данные записи (формат v2) привязаны к её ID и типу через additional data, поэтому сервер не может
+// незаметно перенести шифротекст в другую запись или выдать его за запись другого типа. ID новой
+// записи назначает сервер: клиент добавляет её с пустой заготовкой, привязанной к ID 0, и сразу
+// заменяет содержимое. Записи формата v1 (без привязки) расшифровываются как прежде.
+
+const (
+	// AlgorithmArgon2id - единственный поддерживаемый алгоритм KDF
+	AlgorithmArgon2id = "argon2id"
+
+	// Prefix - префикс версии формата шифротекста
+	Prefix = "v1."
+	// BoundPrefix - префикс шифротекста данных записи, привязанного к её ID и типу
+	BoundPrefix = "v2."
+
+	KeySize  = chacha20poly1305.KeySize
+	SaltSize = 16
+
+	// минимальные параметры, которые принимает сервер
+	MinTime   = 1
+	MinMemory = 19 * 1024
+)
+
+// additional data для обёрнутого ключа, чтобы его нельзя было подменить записью и наоборот
+var wrapAD = []byte("gophkeeper vault key")
+
+// KDFParams - параметры выработки мастер-ключа из пароля
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"`
+	Threads   uint8  `json:"threads"`
+}
+
+// NewKDFParams - параметры по умолчанию со случайной солью
+func NewKDFParams() (KDFParams, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFParams{}, fmt.Errorf("error while generating salt: %w", err)
+	}
+	return KDFParams{
+		Algorithm: AlgorithmArgon2id,
+		Salt:      salt,
+		Time:      3,
+		Memory:    64 * 1024,
+		Threads:   4,
+	}, nil
+}
+
+// Validate - проверка, что параметры поддерживаются и не слабее минимальных
+func (p KDFParams) Validate() error {
+	if p.Algorithm != AlgorithmArgon2id {
+		return fmt.Errorf("unsupported KDF algorithm %q", p.Algorithm)
+	}
+	if len(p.Salt) < SaltSize {
+		return fmt.Errorf("salt must be at least %d bytes", SaltSize)
+	}
+	if p.Time < MinTime || p.Memory < MinMemory || p.Threads == 0 {
+		return fmt.Errorf("KDF parameters are too weak")
+	}
+	return nil
+}
+
+// DeriveKey - выработка мастер-ключа из пароля
+func DeriveKey(password string, p KDFParams) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey([]byte(password), p.Salt, p.Time, p.Memory, p.Threads, KeySize), nil
+}
+
+// NewVaultKey - новый случайный ключ хранилища
+func NewVaultKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error while generating vault key: %w", err)
+	}
+	return key, nil
+}
+
+// WrapKey - шифрование ключа хранилища мастер-ключом
+func WrapKey(masterKey []byte, vaultKey []byte) (string, error) {
+	return seal(masterKey, vaultKey, wrapAD)
+}
+
+// UnwrapKey - расшифровка ключа хранилища; ошибка означает неверный мастер-пароль
+func UnwrapKey(masterKey []byte, wrapped string) ([]byte, error) {
+	key, err := open(masterKey, wrapped, wrapAD)
+	if err != nil {
+		return nil, fmt.Errorf("wrong master password or corrupted key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid vault key size")
+	}
+	return key, nil
+}
+
+// Encrypt - шифрование данных записи id типа kind ключом хранилища (0 - заготовка новой записи)
+func Encrypt(vaultKey []byte, id int64, kind string, plaintext []byte) (string, error) {
+	sealed, err := seal(vaultKey, plaintext, recordAD(id, kind))
+	if err != nil {
+		return "", err
+	}
+	return BoundPrefix + strings.TrimPrefix(sealed, Prefix), nil
+}
+
+// Decrypt - расшифровка данных записи id типа kind ключом хранилища; общая запись владельца
+// расшифровывается ключом записи из шифротекста
+func Decrypt(vaultKey []byte, id int64, kind string, ciphertext string) ([]byte, error) {
+	if wrapped, ok := RecordKey(ciphertext); ok {
+		recordKey, err := UnwrapRecordKey(vaultKey, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("error while unwrapping record key: %w", err)
+		}
+		return DecryptRecord(recordKey, id, kind, ciphertext)
+	}
+	if encoded, found := strings.CutPrefix(ciphertext, BoundPrefix); found {
+		return openData(vaultKey, id, kind, Prefix+encoded)
+	}
+	return open(vaultKey, ciphertext, nil)
+}
+
+// IsCiphertext - проверка формата шифротекста без расшифровки (используется сервером)
+func IsCiphertext(s string) bool {
+	for _, prefix := range []string{RecordPrefix, BoundRecordPrefix} {
+		if rest, found := strings.CutPrefix(s, prefix); found {
+			key, data, found := strings.Cut(rest, ".")
+			return found && IsCiphertext(Prefix+key) && IsCiphertext(Prefix+data)
+		}
+	}
+	if rest, found := strings.CutPrefix(s, BoundPrefix); found {
+		s = Prefix + rest
+	}
+	raw, err := decode(s)
+	return err == nil && len(raw) >= chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead
+}
+
+// recordAD - additional data данных записи id типа kind
+func recordAD(id int64, kind string) []byte {
+	return []byte(fmt.Sprintf("gophkeeper record %d %s", id, kind))
+}
+
+// openData - расшифровка данных записи id типа kind в формате v1 (после замены префикса версии);
+// вместо данных записи принимается только пустая заготовка, привязанная к ID 0
+func openData(key []byte, id int64, kind string, ciphertext string) ([]byte, error) {
+	plaintext, err := open(key, ciphertext, recordAD(id, kind))
+	if err != nil && id != 0 {
+		if draft, draftErr := open(key, ciphertext, recordAD(0, kind)); draftErr == nil && len(draft) == 0 {
+			return draft, nil
+		}
+	}
+	return plaintext, err
+}
+
+func seal(key []byte, plaintext []byte, ad []byte) (string, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error while generating nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, ad)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(key []byte, ciphertext string, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decode(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], ad)
+}
+
+func decode(s string) ([]byte, error) {
+	encoded, found := strings.CutPrefix(s, Prefix)
+	if !found {
+		return nil, fmt.Errorf("unsupported ciphertext format")
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
@@ -0,0 +1,119 @@
+package vault
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncryptBindsRecord(t *testing.T) {
+	key, err := NewVaultKey()
+	if err != nil {
+		t.Fatalf("NewVaultKey: %v", err)
+	}
+	ciphertext, err := Encrypt(key, 7, "text", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, BoundPrefix) || !IsCiphertext(ciphertext) {
+		t.Fatalf("Encrypt = %q", ciphertext)
+	}
+	if plaintext, err := Decrypt(key, 7, "text", ciphertext); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+
+	// шифротекст нельзя перенести в другую запись или выдать за запись другого типа
+	for _, tt := range []struct {
+		id   int64
+		kind string
+	}{{8, "text"}, {7, "card"}, {0, "text"}} {
+		if _, err := Decrypt(key, tt.id, tt.kind, ciphertext); err == nil {
+			t.Errorf("Decrypt as record %d %s succeeded", tt.id, tt.kind)
+		}
+	}
+}
+
+func TestDecryptDraft(t *testing.T) {
+	key, err := NewVaultKey()
+	if err != nil {
+		t.Fatalf("NewVaultKey: %v", err)
+	}
+
+	// пустая заготовка новой записи читается после назначения ID, данные с ID 0 - нет
+	draft, err := Encrypt(key, 0, "text", nil)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if plaintext, err := Decrypt(key, 7, "text", draft); err != nil || len(plaintext) != 0 {
+		t.Fatalf("Decrypt draft = %q, %v", plaintext, err)
+	}
+	if _, err := Decrypt(key, 7, "card", draft); err == nil {
+		t.Fatal("Decrypt draft of another kind succeeded")
+	}
+	unbound, err := Encrypt(key, 0, "text", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := Decrypt(key, 7, "text", unbound); err == nil {
+		t.Fatal("Decrypt of non-empty data bound to ID 0 succeeded")
+	}
+}
+
+func TestDecryptV1(t *testing.T) {
+	key, err := NewVaultKey()
+	if err != nil {
+		t.Fatalf("NewVaultKey: %v", err)
+	}
+	legacy, err := seal(key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if plaintext, err := Decrypt(key, 7, "text", legacy); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("Decrypt v1 = %q, %v", plaintext, err)
+	}
+}
+
+func TestEncryptRecord(t *testing.T) {
+	vaultKey, err := NewVaultKey()
+	if err != nil {
+		t.Fatalf("NewVaultKey: %v", err)
+	}
+	recordKey, err := NewVaultKey()
+	if err != nil {
+		t.Fatalf("NewVaultKey: %v", err)
+	}
+	wrapped, err := WrapRecordKey(vaultKey, recordKey)
+	if err != nil {
+		t.Fatalf("WrapRecordKey: %v", err)
+	}
+
+	ciphertext, err := EncryptRecord(recordKey, wrapped, 7, "text", []byte("shared"))
+	if err != nil {
+		t.Fatalf("EncryptRecord: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, BoundRecordPrefix) || !IsCiphertext(ciphertext) {
+		t.Fatalf("EncryptRecord = %q", ciphertext)
+	}
+	if got, ok := RecordKey(ciphertext); !ok || got != wrapped {
+		t.Fatalf("RecordKey = %q, %v", got, ok)
+	}
+	if plaintext, err := DecryptRecord(recordKey, 7, "text", ciphertext); err != nil || !bytes.Equal(plaintext, []byte("shared")) {
+		t.Fatalf("DecryptRecord = %q, %v", plaintext, err)
+	}
+	if plaintext, err := Decrypt(vaultKey, 7, "text", ciphertext); err != nil || string(plaintext) != "shared" {
+		t.Fatalf("Decrypt by owner = %q, %v", plaintext, err)
+	}
+	if _, err := DecryptRecord(recordKey, 8, "text", ciphertext); err == nil {
+		t.Fatal("DecryptRecord as another record succeeded")
+	}
+
+	// общая запись формата r1 читается без привязки
+	sealed, err := seal(recordKey, []byte("shared"), nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	legacy := RecordPrefix + strings.TrimPrefix(wrapped, Prefix) + "." + strings.TrimPrefix(sealed, Prefix)
+	if plaintext, err := DecryptRecord(recordKey, 7, "text", legacy); err != nil || string(plaintext) != "shared" {
+		t.Fatalf("DecryptRecord r1 = %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.secure_data
+    ADD COLUMN IF NOT EXISTS kind VARCHAR(25) NOT NULL DEFAULT 'text';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.secure_data
+    DROP COLUMN IF EXISTS kind;
+-- +goose StatementEnd
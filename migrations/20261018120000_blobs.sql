@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.blobs
+(
+    id BIGSERIAL NOT NULL,
+    user_id bigint NOT NULL,
+    secure_data_id bigint NOT NULL,
+    size bigint NOT NULL,
+    received bigint NOT NULL DEFAULT 0,
+    sha256 VARCHAR(64) NOT NULL,
+    is_complete BOOLEAN NOT NULL DEFAULT false,
+    history_id bigint,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT blobs_pkey PRIMARY KEY (id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.blobs
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_blobs_secure_data_id
+    ON public.blobs (secure_data_id);
+
+CREATE TABLE IF NOT EXISTS public.blob_chunks
+(
+    blob_id bigint NOT NULL,
+    "offset" bigint NOT NULL,
+    data bytea NOT NULL,
+    CONSTRAINT blob_chunks_pkey PRIMARY KEY (blob_id, "offset")
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.blob_chunks
+    OWNER to postgres;
+
+ALTER TABLE IF EXISTS public.secure_data
+    ADD COLUMN IF NOT EXISTS blob_id bigint;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.secure_data
+    DROP COLUMN IF EXISTS blob_id;
+DROP TABLE IF EXISTS public.blob_chunks;
+DROP TABLE IF EXISTS public.blobs;
+-- +goose StatementEnd
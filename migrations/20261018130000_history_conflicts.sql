@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.history
+    ADD COLUMN IF NOT EXISTS expected_history_id bigint;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.history
+    DROP COLUMN IF EXISTS expected_history_id;
+-- +goose StatementEnd
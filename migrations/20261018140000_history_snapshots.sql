@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.history
+    ADD COLUMN IF NOT EXISTS data TEXT,
+    ADD COLUMN IF NOT EXISTS metadata jsonb,
+    ADD COLUMN IF NOT EXISTS kind VARCHAR(25),
+    ADD COLUMN IF NOT EXISTS is_active BOOLEAN,
+    ADD COLUMN IF NOT EXISTS blob_id bigint,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_history_secure_data_id
+    ON public.history (secure_data_id, id);
+
+ALTER TABLE IF EXISTS public.users
+    ADD COLUMN IF NOT EXISTS history_retention INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.users
+    DROP COLUMN IF EXISTS history_retention;
+
+DROP INDEX IF EXISTS public.idx_history_secure_data_id;
+
+ALTER TABLE IF EXISTS public.history
+    DROP COLUMN IF EXISTS data,
+    DROP COLUMN IF EXISTS metadata,
+    DROP COLUMN IF EXISTS kind,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS blob_id,
+    DROP COLUMN IF EXISTS created_at;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.user_totp
+(
+    user_id bigint NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    last_counter bigint NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT user_totp_pkey PRIMARY KEY (user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.user_totp
+    OWNER to postgres;
+
+CREATE TABLE IF NOT EXISTS public.totp_backup_codes
+(
+    user_id bigint NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    CONSTRAINT totp_backup_codes_pkey PRIMARY KEY (user_id, code_hash)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.totp_backup_codes
+    OWNER to postgres;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public.totp_backup_codes;
+DROP TABLE IF EXISTS public.user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.users
+    ADD COLUMN IF NOT EXISTS verification_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS verification_expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_verification_expires_at
+    ON public.users USING btree
+    (verification_expires_at ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE verification_hash IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS public.idx_users_verification_expires_at;
+
+ALTER TABLE IF EXISTS public.users
+    DROP COLUMN IF EXISTS verification_expires_at,
+    DROP COLUMN IF EXISTS verification_hash;
+-- +goose StatementEnd
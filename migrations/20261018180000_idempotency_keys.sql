@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.idempotency_keys
+(
+    user_id bigint NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT idempotency_keys_pkey PRIMARY KEY (user_id, key)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.idempotency_keys
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at
+    ON public.idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public.idempotency_keys;
+-- +goose StatementEnd
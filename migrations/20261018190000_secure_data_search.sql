@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_secure_data_user_history
+    ON public.secure_data USING btree
+    (user_id, history_id)
+    TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS idx_secure_data_user_kind
+    ON public.secure_data USING btree
+    (user_id, kind, id)
+    TABLESPACE pg_default;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS public.idx_secure_data_user_kind;
+DROP INDEX IF EXISTS public.idx_secure_data_user_history;
+-- +goose StatementEnd
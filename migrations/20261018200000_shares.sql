@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.user_keys
+    ADD COLUMN IF NOT EXISTS public_key TEXT,
+    ADD COLUMN IF NOT EXISTS wrapped_private_key TEXT;
+
+ALTER TABLE IF EXISTS public.history
+    ADD COLUMN IF NOT EXISTS recipient_id bigint;
+
+CREATE TABLE IF NOT EXISTS public.shares
+(
+    id BIGSERIAL NOT NULL,
+    secure_data_id bigint NOT NULL,
+    recipient_id bigint NOT NULL,
+    permission VARCHAR(2) NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    history_id bigint NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    CONSTRAINT shares_pkey PRIMARY KEY (id),
+    CONSTRAINT shares_secure_data_recipient_key UNIQUE (secure_data_id, recipient_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.shares
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_shares_recipient
+    ON public.shares USING btree
+    (recipient_id, history_id)
+    TABLESPACE pg_default;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public.shares;
+
+ALTER TABLE IF EXISTS public.history
+    DROP COLUMN IF EXISTS recipient_id;
+
+ALTER TABLE IF EXISTS public.user_keys
+    DROP COLUMN IF EXISTS public_key,
+    DROP COLUMN IF EXISTS wrapped_private_key;
+-- +goose StatementEnd
@@ -0,0 +1,80 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.organizations
+(
+    id BIGSERIAL NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT organizations_pkey PRIMARY KEY (id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.organizations
+    OWNER to postgres;
+
+CREATE TABLE IF NOT EXISTS public.org_members
+(
+    org_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    history_id bigint NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    removed_at TIMESTAMPTZ,
+    CONSTRAINT org_members_pkey PRIMARY KEY (org_id, user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.org_members
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_org_members_user
+    ON public.org_members USING btree
+    (user_id)
+    TABLESPACE pg_default;
+
+CREATE TABLE IF NOT EXISTS public.vaults
+(
+    id BIGSERIAL NOT NULL,
+    name TEXT NOT NULL,
+    user_id bigint,
+    org_id bigint,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT vaults_pkey PRIMARY KEY (id),
+    CONSTRAINT vaults_owner_check CHECK ((user_id IS NULL) <> (org_id IS NULL))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.vaults
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_vaults_org
+    ON public.vaults USING btree
+    (org_id)
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.secure_data
+    ADD COLUMN IF NOT EXISTS vault_id bigint;
+
+CREATE INDEX IF NOT EXISTS idx_secure_data_vault
+    ON public.secure_data USING btree
+    (vault_id, history_id)
+    TABLESPACE pg_default;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS public.idx_secure_data_vault;
+
+ALTER TABLE IF EXISTS public.secure_data
+    DROP COLUMN IF EXISTS vault_id;
+
+DROP TABLE IF EXISTS public.vaults;
+
+DROP TABLE IF EXISTS public.org_members;
+
+DROP TABLE IF EXISTS public.organizations;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.users
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.users
+    DROP COLUMN IF EXISTS disabled_at;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.user_quotas
+(
+    user_id bigint NOT NULL,
+    max_records bigint NOT NULL DEFAULT 0,
+    max_bytes bigint NOT NULL DEFAULT 0,
+    max_record_size bigint NOT NULL DEFAULT 0,
+    max_revisions integer NOT NULL DEFAULT 0,
+    CONSTRAINT user_quotas_pkey PRIMARY KEY (user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.user_quotas
+    OWNER to postgres;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public.user_quotas;
+-- +goose StatementEnd
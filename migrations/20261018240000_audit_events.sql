@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS public.audit_events
+(
+    id BIGSERIAL NOT NULL,
+    user_id bigint NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    action TEXT NOT NULL,
+    success boolean NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT audit_events_pkey PRIMARY KEY (id),
+    CONSTRAINT audit_events_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.audit_events
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id
+    ON public.audit_events USING btree
+    (user_id, id)
+    TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at
+    ON public.audit_events USING btree
+    (created_at)
+    TABLESPACE pg_default;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public.audit_events;
+-- +goose StatementEnd
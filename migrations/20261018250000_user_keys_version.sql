@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.user_keys
+    ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE IF EXISTS public.user_keys
+    DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd